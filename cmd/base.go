@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"unicode"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 const (
@@ -51,7 +53,13 @@ They can be specified with a base prefix :
 - "0x" for hexadecimal : "0x1234" means 1234 in hexadecimal.
 
 Alternatively, you can specify the integer base with the --base flag but if you do so,
-integer should not be prefixed with "0b", "0o" or "0x".`,
+integer should not be prefixed with "0b", "0o" or "0x".
+
+JSON/YAML output schema:
+  input: the integer as given (string)
+  decimal: the integer value (integer)
+  base: the destination base (integer)
+  result: the integer in the destination base (string)`,
 				Args: cobra.ExactArgs(2),
 				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 					if len(args) == 0 {
@@ -63,18 +71,22 @@ integer should not be prefixed with "0b", "0o" or "0x".`,
 					cfg := config.GetFromCommandContext(cmd)
 					givenBase, err := getBase(cfg.GetString(configKeyBaseInput))
 					if err != nil {
-						return fmt.Errorf("invalid input base: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid input base: %w", err)
 					}
 					destBase, err := getBase(args[0])
 					if err != nil {
-						return fmt.Errorf("invalid destination base: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid destination base: %w", err)
 					}
 					input, err := parseInput(args[1], givenBase)
 					if err != nil {
-						return fmt.Errorf("cannot parse integer: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "cannot parse integer: %w", err)
 					}
-					_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", strconv.FormatInt(input, destBase))
-					return err
+					return renderResult(cmd, &baseResult{
+						Input:   args[1],
+						Decimal: input,
+						Base:    destBase,
+						Result:  strconv.FormatInt(input, destBase),
+					})
 				},
 			}
 		},
@@ -83,6 +95,26 @@ integer should not be prefixed with "0b", "0o" or "0x".`,
 	)
 }
 
+type baseResult struct {
+	Input   string `json:"input"`
+	Decimal int64  `json:"decimal"`
+	Base    int    `json:"base"`
+	Result  string `json:"result"`
+}
+
+func (r *baseResult) RenderText(w io.Writer) error {
+	_, err := fmt.Fprintln(w, r.Result)
+	return err
+}
+
+func (r *baseResult) Header() []string {
+	return []string{"input", "decimal", "base", "result"}
+}
+
+func (r *baseResult) Rows() [][]string {
+	return [][]string{{r.Input, strconv.FormatInt(r.Decimal, 10), strconv.Itoa(r.Base), r.Result}}
+}
+
 func getBaseCompletion(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	baseList := []string{"binary", "octal", "decimal", "hexadecimal"}
 	list := make([]string, 0, len(baseList))
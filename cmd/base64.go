@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"encoding/base64"
+	"io"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
 )
 
 const (
@@ -40,6 +43,10 @@ func init() {
 				Use:     "base64",
 				Short:   "Base64 tools",
 				Aliases: []string{"b64"},
+				Long: `Base64 tools.
+
+With the text format, the result is streamed as is. Other formats buffer the result, with the schema:
+  result: the encoded or decoded data (string)`,
 			}
 		},
 		commander.WithConfig(configKeyBase64URLEncoding),
@@ -61,3 +68,32 @@ func getBase64Encoding(cmd *cobra.Command) *base64.Encoding {
 	}
 	return base64.StdEncoding
 }
+
+// base64Write streams the result of f to the command output with the text format,
+// or renders it as a base64Result with other formats.
+func base64Write(cmd *cobra.Command, f func(w io.Writer) error) error {
+	format, err := getFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if format == render.FormatText {
+		return f(cmd.OutOrStdout())
+	}
+	buf := &strings.Builder{}
+	if err := f(buf); err != nil {
+		return err
+	}
+	return render.Render(cmd.OutOrStdout(), format, &base64Result{Result: buf.String()})
+}
+
+type base64Result struct {
+	Result string `json:"result"`
+}
+
+func (r *base64Result) Header() []string {
+	return []string{"result"}
+}
+
+func (r *base64Result) Rows() [][]string {
+	return [][]string{{r.Result}}
+}
@@ -24,9 +24,10 @@ func init() {
 						return err
 					}
 					defer in.Close()
-					enc := base64.NewDecoder(getBase64Encoding(cmd), in)
-					_, err = io.Copy(cmd.OutOrStdout(), enc)
-					return err
+					return base64Write(cmd, func(w io.Writer) error {
+						_, err := io.Copy(w, base64.NewDecoder(getBase64Encoding(cmd), in))
+						return err
+					})
 				},
 			}
 		},
@@ -24,9 +24,13 @@ func init() {
 						return err
 					}
 					defer in.Close()
-					enc := base64.NewEncoder(getBase64Encoding(cmd), cmd.OutOrStdout())
-					_, err = io.Copy(enc, in)
-					return err
+					return base64Write(cmd, func(w io.Writer) error {
+						enc := base64.NewEncoder(getBase64Encoding(cmd), w)
+						if _, err := io.Copy(enc, in); err != nil {
+							return err
+						}
+						return enc.Close()
+					})
 				},
 			}
 		},
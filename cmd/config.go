@@ -1,17 +1,17 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"go.uber.org/zap"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 const (
@@ -33,6 +33,14 @@ func init() {
 			return &cobra.Command{
 				Use:   "config [flags] [name] [value]",
 				Short: "Get or set a configuration value",
+				Long: `Get or set a configuration value.
+
+Without a name, all the configuration values are printed.
+
+JSON/YAML output schema, a list of:
+  name: the configuration name (string)
+  value: the configuration value, typed (string, integer, boolean, list or map)
+  display: the human readable value (string)`,
 				ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 					var commands []string
 					for _, v := range config.GetValues() {
@@ -60,16 +68,16 @@ func init() {
 					} else if len(args) == 1 {
 						v, err := config.GetValue(args[0])
 						if err != nil {
-							return err
+							return dsakerr.Wrap(dsakerr.CategoryNotFound, err)
 						}
 						return configPrintValue(cmd, v)
 					}
 					v, err := config.GetValue(args[0])
 					if err != nil {
-						return err
+						return dsakerr.Wrap(dsakerr.CategoryNotFound, err)
 					}
 					if err := v.Set(cmd, args[1]); err != nil {
-						return fmt.Errorf("failed to set config value: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "failed to set config value: %w", err)
 					}
 					if err := config.Write(cfg); err != nil {
 						return fmt.Errorf("failed to save config file: %w", err)
@@ -83,44 +91,71 @@ func init() {
 }
 
 func configPrintAllValues(cmd *cobra.Command) error {
-	logger := getLogger(cmd)
 	cfg := config.GetFromCommandContext(cmd)
 	if cfg.GetBool(configKeyConfigRaw) {
-		return errors.New("Raw value is only supported with a config name")
+		return dsakerr.New(dsakerr.CategoryUsage, "Raw value is only supported with a config name")
 	}
 	values := config.GetValues()
-	keys := make([]string, 0, len(values))
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].GetName() < values[j].GetName()
+	})
+	res := make(configResult, 0, len(values))
 	for _, v := range values {
-		keys = append(keys, v.GetName())
-	}
-	sort.Strings(keys)
-	for _, k := range keys {
-		v, err := config.GetValue(k)
-		if err != nil {
-			continue
-		}
-		if err := configPrintValue(cmd, v); err != nil {
-			logger.
-				With(zap.Error(err)).
-				With(zap.String("value", v.GetName())).
-				Warn("failed to print config value")
-		}
+		res = append(res, newConfigResultValue(cmd, v))
 	}
-	return nil
+	return renderResult(cmd, res)
 }
 
 func configPrintValue(cmd *cobra.Command, v *config.Value) error {
 	cfg := config.GetFromCommandContext(cmd)
-	raw := cfg.GetBool(configKeyConfigRaw)
-	if raw {
-		fmt.Fprintln(cmd.OutOrStdout(), v.AsRawString(cmd))
-	} else {
-		name := color.New(color.FgBlue)
-		value := color.New(color.Bold, color.FgGreen)
-		name.Fprintf(cmd.OutOrStdout(), "[%s]:\n", v.GetName())
-		res := v.AsString(cmd)
-		res = strings.Trim(strings.Join(strings.Split(res, "\n"), "\n\t"), "\n\t")
-		value.Fprintf(cmd.OutOrStdout(), "\t%s\n", res)
+	if cfg.GetBool(configKeyConfigRaw) {
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), v.AsRawString(cmd))
+		return err
+	}
+	return renderResult(cmd, configResult{newConfigResultValue(cmd, v)})
+}
+
+type configResultValue struct {
+	Name    string `json:"name"`
+	Value   any    `json:"value"`
+	Display string `json:"display"`
+}
+
+func newConfigResultValue(cmd *cobra.Command, v *config.Value) *configResultValue {
+	var value any = config.RedactedValue
+	if !v.IsSecret() {
+		value = config.GetFromCommandContext(cmd).Get(v.GetName())
+	}
+	return &configResultValue{
+		Name:    v.GetName(),
+		Value:   value,
+		Display: v.AsString(cmd),
+	}
+}
+
+type configResult []*configResultValue
+
+func (r configResult) RenderText(w io.Writer) error {
+	name := color.New(color.FgBlue)
+	value := color.New(color.Bold, color.FgGreen)
+	for _, v := range r {
+		name.Fprintf(w, "[%s]:\n", v.Name)
+		res := strings.Trim(strings.Join(strings.Split(v.Display, "\n"), "\n\t"), "\n\t")
+		if _, err := value.Fprintf(w, "\t%s\n", res); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+func (r configResult) Header() []string {
+	return []string{"name", "value"}
+}
+
+func (r configResult) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, v := range r {
+		rows = append(rows, []string{v.Name, v.Display})
+	}
+	return rows
+}
@@ -1,16 +1,27 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
 )
 
 const (
 	configKeyDNSServerAliases = "dns.serveraliases"
+	configKeyDNSTSIGKeys      = "dns.tsigkeys"
+	configKeyDNSResolvConf    = "dns.resolvconf"
+	configKeyDNSHostsFile     = "dns.hostsfile"
 )
 
 func init() {
@@ -23,7 +34,29 @@ func init() {
 				"8.8.8.8",
 			},
 		}),
-		config.Description("List of DNS server aliases"),
+		config.Description("List of DNS server aliases, the system alias being the name servers of resolv.conf unless set"),
+	)
+	config.RegisterValue(
+		configKeyDNSResolvConf,
+		config.ValueTypeString,
+		config.DefaultValue(dns.DefaultResolvConf),
+		config.Flag("resolv-conf"),
+		config.FlagIsPersistent(),
+		config.Description("Resource of the resolv.conf of the system resolver, eg: the one of a container"),
+	)
+	config.RegisterValue(
+		configKeyDNSHostsFile,
+		config.ValueTypeString,
+		config.DefaultValue(dns.DefaultHostsFile),
+		config.Flag("hosts-file"),
+		config.FlagIsPersistent(),
+		config.Description("Resource of the hosts file of the system resolver"),
+	)
+	config.RegisterValue(
+		configKeyDNSTSIGKeys,
+		config.ValueTypeStringsMap,
+		config.Secret(),
+		config.Description("TSIG keys authenticating zone transfers, by name: their secret, or their algorithm and secret"),
 	)
 	commander.Register(
 		"dns",
@@ -34,17 +67,93 @@ func init() {
 			}
 		},
 		commander.WithConfig(configKeyDNSServerAliases),
+		commander.WithConfig(configKeyDNSTSIGKeys),
+		commander.WithConfig(configKeyDNSResolvConf),
+		commander.WithConfig(configKeyDNSHostsFile),
 	)
 }
 
+// dnsReadFile returns the content of the resource of the configuration value key.
+func dnsReadFile(cmd *cobra.Command, key string) ([]byte, error) {
+	name := config.GetFromCommandContext(cmd).GetString(key)
+	in, err := resource.New(cmd, name, getLogger(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", name, err)
+	}
+	defer in.Close()
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// dnsSystemConfig returns the configuration of the system resolver, read from the resolv.conf resource.
+func dnsSystemConfig(cmd *cobra.Command) (*dns.SystemConfig, error) {
+	b, err := dnsReadFile(cmd, configKeyDNSResolvConf)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	return dns.ParseResolvConf(bytes.NewReader(b), hostname)
+}
+
+// dnsHosts returns the addresses of the names of the hosts file resource.
+func dnsHosts(cmd *cobra.Command) (*dns.Hosts, error) {
+	b, err := dnsReadFile(cmd, configKeyDNSHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	return dns.ParseHosts(bytes.NewReader(b), config.GetFromCommandContext(cmd).GetString(configKeyDNSHostsFile))
+}
+
+// dnsGetTSIGKey returns the TSIG key named by the configuration value key, nil if it is empty.
+func dnsGetTSIGKey(cmd *cobra.Command, key string) (*dns.TSIGKey, error) {
+	cfg := config.GetFromCommandContext(cmd)
+	name := cfg.GetString(key)
+	if name == "" {
+		return nil, nil
+	}
+	keys := cfg.GetStringMapStringSlice(configKeyDNSTSIGKeys)
+	for n, spec := range keys {
+		if strings.EqualFold(strings.TrimSuffix(n, "."), strings.TrimSuffix(name, ".")) {
+			k, err := dns.ParseTSIGKey(name, spec)
+			if err != nil {
+				return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+			}
+			return k, nil
+		}
+	}
+	return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "unknown TSIG key %s, keys are set in %s", name, configKeyDNSTSIGKeys)
+}
+
+func getDNSTSIGKeyCompletion(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg := config.GetFromCommandContext(cmd)
+	completions := make([]string, 0)
+	for name := range cfg.GetStringMapStringSlice(configKeyDNSTSIGKeys) {
+		if strings.HasPrefix(name, strings.ToLower(toComplete)) {
+			completions = append(completions, name)
+		}
+	}
+	slices.Sort(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
 func getDNSServerAliasCompletion(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
 	cfg := config.GetFromCommandContext(cmd)
 	aliases := cfg.GetStringMapStringSlice(configKeyDNSServerAliases)
 	flags := cobra.ShellCompDirectiveNoFileComp
 
 	toComplete = strings.ToLower(toComplete)
-	completions := make([]string, 0, len(aliases))
+	names := make([]string, 0, len(aliases)+1)
 	for v := range aliases {
+		names = append(names, v)
+	}
+	if _, ok := aliases[dns.SystemAlias]; !ok {
+		names = append(names, dns.SystemAlias)
+	}
+	completions := make([]string, 0, len(names))
+	for _, v := range names {
 		v = strings.ToLower(v)
 		if toComplete == "" || strings.Contains(v, toComplete) {
 			completions = append(completions, v)
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
+)
+
+const (
+	configKeyDNSAXFRUseServers = "dns.axfr.useservers"
+	configKeyDNSAXFRTSIGKey    = "dns.axfr.tsigkey"
+	configKeyDNSAXFRCompare    = "dns.axfr.compare"
+	configKeyDNSAXFRTimeout    = "dns.axfr.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSAXFRUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.Description("DNS server aliases or IP addresses/hostnames to transfer the zone from"),
+	)
+	config.RegisterValue(
+		configKeyDNSAXFRTSIGKey,
+		config.ValueTypeString,
+		config.Flag("tsig-key"),
+		config.ShortFlag('k'),
+		config.Description("Name of the TSIG key of dns.tsigkeys authenticating the transfer"),
+	)
+	config.RegisterValue(
+		configKeyDNSAXFRCompare,
+		config.ValueTypeBool,
+		config.Flag("compare"),
+		config.ShortFlag('c'),
+		config.Description("Transfer the zone from every server and compare their records"),
+	)
+	config.RegisterValue(
+		configKeyDNSAXFRTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns axfr, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>axfr",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "axfr [flags] zone",
+				Short: "Transfer a zone from its primary or secondary servers",
+				Long: `Transfer a zone with AXFR (RFC 5936) from the servers given by --servers, to export it or to
+check that the secondaries are in sync with the primary.
+
+Transfers are done over TCP, or over TLS from tls:// servers (RFC 9103). They are authenticated with
+the TSIG key named by --tsig-key, the keys being set in the configuration file, by name:
+  dns:
+    tsigkeys:
+      transfer-key: [hmac-sha256, c2VjcmV0...]   # or only the secret, hmac-sha256 being the default
+
+The records are written to the output as they are received, from the first server the transfer
+succeeds from: in the zone file format with the text format, the SOA record being the first and the
+last one, or as JSON lines with the json and jsonl formats.
+
+JSON output schema, one object per record:
+  name, type, class: the name, type and class of the record (strings)
+  ttl: the TTL (number)
+  data: the data (string)
+  fields: the data fields, for the types having a structured representation (object)
+
+With --compare, the zone is transferred from every server and their records are compared to the ones
+of the first server, the command failing if they differ or if a transfer failed.
+
+JSON/YAML output schema with --compare:
+  zone: the zone (string)
+  in_sync: true if every server sent the same records (boolean)
+  servers: list of, in the order of --servers
+    server: the server (string)
+    serial: the serial of the zone (number)
+    records: the number of records (number)
+    signed: true if the transfer was authenticated by the TSIG key (boolean)
+    duration: the duration of the transfer (string)
+    error: the error the transfer failed with, if any (string)
+    differs: true if the records differ from the ones of the first server (boolean)
+    changes: the records added, removed or whose TTL changed, see dns zone diff (list of objects)
+
+Example:
+  dsak dns axfr -s ns1.example.com example.com -o example.com.zone
+  dsak dns axfr -s ns1.example.com -s ns2.example.com -k transfer-key --compare example.com`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSAXFRTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					client, servers, err := dnsTransferClient(cmd, configKeyDNSAXFRUseServers, configKeyDNSAXFRTSIGKey)
+					if err != nil {
+						return err
+					}
+					if cfg.GetBool(configKeyDNSAXFRCompare) {
+						res := client.CompareTransfers(cmd.Context(), args[0])
+						if err := renderResultWithoutCancel(cmd, res); err != nil {
+							return err
+						}
+						if !res.InSync {
+							return dsakerr.Errorf(dsakerr.CategoryValidation, "the servers of %s are not in sync", res.Zone)
+						}
+						return nil
+					}
+					return dnsTransferStream(cmd, servers, func(server string, fn func(dnslib.RR) error) (*dns.Transfer, error) {
+						return client.AXFR(cmd.Context(), server, args[0], fn)
+					})
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSAXFRUseServers),
+		commander.WithConfig(configKeyDNSAXFRTSIGKey),
+		commander.WithConfig(configKeyDNSAXFRCompare),
+		commander.WithConfig(configKeyDNSAXFRTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSAXFRUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+		commander.WithFlagCompletion(
+			configKeyDNSAXFRTSIGKey,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSTSIGKeyCompletion(cmd, toComplete)
+			},
+		),
+	)
+}
+
+// dnsTransferClient returns a client transferring zones from the servers of serversKey, authenticated by
+// the TSIG key of tsigKey, and the servers.
+func dnsTransferClient(cmd *cobra.Command, serversKey, tsigKey string) (*dns.Client, []string, error) {
+	servers := dnsGetServers(cmd, serversKey)
+	if len(servers) == 0 {
+		return nil, nil, dsakerr.New(dsakerr.CategoryUsage, "at least a server to transfer the zone from must be given with --servers")
+	}
+	connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := []dns.Option{dns.WithServers(servers...), dns.WithTimeouts(connectTimeout, readTimeout)}
+	key, err := dnsGetTSIGKey(cmd, tsigKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key != nil {
+		opts = append(opts, dns.WithTSIG(key))
+	}
+	return dns.NewClient(getLogger(cmd), opts...), servers, nil
+}
+
+// dnsTransferStream writes the records of a transfer to the output as they are received, from the first
+// server of servers it succeeds from. Another server is only tried if no record was written.
+func dnsTransferStream(
+	cmd *cobra.Command,
+	servers []string,
+	transfer func(server string, fn func(dnslib.RR) error) (*dns.Transfer, error),
+) error {
+	format, err := getFormat(cmd)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case render.FormatText, render.FormatJSON, render.FormatJSONLines:
+	default:
+		return dsakerr.New(dsakerr.CategoryUsage, fmt.Sprintf("format %s is not supported by zone transfers, use text, json or jsonl", format))
+	}
+	out := cmd.OutOrStdout()
+	logger := getLogger(cmd)
+	written := 0
+	write := func(rr dnslib.RR) error {
+		written++
+		if format == render.FormatText {
+			_, err := fmt.Fprintln(out, rr.String())
+			return err
+		}
+		b, err := json.Marshal(dns.NewRecord(rr))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+	var transferErr error
+	for i, server := range servers {
+		t, err := transfer(server, write)
+		if err == nil {
+			logger.With(
+				zap.String("server", t.Server),
+				zap.String("zone", t.Zone),
+				zap.Uint32("serial", t.Serial),
+				zap.Int("records", t.Records),
+				zap.Bool("signed", t.Signed),
+				zap.Duration("duration", time.Duration(t.Duration)),
+			).Info("Zone transferred")
+			return nil
+		}
+		transferErr = fmt.Errorf("transfer from %s failed: %w", server, err)
+		if written > 0 || cmd.Context().Err() != nil || i == len(servers)-1 {
+			break
+		}
+		logger.With(zap.String("server", server), zap.Error(err)).Warn("Zone transfer failed")
+	}
+	return transferErr
+}
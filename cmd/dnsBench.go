@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSBenchUseServers   = "dns.bench.useservers"
+	configKeyDNSBenchNames        = "dns.bench.names"
+	configKeyDNSBenchType         = "dns.bench.type"
+	configKeyDNSBenchUncached     = "dns.bench.uncached"
+	configKeyDNSBenchConcurrency  = "dns.bench.concurrency"
+	configKeyDNSBenchDuration     = "dns.bench.duration"
+	configKeyDNSBenchQueries      = "dns.bench.queries"
+	configKeyDNSBenchQueryTimeout = "dns.bench.querytimeout"
+	configKeyDNSBenchSeed         = "dns.bench.seed"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSBenchUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames to benchmark"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchNames,
+		config.ValueTypeStrings,
+		config.Flag("name"),
+		config.ShortFlag('n'),
+		config.Description("Names queried, and parents of the random subdomains of uncached queries, default is popular names"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchType,
+		config.ValueTypeString,
+		config.Flag("type"),
+		config.ShortFlag('t'),
+		config.DefaultValue("A"),
+		config.Description("Record type to query"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchUncached,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(20)),
+		config.Flag("uncached"),
+		config.ShortFlag('u'),
+		config.Description("Percentage of uncached queries, for random subdomains of the names"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchConcurrency,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(10)),
+		config.Flag("concurrency"),
+		config.ShortFlag('j'),
+		config.Description("Number of queries sent at the same time to each server"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchDuration,
+		config.ValueTypeDuration,
+		config.DefaultValue(10*time.Second),
+		config.Flag("duration"),
+		config.ShortFlag('d'),
+		config.Description("Duration of the benchmark of each server, 0 to only stop after --queries"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchQueries,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(0)),
+		config.Flag("queries"),
+		config.Description("Maximum number of queries sent to each server, 0 for no limit"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchQueryTimeout,
+		config.ValueTypeDuration,
+		config.DefaultValue(2*time.Second),
+		config.Flag("query-timeout"),
+		config.Description("Timeout of each query, queries timing out being counted as timeouts"),
+	)
+	config.RegisterValue(
+		configKeyDNSBenchSeed,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(1)),
+		config.Flag("seed"),
+		config.Description("Seed of the sequence of queried names, the same sequence being sent with the same seed"),
+	)
+
+	commander.Register(
+		"dns>bench",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "bench [flags]",
+				Short: "Benchmark DNS resolvers",
+				Long: `Benchmark DNS resolvers, eg: to choose the upstream resolvers of offices or Kubernetes nodes.
+
+The servers given by --servers, aliases being expanded, are benchmarked one after the other so that
+they do not compete for the network and the CPU. The names given by --name (default is popular names)
+are first queried once, so that they are cached by the servers, then queries are sent by --concurrency
+workers for --duration, or until --queries were sent. --uncached percent of the queries are for a
+random subdomain of the names, eg: dsak-1f2e3d4c5b6a.example.com, that resolvers have never cached and
+must resolve from the authoritative servers.
+
+The sequence of names and of cached and uncached queries is the same for every server, and for every
+run with the same --seed, for reproducible numbers. The random subdomains differ between runs.
+
+Queries without response after --query-timeout are counted as timeouts. Responses other than NOERROR,
+NXDOMAIN and SERVFAIL, and network errors, are counted as errors. The latencies are the ones of the
+responses, whatever their response code. The servers are ranked by failure rate (timeouts, SERVFAIL and
+errors) rounded to 0.1%, then by 90th percentile latency.
+
+JSON/YAML output schema:
+  type: the record type queried (string)
+  names: the number of names (integer)
+  uncached: the share of uncached queries, from 0 to 1 (number)
+  concurrency: the number of queries sent at the same time (integer)
+  duration: the duration of the benchmark of each server (string)
+  seed: the seed of the sequence of queries (integer)
+  servers: list of, by rank
+    rank: the rank of the server, 1 being the best (integer)
+    server: the server (string)
+    queries, cached, uncached: the number of queries sent, of each kind (integers)
+    succeeded, timeouts, servfails, errors: the number of queries by outcome (integers)
+    failure_rate, timeout_rate, servfail_rate: the shares of failed queries, in percent (numbers)
+    qps: the number of responses per second (number)
+    latency, cached_latency, uncached_latency: the latencies of all the responses, and of each kind of
+      query: min, mean, p50, p90, p95, p99, max (strings)
+    error: the error the server could not be benchmarked with, if any (string)
+
+Example:
+  dsak dns bench -s 1.1.1.1 -s 8.8.8.8 -s 9.9.9.9 -s corp -d 30s --format table`,
+				Args:        cobra.NoArgs,
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				RunE: func(cmd *cobra.Command, _ []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					t, err := dns.GetType(cfg.GetString(configKeyDNSBenchType))
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					uncached := cfg.GetUint64(configKeyDNSBenchUncached)
+					if uncached > 100 {
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid percentage of uncached queries %d, it must be at most 100", uncached)
+					}
+					duration, err := config.GetDuration(cfg, configKeyDNSBenchDuration)
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					queries := cfg.GetUint64(configKeyDNSBenchQueries)
+					if duration == 0 && queries == 0 {
+						return dsakerr.New(dsakerr.CategoryUsage, "a --duration or a number of --queries must be given")
+					}
+					queryTimeout, err := config.GetDuration(cfg, configKeyDNSBenchQueryTimeout)
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
+					client := dns.NewClient(
+						getLogger(cmd),
+						dns.WithServers(dnsGetServers(cmd, configKeyDNSBenchUseServers)...),
+						dns.WithTimeouts(connectTimeout, readTimeout),
+					)
+					res := client.Bench(cmd.Context(), dns.BenchOptions{
+						Names:       cfg.GetStringSlice(configKeyDNSBenchNames),
+						Type:        t,
+						Uncached:    float64(uncached) / 100,
+						Concurrency: max(int(cfg.GetUint64(configKeyDNSBenchConcurrency)), 1),
+						Duration:    duration,
+						Queries:     int(queries),
+						Timeout:     queryTimeout,
+						Seed:        int64(cfg.GetUint64(configKeyDNSBenchSeed)),
+					})
+					return renderResultWithoutCancel(cmd, res)
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSBenchUseServers),
+		commander.WithConfig(configKeyDNSBenchNames),
+		commander.WithConfig(configKeyDNSBenchType),
+		commander.WithConfig(configKeyDNSBenchUncached),
+		commander.WithConfig(configKeyDNSBenchConcurrency),
+		commander.WithConfig(configKeyDNSBenchDuration),
+		commander.WithConfig(configKeyDNSBenchQueries),
+		commander.WithConfig(configKeyDNSBenchQueryTimeout),
+		commander.WithConfig(configKeyDNSBenchSeed),
+		commander.WithFlagCompletion(
+			configKeyDNSBenchUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+		commander.WithFlagCompletion(configKeyDNSBenchType, dnsTypeCompletion),
+	)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"strconv"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSIXFRUseServers = "dns.ixfr.useservers"
+	configKeyDNSIXFRTSIGKey    = "dns.ixfr.tsigkey"
+	configKeyDNSIXFRTimeout    = "dns.ixfr.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSIXFRUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.Description("DNS server aliases or IP addresses/hostnames to transfer the zone from"),
+	)
+	config.RegisterValue(
+		configKeyDNSIXFRTSIGKey,
+		config.ValueTypeString,
+		config.Flag("tsig-key"),
+		config.ShortFlag('k'),
+		config.Description("Name of the TSIG key of dns.tsigkeys authenticating the transfer"),
+	)
+	config.RegisterValue(
+		configKeyDNSIXFRTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns ixfr, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>ixfr",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "ixfr [flags] zone serial",
+				Short: "Transfer the changes of a zone since a serial",
+				Long: `Transfer the changes of a zone since the version with the given serial with IXFR (RFC 1995), from
+the first of the servers given by --servers the transfer succeeds from. Transfers are done and
+authenticated like with dns axfr.
+
+The records are written to the output as they are received: the SOA record of the current version of
+the zone, then for each version the SOA record of the previous version and the removed records, the SOA
+record of the version and the added records, then the SOA record of the current version again. Servers
+can also send the whole zone, like with dns axfr, or only the SOA record if the zone did not change.
+
+The records are written in the zone file format with the text format, or as JSON lines with the json
+and jsonl formats, see dns axfr for their schema.
+
+Example:
+  dsak dns ixfr -s ns1.example.com -k transfer-key example.com 2024010101`,
+				Args:        cobra.ExactArgs(2),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSIXFRTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					serial, err := strconv.ParseUint(args[1], 10, 32)
+					if err != nil {
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid serial %s: %s", args[1], err)
+					}
+					client, servers, err := dnsTransferClient(cmd, configKeyDNSIXFRUseServers, configKeyDNSIXFRTSIGKey)
+					if err != nil {
+						return err
+					}
+					return dnsTransferStream(cmd, servers, func(server string, fn func(dnslib.RR) error) (*dns.Transfer, error) {
+						return client.IXFR(cmd.Context(), server, args[0], uint32(serial), fn)
+					})
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSIXFRUseServers),
+		commander.WithConfig(configKeyDNSIXFRTSIGKey),
+		commander.WithConfig(configKeyDNSIXFRTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSIXFRUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+		commander.WithFlagCompletion(
+			configKeyDNSIXFRTSIGKey,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSTSIGKeyCompletion(cmd, toComplete)
+			},
+		),
+	)
+}
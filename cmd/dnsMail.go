@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"net/netip"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSMailUseServers = "dns.mail.useservers"
+	configKeyDNSMailSelectors  = "dns.mail.selectors"
+	configKeyDNSMailIP         = "dns.mail.ip"
+	configKeyDNSMailStrict     = "dns.mail.strict"
+	configKeyDNSMailTimeout    = "dns.mail.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSMailUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames to use"),
+	)
+	config.RegisterValue(
+		configKeyDNSMailSelectors,
+		config.ValueTypeStrings,
+		config.Flag("selector"),
+		config.Description("DKIM selectors to check, default is the selectors of common mail providers"),
+	)
+	config.RegisterValue(
+		configKeyDNSMailIP,
+		config.ValueTypeString,
+		config.Flag("ip"),
+		config.Description("Address of a sending server to evaluate the SPF policy for"),
+	)
+	config.RegisterValue(
+		configKeyDNSMailStrict,
+		config.ValueTypeBool,
+		config.Flag("strict"),
+		config.Description("Fail on warnings too"),
+	)
+	config.RegisterValue(
+		configKeyDNSMailTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns mail, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>mail",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "mail [flags] domain",
+				Short: "Check the email authentication records of a domain",
+				Long: `Check the records receiving and authenticating the mail of a domain, eg: when mail is rejected
+or lands in spam.
+
+The records are queried with the first server of --servers giving a successful response:
+  mx: the MX records, and that their hosts resolve and are not CNAME records, or a null MX record
+  spf: the SPF record and the records it includes, errors and the limits of receivers (10 DNS
+    lookups and 2 lookups without answer per evaluation), all mechanisms letting unauthorized
+    servers pass; with --ip, the result of the evaluation for a sending server and the term giving it
+  dmarc: the DMARC record, or the one of the organizational domain, its policy, and its report URIs,
+    that a domain receiving reports for another one must authorize
+  dkim: the keys of the --selector selectors, required, or of the selectors of common mail providers,
+    their type and size
+  mta-sts: the MTA-STS record, and the policy fetched over HTTPS from the mta-sts subdomain, redirects
+    not being followed, whose mx patterns must match the MX hosts
+  tls-rpt: the SMTP TLS reporting record, recommended with MTA-STS
+  bimi: the BIMI record, its logo and mark certificate, that require an enforced DMARC policy
+
+The severity of each finding is:
+  error: mail is rejected, spoofable or undeliverable
+  warning: the authentication of mail or its reports are weakened
+  info: an optional mechanism is missing, or a notable setting
+
+The command fails if errors are found, or warnings with --strict.
+
+JSON/YAML output schema:
+  domain: the domain (string)
+  mx: list of
+    preference: the preference of the record (integer)
+    host: the host (string)
+    addresses: the addresses of the host (list of strings)
+    error: the reason the host cannot receive mail, if any (string)
+  null_mx: true if the domain accepts no mail (boolean)
+  spf: the SPF policy, if any
+    record: the record, recursively
+      domain, text: the domain and the record (strings)
+      terms: list of
+        qualifier: "+", "-", "~" or "?" (string)
+        name, value: the mechanism or the modifier, and its value (strings)
+        modifier: true for modifiers (boolean)
+        addresses: the addresses of a and mx mechanisms (list of strings)
+        record: the record of include mechanisms and redirect modifiers (object)
+      error: the reason the record gives a permerror, if any (string)
+    lookups, void_lookups, depth: the DNS lookups of an evaluation, the ones without answer, and the
+      nesting of includes (integers)
+    ip, result, match: the --ip address, its result, eg: "pass", and the term giving it (strings)
+  dmarc: the DMARC policy, if any
+    domain, record: the domain and the record (strings)
+    policy, subdomain_policy: "none", "quarantine" or "reject" (strings)
+    percent: the percentage of failing mail the policy applies to (integer)
+    adkim, aspf: the alignments, "r" or "s" (strings)
+    rua, ruf: the report URIs (list of strings)
+  dkim: list of
+    selector, name, record: the selector, its name and its record (strings)
+    key_type: "rsa" or "ed25519" (string)
+    bits: the size of the key (integer)
+    testing, revoked: true if the key is in testing mode, or empty (booleans)
+  mta_sts: the MTA-STS record, if any
+    record, id, policy_url: the record, the id of the policy and its URL (strings)
+    policy: the policy, if fetched, with version, mode, mx (list of strings) and max_age (integer)
+  tls_rpt: the TLS-RPT record, if any, with record and rua (list of strings)
+  bimi: the BIMI record, if any, with record, logo and authority (strings)
+  findings: list of
+    severity: "error", "warning" or "info" (string)
+    check: the check, eg: "spf" (string)
+    message: the description of the finding (string)
+
+Examples:
+  dsak dns mail example.com
+  dsak dns mail --selector google --ip 192.0.2.25 example.com`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSMailTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					opts := dns.MailOptions{Selectors: cfg.GetStringSlice(configKeyDNSMailSelectors)}
+					if s := cfg.GetString(configKeyDNSMailIP); s != "" {
+						ip, err := netip.ParseAddr(s)
+						if err != nil {
+							return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid sending server address %s: %w", s, err)
+						}
+						opts.IP = ip.Unmap()
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
+					client := dns.NewClient(
+						getLogger(cmd),
+						dns.WithServers(dnsGetServers(cmd, configKeyDNSMailUseServers)...),
+						dns.WithTimeouts(connectTimeout, readTimeout),
+					)
+					res := client.CheckMail(cmd.Context(), args[0], opts)
+					if err := renderResultWithoutCancel(cmd, res); err != nil {
+						return err
+					}
+					if res.Errors() > 0 || (cfg.GetBool(configKeyDNSMailStrict) && res.Warnings() > 0) {
+						return dsakerr.Errorf(dsakerr.CategoryValidation, "%d errors and %d warnings found for %s", res.Errors(), res.Warnings(), args[0])
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSMailUseServers),
+		commander.WithConfig(configKeyDNSMailSelectors),
+		commander.WithConfig(configKeyDNSMailIP),
+		commander.WithConfig(configKeyDNSMailStrict),
+		commander.WithConfig(configKeyDNSMailTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSMailUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+	)
+}
@@ -2,30 +2,71 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
 	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 const (
 	configKeyDNSQueryUseServers = "dns.query.useservers"
 	configKeyDNSQueryType       = "dns.query.type"
+	configKeyDNSQueryTimeout    = "dns.query.timeout"
+	configKeyDNSQueryFirst      = "dns.query.first"
+	configKeyDNSQueryInsecure   = "dns.query.insecure"
+	configKeyDNSQueryDoHMethod  = "dns.query.dohmethod"
+	configKeyDNSQueryShort      = "dns.query.short"
+	configKeyDNSQueryExplain    = "dns.query.explain"
+	configKeyDNSQueryDNSSEC     = "dns.query.dnssec"
+	configKeyDNSQueryAnchors    = "dns.query.trustanchors"
+	configKeyDNSQueryUDPSize    = "dns.query.udpsize"
+	configKeyDNSQuerySubnet     = "dns.query.subnet"
+	configKeyDNSQueryNSID       = "dns.query.nsid"
+	configKeyDNSQueryCookie     = "dns.query.cookie"
+	configKeyDNSQueryPadding    = "dns.query.padding"
+	configKeyDNSQueryNoRD       = "dns.query.nord"
+	configKeyDNSQueryCD         = "dns.query.cd"
+	configKeyDNSQueryAD         = "dns.query.ad"
+	configKeyDNSQueryTCP        = "dns.query.tcp"
+	configKeyDNSQueryTCPRetry   = "dns.query.tcpfallback"
+	configKeyDNSQuerySource     = "dns.query.source"
+	configKeyDNSQueryNamesFrom  = "dns.query.namesfrom"
+	configKeyDNSQueryConcurrent = "dns.query.concurrency"
+	configKeyDNSQuerySystem     = "dns.query.system"
 )
 
 func init() {
 	config.RegisterValue(
 		configKeyDNSQueryType,
-		config.ValueTypeString,
+		config.ValueTypeStrings,
 		config.Flag("type"),
 		config.ShortFlag('t'),
-		config.DefaultValue("A"),
-		config.Description("Record type to query"),
+		config.DefaultValue([]string{"A"}),
+		config.Description("Record types to query, comma-separated or repeated, all-common for A, AAAA, CNAME, MX, NS, TXT, SOA, CAA and HTTPS"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryNamesFrom,
+		config.ValueTypeString,
+		config.Flag("names-from"),
+		config.Description("Resource of names to query in addition to the arguments, one per line, empty lines and lines starting with # being ignored"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryConcurrent,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(10)),
+		config.Flag("concurrency"),
+		config.ShortFlag('j'),
+		config.Description("Maximum number of queries of several names or types sent at the same time"),
 	)
 	config.RegisterValue(
 		configKeyDNSQueryUseServers,
@@ -35,82 +76,533 @@ func init() {
 		config.DefaultValue([]string{"default"}),
 		config.Description("DNS server aliases or IP addresses/hostnames to use"),
 	)
+	config.RegisterValue(
+		configKeyDNSQueryFirst,
+		config.ValueTypeBool,
+		config.Flag("first"),
+		config.Description("Stop at the first successful response (NOERROR or NXDOMAIN) instead of waiting for every server"),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQueryInsecure,
+		config.ValueTypeBool,
+		config.Flag("insecure"),
+		config.Description("Do not verify the certificates of DNS over TLS, HTTPS and QUIC servers"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryDoHMethod,
+		config.ValueTypeString,
+		config.DefaultValue(http.MethodPost),
+		config.Flag("doh-method"),
+		config.Description("HTTP method of DNS over HTTPS queries: GET or POST"),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQueryShort,
+		config.ValueTypeBool,
+		config.Flag("short"),
+		config.Description("Only print the data of the answer records, one per line, with the text format"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryExplain,
+		config.ValueTypeBool,
+		config.Flag("explain"),
+		config.Description("Describe the record types of the responses, with links to their specifications"),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQueryDNSSEC,
+		config.ValueTypeBool,
+		config.Flag("dnssec"),
+		config.Description("Request DNSSEC records (DO and CD bits) and validate the responses up to the trust anchor: secure, insecure (unsigned zone), bogus (with the reason) or indeterminate"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryAnchors,
+		config.ValueTypeStrings,
+		config.Flag("trust-anchor"),
+		config.Description("DS or DNSKEY record used as DNSSEC trust anchor instead of the root one, eg: \". IN DS 20326 8 2 E06D...\""),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQueryUDPSize,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(0)),
+		config.Flag("udp-size"),
+		config.Description("EDNS UDP buffer size advertised by queries, 0 for no EDNS unless an EDNS option is set"),
+	)
+	config.RegisterValue(
+		configKeyDNSQuerySubnet,
+		config.ValueTypeString,
+		config.Flag("subnet"),
+		config.Description("EDNS Client Subnet of queries, to see the answers given to its clients by GeoDNS or CDN steering: a CIDR range or an address, taken as its /24 or /56, eg: 203.0.113.0/24"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryNSID,
+		config.ValueTypeBool,
+		config.Flag("nsid"),
+		config.Description("Request the name server identifier (NSID) of the servers, eg: the anycast instance that answered"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryCookie,
+		config.ValueTypeBool,
+		config.Flag("cookie"),
+		config.Description("Send a DNS client cookie, servers answering with their cookie"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryPadding,
+		config.ValueTypeBool,
+		config.Flag("padding"),
+		config.Description("Pad queries to a multiple of 128 bytes"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryNoRD,
+		config.ValueTypeBool,
+		config.Flag("no-rd"),
+		config.Description("Clear the RD (recursion desired) flag of queries"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryCD,
+		config.ValueTypeBool,
+		config.Flag("cd"),
+		config.Description("Set the CD (checking disabled) flag of queries"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryAD,
+		config.ValueTypeBool,
+		config.Flag("ad"),
+		config.Description("Set the AD (authenticated data) flag of queries"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryTCP,
+		config.ValueTypeBool,
+		config.Flag("tcp"),
+		config.Description("Query the servers given as addresses over TCP instead of UDP"),
+	)
+	config.RegisterValue(
+		configKeyDNSQueryTCPRetry,
+		config.ValueTypeBool,
+		config.Flag("tcp-fallback"),
+		config.Description("Query again over TCP the servers whose UDP response is truncated"),
+	)
+	config.RegisterValue(
+		configKeyDNSQuerySource,
+		config.ValueTypeString,
+		config.Flag("source"),
+		config.Description("Source address and port of queries: ip, ip:port or :port, ignored over DNS over HTTPS and QUIC"),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQuerySystem,
+		config.ValueTypeBool,
+		config.Flag("system"),
+		config.Description("Resolve names like the glibc system resolver: hosts file, then search domains, ndots, timeout and attempts of resolv.conf, querying its name servers unless --servers is given"),
+	)
+
+	config.RegisterValue(
+		configKeyDNSQueryTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns query, 0 for the global timeout"),
+	)
 
 	commander.Register(
 		"dns>query",
 		func() *cobra.Command {
 			return &cobra.Command{
-				Use:   "query [flags] value",
+				Use:   "query [flags] name...",
 				Short: "Run a dns query",
-				Args:  cobra.ExactArgs(1),
+				Long: `Run a dns query.
+
+The query is sent concurrently to every server given by --servers: addresses, aliases or URLs for
+other transports than UDP (tcp://, tls://, https:// and quic://, see dsak dns servers add -h). A single
+response is shown like dig does. With several servers, the response code, the round trip time and the
+answer of each one are shown, the responses that differ from the ones given by most servers being
+highlighted, eg: split-horizon views or stale caches. The query fails if no server answered.
+With several names or record types, every type of every name is queried and the answer records are
+aggregated, the queries fail if no server answered any of them.
+With --system, names are resolved like the system resolver of a container or a pod, given by
+--resolv-conf and --hosts-file, the candidate names queried being shown before the response.
+See the flags for the output modes, the EDNS options, the header flags and the DNSSEC validation.
+
+JSON/YAML output schema, with several names or types:
+  consistent: false if servers gave different responses to a query (boolean)
+  types: with --explain, list of {type, description (strings), urls (list of strings)}
+  queries: list of, by name then by type
+    name, type: the name and the record type queried (strings)
+    error: the error the query failed with if no server answered (string)
+    search: with --system, the resolution of the name, as below
+    consistent, servers: the result of the query, as below
+
+JSON/YAML output schema:
+  consistent: false if servers gave different responses (boolean)
+  search: with --system, the resolution of the name
+    name: the name (string)
+    hosts: the hosts file the name was found in, if any (string)
+    candidates: list of the names queried, in order
+      name, rcode: the name and the response code given by most servers (strings)
+      answers: the number of answer records (integer)
+      error: the error the query failed with, if any (string)
+    matched: the name that has records, if any (string)
+  types: with --explain, list of {type, description (strings), urls (list of strings)}
+  servers: list of
+    server: the address of the server (string)
+    differs: true if the response differs from the one given by most servers (boolean)
+    error: the error the query failed with, if any (string)
+    response: the response of the server, if any
+      server: the address of the server that answered (string)
+      transport: "udp", "tcp", "tls", "https", "quic", or "hosts" for the hosts file (string)
+      rtt: the round trip time, eg: "12.5ms" (string)
+      id: the message id (integer)
+      opcode: the message opcode, eg: "QUERY" (string)
+      rcode: the response code, eg: "NOERROR" (string)
+      flags: the header flags aa, tc, rd, ra, ad and cd (booleans)
+      edns: the OPT record of the response, if any
+        version, udp_size (integers), do (boolean)
+        nsid: the name server identifier in hexadecimal, nsid_text if it is printable (strings)
+        client_subnet: the EDNS Client Subnet (string), client_subnet_scope: its scope (integer)
+        cookie: the client then server cookies in hexadecimal (string)
+        padding: the number of padding bytes (integer)
+        errors: the extended errors, list of {code (integer), name, text (strings)}
+        options: the other options, eg: "65001: 0a0b" (list of strings)
+      tcp_fallback: true if the UDP response was truncated and the query sent again over TCP (boolean)
+      question: list of {name, type, class} (strings)
+      answer, authority, additional: lists of records
+        name, type, class (strings)
+        ttl: the TTL, in seconds (integer)
+        data: the record data in the presentation format (string)
+        fields: the data fields, depending on the type (object):
+          A, AAAA: address; CNAME, DNAME, NS, PTR: target; TXT, SPF: strings (list of strings)
+          MX: preference, exchange; SRV: priority, weight, port, target; CAA: flag, tag, value
+          SVCB, HTTPS: priority, target, params (object, eg: {"alpn": "h2,h3"})
+          SOA: ns, mbox, serial, refresh, retry, expire, minttl
+      tls: the TLS connection details, for encrypted transports
+        version, cipher_suite, alpn, server_name (strings)
+        handshake: the TLS handshake duration, eg: "12.5ms" (string)
+        verified: true if the server certificate is verified (boolean)
+        certificates: list of {subject, issuer (strings), not_before, not_after (RFC 3339 strings),
+          dns_names (list of strings)}
+      dnssec: with --dnssec, the validation of the response
+        status: "secure", "insecure", "bogus" or "indeterminate" (string)
+        reason: why the response has this status (string)
+        chain: list of the zones whose keys were validated, from the root
+          zone, status, reason (strings)
+          keys: the keys of the zone, eg: "20326 RSASHA256 KSK" (list of strings)
+
+The table and csv formats list the records of every section of every server response, starting with
+the name and the type of the query with several names or types.
+
+Examples:
+  dsak dns query -t MX example.com
+  dsak dns query -s default -s tls://9.9.9.9 --short example.com
+  dsak dns query --dnssec --subnet 203.0.113.0/24 --nsid -s 8.8.8.8 www.example.com
+  dsak dns query -t all-common example.com www.example.com
+  dsak dns query -t A,AAAA --names-from hosts.txt --format csv
+  dsak dns query --system --resolv-conf pod-resolv.conf kubernetes.default`,
+				Args:        cobra.ArbitraryArgs,
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSQueryTimeout},
 				RunE: func(cmd *cobra.Command, args []string) error {
-					servers := dnsQueryGetServers(cmd)
+					servers := dnsGetServers(cmd, configKeyDNSQueryUseServers)
 					cfg := config.GetFromCommandContext(cmd)
-					tName := cfg.GetString(configKeyDNSQueryType)
-					t, err := dns.GetType(tName)
+					types, err := dns.ParseTypes(cfg.GetStringSlice(configKeyDNSQueryType)...)
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					names := args
+					if from := cfg.GetString(configKeyDNSQueryNamesFrom); from != "" {
+						inputs, err := eachReadInputs(cmd, from)
+						if err != nil {
+							return err
+						}
+						names = append(slices.Clone(args), inputs...)
+					}
+					if len(names) == 0 {
+						return dsakerr.New(dsakerr.CategoryUsage, "expected a name to query, as argument or with --names-from")
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
 					if err != nil {
 						return err
 					}
-					client := dns.NewClient(getLogger(cmd), servers...)
-					res, err := client.Query(cmd.Context(), t, args[0])
+					opts := []dns.Option{
+						dns.WithServers(servers...),
+						dns.WithTimeouts(connectTimeout, readTimeout),
+					}
+					if cfg.GetBool(configKeyDNSQueryFirst) {
+						opts = append(opts, dns.WithFirstAnswer())
+					}
+					if cfg.GetBool(configKeyDNSQueryInsecure) {
+						opts = append(opts, dns.WithInsecure())
+					}
+					switch method := strings.ToUpper(cfg.GetString(configKeyDNSQueryDoHMethod)); method {
+					case http.MethodGet, http.MethodPost:
+						opts = append(opts, dns.WithDoHMethod(method))
+					default:
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid DNS over HTTPS method %s, valid methods are GET and POST", method)
+					}
+					if cfg.GetBool(configKeyDNSQueryDNSSEC) {
+						anchors := cfg.GetStringSlice(configKeyDNSQueryAnchors)
+						if err := dns.CheckTrustAnchors(anchors...); err != nil {
+							return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+						}
+						opts = append(opts, dns.WithDNSSEC(anchors...))
+					}
+					queryOpts, err := dnsQueryOptions(cmd)
+					if err != nil {
+						return err
+					}
+					system := cfg.GetBool(configKeyDNSQuerySystem)
+					if system {
+						systemOpts, err := dnsQuerySystemOptions(cmd, connectTimeout, readTimeout)
+						if err != nil {
+							return err
+						}
+						queryOpts = append(queryOpts, systemOpts...)
+					}
+					client := dns.NewClient(getLogger(cmd), append(opts, queryOpts...)...)
+					if len(names) > 1 || len(types) > 1 {
+						res := client.QueryBatch(cmd.Context(), names, types, int(cfg.GetUint64(configKeyDNSQueryConcurrent)))
+						out := &dnsQueryBatch{Batch: res, short: cfg.GetBool(configKeyDNSQueryShort)}
+						if cfg.GetBool(configKeyDNSQueryExplain) {
+							out.Types = res.Types()
+						}
+						if err := renderResultWithoutCancel(cmd, out); err != nil {
+							return err
+						}
+						if res.Failed() == len(res.Queries) {
+							return fmt.Errorf("failed to query: %w", res.Queries[0].Err())
+						}
+						return nil
+					}
+					var (
+						res    *dns.Result
+						search *dns.Search
+					)
+					if system {
+						res, search, err = client.Resolve(cmd.Context(), types[0], names[0])
+					} else {
+						res, err = client.Query(cmd.Context(), types[0], names[0])
+					}
 					if err != nil {
 						return fmt.Errorf("failed to query: %w", err)
 					}
-					fmt.Fprintln(cmd.OutOrStdout(), res)
-					return nil
+					out := &dnsQueryResult{Result: res, Search: search, short: cfg.GetBool(configKeyDNSQueryShort)}
+					if cfg.GetBool(configKeyDNSQueryExplain) {
+						out.Types = res.Types()
+					}
+					return renderResult(cmd, out)
 				},
 			}
 		},
 		commander.WithConfig(configKeyDNSQueryUseServers),
 		commander.WithConfig(configKeyDNSQueryType),
+		commander.WithConfig(configKeyDNSQueryTimeout),
+		commander.WithConfig(configKeyDNSQueryFirst),
+		commander.WithConfig(configKeyDNSQueryInsecure),
+		commander.WithConfig(configKeyDNSQueryDoHMethod),
+		commander.WithConfig(configKeyDNSQueryShort),
+		commander.WithConfig(configKeyDNSQueryExplain),
+		commander.WithConfig(configKeyDNSQueryDNSSEC),
+		commander.WithConfig(configKeyDNSQueryAnchors),
+		commander.WithConfig(configKeyDNSQueryUDPSize),
+		commander.WithConfig(configKeyDNSQuerySubnet),
+		commander.WithConfig(configKeyDNSQueryNSID),
+		commander.WithConfig(configKeyDNSQueryCookie),
+		commander.WithConfig(configKeyDNSQueryPadding),
+		commander.WithConfig(configKeyDNSQueryNoRD),
+		commander.WithConfig(configKeyDNSQueryCD),
+		commander.WithConfig(configKeyDNSQueryAD),
+		commander.WithConfig(configKeyDNSQueryTCP),
+		commander.WithConfig(configKeyDNSQueryTCPRetry),
+		commander.WithConfig(configKeyDNSQuerySource),
+		commander.WithConfig(configKeyDNSQueryNamesFrom),
+		commander.WithConfig(configKeyDNSQueryConcurrent),
+		commander.WithConfig(configKeyDNSQuerySystem),
 		commander.WithFlagCompletion(
 			configKeyDNSQueryUseServers,
 			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-				cfg := config.GetFromCommandContext(cmd)
-				aliases := cfg.GetStringMapStringSlice(configKeyDNSServerAliases)
-				flags := cobra.ShellCompDirectiveNoFileComp
-
-				toComplete = strings.ToLower(toComplete)
-				completions := make([]string, 0, len(aliases))
-				for v := range aliases {
-					v = strings.ToLower(v)
-					if toComplete == "" || strings.Contains(v, toComplete) {
-						completions = append(completions, v)
-					}
-				}
-				return completions, flags
-			},
-		),
-		commander.WithFlagCompletion(
-			configKeyDNSQueryType,
-			func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-				flags := cobra.ShellCompDirectiveNoFileComp
-				var completions []string
-				toComplete = strings.ToLower(toComplete)
-				for _, v := range dns.GetTypeNames() {
-					t, err := dns.GetType(v)
-					if err != nil {
-						continue
-					}
-					vv := strings.ToLower(v)
-					if toComplete == "" || strings.Contains(vv, toComplete) {
-						completions = append(completions, fmt.Sprintf("%s\t%s", v, dns.GetTypeDescription(t)))
-					}
-				}
-				sort.Strings(completions)
-				return completions, flags
+				return getDNSServerAliasCompletion(cmd, toComplete)
 			},
 		),
+		commander.WithFlagCompletion(configKeyDNSQueryType, dnsTypesCompletion),
 	)
 }
 
-func dnsQueryGetServers(cmd *cobra.Command) []string {
+// dnsQueryOptions returns the client options setting the EDNS options, header flags and transport of queries.
+func dnsQueryOptions(cmd *cobra.Command) ([]dns.Option, error) {
+	cfg := config.GetFromCommandContext(cmd)
+	var opts []dns.Option
+	if size := cfg.GetUint64(configKeyDNSQueryUDPSize); size > 0 {
+		if size < 512 || size > 65535 {
+			return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "invalid UDP size %d, it must be between 512 and 65535", size)
+		}
+		opts = append(opts, dns.WithUDPSize(uint16(size)))
+	}
+	if v := cfg.GetString(configKeyDNSQuerySubnet); v != "" {
+		subnet, err := dns.ParseClientSubnet(v)
+		if err != nil {
+			return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+		}
+		opts = append(opts, dns.WithClientSubnet(subnet))
+	}
+	if v := cfg.GetString(configKeyDNSQuerySource); v != "" {
+		addr, port, err := dns.ParseSource(v)
+		if err != nil {
+			return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+		}
+		opts = append(opts, dns.WithSource(addr, port))
+	}
+	for key, opt := range map[string]dns.Option{
+		configKeyDNSQueryNSID:     dns.WithNSID(),
+		configKeyDNSQueryCookie:   dns.WithCookie(),
+		configKeyDNSQueryPadding:  dns.WithPadding(),
+		configKeyDNSQueryNoRD:     dns.WithoutRecursion(),
+		configKeyDNSQueryCD:       dns.WithCheckingDisabled(),
+		configKeyDNSQueryAD:       dns.WithAuthenticatedData(),
+		configKeyDNSQueryTCP:      dns.WithTCP(),
+		configKeyDNSQueryTCPRetry: dns.WithTCPFallback(),
+	} {
+		if cfg.GetBool(key) {
+			opts = append(opts, opt)
+		}
+	}
+	return opts, nil
+}
+
+// dnsQuerySystemOptions returns the client options resolving names like the system resolver, with the
+// hosts file and the search domains, options and name servers of resolv.conf, unless --servers is given.
+func dnsQuerySystemOptions(cmd *cobra.Command, connectTimeout, readTimeout time.Duration) ([]dns.Option, error) {
+	sc, err := dnsSystemConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := dnsHosts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts := []dns.Option{
+		dns.WithSearch(sc.Search, sc.NDots),
+		dns.WithHosts(hosts),
+		dns.WithAttempts(sc.Attempts),
+	}
+	if !cmd.Flags().Changed("servers") {
+		opts = append(opts, dns.WithServers(sc.Servers...))
+	}
+	if readTimeout == 0 {
+		opts = append(opts, dns.WithTimeouts(connectTimeout, time.Duration(sc.Timeout)))
+	}
+	return opts, nil
+}
+
+type dnsQueryResult struct {
+	*dns.Result
+	Search *dns.Search    `json:"search,omitempty"`
+	Types  []dns.TypeInfo `json:"types,omitempty"`
+
+	short bool
+}
+
+func (r *dnsQueryResult) RenderText(w io.Writer) error {
+	render := r.Result.RenderText
+	if r.short {
+		render = r.Result.RenderShort
+	}
+	if r.Search != nil && !r.short {
+		if err := r.Search.RenderText(w); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	if err := render(w); err != nil {
+		return err
+	}
+	if len(r.Types) == 0 {
+		return nil
+	}
+	if !r.short {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return dns.RenderTypes(w, r.Types)
+}
+
+type dnsQueryBatch struct {
+	*dns.Batch
+	Types []dns.TypeInfo `json:"types,omitempty"`
+
+	short bool
+}
+
+func (r *dnsQueryBatch) RenderText(w io.Writer) error {
+	render := r.Batch.RenderText
+	if r.short {
+		render = r.Batch.RenderShort
+	}
+	if err := render(w); err != nil {
+		return err
+	}
+	if len(r.Types) == 0 {
+		return nil
+	}
+	if !r.short {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return dns.RenderTypes(w, r.Types)
+}
+
+// dnsTypesCompletion completes the last type of a comma-separated record types flag.
+func dnsTypesCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+	completions, flags := dnsTypeCompletion(cmd, args, toComplete)
+	if strings.Contains(dns.AllCommonTypes, strings.ToLower(toComplete)) {
+		completions = append(completions, dns.AllCommonTypes+"\tCommon record types")
+	}
+	for i, c := range completions {
+		completions[i] = prefix + c
+	}
+	return completions, flags
+}
+
+// dnsTypeCompletion completes a record type flag.
+func dnsTypeCompletion(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	flags := cobra.ShellCompDirectiveNoFileComp
+	var completions []string
+	toComplete = strings.ToLower(toComplete)
+	for _, v := range dns.GetTypeNames() {
+		t, err := dns.GetType(v)
+		if err != nil {
+			continue
+		}
+		vv := strings.ToLower(v)
+		if toComplete == "" || strings.Contains(vv, toComplete) {
+			completions = append(completions, fmt.Sprintf("%s\t%s", v, dns.GetTypeDescription(t)))
+		}
+	}
+	sort.Strings(completions)
+	return completions, flags
+}
+
+// dnsGetServers returns the servers given by the config key, aliases being expanded.
+func dnsGetServers(cmd *cobra.Command, key string) []string {
 	cfg := config.GetFromCommandContext(cmd)
 	aliases := cfg.GetStringMapStringSlice(configKeyDNSServerAliases)
 	list := make([]string, 0)
 
-	for _, server := range cfg.GetStringSlice(configKeyDNSQueryUseServers) {
+	for _, server := range cfg.GetStringSlice(key) {
 		aliasList, ok := aliases[server]
+		if !ok && server == dns.SystemAlias {
+			sc, err := dnsSystemConfig(cmd)
+			if err != nil {
+				getLogger(cmd).With(zap.Error(err)).Warn("Cannot read the name servers of the system alias")
+				continue
+			}
+			aliasList, ok = sc.Servers, true
+		}
 		if ok {
 			for _, addr := range aliasList {
 				if !slices.Contains(list, addr) {
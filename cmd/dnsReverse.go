@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSReverseUseServers  = "dns.reverse.useservers"
+	configKeyDNSReverseConcurrency = "dns.reverse.concurrency"
+	configKeyDNSReverseTimeout     = "dns.reverse.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSReverseUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames to use"),
+	)
+	config.RegisterValue(
+		configKeyDNSReverseConcurrency,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(20)),
+		config.Flag("concurrency"),
+		config.ShortFlag('j'),
+		config.Description("Maximum number of addresses looked up at the same time"),
+	)
+	config.RegisterValue(
+		configKeyDNSReverseTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns reverse, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>reverse",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "reverse [flags] ip|cidr...",
+				Short: "Look up the PTR records of addresses and check they resolve back",
+				Long: `Look up the PTR records of IP addresses and CIDR ranges, and check they resolve back to the
+addresses (forward-confirmed reverse DNS, FCrDNS).
+
+The in-addr.arpa or ip6.arpa name of each address is queried with the servers given by --servers,
+the first successful response being used, up to --concurrency addresses at the same time. The A or
+AAAA records of every PTR name are then queried. At most 65536 addresses can be looked up.
+
+The status of each address is:
+  confirmed: a PTR name resolves back to the address
+  mismatch: no PTR name resolves back to the address
+  no-ptr: the address has no PTR record
+  error: a lookup failed
+
+JSON/YAML output schema:
+  addresses: list of, in the order of the arguments
+    ip: the address (string)
+    name: its in-addr.arpa or ip6.arpa name (string)
+    ptr: its PTR names (list of strings)
+    forward: the addresses of the PTR names (list of strings)
+    confirmed: true if a PTR name resolves back to the address (boolean)
+    status: "confirmed", "mismatch", "no-ptr" or "error" (string)
+    error: the error a lookup failed with, if any (string)
+
+The command fails if every lookup failed.`,
+				Args:        cobra.MinimumNArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSReverseTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					addrs, err := dns.ParseReverseTargets(args...)
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
+					client := dns.NewClient(
+						getLogger(cmd),
+						dns.WithServers(dnsGetServers(cmd, configKeyDNSReverseUseServers)...),
+						dns.WithTimeouts(connectTimeout, readTimeout),
+					)
+					res := client.Reverse(cmd.Context(), addrs, int(cfg.GetUint64(configKeyDNSReverseConcurrency)))
+					if err := renderResultWithoutCancel(cmd, res); err != nil {
+						return err
+					}
+					if res.Counts()[dns.ReverseError] == len(res.Addresses) {
+						return fmt.Errorf("every lookup failed: %w", res.Addresses[0].Err())
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSReverseUseServers),
+		commander.WithConfig(configKeyDNSReverseConcurrency),
+		commander.WithConfig(configKeyDNSReverseTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSReverseUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+	)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
+)
+
+const (
+	configKeyDNSServeListen         = "dns.serve.listen"
+	configKeyDNSServeZones          = "dns.serve.zones"
+	configKeyDNSServeForward        = "dns.serve.forward"
+	configKeyDNSServeUseServers     = "dns.serve.useservers"
+	configKeyDNSServeReloadInterval = "dns.serve.reloadinterval"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSServeListen,
+		config.ValueTypeString,
+		config.DefaultValue("127.0.0.1:5353"),
+		config.Flag("listen"),
+		config.ShortFlag('l'),
+		config.Description("Address to listen on over UDP and TCP, host:port"),
+	)
+	config.RegisterValue(
+		configKeyDNSServeZones,
+		config.ValueTypeStrings,
+		config.Flag("zone"),
+		config.ShortFlag('z'),
+		config.Description("Zone files to answer from, in the BIND format or YAML (.yaml, .yml)"),
+	)
+	config.RegisterValue(
+		configKeyDNSServeForward,
+		config.ValueTypeBool,
+		config.Flag("forward"),
+		config.ShortFlag('f'),
+		config.Description("Forward the queries for names that are not in the zone files to --servers"),
+	)
+	config.RegisterValue(
+		configKeyDNSServeUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames to forward queries to"),
+	)
+	config.RegisterValue(
+		configKeyDNSServeReloadInterval,
+		config.ValueTypeDuration,
+		config.DefaultValue(time.Second),
+		config.Flag("reload-interval"),
+		config.Description("Interval the zone files are checked for changes at, 0 to disable reloading"),
+	)
+
+	commander.Register(
+		"dns>serve",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "serve [flags]",
+				Short: "Run a local DNS server answering from zone files",
+				Long: `Run a local DNS server, over UDP and TCP, answering from zone files, to override names
+during development or to serve deterministic records to integration tests.
+
+Zone files given by --zone are in the BIND format, or in YAML if their extension is .yaml or .yml:
+  origin: example.test.      # the origin of the relative names, default is the root
+  ttl: 300                   # the default TTL, default is 3600
+  records:
+    - name: www              # relative to the origin, "@" for the origin, or absolute
+      type: A
+      data: [192.0.2.1, 192.0.2.2]
+    - name: api
+      type: CNAME
+      ttl: 60
+      data: www
+
+Queries are answered authoritatively from the records of the zone files, following CNAME records and
+wildcards. The names of a zone with a SOA record that have no record are answered with NXDOMAIN; other
+names are forwarded with --forward to the servers given by --servers, in order until one answers, or
+refused otherwise. The zone files are reloaded when they change, an invalid file being reported and
+ignored until it is fixed.
+
+The server runs until it is interrupted. Each query is written to the output with its answer, as a
+line with the text format, or as a JSON line with the json and jsonl formats.
+
+JSON output schema, one object per query:
+  time: the time of the query (string)
+  client: the address of the client (string)
+  protocol: "udp" or "tcp" (string)
+  name, type: the name and type queried (strings)
+  rcode: the response code (string)
+  source: "local", "forward", "refused" or "error" if forwarding failed (string)
+  answer: the type and data of the answer records (list of strings)
+  duration: the time to answer (string)
+  error: the error forwarding failed with, if any (string)
+
+Example:
+  dsak dns serve -z dev.zone --forward
+  dsak dns query -s 127.0.0.1:5353 www.dev.test`,
+				Args:        cobra.NoArgs,
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				RunE: func(cmd *cobra.Command, _ []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					format, err := getFormat(cmd)
+					if err != nil {
+						return err
+					}
+					switch format {
+					case render.FormatText, render.FormatJSONLines:
+					case render.FormatJSON:
+						format = render.FormatJSONLines
+					default:
+						return dsakerr.New(dsakerr.CategoryUsage, fmt.Sprintf("format %s is not supported by dns serve, use text, json or jsonl", format))
+					}
+					zones := cfg.GetStringSlice(configKeyDNSServeZones)
+					forward := cfg.GetBool(configKeyDNSServeForward)
+					if len(zones) == 0 && !forward {
+						return dsakerr.New(dsakerr.CategoryUsage, "at least a --zone or --forward must be given")
+					}
+					reloadInterval, err := config.GetDuration(cfg, configKeyDNSServeReloadInterval)
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+
+					logger := getLogger(cmd)
+					out := cmd.OutOrStdout()
+					mu := sync.Mutex{}
+					opts := []dns.StubOption{
+						dns.WithZoneFiles(zones...),
+						dns.WithReloadInterval(reloadInterval),
+						dns.WithQueryLog(func(q *dns.QueryLog) {
+							// Queries are answered concurrently.
+							mu.Lock()
+							defer mu.Unlock()
+							if err := render.Render(out, format, q); err != nil {
+								logger.With(zap.Error(err)).Warn("Cannot write query log")
+							}
+						}),
+					}
+					if forward {
+						connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+						if err != nil {
+							return err
+						}
+						opts = append(opts, dns.WithForwarder(dns.NewClient(
+							logger,
+							dns.WithServers(dnsGetServers(cmd, configKeyDNSServeUseServers)...),
+							dns.WithTimeouts(connectTimeout, readTimeout),
+						)))
+					}
+					srv := dns.NewStubServer(logger, opts...)
+					if err := srv.Load(); err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryValidation, err)
+					}
+					if err := srv.Listen(cfg.GetString(configKeyDNSServeListen)); err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryNetwork, err)
+					}
+					logger.With(zap.String("address", srv.Addr()), zap.Strings("zones", zones)).Info("DNS server listening")
+					if err := srv.Serve(cmd.Context()); err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryNetwork, err)
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSServeListen),
+		commander.WithConfig(configKeyDNSServeZones),
+		commander.WithConfig(configKeyDNSServeForward),
+		commander.WithConfig(configKeyDNSServeUseServers),
+		commander.WithConfig(configKeyDNSServeReloadInterval),
+		commander.WithFlagCompletion(
+			configKeyDNSServeUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+	)
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 func init() {
@@ -14,9 +16,16 @@ func init() {
 		"dns>servers>add",
 		func() *cobra.Command {
 			return &cobra.Command{
-				Use:     "add [flags] alias ip_or_hostname...",
-				Short:   "Add one or more IPs or hostname to a DNS server alias",
-				Example: "add lan 192.168.1.1 192.168.2.1",
+				Use:   "add [flags] alias server...",
+				Short: "Add one or more servers to a DNS server alias",
+				Long: `Add one or more servers to a DNS server alias.
+
+Servers are IP addresses or hostnames, with an optional port, queried over UDP, or URLs:
+- udp://host[:port] and tcp://host[:port] for plain DNS over UDP or TCP (port 53),
+- tls://host[:port] for DNS over TLS (port 853),
+- https://host[:port][/path] for DNS over HTTPS (path /dns-query),
+- quic://host[:port] for DNS over QUIC (port 853).`,
+				Example: "add lan 192.168.1.1 192.168.2.1 tls://192.168.1.1 https://dns.lan/dns-query",
 				Args:    cobra.MinimumNArgs(2),
 				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 					if len(args) == 0 {
@@ -33,6 +42,9 @@ func init() {
 					}
 					added := 0
 					for _, addr := range args[1:] {
+						if err := dns.CheckServer(addr); err != nil {
+							return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+						}
 						if !slices.Contains(list, addr) {
 							list = append(list, addr)
 							added++
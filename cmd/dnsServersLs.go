@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"io"
+	"sort"
+
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
 )
 
 func init() {
@@ -22,21 +27,71 @@ func init() {
 					}
 					return nil, cobra.ShellCompDirectiveNoFileComp
 				},
-				Run: func(cmd *cobra.Command, args []string) {
-					name := color.New(color.FgBlue)
-					value := color.New(color.Bold, color.FgGreen)
+				Long: `List a specific or all DNS server alias.
+
+The system alias, unless set, is the name servers of the resolv.conf given by --resolv-conf.
+
+JSON/YAML output schema, a list of:
+  alias: the alias name (string)
+  servers: the addresses of the servers (list of strings)`,
+				RunE: func(cmd *cobra.Command, args []string) error {
 					cfg := config.GetFromCommandContext(cmd)
 					aliases := cfg.GetStringMapStringSlice(configKeyDNSServerAliases)
+					res := make(dnsServersLsResult, 0, len(aliases))
 					for alias, list := range aliases {
 						if len(args) == 0 || alias == args[0] {
-							name.Fprintf(cmd.OutOrStdout(), "[%s]:\n", alias)
-							for _, addr := range list {
-								value.Fprintf(cmd.OutOrStdout(), "  - %s\n", addr)
-							}
+							res = append(res, &dnsServersLsAlias{Alias: alias, Servers: list})
 						}
 					}
+					if _, ok := aliases[dns.SystemAlias]; !ok && (len(args) == 0 || args[0] == dns.SystemAlias) {
+						sc, err := dnsSystemConfig(cmd)
+						if err != nil {
+							getLogger(cmd).With(zap.Error(err)).Warn("Cannot read the name servers of the system alias")
+						} else {
+							res = append(res, &dnsServersLsAlias{Alias: dns.SystemAlias, Servers: sc.Servers})
+						}
+					}
+					sort.Slice(res, func(i, j int) bool {
+						return res[i].Alias < res[j].Alias
+					})
+					return renderResult(cmd, res)
 				},
 			}
 		},
 	)
 }
+
+type dnsServersLsAlias struct {
+	Alias   string   `json:"alias"`
+	Servers []string `json:"servers"`
+}
+
+type dnsServersLsResult []*dnsServersLsAlias
+
+func (r dnsServersLsResult) RenderText(w io.Writer) error {
+	name := color.New(color.FgBlue)
+	value := color.New(color.Bold, color.FgGreen)
+	for _, a := range r {
+		if _, err := name.Fprintf(w, "[%s]:\n", a.Alias); err != nil {
+			return err
+		}
+		for _, addr := range a.Servers {
+			value.Fprintf(w, "  - %s\n", addr)
+		}
+	}
+	return nil
+}
+
+func (r dnsServersLsResult) Header() []string {
+	return []string{"alias", "server"}
+}
+
+func (r dnsServersLsResult) Rows() [][]string {
+	var rows [][]string
+	for _, a := range r {
+		for _, addr := range a.Servers {
+			rows = append(rows, []string{a.Alias, addr})
+		}
+	}
+	return rows
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"errors"
 	"slices"
 	"strings"
 
@@ -9,6 +8,7 @@ import (
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 func init() {
@@ -65,7 +65,7 @@ func init() {
 
 func dnsServersRmAlias(cmd *cobra.Command, alias string) error {
 	if alias == "default" {
-		return errors.New("cannot remove the default DNS server alias")
+		return dsakerr.New(dsakerr.CategoryUsage, "cannot remove the default DNS server alias")
 	}
 	cfg := config.GetFromCommandContext(cmd)
 	aliases := cfg.GetStringMapStringSlice(configKeyDNSServerAliases)
@@ -92,7 +92,7 @@ func dnsServersRmEntries(cmd *cobra.Command, alias string, entries ...string) er
 	}
 	if len(newList) == 0 {
 		if alias == "default" {
-			return errors.New("the default DNS server alias must have at least one entry")
+			return dsakerr.New(dsakerr.CategoryUsage, "the default DNS server alias must have at least one entry")
 		}
 		delete(aliases, alias)
 	} else {
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSTraceType       = "dns.trace.type"
+	configKeyDNSTraceRoots      = "dns.trace.roots"
+	configKeyDNSTraceUseServers = "dns.trace.useservers"
+	configKeyDNSTraceTimeout    = "dns.trace.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSTraceType,
+		config.ValueTypeString,
+		config.Flag("type"),
+		config.ShortFlag('t'),
+		config.DefaultValue("A"),
+		config.Description("Record type to query"),
+	)
+	config.RegisterValue(
+		configKeyDNSTraceRoots,
+		config.ValueTypeStrings,
+		config.Flag("roots"),
+		config.Description("IP addresses of the servers to start from, default is the root servers"),
+	)
+	config.RegisterValue(
+		configKeyDNSTraceUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames used to resolve name servers without glue"),
+	)
+	config.RegisterValue(
+		configKeyDNSTraceTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of dns trace, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>trace",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "trace [flags] name",
+				Short: "Resolve a name iteratively from the root servers",
+				Long: `Resolve a name iteratively from the root servers, like dig +trace does.
+
+Starting from the root servers, or the ones given by --roots, every server of each zone of the
+delegation chain is queried without recursion. Referrals are followed to the zone given by most
+servers, using the A and AAAA glue records of the referral; name servers without glue are resolved
+with the servers given by --servers. The text format shows, for each zone, the servers queried with
+their address, whether it came from glue, their status and round trip time, then the referral to the
+next zone and the answer of the authoritative servers.
+
+Issues are reported for each zone:
+  - lame delegations: servers answering with an error or without authority for the zone
+  - unreachable servers
+  - name servers whose address cannot be resolved
+  - NS sets given by the parent zone and by the zone itself that differ
+
+The trace fails if no server of a zone gave an answer or a referral.
+
+JSON/YAML output schema:
+  name, type: the name and type queried (strings)
+  steps: list of the zones of the delegation chain
+    zone: the zone (string)
+    ns: the name servers of the zone, as given by the parent zone (list of strings)
+    child_ns: the name servers of the zone, as given by its servers (list of strings)
+    servers: list of
+      name, address: the name and address of the server (strings)
+      glue: true if the address was given as glue by the parent zone (boolean)
+      status: "answer", "referral", "lame" or "error" (string)
+      rcode: the response code, eg: "NOERROR" (string)
+      rtt: the round trip time, eg: "12.5ms" (string)
+      detail: the zone referred to, or why the server is lame or failed (string)
+    referral: the delegation to the next zone, if any
+      zone: the zone referred to (string)
+      ns: its name servers (list of strings)
+      glue: list of records {name, type, class, ttl, data, fields}, see dsak dns query -h
+    issues: the issues found (list of strings)
+  rcode: the response code of the answer (string)
+  answer: list of records, see dsak dns query -h
+  answered_by: the name and address of the server that answered (string)
+  error: why the trace failed, if it did (string)
+
+The table and csv formats list every server queried, for each zone.`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSTraceTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					t, err := dns.GetType(cfg.GetString(configKeyDNSTraceType))
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
+					opts := []dns.Option{
+						dns.WithServers(dnsGetServers(cmd, configKeyDNSTraceUseServers)...),
+						dns.WithTimeouts(connectTimeout, readTimeout),
+					}
+					if roots := cfg.GetStringSlice(configKeyDNSTraceRoots); len(roots) > 0 {
+						opts = append(opts, dns.WithRootServers(roots...))
+					}
+					client := dns.NewClient(getLogger(cmd), opts...)
+					trace, traceErr := client.Trace(cmd.Context(), t, args[0])
+					if err := renderResultWithoutCancel(cmd, trace); err != nil {
+						return err
+					}
+					return traceErr
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSTraceType),
+		commander.WithConfig(configKeyDNSTraceRoots),
+		commander.WithConfig(configKeyDNSTraceUseServers),
+		commander.WithConfig(configKeyDNSTraceTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSTraceUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+		commander.WithFlagCompletion(configKeyDNSTraceType, dnsTypeCompletion),
+	)
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSWaitUseServers    = "dns.wait.useservers"
+	configKeyDNSWaitType          = "dns.wait.type"
+	configKeyDNSWaitExpect        = "dns.wait.expect"
+	configKeyDNSWaitExpectAbsent  = "dns.wait.expectabsent"
+	configKeyDNSWaitAuthoritative = "dns.wait.authoritative"
+	configKeyDNSWaitInterval      = "dns.wait.interval"
+	configKeyDNSWaitMaxInterval   = "dns.wait.maxinterval"
+	configKeyDNSWaitTimeout       = "dns.wait.timeout"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSWaitUseServers,
+		config.ValueTypeStrings,
+		config.Flag("servers"),
+		config.ShortFlag('s'),
+		config.DefaultValue([]string{"default"}),
+		config.Description("DNS server aliases or IP addresses/hostnames to poll"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitType,
+		config.ValueTypeString,
+		config.Flag("type"),
+		config.ShortFlag('t'),
+		config.DefaultValue("A"),
+		config.Description("Record type to poll"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitExpect,
+		config.ValueTypeStrings,
+		config.Flag("expect"),
+		config.Description("Expected record data, repeat it to expect a set of records, eg: 203.0.113.7 or \"10 mail.example.com\""),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitExpectAbsent,
+		config.ValueTypeBool,
+		config.Flag("expect-absent"),
+		config.Description("Wait for the records to be absent"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitAuthoritative,
+		config.ValueTypeBool,
+		config.Flag("authoritative"),
+		config.Description("Poll the authoritative name servers of the zone instead of --servers"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitInterval,
+		config.ValueTypeDuration,
+		config.DefaultValue(5*time.Second),
+		config.Flag("interval"),
+		config.ShortFlag('n'),
+		config.Description("Minimum interval between two polls"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitMaxInterval,
+		config.ValueTypeDuration,
+		config.DefaultValue(time.Minute),
+		config.Flag("max-interval"),
+		config.Description("Maximum interval between two polls, when waiting for cached records to expire"),
+	)
+	config.RegisterValue(
+		configKeyDNSWaitTimeout,
+		config.ValueTypeDuration,
+		config.DefaultValue(10*time.Minute),
+		config.Description("Default total timeout of dns wait, 0 for the global timeout"),
+	)
+
+	commander.Register(
+		"dns>wait",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "wait [flags] name",
+				Short: "Wait for DNS servers to return the expected records",
+				Long: `Wait for DNS servers to return the expected records, eg: to block a deployment on a DNS cutover.
+
+Every server given by --servers, aliases being expanded, or with --authoritative every authoritative
+name server of the zone of the name, is polled until all of them return exactly the records given
+by --expect (the data of the records of the --type type, names being compared case-insensitively
+and without their trailing dot), or no record with --expect-absent.
+
+Polls are --interval apart. When a caching server does not match yet, the next poll waits for the
+TTL of its records to expire, up to --max-interval. Authoritative answers are polled every --interval.
+
+When the standard error is a terminal, a status grid of the servers is redrawn after each poll,
+otherwise a progress line is written to it after each poll. The final status is written to the
+output once the records match, or when the timeout (default 10 minutes, see --timeout) expires,
+the command then failing with the timeout exit code.
+
+Example:
+  dsak dns wait --authoritative --expect 203.0.113.7 www.example.com
+
+JSON/YAML output schema:
+  name, type: the name and type polled (strings)
+  expect: the expected values (list of strings)
+  expect_absent: true if the records are expected to be absent (boolean)
+  done: true if every server returns the expected records (boolean)
+  polls: the number of polls (integer)
+  elapsed: the time since the first poll, eg: "1m30s" (string)
+  next_poll: the interval before the next poll, if not done (string)
+  servers: list of
+    server: the address of the server (string)
+    rcode: the response code of its last response (string)
+    values: the data of the records it returned (list of strings)
+    ttl: the TTL of the records, or the negative caching TTL (integer)
+    authoritative: true if the response is authoritative (boolean)
+    matches: true if it returns the expected records (boolean)
+    matched_after: the time it took to match, if it does (string)
+    error: the error the last query failed with, if any (string)`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyDNSWaitTimeout},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					t, err := dns.GetType(cfg.GetString(configKeyDNSWaitType))
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					opts := dns.WaitOptions{
+						Expect: cfg.GetStringSlice(configKeyDNSWaitExpect),
+						Absent: cfg.GetBool(configKeyDNSWaitExpectAbsent),
+					}
+					if (len(opts.Expect) > 0) == opts.Absent {
+						return dsakerr.New(dsakerr.CategoryUsage, "either --expect or --expect-absent must be given")
+					}
+					if opts.Interval, err = config.GetDuration(cfg, configKeyDNSWaitInterval); err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					if opts.Interval <= 0 {
+						return dsakerr.New(dsakerr.CategoryUsage, "interval must be positive")
+					}
+					if opts.MaxInterval, err = config.GetDuration(cfg, configKeyDNSWaitMaxInterval); err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+					}
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
+					servers := dnsGetServers(cmd, configKeyDNSWaitUseServers)
+					client := dns.NewClient(getLogger(cmd), dns.WithServers(servers...), dns.WithTimeouts(connectTimeout, readTimeout))
+					if cfg.GetBool(configKeyDNSWaitAuthoritative) {
+						if servers, err = client.AuthoritativeServers(cmd.Context(), args[0]); err != nil {
+							return fmt.Errorf("cannot find the authoritative servers of %s: %w", args[0], err)
+						}
+						client = dns.NewClient(getLogger(cmd), dns.WithServers(servers...), dns.WithTimeouts(connectTimeout, readTimeout))
+					}
+
+					progress := &dnsWaitProgress{w: cmd.ErrOrStderr(), live: term.IsTerminal(syscall.Stderr)}
+					status, waitErr := client.Wait(cmd.Context(), t, args[0], opts, progress.update)
+					progress.clear()
+					if err := renderResultWithoutCancel(cmd, status); err != nil {
+						return err
+					}
+					return waitErr
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSWaitUseServers),
+		commander.WithConfig(configKeyDNSWaitType),
+		commander.WithConfig(configKeyDNSWaitExpect),
+		commander.WithConfig(configKeyDNSWaitExpectAbsent),
+		commander.WithConfig(configKeyDNSWaitAuthoritative),
+		commander.WithConfig(configKeyDNSWaitInterval),
+		commander.WithConfig(configKeyDNSWaitMaxInterval),
+		commander.WithConfig(configKeyDNSWaitTimeout),
+		commander.WithFlagCompletion(
+			configKeyDNSWaitUseServers,
+			func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return getDNSServerAliasCompletion(cmd, toComplete)
+			},
+		),
+		commander.WithFlagCompletion(configKeyDNSWaitType, dnsTypeCompletion),
+	)
+}
+
+// dnsWaitProgress shows the status of the servers after each poll: a grid redrawn in place on a
+// terminal, a line per poll otherwise.
+type dnsWaitProgress struct {
+	w     io.Writer
+	live  bool
+	lines int
+}
+
+func (p *dnsWaitProgress) update(s *dns.WaitStatus) {
+	if !p.live {
+		fmt.Fprintf(p.w, "poll %d: %d/%d servers match\n", s.Polls, s.Matching(), len(s.Servers))
+		return
+	}
+	buf := &bytes.Buffer{}
+	if p.lines > 0 {
+		fmt.Fprintf(buf, "\033[%dA\033[J", p.lines)
+	}
+	grid := &bytes.Buffer{}
+	_ = s.RenderText(grid)
+	p.lines = strings.Count(grid.String(), "\n")
+	buf.Write(grid.Bytes())
+	_, _ = p.w.Write(buf.Bytes())
+}
+
+// clear erases the grid, the final status being written to the output.
+func (p *dnsWaitProgress) clear() {
+	if p.live && p.lines > 0 {
+		fmt.Fprintf(p.w, "\033[%dA\033[J", p.lines)
+		p.lines = 0
+	}
+}
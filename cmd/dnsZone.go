@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+)
+
+func init() {
+	commander.Register(
+		"dns>zone",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "zone",
+				Short: "Lint, format and diff BIND zone files",
+			}
+		},
+	)
+}
+
+// dnsZoneRead returns the content of the zone file resource name.
+func dnsZoneRead(cmd *cobra.Command, name string) ([]byte, error) {
+	in, err := resource.New(cmd, name, getLogger(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open zone file: %w", err)
+	}
+	defer in.Close()
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read zone file %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// dnsZoneParse returns the zone of the zone file resource name, syntax errors being validation errors.
+func dnsZoneParse(cmd *cobra.Command, name, origin string) (*dns.Zone, error) {
+	b, err := dnsZoneRead(cmd, name)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := dns.ParseZone(bytes.NewReader(b), name, origin)
+	if err != nil {
+		return nil, dsakerr.Wrap(dsakerr.CategoryValidation, err)
+	}
+	return zone, nil
+}
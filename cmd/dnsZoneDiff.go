@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+)
+
+const (
+	configKeyDNSZoneDiffOrigin = "dns.zone.diff.origin"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSZoneDiffOrigin,
+		config.ValueTypeString,
+		config.Flag("origin"),
+		config.Description("Origin of the zones, if the zone files have no $ORIGIN directive or SOA record"),
+	)
+
+	commander.Register(
+		"dns>zone>diff",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "diff [flags] old-resource new-resource",
+				Short: "Show the records that changed between two versions of a BIND zone file",
+				Long: `Show the records added, removed, or whose TTL changed between two versions of a BIND zone file,
+read from resources.
+
+The diff is semantic: records are compared regardless of their order, formatting, relative or absolute
+names and the case of their names. Records are shown with their absolute names, in the order of their
+names, a record whose data changed being removed then added.
+
+Example:
+  git show HEAD:example.com.zone > /tmp/previous.zone
+  dsak dns zone diff /tmp/previous.zone example.com.zone
+
+JSON/YAML output schema:
+  from, to: the old and new zone files (strings)
+  changes: list of
+    change: "added", "removed" or "ttl" (string)
+    name: the absolute name of the record (string)
+    type: the type of the record (string)
+    ttl: the TTL of the record, the new one if it changed (integer)
+    old_ttl: the old TTL of the record, if it changed (integer)
+    data: the data of the record (string)`,
+				Args: cobra.ExactArgs(2),
+				RunE: func(cmd *cobra.Command, args []string) error {
+					origin := config.GetFromCommandContext(cmd).GetString(configKeyDNSZoneDiffOrigin)
+					from, err := dnsZoneParse(cmd, args[0], origin)
+					if err != nil {
+						return err
+					}
+					to, err := dnsZoneParse(cmd, args[1], origin)
+					if err != nil {
+						return err
+					}
+					return renderResult(cmd, dns.DiffZones(from, to))
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSZoneDiffOrigin),
+	)
+}
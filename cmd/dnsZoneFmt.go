@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSZoneFmtOrigin = "dns.zone.fmt.origin"
+	configKeyDNSZoneFmtCheck  = "dns.zone.fmt.check"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSZoneFmtOrigin,
+		config.ValueTypeString,
+		config.Flag("origin"),
+		config.Description("Origin of the zone, if the zone file has no $ORIGIN directive or SOA record"),
+	)
+	config.RegisterValue(
+		configKeyDNSZoneFmtCheck,
+		config.ValueTypeBool,
+		config.Flag("check"),
+		config.Description("Only check that the zone file is formatted, failing if it is not"),
+	)
+
+	commander.Register(
+		"dns>zone>fmt",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "fmt [flags] resource",
+				Short: "Format a BIND zone file",
+				Long: `Format a BIND zone file read from a resource, writing it to the output.
+
+The formatted zone starts with $ORIGIN and $TTL directives, the TTL being the most common one, then
+the SOA and NS records of the origin, then the other records sorted by name and type. Names are relative
+to the origin, TTLs are only written when they differ from the default one, columns are aligned.
+The output is always a zone file, --format is ignored.
+
+With --check, nothing is written and the command fails if the zone file is not formatted, eg: in CI.
+
+Example:
+  dsak dns zone fmt --output example.com.zone.new example.com.zone`,
+				Args: cobra.ExactArgs(1),
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					b, err := dnsZoneRead(cmd, args[0])
+					if err != nil {
+						return err
+					}
+					zone, err := dns.ParseZone(bytes.NewReader(b), args[0], cfg.GetString(configKeyDNSZoneFmtOrigin))
+					if err != nil {
+						return dsakerr.Wrap(dsakerr.CategoryValidation, err)
+					}
+					formatted := &bytes.Buffer{}
+					if err := dns.FormatZone(formatted, zone); err != nil {
+						return err
+					}
+					if cfg.GetBool(configKeyDNSZoneFmtCheck) {
+						if !bytes.Equal(formatted.Bytes(), b) {
+							return dsakerr.Errorf(dsakerr.CategoryValidation, "%s is not formatted", args[0])
+						}
+						return nil
+					}
+					_, err = cmd.OutOrStdout().Write(formatted.Bytes())
+					return err
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSZoneFmtOrigin),
+		commander.WithConfig(configKeyDNSZoneFmtCheck),
+	)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	configKeyDNSZoneLintOrigin   = "dns.zone.lint.origin"
+	configKeyDNSZoneLintPrevious = "dns.zone.lint.previous"
+	configKeyDNSZoneLintStrict   = "dns.zone.lint.strict"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyDNSZoneLintOrigin,
+		config.ValueTypeString,
+		config.Flag("origin"),
+		config.Description("Origin of the zone, if the zone file has no $ORIGIN directive or SOA record"),
+	)
+	config.RegisterValue(
+		configKeyDNSZoneLintPrevious,
+		config.ValueTypeString,
+		config.Flag("previous"),
+		config.Description("Resource of the previous version of the zone, to check that the SOA serial has been incremented"),
+	)
+	config.RegisterValue(
+		configKeyDNSZoneLintStrict,
+		config.ValueTypeBool,
+		config.Flag("strict"),
+		config.Description("Fail on warnings too"),
+	)
+
+	commander.Register(
+		"dns>zone>lint",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "lint [flags] resource",
+				Short: "Check a BIND zone file for errors",
+				Long: `Check a BIND zone file, read from a resource, for errors before it is published.
+
+Errors:
+  syntax: the zone file cannot be parsed
+  soa: no SOA record, several, or outside of the origin
+  cname-apex: a CNAME record at the origin
+  cname-other-data: a CNAME record with other records, or several CNAME records, at the same name
+  missing-glue: a name server inside the zone without A or AAAA record
+  serial: the SOA serial has not been incremented since the --previous version while the records
+    changed, or is lower
+Warnings:
+  ns: no NS record at the origin
+  dangling-target: the target of a CNAME, MX or SRV record inside the zone does not exist
+  target-cname: the target of a MX, SRV or NS record is a CNAME record
+  ttl-mismatch: records of the same name and type with different TTLs
+  out-of-zone: a record outside of the origin
+
+The command fails if errors are found, or warnings with --strict.
+
+Example, in a git pre-commit hook:
+  git show HEAD:example.com.zone > /tmp/previous.zone
+  dsak dns zone lint --previous /tmp/previous.zone example.com.zone
+
+JSON/YAML output schema:
+  file: the zone file (string)
+  origin: the origin of the zone (string)
+  records: the number of records (integer)
+  findings: list of
+    severity: "error" or "warning" (string)
+    check: the check, eg: "cname-apex" (string)
+    name: the name of the records (string)
+    type: the type of the records (string)
+    message: the description of the issue (string)`,
+				Args: cobra.ExactArgs(1),
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					origin := cfg.GetString(configKeyDNSZoneLintOrigin)
+					var previous *dns.Zone
+					if name := cfg.GetString(configKeyDNSZoneLintPrevious); name != "" {
+						var err error
+						if previous, err = dnsZoneParse(cmd, name, origin); err != nil {
+							return err
+						}
+					}
+					b, err := dnsZoneRead(cmd, args[0])
+					if err != nil {
+						return err
+					}
+					lint := dns.LintZone(bytes.NewReader(b), args[0], origin, previous)
+					if err := renderResult(cmd, lint); err != nil {
+						return err
+					}
+					if lint.Errors() > 0 || (cfg.GetBool(configKeyDNSZoneLintStrict) && len(lint.Findings) > 0) {
+						return dsakerr.Errorf(dsakerr.CategoryValidation, "%d errors and %d warnings found in %s", lint.Errors(), lint.Warnings(), args[0])
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyDNSZoneLintOrigin),
+		commander.WithConfig(configKeyDNSZoneLintPrevious),
+		commander.WithConfig(configKeyDNSZoneLintStrict),
+	)
+}
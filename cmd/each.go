@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+)
+
+const (
+	configKeyEachConcurrency = "each.concurrency"
+
+	eachPlaceholder = "{}"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyEachConcurrency,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(10)),
+		config.Flag("concurrency"),
+		config.ShortFlag('j'),
+		config.Description("Maximum number of commands running at the same time"),
+	)
+
+	commander.Register(
+		"each",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "each [flags] inputs -- command [args...]",
+				Short: "Run a dsak command for each input read from a resource",
+				Long: `Run a dsak command for each input read from a resource, eg: hostnames, URLs or domains.
+
+Inputs are the lines of the inputs resource, empty lines and lines starting with # being ignored.
+Each occurrence of {} in the command arguments is replaced by the input, the input is appended to
+the arguments if there is none.
+
+Commands run in-process with the global flags of each, up to --concurrency at the same time, and
+their results are reported in the order of the inputs. With the text and jsonl formats, each result
+is printed as soon as it and the ones before it are done. Other formats print the results once every
+command has run. With the json, yaml and jsonl formats, commands are run with --format json and
+their output is embedded as JSON when it is valid JSON.
+
+each fails if a command failed for at least one input.
+
+JSON/YAML output schema, list of:
+  input: the input (string)
+  status: "ok" or "failed" (string)
+  exit_status: the exit status of the command (integer)
+  duration: the duration of the command, eg: "12.5ms" (string)
+  output: the output of the command (JSON value or string)
+  error: the error the command failed with, if any (string)
+
+Example:
+  dsak each -j 20 --format table hosts.txt -- http cert https://{}`,
+				Args: func(cmd *cobra.Command, args []string) error {
+					dash := cmd.ArgsLenAtDash()
+					if dash != 1 || len(args) < 2 {
+						return dsakerr.New(dsakerr.CategoryUsage, "expected an inputs resource, then -- and a command")
+					}
+					return nil
+				},
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					dash := cmd.ArgsLenAtDash()
+					if dash < 0 {
+						return nil, cobra.ShellCompDirectiveDefault
+					}
+					r := commander.NewRunner(commander.WithRunnerConfig(config.GetFromCommandContext(cmd)))
+					list, directive, err := r.Complete(cmd.Context(), args[dash:], toComplete)
+					if err != nil {
+						return nil, cobra.ShellCompDirectiveError
+					}
+					return list, directive
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					concurrency := cfg.GetUint64(configKeyEachConcurrency)
+					if concurrency == 0 {
+						return dsakerr.New(dsakerr.CategoryUsage, "concurrency must be positive")
+					}
+					format, err := getFormat(cmd)
+					if err != nil {
+						return err
+					}
+					inputs, err := eachReadInputs(cmd, args[0])
+					if err != nil {
+						return err
+					}
+					res, err := eachRun(cmd, inputs, args[1:], int(concurrency), format)
+					if err != nil {
+						return err
+					}
+					if failed := res.failed(); failed > 0 {
+						return dsakerr.Errorf(dsakerr.CategoryValidation, "%d of %d inputs failed", failed, len(res))
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyEachConcurrency),
+	)
+}
+
+// eachReadInputs returns the inputs read from the resource name.
+func eachReadInputs(cmd *cobra.Command, name string) ([]string, error) {
+	in, err := resource.New(cmd, name, getLogger(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open inputs: %w", err)
+	}
+	defer in.Close()
+	var inputs []string
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inputs = append(inputs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read inputs: %w", err)
+	}
+	return inputs, nil
+}
+
+// eachArgs returns the command line run for input.
+func eachArgs(args []string, input string) []string {
+	res := make([]string, len(args))
+	replaced := false
+	for i, arg := range args {
+		if strings.Contains(arg, eachPlaceholder) {
+			arg = strings.ReplaceAll(arg, eachPlaceholder, input)
+			replaced = true
+		}
+		res[i] = arg
+	}
+	if !replaced {
+		res = append(res, input)
+	}
+	return res
+}
+
+// eachRun runs the command for every input, printing the results in the given format.
+func eachRun(cmd *cobra.Command, inputs, args []string, concurrency int, format render.Format) (eachResult, error) {
+	ctx := cmd.Context()
+	innerFormat := render.FormatText
+	if format == render.FormatJSON || format == render.FormatYAML || format == render.FormatJSONLines {
+		innerFormat = render.FormatJSON
+	}
+	cfg := config.GetFromCommandContext(cmd)
+	gArgs := globalArgs(cmd)
+	res := make(eachResult, len(inputs))
+	done := make([]chan struct{}, len(inputs))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	go func() {
+		sem := make(chan struct{}, concurrency)
+		wg := sync.WaitGroup{}
+		for i, input := range inputs {
+			select {
+			case <-ctx.Done():
+			case sem <- struct{}{}:
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			// The configuration is not safe for concurrent use, each command gets its own copy.
+			runCfg, err := config.Clone(cfg)
+			if err != nil {
+				res[i] = &eachInputResult{Input: input, Status: eachStatusFailed, ExitStatus: dsakerr.ExitCode(err), Error: err.Error()}
+				close(done[i])
+				<-sem
+				continue
+			}
+			runCfg.Set(configKeyGlobalOutput, "stdout")
+			runCfg.Set(configKeyGlobalFormat, string(innerFormat))
+			wg.Add(1)
+			go func(i int, input string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				runArgs := append(append([]string{}, gArgs...), eachArgs(args, input)...)
+				res[i] = eachRunInput(ctx, runCfg, runArgs, input, innerFormat)
+				close(done[i])
+			}(i, input)
+		}
+		wg.Wait()
+	}()
+
+	out := cmd.OutOrStdout()
+	for i := range inputs {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done[i]:
+		}
+		switch format {
+		case render.FormatText:
+			if err := res[i].RenderText(out); err != nil {
+				return nil, err
+			}
+		case render.FormatJSONLines:
+			if err := render.Render(out, format, res[i]); err != nil {
+				return nil, err
+			}
+		default:
+		}
+	}
+	if format != render.FormatText && format != render.FormatJSONLines {
+		if err := render.Render(out, format, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func eachRunInput(ctx context.Context, cfg *viper.Viper, args []string, input string, format render.Format) *eachInputResult {
+	out := &bytes.Buffer{}
+	r := commander.NewRunner(
+		commander.WithRunnerConfig(cfg),
+		commander.WithRunnerIn(&bytes.Buffer{}),
+		commander.WithRunnerOut(out),
+		commander.WithRunnerErr(io.Discard),
+	)
+	start := time.Now()
+	err := r.Run(context.WithValue(ctx, cmdContextNested, true), args)
+	res := &eachInputResult{
+		Input:      input,
+		Status:     eachStatusOK,
+		ExitStatus: dsakerr.ExitCode(err),
+		Duration:   time.Since(start).Round(time.Microsecond).String(),
+		Output:     repl.StripANSI(out.String()),
+	}
+	if err != nil {
+		res.Status = eachStatusFailed
+		res.Error = err.Error()
+	}
+	if format == render.FormatJSON {
+		if b := bytes.TrimSpace(out.Bytes()); json.Valid(b) {
+			res.Output = json.RawMessage(b)
+		}
+	}
+	return res
+}
+
+const (
+	eachStatusOK     = "ok"
+	eachStatusFailed = "failed"
+)
+
+type eachInputResult struct {
+	Input      string `json:"input"`
+	Status     string `json:"status"`
+	ExitStatus int    `json:"exit_status"`
+	Duration   string `json:"duration"`
+	Output     any    `json:"output"`
+	Error      string `json:"error,omitempty"`
+}
+
+// outputString returns the output on a line, JSON being compacted.
+func (r *eachInputResult) outputString() string {
+	switch v := r.Output.(type) {
+	case json.RawMessage:
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, v); err != nil {
+			return string(v)
+		}
+		return buf.String()
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return ""
+	}
+}
+
+func (r *eachInputResult) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	if r.Status == eachStatusOK {
+		color.New(color.Bold, color.FgGreen).Fprint(buf, "OK    ")
+	} else {
+		color.New(color.Bold, color.FgRed).Fprint(buf, "FAILED")
+	}
+	fmt.Fprintf(buf, " %s", r.Input)
+	color.New(color.FgBlue).Fprintf(buf, " (%s)", r.Duration)
+	buf.WriteString("\n")
+	if r.Error != "" {
+		color.New(color.FgRed).Fprintf(buf, "       %s", r.Error)
+		buf.WriteString("\n")
+	}
+	if output := strings.TrimRight(r.outputString(), "\n"); output != "" {
+		fmt.Fprintf(buf, "       %s\n", strings.ReplaceAll(output, "\n", "\n       "))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type eachResult []*eachInputResult
+
+func (r eachResult) failed() int {
+	n := 0
+	for _, ir := range r {
+		if ir != nil && ir.Status == eachStatusFailed {
+			n++
+		}
+	}
+	return n
+}
+
+func (r eachResult) Header() []string {
+	return []string{"input", "status", "exit_status", "duration", "output"}
+}
+
+func (r eachResult) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, ir := range r {
+		rows = append(rows, []string{
+			ir.Input,
+			ir.Status,
+			strconv.Itoa(ir.ExitStatus),
+			ir.Duration,
+			ir.outputString(),
+		})
+	}
+	return rows
+}
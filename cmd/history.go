@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/history"
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+)
+
+const (
+	configKeyHistoryDir    = "history.dir"
+	configKeyHistorySize   = "history.size"
+	configKeyHistoryOutput = "history.output"
+)
+
+// historyMaxOutput is the maximum size of a recorded output.
+const historyMaxOutput = 1 << 20
+
+// annotationNoHistory is set on commands that are not recorded in the history, with their sub-commands.
+const annotationNoHistory = "dsak.nohistory"
+
+func init() {
+	config.RegisterValue(
+		configKeyHistoryDir,
+		config.ValueTypeString,
+		config.DefaultValue("~/.dsak/history"),
+		config.Description("Directory where invocations are recorded, empty to disable the history"),
+	)
+	config.RegisterValue(
+		configKeyHistorySize,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(1000)),
+		config.Description("Number of invocations kept in the history"),
+	)
+	config.RegisterValue(
+		configKeyHistoryOutput,
+		config.ValueTypeBool,
+		config.DefaultValue(false),
+		config.Flag("record-output"),
+		config.FlagIsPersistent(),
+		config.Description("Record the output of the command in the history, to be diffed later"),
+	)
+
+	commander.Register(
+		"history",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "history",
+				Short: "List, show, rerun or diff recorded invocations",
+				Long: `List, show, rerun or diff recorded invocations.
+
+Each dsak invocation is recorded in the history directory (see the history.dir configuration) with its
+arguments, its working directory, its configuration file and effective configuration, its duration and
+its exit status. With --record-output (or the history.output configuration), its output is recorded
+too, up to 1MiB, so that it can be diffed against a rerun.
+
+The history, shell and completion commands are not recorded, nor the commands run by other commands.`,
+				Annotations: map[string]string{annotationNoHistory: "true"},
+			}
+		},
+		commander.WithConfig(configKeyHistoryDir),
+		commander.WithConfig(configKeyHistorySize),
+	)
+}
+
+type cmdContextHistoryKeyType string
+
+var (
+	cmdContextHistoryCapture = cmdContextHistoryKeyType("history capture")
+	cmdContextNested         = cmdContextHistoryKeyType("nested")
+)
+
+func getHistoryStore(cmd *cobra.Command) (*history.Store, error) {
+	cfg := config.GetFromCommandContext(cmd)
+	dir := cfg.GetString(configKeyHistoryDir)
+	if dir == "" {
+		return nil, dsakerr.New(dsakerr.CategoryUsage, "history is disabled, see the history.dir configuration")
+	}
+	return history.NewStore(config.ExpandHome(dir), int(cfg.GetUint64(configKeyHistorySize))), nil
+}
+
+// historyRecorded returns true if the invocation of cmd is to be recorded in the history.
+func historyRecorded(cmd *cobra.Command) bool {
+	if nested, _ := cmd.Context().Value(cmdContextNested).(bool); nested {
+		return false
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return false
+		}
+		if c.Annotations[annotationNoHistory] != "" {
+			return false
+		}
+	}
+	return config.GetFromCommandContext(cmd).GetString(configKeyHistoryDir) != ""
+}
+
+// historyCaptureOutput wraps the output of cmd to record it, if asked to.
+func historyCaptureOutput(cmd *cobra.Command) {
+	if !historyRecorded(cmd) || !config.GetFromCommandContext(cmd).GetBool(configKeyHistoryOutput) {
+		return
+	}
+	capture := history.NewCapture(historyMaxOutput)
+	cmd.SetOut(capture.Tee(cmd.OutOrStdout()))
+	cmd.SetContext(context.WithValue(cmd.Context(), cmdContextHistoryCapture, capture))
+}
+
+// recordHistory records an invocation in the history, it is the finish hook of the root command.
+func recordHistory(cmd *cobra.Command, args []string, duration time.Duration, err error) {
+	if cmd.Context() == nil || !historyRecorded(cmd) {
+		return
+	}
+	cfg := config.GetFromCommandContext(cmd)
+	e := &history.Entry{
+		Time:       time.Now().Add(-duration),
+		Args:       args,
+		Command:    cmd.CommandPath(),
+		ConfigFile: cfg.ConfigFileUsed(),
+		Config:     make(map[string]any),
+		Duration:   history.Duration(duration),
+		ExitStatus: dsakerr.ExitCode(err),
+	}
+	e.Dir, _ = os.Getwd()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	for _, v := range config.GetValues() {
+		if v.IsSecret() {
+			continue
+		}
+		value := cfg.Get(v.GetName())
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		e.Config[v.GetName()] = value
+	}
+	if capture, ok := cmd.Context().Value(cmdContextHistoryCapture).(*history.Capture); ok {
+		output := repl.StripANSI(capture.String())
+		e.Output = &output
+		e.OutputTruncated = capture.Truncated()
+	}
+	store, storeErr := getHistoryStore(cmd)
+	if storeErr == nil {
+		storeErr = store.Add(e)
+	}
+	if storeErr != nil {
+		getLogger(cmd).Sugar().Warnf("failed to record invocation in history: %s", storeErr)
+	}
+}
+
+// getHistoryEntry returns the history entry designated by arg, its ID or "last".
+func getHistoryEntry(store *history.Store, arg string) (*history.Entry, error) {
+	if arg == "last" {
+		entries, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, dsakerr.New(dsakerr.CategoryNotFound, "history is empty")
+		}
+		return entries[len(entries)-1], nil
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "invalid history entry ID: %s", arg)
+	}
+	return store.Get(id)
+}
+
+func getHistoryEntryCompletion(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+	store, err := getHistoryStore(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	entries, err := store.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var list []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		id := strconv.Itoa(entries[i].ID)
+		if strings.HasPrefix(id, toComplete) {
+			list = append(list, fmt.Sprintf("%s\t%s", id, entries[i].CommandLine()))
+		}
+	}
+	return list, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// historyConfig returns the configuration recorded in e, environment variables still apply. The secret
+// values, that are not recorded, are taken from the configuration of cmd.
+func historyConfig(cmd *cobra.Command, e *history.Entry) (*viper.Viper, error) {
+	cfg := viper.New()
+	cfg.SetConfigFile(e.ConfigFile)
+	cfg.SetConfigType("yaml")
+	values := make(map[string]any)
+	for name, v := range e.Config {
+		parts := strings.Split(name, ".")
+		m := values
+		for _, part := range parts[:len(parts)-1] {
+			sub, ok := m[part].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				m[part] = sub
+			}
+			m = sub
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	if err := cfg.MergeConfigMap(values); err != nil {
+		return nil, fmt.Errorf("failed to load recorded configuration: %w", err)
+	}
+	current := config.GetFromCommandContext(cmd)
+	for _, v := range config.GetValues() {
+		if v.IsSecret() && current.IsSet(v.GetName()) {
+			cfg.Set(v.GetName(), current.Get(v.GetName()))
+		}
+	}
+	return cfg, nil
+}
+
+// historyRun runs the command line of e in-process with its configuration, relative file paths being
+// relative to its working directory.
+func historyRun(cmd *cobra.Command, e *history.Entry, cfg *viper.Viper, opts ...commander.RunnerOption) error {
+	ctx := context.WithValue(cmd.Context(), cmdContextNested, true)
+	if e.Dir != "" {
+		ctx = resource.WithDir(ctx, e.Dir)
+	}
+	r := commander.NewRunner(append([]commander.RunnerOption{
+		commander.WithRunnerConfig(cfg),
+		commander.WithRunnerErr(cmd.ErrOrStderr()),
+	}, opts...)...)
+	return r.Run(ctx, append(globalArgs(cmd), e.Args...))
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/history"
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+)
+
+func init() {
+	commander.Register(
+		"history>diff",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "diff <id|last> [id]",
+				Short: "Diff the output of a recorded invocation against a rerun or another invocation",
+				Long: `Diff the recorded output of an invocation against the output of the same command run now,
+or against the recorded output of another invocation if a second ID is given.
+
+Only invocations run with --record-output (or the history.output configuration) have a recorded output.
+The rerun uses the recorded arguments, working directory and configuration, like history rerun does,
+its output being captured instead of written to the recorded --output.
+
+JSON/YAML output schema:
+  from: the first invocation
+    id: the entry ID (integer)
+    time: the invocation start time (RFC 3339 string)
+    exit_status: the exit status (integer)
+  to: the second invocation or the rerun, with the same schema, without id for the rerun
+  changed: true if the outputs or the exit statuses differ (boolean)
+  lines: the output lines
+    op: " " for unchanged lines, "-" for removed lines, "+" for added lines (string)
+    text: the line (string)`,
+				Args:        cobra.RangeArgs(1, 2),
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					if len(args) < 2 {
+						return getHistoryEntryCompletion(cmd, toComplete)
+					}
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					store, err := getHistoryStore(cmd)
+					if err != nil {
+						return err
+					}
+					from, err := getHistoryDiffEntry(store, args[0])
+					if err != nil {
+						return err
+					}
+					var to *history.Entry
+					if len(args) == 2 {
+						to, err = getHistoryDiffEntry(store, args[1])
+					} else {
+						to, err = historyDiffRerun(cmd, from)
+					}
+					if err != nil {
+						return err
+					}
+					res := &historyDiffResult{
+						From:  newHistoryDiffSide(from),
+						To:    newHistoryDiffSide(to),
+						Lines: history.Diff(*from.Output, *to.Output),
+					}
+					res.Changed = history.Changed(res.Lines) || from.ExitStatus != to.ExitStatus
+					return renderResult(cmd, res)
+				},
+			}
+		},
+	)
+}
+
+func getHistoryDiffEntry(store *history.Store, arg string) (*history.Entry, error) {
+	e, err := getHistoryEntry(store, arg)
+	if err != nil {
+		return nil, err
+	}
+	if e.Output == nil {
+		return nil, dsakerr.Errorf(
+			dsakerr.CategoryUsage,
+			"history entry %d has no recorded output, run the command with --record-output", e.ID,
+		)
+	}
+	return e, nil
+}
+
+// historyDiffRerun runs e again, returning the rerun as an entry without ID.
+func historyDiffRerun(cmd *cobra.Command, e *history.Entry) (*history.Entry, error) {
+	cfg, err := historyConfig(cmd, e)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Set(configKeyGlobalOutput, "stdout")
+	capture := history.NewCapture(historyMaxOutput)
+	start := time.Now()
+	runErr := historyRun(cmd, e, cfg, commander.WithRunnerOut(capture))
+	output := repl.StripANSI(capture.String())
+	return &history.Entry{
+		Time:       start,
+		ExitStatus: dsakerr.ExitCode(runErr),
+		Output:     &output,
+	}, nil
+}
+
+type historyDiffSide struct {
+	ID         int       `json:"id,omitempty"`
+	Time       time.Time `json:"time"`
+	ExitStatus int       `json:"exit_status"`
+}
+
+func newHistoryDiffSide(e *history.Entry) historyDiffSide {
+	return historyDiffSide{
+		ID:         e.ID,
+		Time:       e.Time,
+		ExitStatus: e.ExitStatus,
+	}
+}
+
+func (s historyDiffSide) String() string {
+	name := "rerun"
+	if s.ID != 0 {
+		name = fmt.Sprintf("#%d", s.ID)
+	}
+	return fmt.Sprintf("%s %s (exit status %d)", name, s.Time.Local().Format(time.DateTime), s.ExitStatus)
+}
+
+type historyDiffResult struct {
+	From    historyDiffSide    `json:"from"`
+	To      historyDiffSide    `json:"to"`
+	Changed bool               `json:"changed"`
+	Lines   []history.DiffLine `json:"lines"`
+}
+
+func (r *historyDiffResult) RenderText(w io.Writer) error {
+	removed := color.New(color.FgRed)
+	added := color.New(color.FgGreen)
+	removed.Fprintf(w, "--- %s\n", r.From)
+	added.Fprintf(w, "+++ %s\n", r.To)
+	for _, l := range r.Lines {
+		switch l.Op {
+		case history.DiffRemoved:
+			removed.Fprintf(w, "-%s\n", l.Text)
+		case history.DiffAdded:
+			added.Fprintf(w, "+%s\n", l.Text)
+		case history.DiffEqual:
+			fmt.Fprintf(w, " %s\n", l.Text)
+		}
+	}
+	if !r.Changed {
+		_, err := color.New(color.Faint).Fprintln(w, "no difference")
+		return err
+	}
+	return nil
+}
+
+func (r *historyDiffResult) Header() []string {
+	return []string{"op", "text"}
+}
+
+func (r *historyDiffResult) Rows() [][]string {
+	rows := make([][]string, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		rows = append(rows, []string{string(l.Op), l.Text})
+	}
+	return rows
+}
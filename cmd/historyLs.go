@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/history"
+)
+
+func init() {
+	commander.Register(
+		"history>ls",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:     "ls",
+				Short:   "List recorded invocations",
+				Aliases: []string{"list"},
+				Long: `List recorded invocations, oldest first.
+
+JSON/YAML output schema, a list of:
+  id: the entry ID (integer)
+  time: the invocation start time (RFC 3339 string)
+  command: the command line (string)
+  duration: the invocation duration (string, eg: "1.2s")
+  exit_status: the exit status (integer)
+  output: true if the output was recorded (boolean)`,
+				Args: cobra.NoArgs,
+				RunE: func(cmd *cobra.Command, _ []string) error {
+					store, err := getHistoryStore(cmd)
+					if err != nil {
+						return err
+					}
+					entries, err := store.List()
+					if err != nil {
+						return err
+					}
+					res := make(historyLsResult, 0, len(entries))
+					for _, e := range entries {
+						res = append(res, &historyLsEntry{
+							ID:         e.ID,
+							Time:       e.Time,
+							Command:    e.CommandLine(),
+							Duration:   e.Duration,
+							ExitStatus: e.ExitStatus,
+							Output:     e.Output != nil,
+						})
+					}
+					return renderResult(cmd, res)
+				},
+			}
+		},
+	)
+}
+
+type historyLsEntry struct {
+	ID         int              `json:"id"`
+	Time       time.Time        `json:"time"`
+	Command    string           `json:"command"`
+	Duration   history.Duration `json:"duration"`
+	ExitStatus int              `json:"exit_status"`
+	Output     bool             `json:"output"`
+}
+
+type historyLsResult []*historyLsEntry
+
+func (r historyLsResult) Header() []string {
+	return []string{"id", "time", "duration", "status", "output", "command"}
+}
+
+func (r historyLsResult) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, e := range r {
+		output := ""
+		if e.Output {
+			output = "yes"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(e.ID),
+			e.Time.Local().Format(time.DateTime),
+			time.Duration(e.Duration).Round(time.Millisecond).String(),
+			strconv.Itoa(e.ExitStatus),
+			output,
+			e.Command,
+		})
+	}
+	return rows
+}
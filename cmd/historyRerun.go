@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+)
+
+func init() {
+	commander.Register(
+		"history>rerun",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "rerun <id|last>",
+				Short: "Run a recorded invocation again",
+				Long: `Run a recorded invocation again, with the same arguments, working directory and configuration.
+
+Relative file paths are relative to the recorded working directory, the current one is left as is.
+The recorded configuration replaces the configuration file, DSAK_* environment variables still apply.
+Global flags given to rerun apply unless the recorded command line sets them too,
+eg: dsak history rerun 42 --format json.
+The rerun exits with the exit status of the command and is not recorded in the history.`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					if len(args) == 0 {
+						return getHistoryEntryCompletion(cmd, toComplete)
+					}
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					store, err := getHistoryStore(cmd)
+					if err != nil {
+						return err
+					}
+					e, err := getHistoryEntry(store, args[0])
+					if err != nil {
+						return err
+					}
+					cfg, err := historyConfig(cmd, e)
+					if err != nil {
+						return err
+					}
+					color.New(color.Faint).Fprintf(cmd.ErrOrStderr(), "# %s\n", e.CommandLine())
+					return historyRun(cmd, e, cfg, commander.WithRunnerOut(cmd.OutOrStdout()))
+				},
+			}
+		},
+	)
+}
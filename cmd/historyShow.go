@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/history"
+)
+
+func init() {
+	commander.Register(
+		"history>show",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "show <id|last>",
+				Short: "Show a recorded invocation",
+				Long: `Show a recorded invocation, with its effective configuration and its recorded output.
+
+JSON/YAML output schema:
+  id: the entry ID (integer)
+  time: the invocation start time (RFC 3339 string)
+  args: the command line arguments (list of strings)
+  command: the command that ran (string)
+  dir: the working directory (string)
+  config_file: the configuration file (string)
+  config: the effective configuration values, by name (map)
+  duration: the invocation duration (string, eg: "1.2s")
+  exit_status: the exit status (integer)
+  error: the error message, if the command failed (string)
+  output: the recorded output, if any (string)
+  output_truncated: true if the output exceeded 1MiB (boolean)`,
+				Args: cobra.ExactArgs(1),
+				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					if len(args) == 0 {
+						return getHistoryEntryCompletion(cmd, toComplete)
+					}
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					store, err := getHistoryStore(cmd)
+					if err != nil {
+						return err
+					}
+					e, err := getHistoryEntry(store, args[0])
+					if err != nil {
+						return err
+					}
+					return renderResult(cmd, &historyShowResult{e})
+				},
+			}
+		},
+	)
+}
+
+type historyShowResult struct {
+	*history.Entry
+}
+
+func (r *historyShowResult) RenderText(w io.Writer) error {
+	name := color.New(color.FgBlue)
+	line := func(label string, value any) {
+		name.Fprintf(w, "%-12s ", label+":")
+		fmt.Fprintln(w, value)
+	}
+	line("ID", r.ID)
+	line("Time", r.Time.Local().Format(time.DateTime))
+	line("Command", r.CommandLine())
+	line("Directory", r.Dir)
+	line("Config file", r.ConfigFile)
+	line("Duration", time.Duration(r.Duration).Round(time.Millisecond))
+	line("Exit status", r.ExitStatus)
+	if r.Error != "" {
+		line("Error", r.Error)
+	}
+	name.Fprintln(w, "Config:")
+	names := make([]string, 0, len(r.Config))
+	for n := range r.Config {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(w, "  %s: %v\n", n, r.Config[n])
+	}
+	if r.Output == nil {
+		return nil
+	}
+	if r.OutputTruncated {
+		name.Fprintln(w, "Output (truncated):")
+	} else {
+		name.Fprintln(w, "Output:")
+	}
+	output := strings.TrimRight(*r.Output, "\n")
+	if output != "" {
+		_, err := fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(output, "\n", "\n  "))
+		return err
+	}
+	return nil
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 const (
@@ -42,50 +45,75 @@ func init() {
 			return &cobra.Command{
 				Use:   "cert [flags] hostname",
 				Short: "Check HTTPS certificate for a given hostname",
-				Args:  cobra.ExactArgs(1),
+				Long: `Check HTTPS certificate for a given hostname.
+
+The certificate is checked on every IP address the hostname resolves to.
+IP addresses without a route are skipped.
+
+The command fails with a validation error (exit code 5) if a certificate is invalid,
+or with a network error (exit code 3) if a server cannot be reached (timeout, exit code 4,
+if the connection timed out).
+
+JSON/YAML output schema, a list of:
+  ip: the server IP address (string)
+  status: "ok", "invalid", "unreachable" or "skipped" (string)
+  errors: the validation or connection errors (list of strings)`,
+				Args: cobra.ExactArgs(1),
 				RunE: func(cmd *cobra.Command, args []string) error {
 					domain, port, ips, err := httpCertResolve(cmd, args[0])
 					if err != nil {
 						return fmt.Errorf("failed to resolve %s: %w", args[0], err)
 					}
-					errs := make(map[*net.IP][]error)
-					countRuns := 0
-					errsMtx := sync.Mutex{}
-					getResult := func(res *httpCertResult) {
-						errsMtx.Lock()
-						errs[&res.ip] = res.errs
-						if res.runned {
-							countRuns++
-						}
-						errsMtx.Unlock()
-					}
+					results := make([]*httpCertResult, len(ips))
 					wg := sync.WaitGroup{}
-					for _, ip := range ips {
+					for i, ip := range ips {
 						wg.Add(1)
-						go func(ip net.IP) {
+						go func(i int, ip net.IP) {
 							defer wg.Done()
-							getResult(httpCertOnIP(cmd, domain, ip, port))
-						}(ip)
+							results[i] = httpCertOnIP(cmd, domain, ip, port)
+						}(i, ip)
 					}
 					wg.Wait()
 
+					res := make(httpCertOutput, 0, len(results))
 					valid := true
-					for ip, err := range errs {
-						ipStr := ip.String()
-						if len(err) == 0 {
-							fmt.Printf("%s: OK\n", ipStr)
-						} else {
-							for _, e := range err {
-								if e != nil {
-									fmt.Printf("%s: %s\n", ipStr, e)
-									valid = false
-								}
+					var connectErr error
+					for _, r := range results {
+						ipRes := &httpCertIPResult{IP: r.ip.String(), Status: httpCertStatusOK}
+						for _, e := range r.errs {
+							if e != nil {
+								ipRes.Errors = append(ipRes.Errors, e.Error())
 							}
 						}
+						switch {
+						case r.unreachable:
+							ipRes.Status = httpCertStatusUnreachable
+							if connectErr == nil {
+								connectErr = r.errs[0]
+							}
+						case len(ipRes.Errors) > 0:
+							ipRes.Status = httpCertStatusInvalid
+							valid = false
+						case !r.runned:
+							ipRes.Status = httpCertStatusSkipped
+						}
+						res = append(res, ipRes)
+					}
+					sort.Slice(res, func(i, j int) bool {
+						return res[i].IP < res[j].IP
+					})
+					if err := renderResult(cmd, res); err != nil {
+						return err
 					}
 					if !valid {
-						cmd.SilenceUsage = true
-						return errors.New("failed to validate certificate")
+						return dsakerr.New(dsakerr.CategoryValidation, "failed to validate certificate")
+					}
+					if connectErr != nil {
+						connectErr = fmt.Errorf("failed to connect to server: %w", connectErr)
+						if dsakerr.CategoryOf(connectErr) != dsakerr.CategoryTimeout {
+							connectErr = dsakerr.Wrap(dsakerr.CategoryNetwork, connectErr)
+						}
+						return connectErr
 					}
 					return nil
 				},
@@ -102,7 +130,7 @@ func httpCertResolve(_ *cobra.Command, arg string) (string, int, []net.IP, error
 		var err error
 		domain, port, err = httpCertResolveURL(arg)
 		if err != nil {
-			return "", 0, nil, fmt.Errorf("invalid URL: %w", err)
+			return "", 0, nil, dsakerr.Errorf(dsakerr.CategoryUsage, "invalid URL: %w", err)
 		}
 	}
 	parts := strings.SplitN(domain, ":", 2)
@@ -110,7 +138,7 @@ func httpCertResolve(_ *cobra.Command, arg string) (string, int, []net.IP, error
 		var err error
 		port, err = strconv.ParseInt(parts[1], 10, 64)
 		if err != nil {
-			return "", 0, nil, fmt.Errorf("invalid port: %w", err)
+			return "", 0, nil, dsakerr.Errorf(dsakerr.CategoryUsage, "invalid port: %w", err)
 		}
 		domain = parts[0]
 	}
@@ -146,17 +174,69 @@ func httpCertResolveURL(uri string) (string, int64, error) {
 	return u.Hostname(), port, nil
 }
 
+const (
+	httpCertStatusOK      = "ok"
+	httpCertStatusInvalid = "invalid"
+	httpCertStatusSkipped = "skipped"
+	// httpCertStatusUnreachable is the status of servers that could not be connected to.
+	httpCertStatusUnreachable = "unreachable"
+)
+
+type httpCertIPResult struct {
+	IP     string   `json:"ip"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type httpCertOutput []*httpCertIPResult
+
+func (r httpCertOutput) RenderText(w io.Writer) error {
+	for _, ipRes := range r {
+		switch ipRes.Status {
+		case httpCertStatusInvalid, httpCertStatusUnreachable:
+			for _, e := range ipRes.Errors {
+				if _, err := fmt.Fprintf(w, "%s: %s\n", ipRes.IP, e); err != nil {
+					return err
+				}
+			}
+		case httpCertStatusOK:
+			if _, err := fmt.Fprintf(w, "%s: OK\n", ipRes.IP); err != nil {
+				return err
+			}
+		case httpCertStatusSkipped:
+			if _, err := fmt.Fprintf(w, "%s: skipped, no route\n", ipRes.IP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r httpCertOutput) Header() []string {
+	return []string{"ip", "status", "errors"}
+}
+
+func (r httpCertOutput) Rows() [][]string {
+	rows := make([][]string, 0, len(r))
+	for _, ipRes := range r {
+		rows = append(rows, []string{ipRes.IP, ipRes.Status, strings.Join(ipRes.Errors, "; ")})
+	}
+	return rows
+}
+
 type httpCertResult struct {
-	errs   []error
-	runned bool
-	ip     net.IP
+	errs        []error
+	runned      bool
+	unreachable bool
+	ip          net.IP
 }
 
 func httpCertOnIPErrResult(err error, ip net.IP, runned bool) *httpCertResult {
 	return &httpCertResult{
-		errs:   []error{err},
-		runned: runned,
-		ip:     ip,
+		errs:        []error{err},
+		runned:      runned,
+		unreachable: true,
+		ip:          ip,
 	}
 }
 
@@ -183,22 +263,31 @@ func httpCertOnIP(cmd *cobra.Command, domain string, ip net.IP, port int) *httpC
 		With(zap.String("domain", domain))
 	logger.Debug("connecting to server")
 
-	conn, err := tls.Dial(
-		"tcp",
-		address,
-		&tls.Config{
+	connectTimeout, _, err := getNetworkTimeouts(cmd)
+	if err != nil {
+		return httpCertOnIPErrResult(err, ip, false)
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: connectTimeout},
+		Config: &tls.Config{
 			InsecureSkipVerify: true, //nolint:gosec
 			ServerName:         domain,
 		},
-	)
+	}
+	conn, err := dialer.DialContext(cmd.Context(), "tcp", address)
+	var netErr net.Error
+	if err != nil && connectTimeout > 0 && cmd.Context().Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+		err = &dsakerr.TimeoutError{Phase: dsakerr.PhaseConnect, Timeout: connectTimeout, Err: err}
+	}
 	if err != nil {
 		return httpCertOnIPErrResult(fmt.Errorf("cannot connect to server or is not an HTTPS server: %w", err), ip, true)
 	}
 	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates //nolint:forcetypeassert
 	return &httpCertResult{
 		runned: true,
 		ip:     ip,
-		errs:   httpCertOnIPCheck(cmd, domain, ip, port, conn.ConnectionState().PeerCertificates, logger),
+		errs:   httpCertOnIPCheck(cmd, domain, ip, port, certs, logger),
 	}
 }
 
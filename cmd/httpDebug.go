@@ -20,7 +20,9 @@ import (
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
 	"github.com/jucrouzet/dsak/internal/pkg/contenttype"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 	"github.com/jucrouzet/dsak/internal/pkg/httpdsak"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
 	"github.com/jucrouzet/dsak/internal/pkg/resource"
 )
 
@@ -38,6 +40,7 @@ const (
 	configKeyHTTPDebugResponseJQ         = "http.debug.response.jq"
 	configKeyHTTPDebugResponseRaw        = "http.debug.response.raw"
 	configKeyHTTPDebugResponseStyle      = "http.debug.response.style"
+	configKeyHTTPDebugTimeout            = "http.debug.timeout"
 	configKeyHTTPDebugTrace              = "http.debug.trace"
 )
 
@@ -168,6 +171,12 @@ func init() { //nolint:gocyclo
 		}),
 	)
 
+	config.RegisterValue(
+		configKeyHTTPDebugTimeout,
+		config.ValueTypeDuration,
+		config.Description("Default total timeout of http debug, 0 for the global timeout"),
+	)
+
 	config.RegisterValue(
 		configKeyHTTPDebugTrace,
 		config.ValueTypeBool,
@@ -182,9 +191,26 @@ func init() { //nolint:gocyclo
 			return &cobra.Command{
 				Use:   "debug [flags] url",
 				Short: "Debug an HTTP url by sending a request and see output",
-				Args:  cobra.ExactArgs(1),
+				Long: `Debug an HTTP url by sending a request and see output.
+
+With the text format, the response body is beautified depending on its content type.
+With other formats, the response is rendered with the schema:
+  url: the requested URL (string)
+  method: the request method (string)
+  protocol: the response protocol, eg: "HTTP/2.0" (string)
+  status: the response status, eg: "200 OK" (string)
+  status_code: the response status code (integer)
+  headers: the response headers (map of lists of strings)
+  body: the parsed body for JSON responses, the body as a string otherwise
+  jq: the list of results of the --jq filter, if given`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationTimeoutConfig: configKeyHTTPDebugTimeout},
 				RunE: func(cmd *cobra.Command, args []string) error {
 					cfg := config.GetFromCommandContext(cmd)
+					connectTimeout, readTimeout, err := getNetworkTimeouts(cmd)
+					if err != nil {
+						return err
+					}
 					body, err := httpDebugGetBody(cmd)
 					if err != nil {
 						return fmt.Errorf("failed to get request body: %w", err)
@@ -196,6 +222,7 @@ func init() { //nolint:gocyclo
 						httpdsak.WithAccept(cfg.GetString(configKeyHTTPDebugRequestAccept)),
 						httpdsak.WithBody(body),
 						httpdsak.WithAccept(cfg.GetString(configKeyHTTPDebugRequestContentType)),
+						httpdsak.WithTimeouts(connectTimeout, readTimeout),
 					}
 					if cfg.GetBool(configKeyHTTPDebugInsecure) {
 						opts = append(opts, httpdsak.WithInsecure())
@@ -209,7 +236,7 @@ func init() { //nolint:gocyclo
 					for _, h := range cfg.GetStringSlice(configKeyHTTPDebugRequestHeader) {
 						parts := strings.SplitN(h, ":", 2)
 						if len(parts) != 2 {
-							return fmt.Errorf("invalid header: %s", h)
+							return dsakerr.Errorf(dsakerr.CategoryUsage, "invalid header: %s", h)
 						}
 						opts = append(opts, httpdsak.WithHeader(parts[0], parts[1]))
 					}
@@ -229,11 +256,19 @@ func init() { //nolint:gocyclo
 					if cfg.GetBool(configKeyHTTPDebugTrace) {
 						opts = append(opts, httpdsak.WithTrace())
 					}
+					format, err := getFormat(cmd)
+					if err != nil {
+						return err
+					}
+					if format != render.FormatText {
+						opts = append(opts, httpdsak.WithResultHandler(func(res *httpdsak.Response) error {
+							return render.Render(cmd.OutOrStdout(), format, res)
+						}))
+					}
 					client, err := httpdsak.NewClient(args[0], opts...)
 					if err != nil {
-						return fmt.Errorf("failed to initialize HTTP client: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "failed to initialize HTTP client: %w", err)
 					}
-					cmd.SilenceUsage = true
 					return client.Run(cmd.Context())
 				},
 			}
@@ -252,6 +287,7 @@ func init() { //nolint:gocyclo
 		commander.WithConfig(configKeyHTTPDebugResponseJQ),
 		commander.WithConfig(configKeyHTTPDebugResponseRaw),
 		commander.WithConfig(configKeyHTTPDebugResponseStyle),
+		commander.WithConfig(configKeyHTTPDebugTimeout),
 		commander.WithConfig(configKeyHTTPDebugTrace),
 
 		commander.WithFlagCompletion(
@@ -2,20 +2,27 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/hako/durafmt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"golang.org/x/term"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
 	"github.com/jucrouzet/dsak/internal/pkg/resource"
 )
 
@@ -25,24 +32,42 @@ const (
 	configKeyGlobalVerbose  = "global.verbose"
 	configKeyGlobalOutput   = "global.output"
 	configKeyGlobalNoColor  = "global.nocolor"
+	configKeyGlobalPlugins  = "global.pluginsdir"
+	configKeyGlobalFormat   = "global.format"
+
+	configKeyGlobalConnectTimeout = "global.connecttimeout"
+	configKeyGlobalReadTimeout    = "global.readtimeout"
 )
 
 func init() {
+	// Colors are removed per command, see colorDisabled.
+	color.NoColor = false
 	config.RegisterValue(
 		configKeyGlobalTimeout,
-		config.ValueTypeUint,
-		config.DefaultValue(uint64(10000)),
+		config.ValueTypeDuration,
+		config.DefaultValue(10*time.Second),
 		config.Flag("timeout"),
 		config.FlagIsPersistent(),
-		config.Description("Timeout for command in milliseconds, 0 for unlimited"),
-		config.Stringer(func(cmd *cobra.Command) string {
-			cfg := config.GetFromCommandContext(cmd)
-			msecs := cfg.GetUint64(configKeyGlobalTimeout)
-			if msecs == 0 {
-				return "unlimited"
-			}
-			return durafmt.Parse(time.Duration(msecs) * time.Millisecond).String()
-		}),
+		config.Description("Total timeout for command, eg: 90s or 1m30s (integers are milliseconds), 0 for unlimited"),
+		config.Stringer(timeoutStringer(configKeyGlobalTimeout)),
+	)
+
+	config.RegisterValue(
+		configKeyGlobalConnectTimeout,
+		config.ValueTypeDuration,
+		config.Flag("connect-timeout"),
+		config.FlagIsPersistent(),
+		config.Description("Timeout for network connections, including TLS handshakes, 0 for the total timeout"),
+		config.Stringer(timeoutStringer(configKeyGlobalConnectTimeout)),
+	)
+
+	config.RegisterValue(
+		configKeyGlobalReadTimeout,
+		config.ValueTypeDuration,
+		config.Flag("read-timeout"),
+		config.FlagIsPersistent(),
+		config.Description("Timeout waiting for data from a server once connected, 0 for the total timeout"),
+		config.Stringer(timeoutStringer(configKeyGlobalReadTimeout)),
 	)
 
 	config.RegisterValue(
@@ -80,6 +105,22 @@ func init() {
 		config.Description("Diable color in output"),
 	)
 
+	config.RegisterValue(
+		configKeyGlobalFormat,
+		config.ValueTypeString,
+		config.DefaultValue(string(render.FormatText)),
+		config.Flag("format"),
+		config.FlagIsPersistent(),
+		config.Description("Output format: "+strings.Join(render.Formats(), ", ")),
+	)
+
+	config.RegisterValue(
+		configKeyGlobalPlugins,
+		config.ValueTypeString,
+		config.DefaultValue("~/.dsak/plugins"),
+		config.Description("Directory where plugins are searched before PATH"),
+	)
+
 	commander.Register(
 		"",
 		func() *cobra.Command {
@@ -94,22 +135,48 @@ All the tools he needs in one cli.
 
 When a command or a flag expects a resource, the resource can be stdin, stdout, stderr, a file or an URL.
 
-You can use dsak command -h to get information about a command or its flags.`,
+Every command result can be rendered with --format as text (default, human readable), json, yaml,
+table, csv or jsonl (JSON lines). JSON, JSON lines and YAML share the same schema, documented in the
+help of each command.
+
+You can use dsak command -h to get information about a command or its flags.
+
+Executables named dsak-<name> found in the plugins directory (see the global.pluginsdir configuration)
+or in PATH are available as dsak <name>. They receive the global configuration as DSAK_* environment variables.
+
+Timeouts:
+  --timeout is the total timeout of a command. Commands can have their own timeout in the
+  configuration (eg: http.debug.timeout, dns.query.timeout), used unless --timeout is given. When
+  only global.timeout is set, in the configuration or the environment, it overrides the default
+  timeout of commands, eg: the 10m of dns wait.
+  Network commands also apply --connect-timeout to connections (dial and TLS handshake) and
+  --read-timeout to the wait for data once connected. Timeout errors report the expired phase.
+
+Exit codes:
+  0  success
+  1  generic error
+  2  usage error: invalid command line, argument or configuration value
+  3  network error: unreachable host, refused connection, ...
+  4  timeout
+  5  validation failure: a check ran and failed, eg: an invalid or expiring certificate
+  6  not found: missing file, domain, configuration value, ...
+A failing plugin exits with its own exit code.
+With --jsonlogs, the error is printed on stderr as a JSON object: {"code", "category", "message", "causes"}.`,
+				Args: cobra.NoArgs,
 
 				PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
 					return errors.Join(
 						loggerInitializer(cmd),
+						formatInitializer(cmd),
 						outputInitializer(cmd),
 						timeoutInitializer(cmd),
 					)
 				},
-				PersistentPostRun: func(cmd *cobra.Command, _ []string) {
-					cancelTimeout(cmd)
-				},
 				RunE: func(cmd *cobra.Command, _ []string) error {
 					return cmd.Usage()
 				},
 				PersistentPostRunE: func(cmd *cobra.Command, _ []string) error {
+					defer cancelTimeout(cmd)
 					closer, ok := cmd.OutOrStdout().(io.Closer)
 					if ok {
 						if err := closer.Close(); err != nil {
@@ -122,12 +189,39 @@ You can use dsak command -h to get information about a command or its flags.`,
 		},
 		commander.WithConfig(configKeyGlobalJSONLogs),
 		commander.WithConfig(configKeyGlobalTimeout),
+		commander.WithConfig(configKeyGlobalConnectTimeout),
+		commander.WithConfig(configKeyGlobalReadTimeout),
 		commander.WithConfig(configKeyGlobalVerbose),
 		commander.WithConfig(configKeyGlobalOutput),
 		commander.WithConfig(configKeyGlobalNoColor),
+		commander.WithConfig(configKeyGlobalPlugins),
+		commander.WithConfig(configKeyGlobalFormat),
+		commander.WithFlagCompletion(configKeyGlobalFormat, getFormatCompletion),
+		commander.WithPlugins(configKeyGlobalPlugins),
+		commander.WithConfig(configKeyHistoryOutput),
+		commander.WithErrorReporter(reportError),
+		commander.WithFinishHook(recordHistory),
 	)
 }
 
+func reportError(cmd *cobra.Command, err error) {
+	var timeoutErr *dsakerr.TimeoutError
+	if errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &timeoutErr) {
+		if timeout, ok := cmd.Context().Value(cmdContextTimeout).(time.Duration); ok {
+			err = &dsakerr.TimeoutError{Phase: dsakerr.PhaseTotal, Timeout: timeout, Err: err}
+		}
+	}
+	if config.GetFromCommandContext(cmd.Root()).GetBool(configKeyGlobalJSONLogs) {
+		b, jsonErr := json.Marshal(dsakerr.NewReport(err))
+		if jsonErr == nil {
+			cmd.PrintErrln(string(b))
+			return
+		}
+	}
+	cmd.PrintErrln(cmd.ErrPrefix(), err.Error())
+	commander.PrintUsageOnError(cmd, err)
+}
+
 type cmdContextLoggerKeyType string
 
 var cmdContextLoggerKey = cmdContextLoggerKeyType("logger")
@@ -178,38 +272,166 @@ func getLoggerFromContext(ctx context.Context) *zap.Logger {
 	return logger
 }
 
+// colorDisabled is true if colors are disabled by the environment, eg: NO_COLOR or TERM=dumb.
+// Colors are always written otherwise, each command removing them from its outputs with a
+// noColorWriter, so that the commands run in-process, possibly concurrently, have their own mode.
+var colorDisabled = color.NoColor
+
 func outputInitializer(cmd *cobra.Command) error {
 	cfg := config.GetFromCommandContext(cmd)
-	if cfg.GetBool(configKeyGlobalNoColor) || !term.IsTerminal(syscall.Stdout) {
-		color.NoColor = true
+	noColor := colorDisabled || cfg.GetBool(configKeyGlobalNoColor)
+	if noColor || !term.IsTerminal(syscall.Stderr) {
+		cmd.SetErr(&noColorWriter{w: cmd.ErrOrStderr()})
 	}
+	noColor = noColor || !term.IsTerminal(syscall.Stdout)
 	switch cfg.GetString(configKeyGlobalOutput) {
 	case "stdout", "stderr":
 	default:
-		color.NoColor = true
+		noColor = true
+	}
+	if cfg.GetString(configKeyGlobalOutput) == "stdout" && cmd.OutOrStdout() != io.Writer(os.Stdout) {
+		// Output has been set by the caller running the command in-process.
+		if noColor {
+			cmd.SetOut(&noColorWriter{w: cmd.OutOrStdout()})
+		}
+		return nil
 	}
 	out, err := resource.New(cmd, cfg.GetString(configKeyGlobalOutput), getLogger(cmd))
 	if err != nil {
 		return fmt.Errorf("cannot create output resource: %w", err)
 	}
 	cmd.SetOut(out)
+	historyCaptureOutput(cmd)
+	if noColor {
+		cmd.SetOut(&noColorWriter{w: cmd.OutOrStdout()})
+	}
 	return nil
 }
 
+// colorEscape matches the ANSI sequences setting colors and styles, cursor moves being kept.
+var colorEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// noColorWriter removes the colors written to an output without colors.
+type noColorWriter struct {
+	w io.Writer
+}
+
+func (w *noColorWriter) Write(p []byte) (int, error) {
+	if _, err := w.w.Write(colorEscape.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the underlying output, if it is a closer.
+func (w *noColorWriter) Close() error {
+	if closer, ok := w.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func formatInitializer(cmd *cobra.Command) error {
+	_, err := getFormat(cmd)
+	return err
+}
+
+func getFormat(cmd *cobra.Command) (render.Format, error) {
+	f, err := render.ParseFormat(config.GetFromCommandContext(cmd).GetString(configKeyGlobalFormat))
+	return f, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+}
+
+func getFormatCompletion(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var list []string
+	for _, f := range render.Formats() {
+		if strings.HasPrefix(f, toComplete) {
+			list = append(list, f)
+		}
+	}
+	return list, cobra.ShellCompDirectiveNoFileComp
+}
+
 type cmdContextTimeoutCancelKeyType string
 
-var cmdContextTimeoutCancel = cmdContextTimeoutCancelKeyType("timeout cancel")
+var (
+	cmdContextTimeoutCancel = cmdContextTimeoutCancelKeyType("timeout cancel")
+	cmdContextTimeout       = cmdContextTimeoutCancelKeyType("timeout")
+)
+
+// annotationNoTimeout is set on commands that run other commands and should not be subject to the timeout,
+// each command they run having its own.
+const annotationNoTimeout = "dsak.notimeout"
+
+// annotationTimeoutConfig is set on commands having their own default timeout, to the name of its
+// duration configuration value. A zero value means the global timeout is used.
+const annotationTimeoutConfig = "dsak.timeoutconfig"
+
+func timeoutStringer(name string) config.StringerFunc {
+	return func(cmd *cobra.Command) string {
+		d, err := config.GetDuration(config.GetFromCommandContext(cmd), name)
+		if err != nil {
+			return err.Error()
+		}
+		if d == 0 {
+			return "unlimited"
+		}
+		return durafmt.Parse(d).String()
+	}
+}
+
+// getCommandTimeout returns the total timeout of cmd, 0 meaning unlimited.
+// The --timeout flag comes first, then the timeout of the command if it is set in the configuration
+// file or the environment, then the global one if it is, then the default timeout of the command.
+func getCommandTimeout(cmd *cobra.Command) (time.Duration, error) {
+	cfg := config.GetFromCommandContext(cmd)
+	if cmd.Annotations[annotationNoTimeout] != "" {
+		return 0, nil
+	}
+	name := cmd.Annotations[annotationTimeoutConfig]
+	if name != "" && !cmd.Flags().Changed("timeout") && (isValueSet(cfg, name) || !isValueSet(cfg, configKeyGlobalTimeout)) {
+		d, err := config.GetDuration(cfg, name)
+		if err != nil {
+			return 0, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+		}
+		if d > 0 {
+			return d, nil
+		}
+	}
+	d, err := config.GetDuration(cfg, configKeyGlobalTimeout)
+	return d, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+}
+
+// isValueSet reports whether the configuration value name is set in the configuration file or the environment.
+func isValueSet(cfg *viper.Viper, name string) bool {
+	v, err := config.GetValue(name)
+	return err == nil && v.IsSet(cfg)
+}
 
-func timeoutInitializer(cmd *cobra.Command) error { //nolint:unparam
+// getNetworkTimeouts returns the connect and read timeouts of network commands, 0 meaning
+// they are only bounded by the total timeout.
+func getNetworkTimeouts(cmd *cobra.Command) (time.Duration, time.Duration, error) {
 	cfg := config.GetFromCommandContext(cmd)
-	timeoutMS := cfg.GetUint16(configKeyGlobalTimeout)
-	var timeout time.Duration
-	if timeoutMS == 0 {
+	connect, err := config.GetDuration(cfg, configKeyGlobalConnectTimeout)
+	if err != nil {
+		return 0, 0, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+	}
+	read, err := config.GetDuration(cfg, configKeyGlobalReadTimeout)
+	if err != nil {
+		return 0, 0, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+	}
+	return connect, read, nil
+}
+
+func timeoutInitializer(cmd *cobra.Command) error {
+	timeout, err := getCommandTimeout(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(cmd.Context(), cmdContextTimeout, timeout)
+	if timeout == 0 {
 		timeout = 86400 * time.Hour
-	} else {
-		timeout = time.Duration(timeoutMS) * time.Millisecond
 	}
-	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = context.WithValue(ctx, cmdContextTimeoutCancel, cancel)
 	cmd.SetContext(ctx)
 	return nil
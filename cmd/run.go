@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+	"github.com/jucrouzet/dsak/internal/pkg/script"
+)
+
+const (
+	configKeyRunJUnit = "run.junit"
+	configKeyRunVars  = "run.vars"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyRunJUnit,
+		config.ValueTypeString,
+		config.Flag("junit"),
+		config.Description("Write a JUnit XML report to this resource"),
+	)
+	config.RegisterValue(
+		configKeyRunVars,
+		config.ValueTypeStrings,
+		config.Flag("var"),
+		config.ShortFlag('v'),
+		config.Description("Set a script variable, as name=value, overriding the script value"),
+	)
+
+	commander.Register(
+		"run",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "run [flags] script",
+				Short: "Run a dsak script",
+				Long: `Run a dsak script.
+
+A script is a YAML resource listing dsak commands to run, eg:
+
+  name: smoke checks
+  vars:
+    host: example.com
+  steps:
+    - id: health
+      name: health endpoint is ok
+      run: http debug --raw-response https://${host}/health
+      assert:
+        - status: 0
+        - jq: .ok == true
+    - name: get the origin
+      run: http debug --raw-response --jq .origin https://${host}/ip
+      capture:
+        - var: origin
+          jq: .
+    - name: trace the health request on failure
+      when: failure
+      if: .steps.health.status != "passed"
+      run: http debug --trace https://${host}/health
+
+Steps fields:
+- run: the command line, without the dsak prefix, $name and ${name} being replaced by variables,
+- id: an identifier for the step, defaults to step1, step2, ...
+- name: a human readable name for reports,
+- when: "success" (default) runs the step if no previous step failed, "failure" only if one failed, "always",
+- if: a jq expression on the script state {vars, steps: {id: {status, exit_status, output}}, failed},
+  the step is skipped if it is false or null,
+- continue_on_error: a failure of the step does not fail the script,
+- capture: list of variables to set from the output, with a jq expression (output parsed as JSON),
+  a regex (first group is captured) or the whole output,
+- assert: list of assertions, each one of:
+  status (exit status), contains, not_contains, matches (regex) or jq (output parsed as JSON must be true).
+  Unless a status assertion is given, the command must succeed.
+
+Steps run with the global flags of the run command, so --format also applies to their outputs.
+With the text format, each step result is printed once run. Other formats print the script result
+once every step has run, with the schema:
+  name: the script name (string)
+  duration: the script duration, in nanoseconds (integer)
+  steps: list of step results
+    id, name, status ("passed", "failed", "skipped" or "error"), output (strings)
+    args: the command line arguments (list of strings)
+    continue_on_error: true if the step is allowed to fail (boolean, omitted if false)
+    duration: the step duration, in nanoseconds (integer)
+    exit_status: the exit status of the command (integer)
+    failures: the failed assertions (list of strings)`,
+				Args:        cobra.ExactArgs(1),
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					s, err := runLoadScript(cmd, args[0])
+					if err != nil {
+						return err
+					}
+					vars, err := runParseVars(cfg.GetStringSlice(configKeyRunVars))
+					if err != nil {
+						return err
+					}
+					// Steps run in-process with the same configuration, values are read before they run.
+					junit := cfg.GetString(configKeyRunJUnit)
+					format, err := getFormat(cmd)
+					if err != nil {
+						return err
+					}
+					out := cmd.OutOrStdout()
+					opts := []script.Option{script.WithVars(vars), script.WithStatus(dsakerr.ExitCode)}
+					if format == render.FormatText {
+						opts = append(opts, script.WithReporter(func(sr *script.StepResult) {
+							runReportStep(out, sr)
+						}))
+					}
+					runner := script.NewRunner(
+						func(ctx context.Context, args []string, stepOut io.Writer) error {
+							return runInProcess(ctx, cmd, args, stepOut)
+						},
+						opts...,
+					)
+					res := runner.Run(cmd.Context(), s)
+					if format != render.FormatText {
+						if err := render.Render(out, format, &runResult{res}); err != nil {
+							return err
+						}
+					}
+					if junit != "" {
+						if err := runWriteJUnit(cmd, junit, res); err != nil {
+							return err
+						}
+					}
+					if res.Failed() {
+						return dsakerr.New(dsakerr.CategoryValidation, "script failed")
+					}
+					return nil
+				},
+			}
+		},
+		commander.WithConfig(configKeyRunJUnit),
+		commander.WithConfig(configKeyRunVars),
+	)
+}
+
+func runLoadScript(cmd *cobra.Command, name string) (*script.Script, error) {
+	in, err := resource.New(cmd, name, getLogger(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open script: %w", err)
+	}
+	defer in.Close()
+	s, err := script.Load(in)
+	return s, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+}
+
+func runParseVars(list []string) (map[string]string, error) {
+	vars := make(map[string]string, len(list))
+	for _, v := range list {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "invalid variable, expected name=value: %s", v)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+func runReportStep(out io.Writer, sr *script.StepResult) {
+	var status *color.Color
+	var label string
+	switch sr.Status {
+	case script.StepPassed:
+		status, label = color.New(color.Bold, color.FgGreen), "PASS "
+	case script.StepFailed:
+		status, label = color.New(color.Bold, color.FgRed), "FAIL "
+	case script.StepError:
+		status, label = color.New(color.Bold, color.FgRed), "ERROR"
+	case script.StepSkipped:
+		status, label = color.New(color.FgYellow), "SKIP "
+	}
+	status.Fprint(out, label)
+	fmt.Fprintf(out, " %s", sr.Name)
+	if sr.Status != script.StepSkipped {
+		color.New(color.FgBlue).Fprintf(out, " (%s)", sr.Duration.Round(time.Microsecond))
+	}
+	fmt.Fprintln(out)
+	for _, f := range sr.Failures {
+		color.New(color.FgRed).Fprintf(out, "       - %s\n", f)
+	}
+	if (sr.Status == script.StepFailed || sr.Status == script.StepError) && sr.Output != "" {
+		output := strings.TrimRight(sr.Output, "\n")
+		fmt.Fprintf(out, "       %s\n", strings.ReplaceAll(output, "\n", "\n       "))
+	}
+}
+
+type runResult struct {
+	*script.Result
+}
+
+func (r *runResult) Header() []string {
+	return []string{"id", "name", "status", "exit_status", "duration", "failures"}
+}
+
+func (r *runResult) Rows() [][]string {
+	rows := make([][]string, 0, len(r.Steps))
+	for _, sr := range r.Steps {
+		rows = append(rows, []string{
+			sr.ID,
+			sr.Name,
+			string(sr.Status),
+			strconv.Itoa(sr.ExitStatus),
+			sr.Duration.Round(time.Microsecond).String(),
+			strings.Join(sr.Failures, "; "),
+		})
+	}
+	return rows
+}
+
+func runWriteJUnit(cmd *cobra.Command, name string, res *script.Result) error {
+	w, err := resource.New(cmd, name, getLogger(cmd))
+	if err != nil {
+		return fmt.Errorf("cannot create JUnit report resource: %w", err)
+	}
+	if err := script.WriteJUnit(w, res); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+)
+
+const (
+	configKeyShellHistoryFile = "shell.historyfile"
+	configKeyShellHistorySize = "shell.historysize"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyShellHistoryFile,
+		config.ValueTypeString,
+		config.DefaultValue("~/.dsak_history"),
+		config.Description("File where the shell history is persisted, empty to disable history persistence"),
+	)
+	config.RegisterValue(
+		configKeyShellHistorySize,
+		config.ValueTypeUint,
+		config.DefaultValue(uint64(1000)),
+		config.Description("Number of lines kept in the shell history"),
+	)
+
+	commander.Register(
+		"shell",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "shell",
+				Short: "Run an interactive dsak shell",
+				Long: `Run an interactive dsak shell.
+
+Commands are typed without the dsak prefix, eg: dns query example.com
+The configuration is loaded once and global flags given to the shell apply to every command.
+
+Arguments are split like a POSIX shell does, with single and double quotes.
+Variables are expanded with $name or ${name}:
+- $_ is the output of the previous command,
+- $? is the exit status of the previous command,
+- environment variables are available too.
+
+Builtin commands:
+- set name [value...] sets a variable to the given value or to the output of the previous command,
+- unset name... removes variables,
+- vars lists variables,
+- exit or quit (or ctrl+d) leaves the shell.`,
+				Args:        cobra.NoArgs,
+				Annotations: map[string]string{annotationNoTimeout: "true", annotationNoHistory: "true"},
+				RunE: func(cmd *cobra.Command, _ []string) error {
+					cfg := config.GetFromCommandContext(cmd)
+					sh, err := repl.New(
+						func(ctx context.Context, args []string, out io.Writer) error {
+							return runInProcess(ctx, cmd, args, out)
+						},
+						repl.WithCompleter(func(ctx context.Context, args []string, toComplete string) ([]string, error) {
+							r := commander.NewRunner(commander.WithRunnerConfig(cfg))
+							list, _, err := r.Complete(ctx, append(globalArgs(cmd), args...), toComplete)
+							return list, err
+						}),
+						repl.WithHistoryFile(config.ExpandHome(cfg.GetString(configKeyShellHistoryFile))),
+						repl.WithHistorySize(int(cfg.GetUint64(configKeyShellHistorySize))),
+						repl.WithStatus(dsakerr.ExitCode),
+					)
+					if err != nil {
+						return fmt.Errorf("failed to initialize shell: %w", err)
+					}
+					return sh.Run(cmd.Context())
+				},
+			}
+		},
+		commander.WithConfig(configKeyShellHistoryFile),
+		commander.WithConfig(configKeyShellHistorySize),
+	)
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 const (
@@ -42,8 +45,8 @@ func init() {
 		configKeyTimestampFormat,
 		config.ValueTypeString,
 		config.DefaultValue("2006-01-02 15:04:05.000"),
-		config.Flag("format"),
-		config.ShortFlag('f'),
+		config.Flag("layout"),
+		config.ShortFlag('l'),
 		config.FlagIsPersistent(),
 		config.Description("Use this golang time.Format layout string"),
 	)
@@ -59,8 +62,15 @@ func init() {
 The --msecs flag tells that timestamp values are interpreted as milliseconds since epoch.
 The --timezone flag tells to use a timezone other than the machine's default.
 	Timezones flag has completion.
-The --format flag tells to use a golang time.Format layout string for date results and parsing.
-	See https://go.dev/src/time/format.go`,
+The --layout flag tells to use a golang time.Format layout string for date results and parsing.
+	See https://go.dev/src/time/format.go
+
+JSON/YAML output schema of subcommands:
+  timestamp: the timestamp (integer)
+  unit: "s" or "ms" (string)
+  date: the date formatted with --layout (string)
+  rfc3339: the date in RFC3339 format (string)
+  timezone: the timezone of the date (string)`,
 				RunE: func(cmd *cobra.Command, args []string) error {
 					return cmd.Usage()
 				},
@@ -122,6 +132,66 @@ The --format flag tells to use a golang time.Format layout string for date resul
 	)
 }
 
+// getTimestampLocation returns the timezone asked with --timezone.
+func getTimestampLocation(cmd *cobra.Command) (*time.Location, error) {
+	loc := time.Local
+	askedTZ := config.GetFromCommandContext(cmd).GetString(configKeyTimestampTimezone)
+	if askedTZ == "" || askedTZ == loc.String() {
+		return loc, nil
+	}
+	for _, v := range tz.Zones {
+		if strings.EqualFold(v.Zone, askedTZ) && v.Location != nil {
+			return v.Location, nil
+		}
+	}
+	return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "unhandled timezone: %s", askedTZ)
+}
+
+type timestampResult struct {
+	Timestamp int64  `json:"timestamp"`
+	Unit      string `json:"unit"`
+	Date      string `json:"date"`
+	RFC3339   string `json:"rfc3339"`
+	Timezone  string `json:"timezone"`
+
+	// dateAsText renders the date instead of the timestamp with the text format.
+	dateAsText bool
+}
+
+func newTimestampResult(cmd *cobra.Command, t time.Time, dateAsText bool) *timestampResult {
+	cfg := config.GetFromCommandContext(cmd)
+	res := &timestampResult{
+		Timestamp:  t.Unix(),
+		Unit:       "s",
+		Date:       t.Format(cfg.GetString(configKeyTimestampFormat)),
+		RFC3339:    t.Format(time.RFC3339Nano),
+		Timezone:   t.Location().String(),
+		dateAsText: dateAsText,
+	}
+	if cfg.GetBool(configKeyTimestampMSecs) {
+		res.Timestamp = t.UnixMilli()
+		res.Unit = "ms"
+	}
+	return res
+}
+
+func (r *timestampResult) RenderText(w io.Writer) error {
+	if r.dateAsText {
+		_, err := fmt.Fprintln(w, r.Date)
+		return err
+	}
+	_, err := fmt.Fprintln(w, r.Timestamp)
+	return err
+}
+
+func (r *timestampResult) Header() []string {
+	return []string{"timestamp", "unit", "date", "rfc3339", "timezone"}
+}
+
+func (r *timestampResult) Rows() [][]string {
+	return [][]string{{strconv.FormatInt(r.Timestamp, 10), r.Unit, r.Date, r.RFC3339, r.Timezone}}
+}
+
 func init() {
 	for _, z := range tz.Zones {
 		var err error
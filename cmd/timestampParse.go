@@ -1,16 +1,14 @@
 package cmd
 
 import (
-	"fmt"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"zgo.at/tz"
 
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 func init() {
@@ -34,7 +32,7 @@ If - is specified as value, value is read from stdin.`,
 					}
 					ts, err := strconv.ParseInt(arg, 10, 64)
 					if err != nil {
-						return fmt.Errorf("failed to parse timestamp value: %w", err)
+						return dsakerr.Errorf(dsakerr.CategoryUsage, "failed to parse timestamp value: %w", err)
 					}
 					cfg := config.GetFromCommandContext(cmd)
 					var t time.Time
@@ -43,23 +41,11 @@ If - is specified as value, value is read from stdin.`,
 					} else {
 						t = time.Unix(ts, 0)
 					}
-					loc := time.Local
-					askedTZ := cfg.GetString(configKeyTimestampTimezone)
-					if askedTZ != "" && askedTZ != loc.String() {
-						var found *time.Location
-						for _, v := range tz.Zones {
-							if strings.EqualFold(v.Zone, askedTZ) {
-								found = v.Location
-								break
-							}
-						}
-						if found == nil {
-							return fmt.Errorf("unhandled timezone: %s", askedTZ)
-						}
-						loc = found
+					loc, err := getTimestampLocation(cmd)
+					if err != nil {
+						return err
 					}
-					fmt.Println(t.In(loc).Format(cfg.GetString(configKeyTimestampFormat)))
-					return nil
+					return renderResult(cmd, newTimestampResult(cmd, t.In(loc), true))
 				},
 			}
 		},
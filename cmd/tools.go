@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/render"
 )
 
 func getStdinOrValue(cmd *cobra.Command, v string, trimLines ...bool) (string, error) {
@@ -21,3 +27,45 @@ func getStdinOrValue(cmd *cobra.Command, v string, trimLines ...bool) (string, e
 	}
 	return v, nil
 }
+
+// globalArgs returns the global flags given to a command, to be given to the commands it runs in-process.
+func globalArgs(cmd *cobra.Command) []string {
+	var args []string
+	// Inherited flags are a copy of the parents persistent flags, Visit would not see the ones set.
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+		}
+	})
+	return args
+}
+
+// runInProcess runs a dsak command line in-process, with the configuration and global flags of cmd.
+// The command line is not recorded in the history.
+func runInProcess(ctx context.Context, cmd *cobra.Command, args []string, out io.Writer, opts ...commander.RunnerOption) error {
+	ctx = context.WithValue(ctx, cmdContextNested, true)
+	r := commander.NewRunner(append([]commander.RunnerOption{
+		commander.WithRunnerConfig(config.GetFromCommandContext(cmd)),
+		commander.WithRunnerOut(out),
+	}, opts...)...)
+	return r.Run(ctx, append(globalArgs(cmd), args...))
+}
+
+// renderResult writes a command result to its output in the format given by --format.
+func renderResult(cmd *cobra.Command, v any) error {
+	format, err := getFormat(cmd)
+	if err != nil {
+		return err
+	}
+	return render.Render(cmd.OutOrStdout(), format, v)
+}
+
+// renderResultWithoutCancel writes a command result like renderResult, even once the command
+// context is done: the output resource writes with the command context, done once the timeout
+// expired or the command was interrupted, and the partial result of such commands is still written.
+func renderResultWithoutCancel(cmd *cobra.Command, v any) error {
+	if cmd.Context().Err() != nil {
+		cmd.SetContext(context.WithoutCancel(cmd.Context()))
+	}
+	return renderResult(cmd, v)
+}
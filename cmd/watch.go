@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/history"
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+	"github.com/jucrouzet/dsak/internal/pkg/script"
+)
+
+const (
+	configKeyWatchInterval     = "watch.interval"
+	configKeyWatchUntil        = "watch.until"
+	configKeyWatchExitOnChange = "watch.exitonchange"
+	configKeyWatchMaxRuns      = "watch.maxruns"
+)
+
+func init() {
+	config.RegisterValue(
+		configKeyWatchInterval,
+		config.ValueTypeDuration,
+		config.DefaultValue(2*time.Second),
+		config.Flag("interval"),
+		config.ShortFlag('n'),
+		config.Description("Interval between the starts of two runs"),
+	)
+	config.RegisterValue(
+		configKeyWatchUntil,
+		config.ValueTypeStrings,
+		config.Flag("until"),
+		config.Description("Stop once this condition holds: status:<code>, contains:<text>, not_contains:<text>, matches:<regex> or jq:<expression>"),
+	)
+	config.RegisterValue(
+		configKeyWatchExitOnChange,
+		config.ValueTypeBool,
+		config.Flag("exit-on-change"),
+		config.Description("Stop once the output or the exit status changes"),
+	)
+	config.RegisterValue(
+		configKeyWatchMaxRuns,
+		config.ValueTypeUint,
+		config.Flag("max-runs"),
+		config.Description("Stop after this number of runs, 0 for no limit"),
+	)
+
+	commander.Register(
+		"watch",
+		func() *cobra.Command {
+			return &cobra.Command{
+				Use:   "watch [flags] -- command [args...]",
+				Short: "Run a dsak command periodically, showing its output",
+				Long: `Run a dsak command periodically, showing its output.
+
+The command runs in-process with the global flags of watch. When the output is a terminal, the screen is
+cleared and redrawn on each run and the lines that changed since the previous run are highlighted.
+Otherwise each run output is appended, after a header line.
+
+Watching stops when every --until condition holds, or when the output or the exit status changes
+with --exit-on-change. Conditions are written kind:value:
+- status:<code> the exit status of the command is <code>,
+- contains:<text> / not_contains:<text> the output contains / does not contain <text>,
+- matches:<regex> the output matches the regular expression,
+- jq:<expression> the jq expression applied to the output parsed as JSON is true, eg: with --format json.
+
+With --max-runs, watch fails if a condition is given and is still not met after the last run.
+
+Example:
+  dsak watch -n 5s --until 'contains:203.0.113.7' -- dns query example.com`,
+				Args:        cobra.MinimumNArgs(1),
+				Annotations: map[string]string{annotationNoTimeout: "true"},
+				ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					r := commander.NewRunner(commander.WithRunnerConfig(config.GetFromCommandContext(cmd)))
+					list, directive, err := r.Complete(cmd.Context(), args, toComplete)
+					if err != nil {
+						return nil, cobra.ShellCompDirectiveError
+					}
+					return list, directive
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					w, err := newWatcher(cmd)
+					if err != nil {
+						return err
+					}
+					return w.run(cmd, args)
+				},
+			}
+		},
+		commander.WithConfig(configKeyWatchInterval),
+		commander.WithConfig(configKeyWatchUntil),
+		commander.WithConfig(configKeyWatchExitOnChange),
+		commander.WithConfig(configKeyWatchMaxRuns),
+	)
+}
+
+type watcher struct {
+	clear        bool
+	exitOnChange bool
+	interval     time.Duration
+	maxRuns      uint64
+	until        []*script.Assertion
+}
+
+// newWatcher reads the watch configuration, before the watched command rebinds the flags.
+func newWatcher(cmd *cobra.Command) (*watcher, error) {
+	cfg := config.GetFromCommandContext(cmd)
+	interval, err := config.GetDuration(cfg, configKeyWatchInterval)
+	if err != nil {
+		return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+	}
+	if interval <= 0 {
+		return nil, dsakerr.New(dsakerr.CategoryUsage, "interval must be positive")
+	}
+	w := &watcher{
+		clear:        cfg.GetString(configKeyGlobalOutput) == "stdout" && term.IsTerminal(syscall.Stdout),
+		exitOnChange: cfg.GetBool(configKeyWatchExitOnChange),
+		interval:     interval,
+		maxRuns:      cfg.GetUint64(configKeyWatchMaxRuns),
+	}
+	for _, s := range cfg.GetStringSlice(configKeyWatchUntil) {
+		a, err := script.ParseAssertion(s)
+		if err != nil {
+			return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+		}
+		w.until = append(w.until, a)
+	}
+	return w, nil
+}
+
+func (w *watcher) run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	out := cmd.OutOrStdout()
+	var previous string
+	previousStatus := -1
+	for run := uint64(1); ; run++ {
+		start := time.Now()
+		buf := &bytes.Buffer{}
+		err := runInProcess(ctx, cmd, args, buf, commander.WithRunnerErr(buf))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		status := dsakerr.ExitCode(err)
+		output := buf.String()
+		stripped := repl.StripANSI(output)
+		if err := w.draw(out, args, run, status, output, stripped, previous, run == 1); err != nil {
+			return err
+		}
+		if reason := w.stopReason(cmd, run, stripped, status, previous, previousStatus); reason != "" {
+			color.New(color.Faint).Fprintf(cmd.ErrOrStderr(), "%s on run %d\n", reason, run)
+			return nil
+		}
+		if w.maxRuns > 0 && run >= w.maxRuns {
+			if len(w.until) > 0 || w.exitOnChange {
+				return dsakerr.Errorf(dsakerr.CategoryValidation, "conditions not met after %d runs", run)
+			}
+			return nil
+		}
+		previous, previousStatus = stripped, status
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(start.Add(w.interval))):
+		}
+	}
+}
+
+// stopReason returns why watching should stop after a run, an empty string if it should go on.
+func (w *watcher) stopReason(cmd *cobra.Command, run uint64, output string, status int, previous string, previousStatus int) string {
+	if w.exitOnChange && run > 1 && (output != previous || status != previousStatus) {
+		return "output changed"
+	}
+	if len(w.until) == 0 {
+		return ""
+	}
+	for _, a := range w.until {
+		if a.Check(cmd.Context(), output, status) != "" {
+			return ""
+		}
+	}
+	return "conditions met"
+}
+
+func (w *watcher) draw(out io.Writer, args []string, run uint64, status int, output, stripped, previous string, first bool) error {
+	buf := &bytes.Buffer{}
+	if w.clear {
+		buf.WriteString("\033[H\033[2J")
+	} else if !first {
+		buf.WriteString("\n")
+	}
+	color.New(color.Bold).Fprintf(buf, "Every %s: dsak %s", w.interval, history.QuoteArgs(args))
+	statusColor := color.New(color.FgGreen)
+	if status != 0 {
+		statusColor = color.New(color.FgRed)
+	}
+	fmt.Fprintf(buf, "  #%d %s ", run, time.Now().Format(time.TimeOnly))
+	statusColor.Fprintf(buf, "exit status %d", status)
+	buf.WriteString("\n\n")
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	strippedLines := strings.Split(strings.TrimSuffix(stripped, "\n"), "\n")
+	changed := make([]bool, len(strippedLines))
+	if !first {
+		i := 0
+		for _, l := range history.Diff(previous, stripped) {
+			switch l.Op {
+			case history.DiffAdded:
+				changed[i] = true
+				i++
+			case history.DiffEqual:
+				i++
+			case history.DiffRemoved:
+			}
+		}
+	}
+	highlight := color.New(color.ReverseVideo)
+	for i, l := range lines {
+		if i < len(changed) && changed[i] {
+			highlight.Fprint(buf, strippedLines[i])
+			buf.WriteString("\n")
+			continue
+		}
+		buf.WriteString(l + "\n")
+	}
+	_, err := out.Write(buf.Bytes())
+	return err
+}
@@ -3,51 +3,47 @@ package commander
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
+// Run runs the command line given by args.
 func Run(args []string, forCommands ...map[string]registeredCommand) error {
-	var list map[string]registeredCommand
+	r := NewRunner()
 	if len(forCommands) > 0 {
-		list = forCommands[0]
-	} else {
-		list = commandsRegistered
+		r.commands = forCommands[0]
 	}
-	rootCmd, cmds, err := buildCommandTree(list)
-	if err != nil {
-		return fmt.Errorf("failed building command tree: %w", err)
-	}
-	rootCmd.SetContext(context.Background())
-	cfg, err := config.New(args)
-	if err != nil {
-		return fmt.Errorf("failed initializing configuration: %w", err)
-	}
-	config.SetCommandContext(rootCmd, cfg)
-	if err := applyConfigs(list, cmds, cfg); err != nil {
-		return fmt.Errorf("failed applying config to command tree: %w", err)
-	}
-	if err := applyFlagCompletion(list, cmds); err != nil {
-		return fmt.Errorf("failed applying flag completion to command tree: %w", err)
-	}
-	rootCmd.SetArgs(args)
-	return rootCmd.Execute()
+	return r.Run(context.Background(), args)
 }
 
 // CommandFlagCompletionFunc is the type of the completion function for a flag.
 type CommandFlagCompletionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
 
+// ErrorReporterFunc is the type of the function reporting the error a command line failed with.
+type ErrorReporterFunc func(cmd *cobra.Command, err error)
+
+// FinishHookFunc is the type of the function called once a command line has run, with the command
+// that ran, the command line, its duration and the error it failed with.
+type FinishHookFunc func(cmd *cobra.Command, args []string, duration time.Duration, err error)
+
 type registeredCommand struct {
-	creator       CommandCreator
-	configs       []string
-	flagCompleter map[string]CommandFlagCompletionFunc
+	creator          CommandCreator
+	configs          []string
+	errorReporter    ErrorReporterFunc
+	finishHook       FinishHookFunc
+	flagCompleter    map[string]CommandFlagCompletionFunc
+	pluginsDirConfig string
+	plugins          bool
 }
 
 var commandsRegistered = make(map[string]registeredCommand)
@@ -108,6 +104,37 @@ func WithFlagCompletion(configName string, f CommandFlagCompletionFunc) CommandO
 	}
 }
 
+// WithPlugins enables the discovery of external plugin commands as children of the command.
+// Plugins are searched in the directory set by the configuration value `configName`, then in PATH.
+func WithPlugins(configName string) CommandOption {
+	return func(c *registeredCommand) error {
+		if c.plugins {
+			return errors.New("plugins already enabled")
+		}
+		c.plugins = true
+		c.pluginsDirConfig = configName
+		return nil
+	}
+}
+
+// WithErrorReporter sets the function reporting the error a command line failed with.
+// It is only used for the root command, the default reporter prints the error like cobra does.
+func WithErrorReporter(f ErrorReporterFunc) CommandOption {
+	return func(c *registeredCommand) error {
+		c.errorReporter = f
+		return nil
+	}
+}
+
+// WithFinishHook sets a function called once a command line has run, whether it failed or not.
+// It is only used for the root command.
+func WithFinishHook(f FinishHookFunc) CommandOption {
+	return func(c *registeredCommand) error {
+		c.finishHook = f
+		return nil
+	}
+}
+
 func buildCommandTree(commands map[string]registeredCommand) (*cobra.Command, map[string]*cobra.Command, error) {
 	rootCreator, ok := commands[""]
 	if !ok {
@@ -189,3 +216,19 @@ func applyFlagCompletion(
 	}
 	return nil
 }
+
+// applyUsageErrors makes flags and arguments validation errors usage errors.
+func applyUsageErrors(rootCmd *cobra.Command, builtCommands map[string]*cobra.Command) {
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return dsakerr.Wrap(dsakerr.CategoryUsage, err)
+	})
+	for _, cmd := range builtCommands {
+		if cmd.Args == nil {
+			continue
+		}
+		validate := cmd.Args
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			return dsakerr.Wrap(dsakerr.CategoryUsage, validate(cmd, args))
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package commander //nolint:testpackage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -10,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 func Test_buildCommandTree(t *testing.T) {
@@ -263,3 +269,65 @@ func Test_applyConfigs(t *testing.T) {
 		assert.ErrorContains(t, applyConfigs(list, cmds, viper.New()), "value dsqdsqsqdsq not found")
 	})
 }
+
+func Test_applyUsageErrors(t *testing.T) {
+	list := map[string]registeredCommand{
+		"": {
+			creator: func() *cobra.Command {
+				return &cobra.Command{Use: "root", Args: cobra.NoArgs, RunE: func(*cobra.Command, []string) error { return nil }}
+			},
+		},
+		"a": {
+			creator: func() *cobra.Command {
+				return &cobra.Command{
+					Use:  "a",
+					Args: cobra.ExactArgs(1),
+					RunE: func(*cobra.Command, []string) error { return errors.New("failed") },
+				}
+			},
+		},
+	}
+	r := NewRunner(WithRunnerConfig(viper.New()), WithRunnerErr(io.Discard), WithRunnerOut(io.Discard))
+	r.commands = list
+	for _, args := range [][]string{{"unknown"}, {"a"}, {"a", "--unknown", "x"}} {
+		err := r.Run(context.Background(), args)
+		require.Error(t, err)
+		assert.Equal(t, dsakerr.CategoryUsage, dsakerr.CategoryOf(err), "args: %v", args)
+	}
+	err := r.Run(context.Background(), []string{"a", "x"})
+	require.Error(t, err)
+	assert.Equal(t, dsakerr.CategoryGeneric, dsakerr.CategoryOf(err))
+}
+
+func TestRunner_Run_finishHook(t *testing.T) {
+	var reported []error
+	var finished []string
+	list := map[string]registeredCommand{
+		"": {
+			creator: func() *cobra.Command {
+				return &cobra.Command{Use: "root", Args: cobra.NoArgs, RunE: func(*cobra.Command, []string) error { return nil }}
+			},
+			errorReporter: func(_ *cobra.Command, err error) {
+				reported = append(reported, err)
+			},
+			finishHook: func(cmd *cobra.Command, args []string, _ time.Duration, err error) {
+				finished = append(finished, fmt.Sprintf("%s %v %v", cmd.Name(), args, err))
+			},
+		},
+		"a": {
+			creator: func() *cobra.Command {
+				return &cobra.Command{Use: "a", RunE: func(*cobra.Command, []string) error { return errors.New("failed") }}
+			},
+		},
+	}
+	r := NewRunner(WithRunnerConfig(viper.New()), WithRunnerErr(io.Discard), WithRunnerOut(io.Discard))
+	r.commands = list
+	require.NoError(t, r.Run(context.Background(), nil))
+	err := r.Run(context.Background(), []string{"a", "x"})
+	require.Error(t, err)
+	assert.True(t, IsReported(err))
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, []string{"root [] <nil>", "a [a x] failed"}, finished)
+	require.Len(t, reported, 1)
+	assert.False(t, IsReported(reported[0]))
+}
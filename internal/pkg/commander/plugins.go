@@ -0,0 +1,265 @@
+package commander
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+)
+
+const (
+	pluginPrefix = "dsak-"
+	// pluginAnnotation is the annotation of plugin commands, holding the plugin path.
+	pluginAnnotation = "commander.plugin"
+)
+
+type plugin struct {
+	name string
+	path string
+}
+
+func applyPlugins(
+	commands map[string]registeredCommand,
+	builtCommands map[string]*cobra.Command,
+	cfg *viper.Viper,
+) {
+	for name, cmd := range builtCommands {
+		v := commands[name]
+		if !v.plugins {
+			continue
+		}
+		var dirs []string
+		if v.pluginsDirConfig != "" {
+			if dir := config.ExpandHome(cfg.GetString(v.pluginsDirConfig)); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+		dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+		for _, p := range discoverPlugins(pluginPrefixFor(name), dirs) {
+			if hasSubCommand(cmd, p.name) {
+				continue
+			}
+			cmd.AddCommand(newPluginCommand(commands, p))
+		}
+	}
+}
+
+func pluginPrefixFor(name string) string {
+	if name == "" {
+		return pluginPrefix
+	}
+	return pluginPrefix + strings.ReplaceAll(name, ">", "-") + "-"
+}
+
+// discoverPlugins lists the executables whose name starts with prefix in dirs.
+// When a plugin is found in several directories, the first one wins.
+func discoverPlugins(prefix string, dirs []string) []plugin {
+	var list []plugin
+	seen := make(map[string]struct{})
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(prefix, entry.Name())
+			if !ok {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = struct{}{}
+			list = append(list, plugin{name: name, path: path})
+		}
+	}
+	return list
+}
+
+func pluginName(prefix, file string) (string, bool) {
+	if !strings.HasPrefix(file, prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, ".exe")
+	}
+	if !commandNamePartValid(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+func hasSubCommand(cmd *cobra.Command, name string) bool {
+	for _, c := range cmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func newPluginCommand(commands map[string]registeredCommand, p plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:                fmt.Sprintf("%s [args...]", p.name),
+		Short:              fmt.Sprintf("Plugin %s", p.path),
+		Annotations:        map[string]string{pluginAnnotation: p.path},
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			c := exec.CommandContext(cmd.Context(), p.path, args...) //nolint:gosec
+			c.Dir = resource.Dir(cmd.Context())
+			c.Env = pluginEnv(cmd, commands)
+			c.Stdin = cmd.InOrStdin()
+			c.Stdout = cmd.OutOrStdout()
+			c.Stderr = cmd.ErrOrStderr()
+			if err := c.Run(); err != nil {
+				return fmt.Errorf("plugin %s failed: %w", p.name, err)
+			}
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return pluginCompletion(cmd, commands, p, args, toComplete)
+		},
+	}
+}
+
+// withPluginArgs returns args, the command line, with the arguments of the plugin command it runs
+// given after a "--": the flags given before the plugin name are parsed as dsak flags, the
+// arguments given after it are passed to the plugin as is.
+func withPluginArgs(rootCmd *cobra.Command, args []string) []string {
+	cmd, _, err := rootCmd.Find(args)
+	if err != nil || cmd.Annotations[pluginAnnotation] == "" {
+		return args
+	}
+	path := strings.Fields(cmd.CommandPath())[1:]
+	names := path
+	flags := cmd.InheritedFlags()
+	var dsakArgs []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == names[0] {
+			if names = names[1:]; len(names) == 0 {
+				cmd.DisableFlagParsing = false
+				res := append(append(append([]string{}, path...), dsakArgs...), "--")
+				return append(res, args[i+1:]...)
+			}
+			continue
+		}
+		dsakArgs = append(dsakArgs, args[i])
+		if flagTakesValue(flags, args[i]) && i+1 < len(args) {
+			i++
+			dsakArgs = append(dsakArgs, args[i])
+		}
+	}
+	return args
+}
+
+// flagTakesValue returns if arg is a flag of flags whose value is the next argument.
+func flagTakesValue(flags *pflag.FlagSet, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return false
+	}
+	var flag *pflag.Flag
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		flag = flags.Lookup(arg[2:])
+	case len(arg) == 2:
+		flag = flags.ShorthandLookup(arg[1:])
+	}
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+// pluginEnv returns the environment of a plugin: the current environment and the resolved global
+// configuration values as DSAK_* variables, the same variables dsak reads its configuration from.
+func pluginEnv(cmd *cobra.Command, commands map[string]registeredCommand) []string {
+	env := os.Environ()
+	cfg := config.GetFromCommandContext(cmd)
+	if file := cfg.ConfigFileUsed(); file != "" {
+		env = append(env, fmt.Sprintf("DSAK_CONFIGFILE=%s", file))
+	}
+	for _, name := range commands[""].configs {
+		v, err := config.GetValue(name)
+		if err != nil {
+			continue
+		}
+		value := v.AsRawString(cmd)
+		if name == commands[""].pluginsDirConfig {
+			value = config.ExpandHome(value)
+		}
+		env = append(env, fmt.Sprintf("%s=%s", v.GetEnvKey(), value))
+	}
+	return env
+}
+
+// pluginCompletion asks the plugin for completions using cobra's `__complete` protocol:
+// the plugin prints one completion per line then a last line `:<directive>`.
+func pluginCompletion(
+	cmd *cobra.Command,
+	commands map[string]registeredCommand,
+	p plugin,
+	args []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	completeArgs := append([]string{cobra.ShellCompRequestCmd}, args...)
+	completeArgs = append(completeArgs, toComplete)
+	c := exec.CommandContext(cmd.Context(), p.path, completeArgs...) //nolint:gosec
+	c.Env = pluginEnv(cmd, commands)
+	out, err := c.Output()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return parseCompletion(out)
+}
+
+func parseCompletion(out []byte) ([]string, cobra.ShellCompDirective) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	directive := cobra.ShellCompDirectiveDefault
+	if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], ":") {
+		d, err := strconv.Atoi(strings.TrimPrefix(lines[len(lines)-1], ":"))
+		if err == nil {
+			directive = cobra.ShellCompDirective(d)
+		}
+		lines = lines[:len(lines)-1]
+	}
+	completions := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line != "" {
+			completions = append(completions, line)
+		}
+	}
+	return completions, directive
+}
@@ -0,0 +1,125 @@
+package commander //nolint:testpackage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePlugin(t *testing.T, dir, name string, mode os.FileMode) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\necho plugin\n"), mode))
+}
+
+func Test_discoverPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin permissions are not checked on windows")
+	}
+	first := t.TempDir()
+	second := t.TempDir()
+	writePlugin(t, first, "dsak-foo", 0o755)
+	writePlugin(t, first, "dsak-notexec", 0o644)
+	writePlugin(t, first, "dsak-in valid", 0o755)
+	writePlugin(t, first, "other-bar", 0o755)
+	writePlugin(t, second, "dsak-foo", 0o755)
+	writePlugin(t, second, "dsak-bar", 0o755)
+	writePlugin(t, second, "dsak-dns-extra", 0o755)
+	require.NoError(t, os.Mkdir(filepath.Join(second, "dsak-dir"), 0o755))
+
+	t.Run("root plugins", func(t *testing.T) {
+		list := discoverPlugins(pluginPrefixFor(""), []string{first, "", "/path/to/nowhere", second})
+		assert.ElementsMatch(t, []plugin{
+			{name: "foo", path: filepath.Join(first, "dsak-foo")},
+			{name: "bar", path: filepath.Join(second, "dsak-bar")},
+		}, list)
+	})
+
+	t.Run("sub command plugins", func(t *testing.T) {
+		list := discoverPlugins(pluginPrefixFor("dns"), []string{first, second})
+		assert.Equal(t, []plugin{
+			{name: "extra", path: filepath.Join(second, "dsak-dns-extra")},
+		}, list)
+	})
+}
+
+func Test_parseCompletion(t *testing.T) {
+	t.Run("with directive", func(t *testing.T) {
+		list, directive := parseCompletion([]byte("one\tfirst\ntwo\n:4\n"))
+		assert.Equal(t, []string{"one\tfirst", "two"}, list)
+		assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+	})
+
+	t.Run("without directive", func(t *testing.T) {
+		list, directive := parseCompletion([]byte("one\n\ntwo"))
+		assert.Equal(t, []string{"one", "two"}, list)
+		assert.Equal(t, cobra.ShellCompDirectiveDefault, directive)
+	})
+}
+
+func Test_applyPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin permissions are not checked on windows")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "dsak-foo", 0o755)
+	writePlugin(t, dir, "dsak-builtin", 0o755)
+	t.Setenv("PATH", "")
+
+	creator := func(use string) func() *cobra.Command {
+		return func() *cobra.Command {
+			return &cobra.Command{Use: use}
+		}
+	}
+	list := map[string]registeredCommand{
+		"": {
+			creator:          creator("dsak"),
+			plugins:          true,
+			pluginsDirConfig: "test.commander.plugins.dir",
+		},
+		"builtin": {
+			creator: creator("builtin"),
+		},
+	}
+	_, cmds, err := buildCommandTree(list)
+	require.NoError(t, err)
+	cfg := viper.New()
+	cfg.Set("test.commander.plugins.dir", dir)
+	applyPlugins(list, cmds, cfg)
+
+	names := make([]string, 0)
+	for _, c := range cmds[""].Commands() {
+		names = append(names, c.Name())
+	}
+	assert.ElementsMatch(t, []string{"builtin", "foo"}, names)
+}
+
+func Test_withPluginArgs(t *testing.T) {
+	rootCmd := &cobra.Command{Use: "dsak"}
+	rootCmd.PersistentFlags().Bool("verbose", false, "")
+	rootCmd.PersistentFlags().StringP("timeout", "t", "", "")
+	rootCmd.AddCommand(&cobra.Command{Use: "builtin"})
+	dns := &cobra.Command{Use: "dns"}
+	rootCmd.AddCommand(dns)
+	hello := newPluginCommand(nil, plugin{name: "hello", path: "/path/to/dsak-hello"})
+	rootCmd.AddCommand(hello)
+	dns.AddCommand(newPluginCommand(nil, plugin{name: "extra", path: "/path/to/dsak-dns-extra"}))
+
+	assert.Equal(t, []string{"builtin", "--verbose"}, withPluginArgs(rootCmd, []string{"builtin", "--verbose"}))
+	assert.Equal(
+		t,
+		[]string{"hello", "--verbose", "--timeout", "5s", "--", "-x", "--verbose", "hello"},
+		withPluginArgs(rootCmd, []string{"--verbose", "--timeout", "5s", "hello", "-x", "--verbose", "hello"}),
+	)
+	assert.False(t, hello.DisableFlagParsing)
+	assert.Equal(
+		t,
+		[]string{"dns", "extra", "-t", "hello", "--timeout=1s", "--"},
+		withPluginArgs(rootCmd, []string{"dns", "-t", "hello", "--timeout=1s", "extra"}),
+	)
+}
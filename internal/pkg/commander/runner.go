@@ -0,0 +1,196 @@
+package commander
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+// Runner runs command lines in-process.
+// Each run builds a fresh command tree, so a Runner can be used to run several command lines.
+type Runner struct {
+	cfg          *viper.Viper
+	commands     map[string]registeredCommand
+	errOut       io.Writer
+	in           io.Reader
+	noFinishHook bool
+	out          io.Writer
+}
+
+// RunnerOption is a function that can be used to configure a Runner.
+type RunnerOption func(*Runner)
+
+// NewRunner creates a new runner for the registered commands.
+func NewRunner(opts ...RunnerOption) *Runner {
+	r := &Runner{
+		commands: commandsRegistered,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithRunnerConfig makes the runner use the given configuration for every run
+// instead of loading the configuration file on each run.
+func WithRunnerConfig(cfg *viper.Viper) RunnerOption {
+	return func(r *Runner) {
+		r.cfg = cfg
+	}
+}
+
+// WithRunnerIn sets the standard input of the commands.
+func WithRunnerIn(in io.Reader) RunnerOption {
+	return func(r *Runner) {
+		r.in = in
+	}
+}
+
+// WithRunnerOut sets the standard output of the commands.
+func WithRunnerOut(out io.Writer) RunnerOption {
+	return func(r *Runner) {
+		r.out = out
+	}
+}
+
+// WithRunnerErr sets the standard error of the commands.
+func WithRunnerErr(errOut io.Writer) RunnerOption {
+	return func(r *Runner) {
+		r.errOut = errOut
+	}
+}
+
+// WithoutFinishHook disables the finish hook of the root command, for command lines run on behalf
+// of another program.
+func WithoutFinishHook() RunnerOption {
+	return func(r *Runner) {
+		r.noFinishHook = true
+	}
+}
+
+// Config returns the configuration used by the runner, nil if it is loaded on each run.
+func (r *Runner) Config() *viper.Viper {
+	return r.cfg
+}
+
+// Run runs the command line given by args.
+// The error the command failed with is reported by the error reporter of the root command,
+// the usage of the command being printed only for usage errors. The returned error is marked
+// as reported, so that a command running another one does not report the same error twice.
+func (r *Runner) Run(ctx context.Context, args []string) error {
+	rootCmd, err := r.build(ctx, args)
+	if err != nil {
+		return err
+	}
+	rootCmd.SetArgs(withPluginArgs(rootCmd, args))
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	start := time.Now()
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && !IsReported(err) {
+		report := r.commands[""].errorReporter
+		if report == nil {
+			report = defaultErrorReporter
+		}
+		report(cmd, err)
+		err = &reportedError{err: err}
+	}
+	if hook := r.commands[""].finishHook; hook != nil && cmd != nil && !r.noFinishHook {
+		hook(cmd, args, time.Since(start), err)
+	}
+	return err
+}
+
+// reportedError is an error that has already been reported to the user.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// IsReported returns true if err has already been reported by a Runner.
+func IsReported(err error) bool {
+	var reported *reportedError
+	return errors.As(err, &reported)
+}
+
+func defaultErrorReporter(cmd *cobra.Command, err error) {
+	cmd.PrintErrln(cmd.ErrPrefix(), err.Error())
+	PrintUsageOnError(cmd, err)
+}
+
+// PrintUsageOnError prints the usage of the command if err is a usage error.
+func PrintUsageOnError(cmd *cobra.Command, err error) {
+	if dsakerr.CategoryOf(err) == dsakerr.CategoryUsage {
+		cmd.PrintErrln(cmd.UsageString())
+	}
+}
+
+// Complete returns the shell completions for the command line given by args, toComplete being
+// the word being completed.
+func (r *Runner) Complete(ctx context.Context, args []string, toComplete string) ([]string, cobra.ShellCompDirective, error) {
+	completeArgs := append([]string{cobra.ShellCompRequestCmd}, args...)
+	completeArgs = append(completeArgs, toComplete)
+	rootCmd, err := r.build(ctx, completeArgs)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError, err
+	}
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs(completeArgs)
+	if err := rootCmd.Execute(); err != nil {
+		return nil, cobra.ShellCompDirectiveError, err
+	}
+	list, directive := parseCompletion(out.Bytes())
+	return list, directive, nil
+}
+
+func (r *Runner) build(ctx context.Context, args []string) (*cobra.Command, error) {
+	rootCmd, cmds, err := buildCommandTree(r.commands)
+	if err != nil {
+		return nil, fmt.Errorf("failed building command tree: %w", err)
+	}
+	rootCmd.SetContext(ctx)
+	cfg := r.cfg
+	if cfg == nil {
+		cfg, err = config.New(args)
+		if err != nil {
+			return nil, fmt.Errorf("failed initializing configuration: %w", err)
+		}
+	}
+	config.SetCommandContext(rootCmd, cfg)
+	if err := applyConfigs(r.commands, cmds, cfg); err != nil {
+		return nil, fmt.Errorf("failed applying config to command tree: %w", err)
+	}
+	if err := applyFlagCompletion(r.commands, cmds); err != nil {
+		return nil, fmt.Errorf("failed applying flag completion to command tree: %w", err)
+	}
+	applyUsageErrors(rootCmd, cmds)
+	applyPlugins(r.commands, cmds, cfg)
+	if r.in != nil {
+		rootCmd.SetIn(r.in)
+	}
+	if r.out != nil {
+		rootCmd.SetOut(r.out)
+	}
+	if r.errOut != nil {
+		rootCmd.SetErr(r.errOut)
+	}
+	return rootCmd, nil
+}
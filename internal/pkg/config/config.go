@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -53,6 +54,18 @@ func Write(cfg *viper.Viper) error {
 	return nil
 }
 
+// ExpandHome replaces a leading ~ in path with the user home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
 func getDefaultConfigFile() (string, error) {
 	defaultConfigFile := os.Getenv("DSAK_CONFIGFILE")
 	if defaultConfigFile == "" {
@@ -80,3 +93,16 @@ func getConfigFile(args []string) (string, error) {
 	}
 	return file, nil
 }
+
+// Clone returns a copy of cfg holding its effective values, as configuration file values, so that
+// commands can be run concurrently with the same configuration, each one binding its own flags.
+// Environment variables still take precedence over the copied values.
+func Clone(cfg *viper.Viper) (*viper.Viper, error) {
+	clone := viper.New()
+	clone.SetConfigFile(cfg.ConfigFileUsed())
+	clone.SetConfigType("yaml")
+	if err := clone.MergeConfigMap(cfg.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+	return clone, nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -164,3 +165,21 @@ func TestWrite(t *testing.T) {
 		assert.Equal(t, "a: 1\nhello: world\n", string(content))
 	})
 }
+
+func TestClone(t *testing.T) {
+	cfg := viper.New()
+	cfg.SetConfigFile("/path/to/dsak.yaml")
+	cfg.SetDefault("a.b", "default")
+	cfg.Set("a.c", 42)
+	cfg.Set("d", []string{"x", "y"})
+
+	clone, err := Clone(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "/path/to/dsak.yaml", clone.ConfigFileUsed())
+	assert.Equal(t, "default", clone.GetString("a.b"))
+	assert.Equal(t, 42, clone.GetInt("a.c"))
+	assert.Equal(t, []string{"x", "y"}, clone.GetStringSlice("d"))
+
+	clone.Set("a.b", "changed")
+	assert.Equal(t, "default", cfg.GetString("a.b"))
+}
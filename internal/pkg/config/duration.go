@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ParseDuration parses a duration configuration value.
+// Strings use the Go duration syntax (eg: "90s", "1m30s"), integers and strings made of digits
+// only are milliseconds, for compatibility with the previous millisecond values.
+func ParseDuration(v any) (time.Duration, error) {
+	switch vv := v.(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return vv, nil
+	case int:
+		return time.Duration(vv) * time.Millisecond, nil
+	case int64:
+		return time.Duration(vv) * time.Millisecond, nil
+	case uint64:
+		return time.Duration(vv) * time.Millisecond, nil
+	case float64:
+		return time.Duration(vv * float64(time.Millisecond)), nil
+	case string:
+		s := strings.TrimSpace(vv)
+		if s == "" {
+			return 0, nil
+		}
+		if ms, err := strconv.ParseUint(s, 10, 63); err == nil {
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q, expected eg: 500ms, 90s, 1m30s", s)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("invalid negative duration %q", s)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid duration value of type %T", v)
+}
+
+// GetDuration returns the duration configuration value with the given name.
+func GetDuration(cfg *viper.Viper, name string) (time.Duration, error) {
+	d, err := ParseDuration(cfg.Get(name))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
+// durationFlag is a pflag.Value for durations, accepting the ParseDuration syntax.
+type durationFlag struct {
+	d time.Duration
+}
+
+func (f *durationFlag) String() string {
+	return f.d.String()
+}
+
+func (f *durationFlag) Set(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	f.d = d
+	return nil
+}
+
+func (f *durationFlag) Type() string {
+	return "duration"
+}
@@ -0,0 +1,78 @@
+package config //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "nil", value: nil, want: 0},
+		{name: "duration string", value: "1m30s", want: 90 * time.Second},
+		{name: "milliseconds string", value: "70000", want: 70 * time.Second},
+		{name: "milliseconds int", value: 1500, want: 1500 * time.Millisecond},
+		{name: "milliseconds uint64 above uint16", value: uint64(65536), want: 65536 * time.Millisecond},
+		{name: "time.Duration", value: 2 * time.Second, want: 2 * time.Second},
+		{name: "empty", value: "", want: 0},
+		{name: "invalid", value: "soon", wantErr: true},
+		{name: "negative", value: "-1s", wantErr: true},
+		{name: "invalid type", value: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDuration(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
+
+func TestDurationValue(t *testing.T) {
+	defer func() {
+		values = make(map[string]Value)
+	}()
+	RegisterValue("test.duration.flag", ValueTypeDuration, Flag("timeout"), DefaultValue(10*time.Second))
+	RegisterValue("test.duration.noflag", ValueTypeDuration, DefaultValue(time.Minute))
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	cfg := viper.New()
+	SetCommandContext(cmd, cfg)
+	for _, name := range []string{"test.duration.flag", "test.duration.noflag"} {
+		v, err := GetValue(name)
+		require.NoError(t, err)
+		require.NoError(t, v.Apply(cmd, cfg))
+	}
+	d, err := GetDuration(cfg, "test.duration.flag")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, d)
+	d, err = GetDuration(cfg, "test.duration.noflag")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+
+	require.NoError(t, cmd.Flags().Set("timeout", "90s"))
+	d, err = GetDuration(cfg, "test.duration.flag")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+	require.Error(t, cmd.Flags().Set("timeout", "later"))
+
+	v, err := GetValue("test.duration.noflag")
+	require.NoError(t, err)
+	require.NoError(t, v.Set(cmd, "2500"))
+	assert.Equal(t, "2.5s", v.AsRawString(cmd))
+}
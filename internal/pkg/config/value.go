@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,8 +31,13 @@ const (
 	ValueTypeBool
 	// ValueTypeStringsMap represents a map[string][]string.
 	ValueTypeStringsMap
+	// ValueTypeDuration represents a time.Duration, see ParseDuration for the accepted syntax.
+	ValueTypeDuration
 )
 
+// RedactedValue replaces the secret configuration values when they are shown.
+const RedactedValue = "<redacted>"
+
 // StringerFunc is a function that returns a string representing the value.
 type StringerFunc func(cmd *cobra.Command) string
 
@@ -45,6 +52,7 @@ type Value struct {
 	name            string
 	noEnv           bool
 	persistentFlag  bool
+	secret          bool
 	setter          SetterFunc
 	shortFlag       byte
 	stringer        StringerFunc
@@ -91,6 +99,11 @@ func (c Value) GetName() string {
 	return c.name
 }
 
+// IsSecret returns true if the configuration value is a secret, see Secret.
+func (c Value) IsSecret() bool {
+	return c.secret
+}
+
 // GetFlag gets the configuration value's flag.
 func (c Value) GetFlag() string {
 	return c.flag
@@ -98,6 +111,9 @@ func (c Value) GetFlag() string {
 
 // AsString gets the configuration value as a string.
 func (c Value) AsString(cmd *cobra.Command) string {
+	if c.secret {
+		return RedactedValue
+	}
 	if c.stringer != nil {
 		return c.stringer(cmd)
 	}
@@ -128,6 +144,12 @@ func (c Value) AsString(cmd *cobra.Command) string {
 			return err.Error()
 		}
 		return string(v)
+	case ValueTypeDuration:
+		d, err := GetDuration(cfg, c.name)
+		if err != nil {
+			return err.Error()
+		}
+		return d.String()
 	}
 	return ""
 }
@@ -153,6 +175,12 @@ func (c Value) AsRawString(cmd *cobra.Command) string {
 			return err.Error()
 		}
 		return string(v)
+	case ValueTypeDuration:
+		d, err := GetDuration(cfg, c.name)
+		if err != nil {
+			return err.Error()
+		}
+		return d.String()
 	}
 	return ""
 }
@@ -185,6 +213,12 @@ func (c Value) Set(cmd *cobra.Command, val string) error {
 		}
 	case ValueTypeStringsMap:
 		return errors.New("setting this configuration value from string is not supported")
+	case ValueTypeDuration:
+		d, err := ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		v = d.String()
 	}
 	cfg.Set(c.name, v)
 	return nil
@@ -226,6 +260,13 @@ func (c Value) applyFlag(cmd *cobra.Command) error {
 		} else {
 			flagSet.Bool(c.flag, c.defaultValue.(bool), c.getDescription()) //nolint:forcetypeassert
 		}
+	case ValueTypeDuration:
+		f := &durationFlag{d: c.defaultValue.(time.Duration)} //nolint:forcetypeassert
+		if c.shortFlag != 0 {
+			flagSet.VarP(f, c.flag, string(c.shortFlag), c.getDescription())
+		} else {
+			flagSet.Var(f, c.flag, c.getDescription())
+		}
 	}
 	return nil
 }
@@ -247,16 +288,36 @@ func (c Value) applyConfig(cmd *cobra.Command, cfg *viper.Viper) error {
 		if err := cfg.BindPFlag(c.name, flagSet.Lookup(c.flag)); err != nil {
 			return err
 		}
+	} else if d, ok := c.defaultValue.(time.Duration); ok {
+		// Durations are kept as strings so that they are written as such in the configuration file.
+		cfg.SetDefault(c.name, d.String())
 	} else {
 		cfg.SetDefault(c.name, c.defaultValue)
 	}
 	if !c.noEnv {
-		envKey := fmt.Sprintf("DSAK_%s", strings.ReplaceAll(strings.ToUpper(c.name), ".", "_"))
-		return cfg.BindEnv(c.name, envKey)
+		return cfg.BindEnv(c.name, c.GetEnvKey())
 	}
 	return nil
 }
 
+// IsSet reports whether the configuration value is set in the configuration file or the environment,
+// unlike viper.IsSet which is also true when the value has a default.
+func (c Value) IsSet(cfg *viper.Viper) bool {
+	if cfg.InConfig(c.name) {
+		return true
+	}
+	if c.noEnv {
+		return false
+	}
+	_, ok := os.LookupEnv(c.GetEnvKey())
+	return ok
+}
+
+// GetEnvKey gets the name of the environment variable controlling the configuration value.
+func (c Value) GetEnvKey() string {
+	return fmt.Sprintf("DSAK_%s", strings.ReplaceAll(strings.ToUpper(c.name), ".", "_"))
+}
+
 // RegisterValue registers a configuration value.
 // `name` is the global name of the configuration value, must be unique.
 // `valueType` is the type of this configuration value.
@@ -299,6 +360,8 @@ func RegisterValue(name string, valueType ValueType, opts ...ValueOption) {
 	case ValueTypeStringsMap:
 		v.defaultValue = make(map[string][]string)
 		v.noEnv = true
+	case ValueTypeDuration:
+		v.defaultValue = time.Duration(0)
 	default:
 		panic(fmt.Errorf("unknown configuration value type for %s: %d", name, valueType))
 	}
@@ -357,6 +420,15 @@ func FlagIsPersistent() ValueOption {
 	}
 }
 
+// Secret indicates that this configuration value holds secrets: it is shown redacted, and not recorded
+// in the history.
+func Secret() ValueOption {
+	return func(v *Value) error {
+		v.secret = true
+		return nil
+	}
+}
+
 // IgnoreEnv indicates that this configuration is not controlled by an environment variable.
 func IgnoreEnv() ValueOption {
 	return func(v *Value) error {
@@ -407,6 +479,12 @@ func DefaultValue(v interface{}) ValueOption {
 				return fmt.Errorf("invalid value for map[string][]string: %T", v)
 			}
 			c.defaultValue = vv
+		case ValueTypeDuration:
+			vv, ok := v.(time.Duration)
+			if !ok {
+				return fmt.Errorf("invalid value for time.Duration: %T", v)
+			}
+			c.defaultValue = vv
 		}
 		return nil
 	}
@@ -2,8 +2,11 @@ package config //nolint:testpackage
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -279,3 +282,43 @@ func TestDefaultValue(t *testing.T) {
 		})
 	}
 }
+
+func TestSecret(t *testing.T) {
+	defer func() {
+		values = make(map[string]Value)
+	}()
+	RegisterValue("test.secret", ValueTypeString, Secret())
+	RegisterValue("test.public", ValueTypeString)
+	v, err := GetValue("test.secret")
+	assert.NoError(t, err)
+	assert.True(t, v.IsSecret())
+	assert.Equal(t, RedactedValue, v.AsString(nil))
+	v, err = GetValue("test.public")
+	assert.NoError(t, err)
+	assert.False(t, v.IsSecret())
+}
+
+func TestValueIsSet(t *testing.T) {
+	defer func() {
+		values = make(map[string]Value)
+	}()
+	RegisterValue("test.isset.env", ValueTypeDuration, DefaultValue(time.Second))
+	RegisterValue("test.isset.noenv", ValueTypeDuration, DefaultValue(time.Second), IgnoreEnv())
+	cfg := viper.New()
+	for _, v := range GetValues() {
+		assert.NoError(t, v.applyConfig(nil, cfg))
+	}
+	env, err := GetValue("test.isset.env")
+	assert.NoError(t, err)
+	noEnv, err := GetValue("test.isset.noenv")
+	assert.NoError(t, err)
+	assert.True(t, cfg.IsSet("test.isset.env"))
+	assert.False(t, env.IsSet(cfg), "a default is not set")
+	t.Setenv("DSAK_TEST_ISSET_ENV", "2s")
+	t.Setenv("DSAK_TEST_ISSET_NOENV", "2s")
+	assert.True(t, env.IsSet(cfg))
+	assert.False(t, noEnv.IsSet(cfg))
+	cfg.SetConfigType("yaml")
+	assert.NoError(t, cfg.ReadConfig(strings.NewReader("test:\n  isset:\n    noenv: 3s\n")))
+	assert.True(t, noEnv.IsSet(cfg))
+}
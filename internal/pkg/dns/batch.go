@@ -0,0 +1,321 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// AllCommonTypes is the name expanding to CommonTypes in type lists.
+const AllCommonTypes = "all-common"
+
+// CommonTypes are the record types queried for AllCommonTypes.
+var CommonTypes = []Type{TypeA, TypeAAAA, TypeCNAME, TypeMX, TypeNS, TypeTXT, TypeSOA, TypeCAA, TypeHTTPS}
+
+// ParseTypes returns the record types of values, each one being a type name, a comma-separated list
+// of type names or AllCommonTypes. Duplicates are removed.
+func ParseTypes(values ...string) ([]Type, error) {
+	var types []Type
+	seen := make(map[Type]bool)
+	add := func(t Type) {
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			switch {
+			case name == "":
+				continue
+			case strings.EqualFold(name, AllCommonTypes):
+				for _, t := range CommonTypes {
+					add(t)
+				}
+			default:
+				t, err := GetType(name)
+				if err != nil {
+					return nil, err
+				}
+				add(t)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no record type given")
+	}
+	return types, nil
+}
+
+// Batch is the result of the queries of several names and record types.
+type Batch struct {
+	// Consistent is false if servers gave different responses to a query.
+	Consistent bool          `json:"consistent"`
+	Queries    []*BatchQuery `json:"queries"`
+}
+
+// BatchQuery is the result of the query of a name and a record type.
+type BatchQuery struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Error is the error the query failed with if no server answered.
+	Error string `json:"error,omitempty"`
+	// Search is the resolution of the name, if the client resolves names like the system resolver.
+	Search *Search `json:"search,omitempty"`
+	*Result
+
+	err error
+	t   Type
+}
+
+// Err returns the error the query failed with, nil if a server answered.
+func (q *BatchQuery) Err() error {
+	return q.err
+}
+
+// QueryBatch queries every record type of types for every name of names, up to concurrency queries at
+// the same time, each query being sent to every server. Results are ordered by name, then by type.
+// Names are resolved with Client.Resolve if a search list or a hosts file is set.
+func (c *Client) QueryBatch(ctx context.Context, names []string, types []Type, concurrency int) *Batch {
+	res := &Batch{Consistent: true, Queries: make([]*BatchQuery, 0, len(names)*len(types))}
+	for _, name := range names {
+		for _, t := range types {
+			q := &BatchQuery{Name: name, Type: GetTypeName(t), t: t}
+			if !c.resolves() {
+				q.Name = dnslib.Fqdn(name)
+			}
+			res.Queries = append(res.Queries, q)
+		}
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, q := range res.Queries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(q *BatchQuery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var err error
+			if c.resolves() {
+				q.Result, q.Search, err = c.Resolve(ctx, q.t, q.Name)
+			} else {
+				q.Result, err = c.Query(ctx, q.t, q.Name)
+			}
+			if err != nil {
+				q.err = err
+				q.Error = err.Error()
+			}
+		}(q)
+	}
+	wg.Wait()
+	for _, q := range res.Queries {
+		if !q.Consistent {
+			res.Consistent = false
+		}
+	}
+	return res
+}
+
+// Failed returns the number of queries no server answered.
+func (b *Batch) Failed() int {
+	n := 0
+	for _, q := range b.Queries {
+		if q.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// answers returns the servers whose answers are shown for the query: the first one that answered
+// if servers gave the same responses, every one otherwise.
+func (q *BatchQuery) answers() []*ServerResult {
+	var servers []*ServerResult
+	for _, sr := range q.Servers {
+		if sr.Response == nil {
+			continue
+		}
+		if q.Consistent {
+			return []*ServerResult{sr}
+		}
+		servers = append(servers, sr)
+	}
+	return servers
+}
+
+// batchLine is a line of the text rendering of a batch.
+type batchLine struct {
+	columns [4]string
+	t       uint16
+	data    string
+	color   *color.Color
+	server  string
+	differs bool
+}
+
+// RenderText writes the answer records of every query as aligned columns: name, TTL, type and data,
+// the response code of queries without answer, and the error of failed queries. The records of each
+// server are shown for the queries servers gave different responses to, highlighting the ones that
+// differ.
+func (b *Batch) RenderText(w io.Writer) error {
+	var lines []batchLine
+	widths := make([]int, 4)
+	for _, q := range b.Queries {
+		if q.Error != "" {
+			lines = append(lines, batchLine{columns: [4]string{q.Name, "", q.Type}, t: uint16(q.t), data: q.Error, color: color.New(color.FgRed)})
+			continue
+		}
+		for _, sr := range q.answers() {
+			server := ""
+			if !q.Consistent {
+				server = sr.Server
+			}
+			if len(sr.Response.msg.Answer) == 0 {
+				data := sr.Response.Rcode
+				if sr.Response.msg.Rcode == dnslib.RcodeSuccess {
+					data = "no answer"
+				}
+				lines = append(lines, batchLine{
+					columns: [4]string{q.Name, "", q.Type},
+					t:       uint16(q.t),
+					data:    data,
+					color:   rcodeColor(sr.Response.msg.Rcode).Add(color.Faint),
+					server:  server,
+					differs: sr.Differs,
+				})
+				continue
+			}
+			for _, rr := range sr.Response.msg.Answer {
+				h := rr.Header()
+				lines = append(lines, batchLine{
+					columns: [4]string{h.Name, FormatTTL(h.Ttl), GetTypeName(Type(h.Rrtype))},
+					t:       h.Rrtype,
+					data:    prettyData(rr),
+					server:  server,
+					differs: sr.Differs,
+				})
+			}
+		}
+	}
+	for _, l := range lines {
+		for i := range widths {
+			widths[i] = max(widths[i], len(l.columns[i]))
+		}
+		if l.server != "" {
+			widths[3] = max(widths[3], len(l.data))
+		}
+	}
+	buf := &bytes.Buffer{}
+	for _, l := range lines {
+		for i := 0; i < 3; i++ {
+			if i == 2 {
+				typeColor(l.t).Fprint(buf, l.columns[i])
+			} else {
+				buf.WriteString(l.columns[i])
+			}
+			buf.WriteString(strings.Repeat(" ", widths[i]-len(l.columns[i])+2))
+		}
+		data := l.data
+		if l.server != "" {
+			data += strings.Repeat(" ", widths[3]-len(l.data)+2)
+		}
+		if l.color != nil {
+			l.color.Fprint(buf, data)
+		} else {
+			buf.WriteString(data)
+		}
+		if l.server != "" {
+			color.New(color.Faint).Fprint(buf, l.server)
+			if l.differs {
+				color.New(color.Bold, color.FgYellow).Fprint(buf, "  differs")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	var inconsistent int
+	for _, q := range b.Queries {
+		if q.Error == "" && !q.Consistent {
+			inconsistent++
+		}
+	}
+	color.New(color.Faint).Fprintf(buf, "%d queries", len(b.Queries))
+	if failed := b.Failed(); failed > 0 {
+		color.New(color.FgRed).Fprintf(buf, ", %d failed", failed)
+	}
+	if inconsistent > 0 {
+		color.New(color.FgYellow).Fprintf(buf, ", servers gave different responses to %d", inconsistent)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// RenderShort writes the data of the answer records, one per line, starting with the name and the
+// type of the query, then the server address if servers gave different responses to the query.
+func (b *Batch) RenderShort(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	for _, q := range b.Queries {
+		for _, sr := range q.answers() {
+			for _, rec := range sr.Response.Answer {
+				buf.WriteString(q.Name + "\t" + q.Type + "\t")
+				if !q.Consistent {
+					buf.WriteString(sr.Server + "\t")
+				}
+				buf.WriteString(strings.TrimSpace(rec.Data) + "\n")
+			}
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Types returns the description of the record types found in the questions and the records of
+// every response, in the order they first appear.
+func (b *Batch) Types() []TypeInfo {
+	var infos []TypeInfo
+	seen := make(map[uint16]bool)
+	for _, q := range b.Queries {
+		if q.Result == nil {
+			continue
+		}
+		for _, info := range q.Result.Types() {
+			if !seen[info.t] {
+				seen[info.t] = true
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
+
+// Header returns the columns of the batch records table.
+func (b *Batch) Header() []string {
+	return append([]string{"query_name", "query_type"}, (&Result{}).Header()...)
+}
+
+// Rows returns the records of every server response of every query, one per row, starting with the
+// name and the type of the query.
+func (b *Batch) Rows() [][]string {
+	var rows [][]string
+	for _, q := range b.Queries {
+		if q.Result == nil {
+			continue
+		}
+		for _, row := range q.Result.Rows() {
+			rows = append(rows, append([]string{q.Name, q.Type}, row...))
+		}
+	}
+	return rows
+}
@@ -0,0 +1,87 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("a,aaaa", "MX", "A")
+	require.NoError(t, err)
+	assert.Equal(t, []Type{TypeA, TypeAAAA, TypeMX}, types)
+
+	types, err = ParseTypes("TXT,all-common")
+	require.NoError(t, err)
+	assert.Len(t, types, len(CommonTypes))
+	assert.Equal(t, TypeTXT, types[0])
+
+	_, err = ParseTypes("A,NOPE")
+	assert.ErrorContains(t, err, "NOPE")
+	_, err = ParseTypes("", " , ")
+	assert.Error(t, err)
+}
+
+func TestClient_QueryBatch(t *testing.T) {
+	records := map[string]string{
+		"a.example.com. A":   "a.example.com. 300 IN A 192.0.2.1",
+		"a.example.com. MX":  "a.example.com. 300 IN MX 10 mail.example.com.",
+		"b.example.com. A":   "b.example.com. 300 IN A 192.0.2.2",
+		"b.example.com. TXT": "b.example.com. 300 IN TXT \"v=spf1 -all\"",
+	}
+	server := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		if s, ok := records[r.Question[0].Name+" "+dnslib.TypeToString[r.Question[0].Qtype]]; ok {
+			rr, _ := dnslib.NewRR(s)
+			m.Answer = append(m.Answer, rr)
+		} else if r.Question[0].Name == "missing.example.com." {
+			m.Rcode = dnslib.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	client := NewClient(zap.NewNop(), WithServers(server), WithTimeouts(time.Second, time.Second))
+	res := client.QueryBatch(
+		context.Background(),
+		[]string{"a.example.com", "b.example.com", "missing.example.com"},
+		[]Type{TypeA, TypeMX},
+		3,
+	)
+	require.Len(t, res.Queries, 6)
+	assert.True(t, res.Consistent)
+	assert.Zero(t, res.Failed())
+	assert.Equal(t, "a.example.com.", res.Queries[1].Name)
+	assert.Equal(t, "MX", res.Queries[1].Type)
+	assert.Equal(t, "b.example.com.", res.Queries[2].Name)
+	assert.Equal(t, "NXDOMAIN", res.Queries[4].Servers[0].Response.Rcode)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, res.RenderShort(buf))
+	assert.Equal(t, "a.example.com.\tA\t192.0.2.1\na.example.com.\tMX\t10 mail.example.com.\nb.example.com.\tA\t192.0.2.2\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, res.RenderText(buf))
+	assert.Contains(t, buf.String(), "b.example.com.            MX  no answer")
+	assert.Contains(t, buf.String(), "missing.example.com.      A   NXDOMAIN")
+	assert.Contains(t, buf.String(), "6 queries\n")
+
+	assert.Equal(t, []string{"query_name", "query_type", "server", "rcode", "rtt", "differs", "section", "name", "type", "ttl", "data"}, res.Header())
+	rows := res.Rows()
+	require.Len(t, rows, 6)
+	assert.Equal(t, []string{"a.example.com.", "A", server, "NOERROR"}, rows[0][:4])
+	assert.Equal(t, "192.0.2.1", rows[0][10])
+
+	res = NewClient(zap.NewNop(), WithServers("127.0.0.1:1"), WithTimeouts(time.Second, time.Second)).
+		QueryBatch(context.Background(), []string{"a.example.com"}, []Type{TypeA}, 1)
+	assert.Equal(t, 1, res.Failed())
+	assert.Equal(t, dsakerr.CategoryNetwork, dsakerr.CategoryOf(res.Queries[0].Err()))
+}
@@ -0,0 +1,468 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+// DefaultBenchNames are the names queried by benchmarks when none is given, popular names likely to be
+// cached by resolvers.
+var DefaultBenchNames = []string{
+	"google.com", "youtube.com", "facebook.com", "wikipedia.org", "amazon.com", "microsoft.com", "apple.com",
+	"cloudflare.com", "github.com", "netflix.com", "instagram.com", "linkedin.com", "yahoo.com", "bing.com",
+	"reddit.com", "whatsapp.com", "zoom.us", "office.com", "twitch.tv", "ubuntu.com",
+}
+
+// BenchOptions are the parameters of a benchmark, see Client.Bench.
+type BenchOptions struct {
+	// Names are the names of the cached queries, and the parents of the random subdomains of the uncached
+	// ones, default is DefaultBenchNames.
+	Names []string
+	// Type is the record type queried, default is A.
+	Type Type
+	// Uncached is the share of uncached queries, from 0 to 1.
+	Uncached float64
+	// Concurrency is the number of queries sent at the same time to each server, default is 1.
+	Concurrency int
+	// Duration is the duration of the benchmark of each server.
+	Duration time.Duration
+	// Queries is the maximum number of queries sent to each server, 0 for no limit.
+	Queries int
+	// Timeout is the timeout of each query, default is 2s.
+	Timeout time.Duration
+	// Seed seeds the sequence of names and query kinds, the same for every server and every run with the
+	// same seed. The random subdomains differ between runs, so that they are never cached.
+	Seed int64
+}
+
+// Bench is the result of a benchmark of servers.
+type Bench struct {
+	Type        string   `json:"type"`
+	Names       int      `json:"names"`
+	Uncached    float64  `json:"uncached"`
+	Concurrency int      `json:"concurrency"`
+	Duration    Duration `json:"duration"`
+	Seed        int64    `json:"seed"`
+	// Servers are sorted by rank.
+	Servers []*BenchServer `json:"servers"`
+}
+
+// BenchServer is the benchmark of a server.
+type BenchServer struct {
+	// Rank is the rank of the server, 1 being the best: by failure rate, then by 90th percentile latency.
+	Rank   int    `json:"rank"`
+	Server string `json:"server"`
+	// Queries is the number of queries sent, Cached and Uncached the number of each kind.
+	Queries  int `json:"queries"`
+	Cached   int `json:"cached"`
+	Uncached int `json:"uncached"`
+	// Succeeded is the number of NOERROR or NXDOMAIN responses.
+	Succeeded int `json:"succeeded"`
+	Timeouts  int `json:"timeouts"`
+	ServFails int `json:"servfails"`
+	// Errors is the number of other failures, eg: REFUSED responses or network errors.
+	Errors int `json:"errors"`
+	// FailureRate is the share of failed queries (timeouts, SERVFAIL and errors), in percent.
+	FailureRate float64 `json:"failure_rate"`
+	// TimeoutRate and ServFailRate are the shares of timeouts and SERVFAIL responses, in percent.
+	TimeoutRate  float64 `json:"timeout_rate"`
+	ServFailRate float64 `json:"servfail_rate"`
+	// QPS is the number of queries answered per second.
+	QPS float64 `json:"qps"`
+	// Latency is the latency of all the responses, CachedLatency and UncachedLatency of each kind of query.
+	Latency         BenchLatency `json:"latency"`
+	CachedLatency   BenchLatency `json:"cached_latency"`
+	UncachedLatency BenchLatency `json:"uncached_latency"`
+	// Error is the error the benchmark could not run with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// BenchLatency are the statistics of the latencies of responses.
+type BenchLatency struct {
+	Min  Duration `json:"min"`
+	Mean Duration `json:"mean"`
+	P50  Duration `json:"p50"`
+	P90  Duration `json:"p90"`
+	P95  Duration `json:"p95"`
+	P99  Duration `json:"p99"`
+	Max  Duration `json:"max"`
+}
+
+// benchSequence is the sequence of queries of a benchmark.
+type benchSequence struct {
+	mu       sync.Mutex
+	rng      *mathrand.Rand
+	names    []string
+	uncached float64
+	sent     int
+	limit    int
+}
+
+// next returns the name of the next query, and whether it is uncached. ok is false once the limit of
+// queries is reached.
+func (s *benchSequence) next() (name string, uncached bool, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 && s.sent >= s.limit {
+		return "", false, false
+	}
+	s.sent++
+	name = s.names[s.rng.Intn(len(s.names))]
+	if s.rng.Float64() >= s.uncached {
+		return name, false, true
+	}
+	label := make([]byte, 6)
+	_, _ = rand.Read(label)
+	return "dsak-" + hex.EncodeToString(label) + "." + name, true, true
+}
+
+// benchOutcome is the outcome of a query of a benchmark.
+type benchOutcome struct {
+	uncached bool
+	rtt      time.Duration
+	// answered is true if the server responded, whatever the response code.
+	answered bool
+	rcode    int
+	timeout  bool
+}
+
+// Bench benchmarks the servers of c one after the other: the names are queried once to be cached, then
+// queries are sent by opts.Concurrency workers for opts.Duration, or until opts.Queries were sent. The
+// servers are ranked by failure rate rounded to 0.1%, then by 90th percentile latency.
+func (c *Client) Bench(ctx context.Context, opts BenchOptions) *Bench {
+	if len(opts.Names) == 0 {
+		opts.Names = DefaultBenchNames
+	}
+	if opts.Type == 0 {
+		opts.Type = TypeA
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	names := make([]string, 0, len(opts.Names))
+	for _, n := range opts.Names {
+		names = append(names, dnslib.Fqdn(n))
+	}
+	res := &Bench{
+		Type:        GetTypeName(opts.Type),
+		Names:       len(names),
+		Uncached:    opts.Uncached,
+		Concurrency: opts.Concurrency,
+		Duration:    Duration(opts.Duration),
+		Seed:        opts.Seed,
+		Servers:     make([]*BenchServer, 0, len(c.servers)),
+	}
+	for _, server := range c.servers {
+		if ctx.Err() != nil {
+			break
+		}
+		c.logger.With(zap.String("server", server), zap.Duration("duration", opts.Duration)).Info("Benchmarking DNS server")
+		seq := &benchSequence{
+			rng:      mathrand.New(mathrand.NewSource(opts.Seed)), //nolint:gosec // Reproducible, not secret.
+			names:    names,
+			uncached: opts.Uncached,
+			limit:    opts.Queries,
+		}
+		res.Servers = append(res.Servers, c.benchServer(ctx, server, opts, seq))
+	}
+	rankBench(res.Servers)
+	return res
+}
+
+func (c *Client) benchServer(ctx context.Context, address string, opts BenchOptions, seq *benchSequence) *BenchServer {
+	bs := &BenchServer{Server: address}
+	s, err := parseServer(address)
+	if err != nil {
+		bs.Error = err.Error()
+		return bs
+	}
+	bs.Server = s.String()
+	query := func(ctx context.Context, name string) benchOutcome {
+		qctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+		start := time.Now()
+		in, _, _, err := c.exchange(qctx, s, c.newQuery(name, uint16(opts.Type)))
+		o := benchOutcome{rtt: time.Since(start)}
+		if err != nil {
+			var netErr net.Error
+			o.timeout = ctx.Err() == nil && (qctx.Err() != nil || (errors.As(err, &netErr) && netErr.Timeout()))
+			return o
+		}
+		o.answered = true
+		o.rcode = in.Rcode
+		return o
+	}
+
+	// Warm up the caches of the server, the responses are not measured.
+	wg := sync.WaitGroup{}
+	warmup := make(chan string)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range warmup {
+				query(ctx, name)
+			}
+		}()
+	}
+	for _, name := range seq.names {
+		warmup <- name
+	}
+	close(warmup)
+	wg.Wait()
+
+	bctx := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		bctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	outcomes := make([][]benchOutcome, opts.Concurrency)
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for bctx.Err() == nil {
+				name, uncached, ok := seq.next()
+				if !ok {
+					return
+				}
+				// The queries still running at the end of the benchmark complete.
+				o := query(context.WithoutCancel(bctx), name)
+				o.uncached = uncached
+				outcomes[i] = append(outcomes[i], o)
+			}
+		}(i)
+	}
+	wg.Wait()
+	bs.add(outcomes, time.Since(start))
+	return bs
+}
+
+// add computes the statistics of bs from the outcomes of its queries, sent during elapsed.
+func (bs *BenchServer) add(outcomes [][]benchOutcome, elapsed time.Duration) {
+	var all, cached, uncached []time.Duration
+	for _, os := range outcomes {
+		for _, o := range os {
+			bs.Queries++
+			if o.uncached {
+				bs.Uncached++
+			} else {
+				bs.Cached++
+			}
+			switch {
+			case o.timeout:
+				bs.Timeouts++
+				continue
+			case !o.answered:
+				bs.Errors++
+				continue
+			case o.rcode == dnslib.RcodeServerFailure:
+				bs.ServFails++
+			case o.rcode == dnslib.RcodeSuccess || o.rcode == dnslib.RcodeNameError:
+				bs.Succeeded++
+			default:
+				bs.Errors++
+			}
+			all = append(all, o.rtt)
+			if o.uncached {
+				uncached = append(uncached, o.rtt)
+			} else {
+				cached = append(cached, o.rtt)
+			}
+		}
+	}
+	if bs.Queries > 0 {
+		bs.FailureRate = percent(bs.Timeouts+bs.ServFails+bs.Errors, bs.Queries)
+		bs.TimeoutRate = percent(bs.Timeouts, bs.Queries)
+		bs.ServFailRate = percent(bs.ServFails, bs.Queries)
+	}
+	if elapsed > 0 {
+		bs.QPS = math.Round(float64(len(all))/elapsed.Seconds()*10) / 10
+	}
+	bs.Latency = newBenchLatency(all)
+	bs.CachedLatency = newBenchLatency(cached)
+	bs.UncachedLatency = newBenchLatency(uncached)
+}
+
+func percent(n, total int) float64 {
+	return math.Round(float64(n)/float64(total)*10000) / 100
+}
+
+// newBenchLatency returns the statistics of latencies, with nearest-rank percentiles.
+func newBenchLatency(latencies []time.Duration) BenchLatency {
+	if len(latencies) == 0 {
+		return BenchLatency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) Duration {
+		i := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+		return Duration(latencies[max(i, 0)])
+	}
+	return BenchLatency{
+		Min:  Duration(latencies[0]),
+		Mean: Duration(total / time.Duration(len(latencies))),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  Duration(latencies[len(latencies)-1]),
+	}
+}
+
+// rankBench sorts servers by failure rate rounded to 0.1%, then by 90th percentile latency, the servers
+// that could not be benchmarked being the last ones.
+func rankBench(servers []*BenchServer) {
+	sort.SliceStable(servers, func(i, j int) bool {
+		a, b := servers[i], servers[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		fa, fb := math.Round(a.FailureRate*10), math.Round(b.FailureRate*10)
+		if fa != fb {
+			return fa < fb
+		}
+		return a.Latency.P90 < b.Latency.P90
+	})
+	for i, s := range servers {
+		s.Rank = i + 1
+	}
+}
+
+func formatLatency(d Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return time.Duration(d).Round(10 * time.Microsecond).String()
+}
+
+// RenderText writes a line per server, by rank, with its latencies and failures.
+func (b *Bench) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	color.New(color.Bold).Fprintf(
+		buf, "%s queries, %d names, %.0f%% uncached, concurrency %d, %s per server, seed %d",
+		b.Type, b.Names, b.Uncached*100, b.Concurrency, time.Duration(b.Duration), b.Seed,
+	)
+	buf.WriteString("\n")
+	rows := [][]string{{"#", "server", "queries", "qps", "p50", "p90", "p99", "cached p50", "uncached p50", "timeouts", "servfail", "errors"}}
+	for _, s := range b.Servers {
+		if s.Error != "" {
+			rows = append(rows, []string{strconv.Itoa(s.Rank), s.Server, s.Error})
+			continue
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(s.Rank),
+			s.Server,
+			strconv.Itoa(s.Queries),
+			strconv.FormatFloat(s.QPS, 'f', 1, 64),
+			formatLatency(s.Latency.P50),
+			formatLatency(s.Latency.P90),
+			formatLatency(s.Latency.P99),
+			formatLatency(s.CachedLatency.P50),
+			formatLatency(s.UncachedLatency.P50),
+			fmt.Sprintf("%.2f%%", s.TimeoutRate),
+			fmt.Sprintf("%.2f%%", s.ServFailRate),
+			strconv.Itoa(s.Errors),
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		if len(row) < len(widths) {
+			continue
+		}
+		for i, v := range row {
+			widths[i] = max(widths[i], len(v))
+		}
+	}
+	// The last column is not padded.
+	widths[len(widths)-1] = 0
+	for i, row := range rows {
+		for j, v := range row {
+			if j > 0 {
+				buf.WriteString("  ")
+			}
+			switch {
+			case i == 0:
+				faint.Fprintf(buf, "%-*s", widths[j], v)
+			case len(row) < len(widths) && j == len(row)-1:
+				color.New(color.FgRed).Fprint(buf, v)
+			case j == 1 && i == 1:
+				color.New(color.Bold, color.FgGreen).Fprintf(buf, "%-*s", widths[j], v)
+			case (j == 9 || j == 10) && v != "0.00%":
+				color.New(color.FgYellow).Fprintf(buf, "%-*s", widths[j], v)
+			default:
+				fmt.Fprintf(buf, "%-*s", widths[j], v)
+			}
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Header returns the columns of the benchmark table.
+func (b *Bench) Header() []string {
+	return []string{
+		"rank", "server", "queries", "cached", "uncached", "succeeded", "timeouts", "servfails", "errors",
+		"failure_rate", "qps", "min", "mean", "p50", "p90", "p95", "p99", "max", "cached_p50", "uncached_p50", "error",
+	}
+}
+
+// Rows returns the servers, one per row, by rank.
+func (b *Bench) Rows() [][]string {
+	rows := make([][]string, 0, len(b.Servers))
+	d := func(v Duration) string {
+		return time.Duration(v).String()
+	}
+	for _, s := range b.Servers {
+		rows = append(rows, []string{
+			strconv.Itoa(s.Rank),
+			s.Server,
+			strconv.Itoa(s.Queries),
+			strconv.Itoa(s.Cached),
+			strconv.Itoa(s.Uncached),
+			strconv.Itoa(s.Succeeded),
+			strconv.Itoa(s.Timeouts),
+			strconv.Itoa(s.ServFails),
+			strconv.Itoa(s.Errors),
+			strconv.FormatFloat(s.FailureRate, 'f', 2, 64),
+			strconv.FormatFloat(s.QPS, 'f', 1, 64),
+			d(s.Latency.Min),
+			d(s.Latency.Mean),
+			d(s.Latency.P50),
+			d(s.Latency.P90),
+			d(s.Latency.P95),
+			d(s.Latency.P99),
+			d(s.Latency.Max),
+			d(s.CachedLatency.P50),
+			d(s.UncachedLatency.P50),
+			s.Error,
+		})
+	}
+	return rows
+}
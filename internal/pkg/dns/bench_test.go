@@ -0,0 +1,78 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewBenchLatency(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	l := newBenchLatency(latencies)
+	assert.Equal(t, Duration(time.Millisecond), l.Min)
+	assert.Equal(t, Duration(50*time.Millisecond), l.P50)
+	assert.Equal(t, Duration(90*time.Millisecond), l.P90)
+	assert.Equal(t, Duration(99*time.Millisecond), l.P99)
+	assert.Equal(t, Duration(100*time.Millisecond), l.Max)
+	assert.Equal(t, Duration(50500*time.Microsecond), l.Mean)
+	assert.Equal(t, BenchLatency{}, newBenchLatency(nil))
+}
+
+func TestClient_Bench(t *testing.T) {
+	fast := startServer(t, answerA("192.0.2.1", 0))
+	slow := startServer(t, answerA("192.0.2.1", 5*time.Millisecond))
+	// Fails the uncached queries.
+	failing := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		if strings.HasPrefix(r.Question[0].Name, "dsak-") {
+			m := new(dnslib.Msg)
+			m.SetRcode(r, dnslib.RcodeServerFailure)
+			_ = w.WriteMsg(m)
+			return
+		}
+		answerA("192.0.2.1", 0)(w, r)
+	})
+
+	client := NewClient(zap.NewNop(), WithServers(failing, slow, fast))
+	opts := BenchOptions{Names: []string{"example.com", "example.org"}, Uncached: 0.25, Concurrency: 4, Queries: 200, Seed: 42}
+	res := client.Bench(context.Background(), opts)
+	require.Len(t, res.Servers, 3)
+	assert.Equal(t, "A", res.Type)
+	assert.Equal(t, 2, res.Names)
+
+	for i, server := range []string{fast, slow, failing} {
+		bs := res.Servers[i]
+		assert.Equal(t, i+1, bs.Rank)
+		assert.Equal(t, server, bs.Server)
+		assert.Equal(t, 200, bs.Queries)
+		assert.Equal(t, bs.Queries, bs.Cached+bs.Uncached)
+		assert.NotZero(t, bs.Latency.P50)
+	}
+	// The same sequence of queries is sent to every server.
+	assert.Equal(t, res.Servers[0].Uncached, res.Servers[2].Uncached)
+	assert.InDelta(t, 50, res.Servers[0].Uncached, 20)
+	assert.Equal(t, 200, res.Servers[0].Succeeded)
+	assert.Greater(t, res.Servers[1].Latency.P50, Duration(5*time.Millisecond))
+	assert.Equal(t, res.Servers[2].Uncached, res.Servers[2].ServFails)
+	assert.Equal(t, percent(res.Servers[2].ServFails, 200), res.Servers[2].ServFailRate)
+}
+
+func TestClient_Bench_timeouts(t *testing.T) {
+	silent := startServer(t, func(dnslib.ResponseWriter, *dnslib.Msg) {})
+	client := NewClient(zap.NewNop(), WithServers(silent, "ftp://nope"))
+	res := client.Bench(context.Background(), BenchOptions{Queries: 3, Timeout: 20 * time.Millisecond})
+	require.Len(t, res.Servers, 2)
+	assert.Equal(t, 3, res.Servers[0].Timeouts)
+	assert.Equal(t, 100.0, res.Servers[0].TimeoutRate)
+	assert.Equal(t, BenchLatency{}, res.Servers[0].Latency)
+	assert.Equal(t, 2, res.Servers[1].Rank)
+	assert.Contains(t, res.Servers[1].Error, "unsupported DNS server scheme")
+}
@@ -3,53 +3,244 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"strings"
+	"time"
 
-	"github.com/miekg/dns"
 	dnslib "github.com/miekg/dns"
 	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+	"github.com/jucrouzet/dsak/internal/pkg/httpdsak"
 )
 
 // Client represents a DNS client.
 type Client struct {
-	logger  *zap.Logger
-	servers []string
+	attempts          int
+	authenticatedData bool
+	checkingDisabled  bool
+	clientSubnet      netip.Prefix
+	connectTimeout    time.Duration
+	cookie            bool
+	logger            *zap.Logger
+	dnssec            bool
+	dohMethod         string
+	firstAnswer       bool
+	hosts             *Hosts
+	insecure          bool
+	noRecursion       bool
+	ndots             int
+	nsid              bool
+	padding           bool
+	readTimeout       time.Duration
+	rootServers       []string
+	search            []string
+	servers           []string
+	sourceAddr        netip.Addr
+	sourcePort        uint16
+	tcp               bool
+	tcpFallback       bool
+	trustAnchors      []string
+	tsig              *TSIGKey
+	udpSize           uint16
+	// tracePort is the port of the servers queried by traces, see withTracePort.
+	tracePort string
+	// mailFetch fetches the MTA-STS policies instead of the HTTP client, see withMailFetch.
+	mailFetch func(ctx context.Context, url string) (*httpdsak.Response, error)
+}
+
+// Option is a function that configures a Client.
+type Option func(*Client)
+
+// WithServers sets the servers to query, default is 1.1.1.1 and 8.8.8.8.
+// Servers are addresses queried over UDP, or URLs: udp://, tcp://, tls:// (DNS over TLS),
+// https:// (DNS over HTTPS) or quic:// (DNS over QUIC).
+func WithServers(servers ...string) Option {
+	return func(c *Client) {
+		if len(servers) > 0 {
+			c.servers = servers
+		}
+	}
+}
+
+// WithFirstAnswer makes queries stop at the first successful response, NOERROR or NXDOMAIN, instead of
+// waiting for every server.
+func WithFirstAnswer() Option {
+	return func(c *Client) {
+		c.firstAnswer = true
+	}
+}
+
+// WithInsecure disables the verification of the certificates of encrypted DNS servers.
+func WithInsecure() Option {
+	return func(c *Client) {
+		c.insecure = true
+	}
+}
+
+// WithDoHMethod sets the HTTP method of DNS over HTTPS queries, GET or POST (the default).
+func WithDoHMethod(method string) Option {
+	return func(c *Client) {
+		c.dohMethod = strings.ToUpper(method)
+	}
+}
+
+// WithTimeouts sets the connect and read timeouts of queries, 0 meaning they are only bounded by the context.
+func WithTimeouts(connect, read time.Duration) Option {
+	return func(c *Client) {
+		c.connectTimeout = connect
+		c.readTimeout = read
+	}
 }
 
 // NewClient creates a new DNS client.
-func NewClient(logger *zap.Logger, servers ...string) *Client {
-	if len(servers) == 0 {
-		servers = []string{"1.1.1.1", "8.8.8.8"}
+func NewClient(logger *zap.Logger, opts ...Option) *Client {
+	c := &Client{
+		logger:    logger,
+		servers:   []string{"1.1.1.1", "8.8.8.8"},
+		tracePort: "53",
 	}
-	logger.With(zap.Strings("dns_servers", servers)).Debug("Initializing DNS client")
-	return &Client{
-		logger:  logger,
-		servers: servers,
+	for _, opt := range opts {
+		opt(c)
 	}
+	logger.With(zap.Strings("dns_servers", c.servers)).Debug("Initializing DNS client")
+	return c
 }
 
-// Query performs a DNS query for the given domain and returns the response.
-func (c *Client) Query(ctx context.Context, rType Type, domain string) (string, error) {
-	if !strings.HasSuffix(domain, ".") {
-		domain += "."
+// Query sends a query for the given domain to every server concurrently and returns their results,
+// in the order of the servers. The error is only returned if no server answered.
+// With WithFirstAnswer, the result only holds the first successful response.
+func (c *Client) Query(ctx context.Context, rType Type, domain string) (*Result, error) {
+	domain = dnslib.Fqdn(domain)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*ServerResult, len(c.servers))
+	// Buffered, so that queries still running when the first answer is returned do not block.
+	done := make(chan int, len(c.servers))
+	for i, server := range c.servers {
+		go func(i int, server string) {
+			results[i] = c.queryServer(ctx, server, rType, domain)
+			done <- i
+		}(i, server)
 	}
-	client := new(dnslib.Client)
-	co, err := client.DialContext(ctx, "8.8.8.8:53")
+	for range c.servers {
+		i := <-done
+		if c.firstAnswer && results[i].succeeded() {
+			return newResult([]*ServerResult{results[i]}), nil
+		}
+	}
+	res := newResult(results)
+	return res, res.err()
+}
+
+// lookup returns the first successful response (NOERROR or NXDOMAIN) of the servers, or the first
+// response if none succeeded.
+func (c *Client) lookup(ctx context.Context, rType Type, name string) (*Response, error) {
+	client := *c
+	client.firstAnswer = true
+	res, err := client.Query(ctx, rType, name)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for _, sr := range res.Servers {
+		if sr.succeeded() {
+			return sr.Response, nil
+		}
+	}
+	for _, sr := range res.Servers {
+		if sr.Response != nil {
+			return sr.Response, nil
+		}
 	}
-	defer co.Close()
-	m := new(dns.Msg)
-	m.SetQuestion(domain, uint16(rType))
-	in, rtt, err := client.ExchangeWithConnContext(ctx, m, co)
+	return nil, res.err()
+}
+
+func (c *Client) queryServer(ctx context.Context, address string, rType Type, domain string) *ServerResult {
+	res := &ServerResult{Server: address}
+	s, err := parseServer(address)
 	if err != nil {
-		return "", fmt.Errorf("DNS query failed: %w", err)
+		res.setError(dsakerr.Wrap(dsakerr.CategoryUsage, err))
+		return res
 	}
-	c.logger.With(
+	res.Server = s.String()
+	logger := c.logger.With(
+		zap.String("server", res.Server),
 		zap.String("domain", domain),
 		zap.String("type", GetTypeName(rType)),
-		zap.Duration("rtt", rtt),
-	).Debug("DNS query succeeded")
-	return in.String(), nil
+	)
+	if c.tcp && s.transport == TransportUDP {
+		s.transport = TransportTCP
+	}
+	in, rtt, info, err := c.exchange(ctx, s, c.newQuery(domain, uint16(rType)))
+	for attempt := 1; err != nil && attempt < c.attempts && ctx.Err() == nil; attempt++ {
+		logger.With(zap.Error(err)).Debug("DNS query failed, querying again")
+		in, rtt, info, err = c.exchange(ctx, s, c.newQuery(domain, uint16(rType)))
+	}
+	truncated := false
+	if err == nil && in.Truncated && c.tcpFallback && s.transport == TransportUDP {
+		logger.Debug("DNS response truncated, querying over TCP")
+		truncated = true
+		s = &server{transport: TransportTCP, address: s.address, name: s.name}
+		in, rtt, info, err = c.exchange(ctx, s, c.newQuery(domain, uint16(rType)))
+	}
+	if err != nil {
+		res.setError(err)
+		logger.With(zap.Error(err)).Debug("DNS query failed")
+		return res
+	}
+	logger.With(zap.Duration("rtt", rtt)).Debug("DNS query succeeded")
+	res.Response = newResponse(res.Server, s.transport, rtt, in)
+	res.Response.TLS = info
+	res.Response.TCPFallback = truncated
+	if c.dnssec {
+		res.Response.DNSSEC = c.validate(ctx, s, in, domain, rType)
+	}
+	return res
+}
+
+// phaseError returns err as a dsakerr.TimeoutError if the timeout of phase expired.
+// Expiration of the total timeout, the context one, is returned wrapping the context error.
+func (c *Client) phaseError(ctx context.Context, phase dsakerr.Phase, err error) error {
+	timeout := c.connectTimeout
+	if phase == dsakerr.PhaseRead {
+		timeout = c.readTimeout
+	}
+	if err == nil {
+		return nil
+	}
+	ctxErr := ctx.Err()
+	if deadline, ok := ctx.Deadline(); ctxErr == nil && ok && !time.Now().Before(deadline) {
+		// The context deadline, set on the connection, can pass before the context is done.
+		ctxErr = context.DeadlineExceeded
+	}
+	if ctxErr != nil {
+		if errors.Is(err, ctxErr) {
+			return err
+		}
+		// The expiration of the context, the total timeout, shows as a network timeout.
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	var netErr net.Error
+	if timeout == 0 || !errors.As(err, &netErr) || !netErr.Timeout() {
+		return err
+	}
+	return &dsakerr.TimeoutError{Phase: phase, Timeout: timeout, Err: err}
+}
+
+// validate validates the DNSSEC signatures of the response in, querying s for the keys of the zones.
+func (c *Client) validate(ctx context.Context, s *server, in *dnslib.Msg, domain string, rType Type) *DNSSEC {
+	v, err := c.newValidator(ctx, s)
+	if err != nil {
+		return &DNSSEC{Status: DNSSECIndeterminate, Reason: err.Error(), Chain: []DNSSECZone{}}
+	}
+	res := v.validate(in, domain, uint16(rType))
+	c.logger.With(
+		zap.String("server", s.String()),
+		zap.String("status", res.Status),
+		zap.String("reason", res.Reason),
+	).Debug("DNSSEC validation")
+	return res
 }
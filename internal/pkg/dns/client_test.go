@@ -0,0 +1,158 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+// serveDNS starts srv, a DNS server, on the local address over network ("udp", "tcp" or "tcp-tls" with
+// the TLS configuration of srv) and returns its address. The port of address can be 0 for a random one.
+func serveDNS(t *testing.T, network string, address string, srv *dnslib.Server) string {
+	t.Helper()
+	if network == "udp" {
+		pc, err := net.ListenPacket(network, address)
+		require.NoError(t, err)
+		srv.PacketConn = pc
+		address = pc.LocalAddr().String()
+	} else {
+		l, err := net.Listen("tcp", address)
+		require.NoError(t, err)
+		if network == "tcp-tls" {
+			l = tls.NewListener(l, srv.TLSConfig)
+		}
+		srv.Listener = l
+		address = l.Addr().String()
+	}
+	srv.Net = network
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() {
+		_ = srv.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	return address
+}
+
+// startServer starts a DNS server on a random local UDP port, answering with handler, and returns its address.
+func startServer(t *testing.T, handler dnslib.HandlerFunc) string {
+	t.Helper()
+	return serveDNS(t, "udp", "127.0.0.1:0", &dnslib.Server{Handler: handler})
+}
+
+// answerA returns a handler answering A queries with ip, after delay.
+func answerA(ip string, delay time.Duration) dnslib.HandlerFunc {
+	return func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		time.Sleep(delay)
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		rr, _ := dnslib.NewRR(r.Question[0].Name + " 300 IN A " + ip)
+		m.Answer = append(m.Answer, rr)
+		_ = w.WriteMsg(m)
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	a := startServer(t, answerA("192.0.2.1", 0))
+	b := startServer(t, answerA("192.0.2.1", 0))
+	c := startServer(t, answerA("192.0.2.2", 0))
+
+	client := NewClient(zap.NewNop(), WithServers(a, b, c), WithTimeouts(time.Second, time.Second))
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	require.Len(t, res.Servers, 3)
+	assert.False(t, res.Consistent)
+	for i, server := range []string{a, b, c} {
+		assert.Equal(t, server, res.Servers[i].Server)
+		require.NotNil(t, res.Servers[i].Response)
+		assert.Equal(t, "NOERROR", res.Servers[i].Response.Rcode)
+	}
+	assert.False(t, res.Servers[0].Differs)
+	assert.False(t, res.Servers[1].Differs)
+	assert.True(t, res.Servers[2].Differs)
+	assert.Contains(t, res.Servers[2].Response.Answer[0].Data, "192.0.2.2")
+}
+
+func TestClient_Query_firstAnswer(t *testing.T) {
+	slow := startServer(t, answerA("192.0.2.1", 500*time.Millisecond))
+	fast := startServer(t, answerA("192.0.2.2", 0))
+
+	client := NewClient(zap.NewNop(), WithServers(slow, fast), WithFirstAnswer())
+	res, err := client.Query(context.Background(), TypeA, "example.com.")
+	require.NoError(t, err)
+	require.Len(t, res.Servers, 1)
+	assert.Equal(t, fast, res.Servers[0].Server)
+	assert.True(t, res.Consistent)
+}
+
+func TestClient_Query_noAnswer(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	client := NewClient(zap.NewNop(), WithServers(pc.LocalAddr().String()), WithTimeouts(time.Second, 50*time.Millisecond))
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.Error(t, err)
+	require.Len(t, res.Servers, 1)
+	assert.Nil(t, res.Servers[0].Response)
+	assert.NotEmpty(t, res.Servers[0].Error)
+	var timeoutErr *dsakerr.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, dsakerr.PhaseRead, timeoutErr.Phase)
+
+	// The total timeout is not a phase of the query, the command reports it.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client = NewClient(zap.NewNop(), WithServers(pc.LocalAddr().String()), WithTimeouts(time.Second, time.Second))
+	_, err = client.Query(ctx, TypeA, "example.com")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, errors.As(err, &timeoutErr))
+}
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		in        string
+		transport Transport
+		address   string
+		name      string
+		str       string
+	}{
+		{"192.0.2.1", TransportUDP, "192.0.2.1:53", "192.0.2.1", "192.0.2.1:53"},
+		{"192.0.2.1:5353", TransportUDP, "192.0.2.1:5353", "192.0.2.1", "192.0.2.1:5353"},
+		{"2001:db8::1", TransportUDP, "[2001:db8::1]:53", "2001:db8::1", "[2001:db8::1]:53"},
+		{"[2001:db8::1]", TransportUDP, "[2001:db8::1]:53", "2001:db8::1", "[2001:db8::1]:53"},
+		{"tcp://dns.example", TransportTCP, "dns.example:53", "dns.example", "tcp://dns.example:53"},
+		{"tls://1.1.1.1", TransportTLS, "1.1.1.1:853", "1.1.1.1", "tls://1.1.1.1:853"},
+		{"QUIC://dns.example:8853", TransportQUIC, "dns.example:8853", "dns.example", "quic://dns.example:8853"},
+		{"https://dns.example", TransportHTTPS, "https://dns.example/dns-query", "dns.example", "https://dns.example/dns-query"},
+		{"https://dns.example/q?x=1", TransportHTTPS, "https://dns.example/q?x=1", "dns.example", "https://dns.example/q?x=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			s, err := parseServer(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.transport, s.transport)
+			assert.Equal(t, tt.address, s.address)
+			assert.Equal(t, tt.name, s.name)
+			assert.Equal(t, tt.str, s.String())
+		})
+	}
+
+	_, err := parseServer("ftp://dns.example")
+	require.Error(t, err)
+	_, err = parseServer("tls://")
+	require.Error(t, err)
+}
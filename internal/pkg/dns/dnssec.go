@@ -0,0 +1,816 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+)
+
+// DNSSEC validation statuses, see RFC 4035 section 4.3.
+const (
+	// DNSSECSecure is an answer whose signatures chain up to a trust anchor.
+	DNSSECSecure = "secure"
+	// DNSSECInsecure is an answer of a zone proven to be unsigned, eg: its parent has no DS record for it.
+	DNSSECInsecure = "insecure"
+	// DNSSECBogus is an answer that should be signed but whose signatures do not validate.
+	DNSSECBogus = "bogus"
+	// DNSSECIndeterminate is an answer that could not be validated, eg: the keys could not be queried.
+	DNSSECIndeterminate = "indeterminate"
+)
+
+// rootTrustAnchors are the DS records of the root key signing keys, as published on
+// https://data.iana.org/root-anchors/root-anchors.xml.
+var rootTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBB683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// DNSSEC is the result of the DNSSEC validation of a response.
+type DNSSEC struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	// Chain are the zones whose keys were validated, from the trust anchor.
+	Chain []DNSSECZone `json:"chain"`
+}
+
+// DNSSECZone is the validation of the keys of a zone.
+type DNSSECZone struct {
+	Zone   string `json:"zone"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	// Keys are the keys of the zone, eg: "20326 RSASHA256 KSK".
+	Keys []string `json:"keys,omitempty"`
+}
+
+// WithDNSSEC sets the DO and CD bits of queries and validates the responses, against the root trust
+// anchor or the given ones. Anchors are DS or DNSKEY records in the presentation format.
+func WithDNSSEC(anchors ...string) Option {
+	return func(c *Client) {
+		c.dnssec = true
+		c.trustAnchors = anchors
+	}
+}
+
+// parseTrustAnchors parses DS and DNSKEY records, DNSKEY ones being converted to DS records, by zone.
+func parseTrustAnchors(anchors []string) (map[string][]*dnslib.DS, error) {
+	if len(anchors) == 0 {
+		anchors = rootTrustAnchors
+	}
+	res := make(map[string][]*dnslib.DS)
+	for _, a := range anchors {
+		rr, err := dnslib.NewRR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor %q: %w", a, err)
+		}
+		var ds *dnslib.DS
+		switch rr := rr.(type) {
+		case *dnslib.DS:
+			ds = rr
+		case *dnslib.DNSKEY:
+			ds = rr.ToDS(dnslib.SHA256)
+		default:
+			return nil, fmt.Errorf("invalid trust anchor %q: not a DS or DNSKEY record", a)
+		}
+		if ds == nil {
+			return nil, fmt.Errorf("invalid trust anchor %q", a)
+		}
+		zone := dnslib.CanonicalName(ds.Hdr.Name)
+		res[zone] = append(res[zone], ds)
+	}
+	return res, nil
+}
+
+// CheckTrustAnchors returns an error if an anchor is not a valid DS or DNSKEY record, see WithDNSSEC.
+func CheckTrustAnchors(anchors ...string) error {
+	_, err := parseTrustAnchors(anchors)
+	return err
+}
+
+// zoneKeys is the validation of the keys of a zone.
+type zoneKeys struct {
+	status string
+	reason string
+	// keys are the zone keys, if the status is secure.
+	keys []*dnslib.DNSKEY
+	all  []*dnslib.DNSKEY
+}
+
+// validator validates the responses of a server, querying it for the keys of the zones.
+type validator struct {
+	ctx     context.Context
+	client  *Client
+	server  *server
+	anchors map[string][]*dnslib.DS
+	now     time.Time
+	msgs    map[string]*dnslib.Msg
+	zones   map[string]*zoneKeys
+}
+
+func (c *Client) newValidator(ctx context.Context, s *server) (*validator, error) {
+	anchors, err := parseTrustAnchors(c.trustAnchors)
+	if err != nil {
+		return nil, err
+	}
+	return &validator{
+		ctx:     ctx,
+		client:  c,
+		server:  s,
+		anchors: anchors,
+		now:     time.Now(),
+		msgs:    make(map[string]*dnslib.Msg),
+		zones:   make(map[string]*zoneKeys),
+	}, nil
+}
+
+// dnssecQuery returns a query with the DO and CD bits set, the validation being done by the client.
+func dnssecQuery(name string, rType uint16) *dnslib.Msg {
+	m := new(dnslib.Msg)
+	m.SetQuestion(name, rType)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+	return m
+}
+
+// query queries the server, retrying over TCP if the UDP response is truncated.
+func (v *validator) query(name string, rType uint16) (*dnslib.Msg, error) {
+	key := dnslib.CanonicalName(name) + " " + dnslib.TypeToString[rType]
+	if m, ok := v.msgs[key]; ok {
+		return m, nil
+	}
+	in, _, _, err := v.client.exchange(v.ctx, v.server, dnssecQuery(name, rType))
+	if err == nil && in.Truncated && v.server.transport == TransportUDP {
+		in, _, _, err = v.client.exchange(v.ctx, &server{transport: TransportTCP, address: v.server.address, name: v.server.name}, dnssecQuery(name, rType))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s query for %s failed: %w", dnslib.TypeToString[rType], name, err)
+	}
+	if in.Rcode != dnslib.RcodeSuccess && in.Rcode != dnslib.RcodeNameError {
+		return nil, fmt.Errorf("%s query for %s answered %s", dnslib.TypeToString[rType], name, dnslib.RcodeToString[in.Rcode])
+	}
+	v.msgs[key] = in
+	return in, nil
+}
+
+// rrset is the records of a name and type, with their signatures.
+type rrset struct {
+	name  string
+	rType uint16
+	rrs   []dnslib.RR
+	sigs  []*dnslib.RRSIG
+}
+
+// rrsets groups records by name and type, in order, RRSIG records being attached to the set they cover.
+func rrsets(rrs []dnslib.RR) []*rrset {
+	var sets []*rrset
+	index := make(map[string]*rrset)
+	get := func(name string, rType uint16) *rrset {
+		key := dnslib.CanonicalName(name) + " " + dnslib.TypeToString[rType]
+		set, ok := index[key]
+		if !ok {
+			set = &rrset{name: dnslib.CanonicalName(name), rType: rType}
+			index[key] = set
+			sets = append(sets, set)
+		}
+		return set
+	}
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Rrtype == dnslib.TypeOPT {
+			continue
+		}
+		if sig, ok := rr.(*dnslib.RRSIG); ok {
+			set := get(h.Name, sig.TypeCovered)
+			set.sigs = append(set.sigs, sig)
+			continue
+		}
+		set := get(h.Name, h.Rrtype)
+		set.rrs = append(set.rrs, rr)
+	}
+	return sets
+}
+
+func findRRset(sets []*rrset, name string, rType uint16) *rrset {
+	for _, s := range sets {
+		if s.name == dnslib.CanonicalName(name) && s.rType == rType && len(s.rrs) > 0 {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *rrset) String() string {
+	return s.name + " " + dnslib.TypeToString[s.rType]
+}
+
+// supportedAlgorithm returns true if signatures of the algorithm can be verified.
+func supportedAlgorithm(alg uint8) bool {
+	switch alg {
+	case dnslib.RSASHA1, dnslib.RSASHA1NSEC3SHA1, dnslib.RSASHA256, dnslib.RSASHA512,
+		dnslib.ECDSAP256SHA256, dnslib.ECDSAP384SHA384, dnslib.ED25519:
+		return true
+	default:
+		return false
+	}
+}
+
+func supportedDigest(digest uint8) bool {
+	return digest == dnslib.SHA1 || digest == dnslib.SHA256 || digest == dnslib.SHA384
+}
+
+// verify checks that a signature of the set is valid now and made by one of keys.
+func (v *validator) verify(set *rrset, keys []*dnslib.DNSKEY) error {
+	if len(set.sigs) == 0 {
+		return fmt.Errorf("%s is not signed", set)
+	}
+	var errs []error
+	for _, sig := range set.sigs {
+		if !supportedAlgorithm(sig.Algorithm) {
+			errs = append(errs, fmt.Errorf("signature of %s uses the unsupported algorithm %s", set, dnslib.AlgorithmToString[sig.Algorithm]))
+			continue
+		}
+		if !sig.ValidityPeriod(v.now) {
+			expiration := time.Unix(int64(sig.Expiration), 0).UTC()
+			if v.now.After(expiration) {
+				errs = append(errs, fmt.Errorf("signature of %s by key %d expired on %s", set, sig.KeyTag, expiration.Format(time.RFC3339)))
+			} else {
+				errs = append(errs, fmt.Errorf(
+					"signature of %s by key %d is not valid before %s",
+					set, sig.KeyTag, time.Unix(int64(sig.Inception), 0).UTC().Format(time.RFC3339),
+				))
+			}
+			continue
+		}
+		found := false
+		for _, k := range keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			found = true
+			if err := sig.Verify(k, set.rrs); err != nil {
+				errs = append(errs, fmt.Errorf("signature of %s by key %d is invalid: %w", set, sig.KeyTag, err))
+				continue
+			}
+			return nil
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf(
+				"signature of %s is made by key %d (%s) of %s, which is not a key of the zone",
+				set, sig.KeyTag, dnslib.AlgorithmToString[sig.Algorithm], sig.SignerName,
+			))
+		}
+	}
+	return errs[0]
+}
+
+// zoneOf returns the zone name belongs to, from the SOA record of a SOA query.
+func (v *validator) zoneOf(name string) (string, error) {
+	name = dnslib.CanonicalName(name)
+	in, err := v.query(name, dnslib.TypeSOA)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range append(append([]dnslib.RR{}, in.Answer...), in.Ns...) {
+		if soa, ok := rr.(*dnslib.SOA); ok && dnslib.IsSubDomain(dnslib.CanonicalName(soa.Hdr.Name), name) {
+			return dnslib.CanonicalName(soa.Hdr.Name), nil
+		}
+	}
+	return "", fmt.Errorf("cannot find the zone of %s", name)
+}
+
+// parentName returns name without its first label.
+func parentName(name string) string {
+	if name == "." {
+		return "."
+	}
+	_, rest, _ := strings.Cut(name, ".")
+	if rest == "" {
+		return "."
+	}
+	return rest
+}
+
+// zone validates the keys of zone, from a trust anchor or the DS records of its parent zone.
+func (v *validator) zone(zone string) *zoneKeys {
+	zone = dnslib.CanonicalName(zone)
+	if zk, ok := v.zones[zone]; ok {
+		return zk
+	}
+	zk := &zoneKeys{status: DNSSECIndeterminate, reason: "loop in the chain of trust"}
+	v.zones[zone] = zk
+	if anchors, ok := v.anchors[zone]; ok {
+		v.verifyKeys(zk, zone, anchors, "trust anchor")
+		return zk
+	}
+	if zone == "." {
+		zk.reason = "no trust anchor for the root zone"
+		return zk
+	}
+
+	in, err := v.query(zone, dnslib.TypeDS)
+	if err != nil {
+		zk.reason = err.Error()
+		return zk
+	}
+	sets := rrsets(in.Answer)
+	if ds := findRRset(sets, zone, dnslib.TypeDS); ds != nil {
+		signer := parentName(zone)
+		if len(ds.sigs) > 0 {
+			signer = dnslib.CanonicalName(ds.sigs[0].SignerName)
+		} else if signer, err = v.zoneOf(signer); err != nil {
+			zk.reason = err.Error()
+			return zk
+		}
+		if signer == zone || !dnslib.IsSubDomain(signer, zone) {
+			zk.status, zk.reason = DNSSECBogus, fmt.Sprintf("DS records of %s are signed by %s, which is not a parent zone", zone, signer)
+			return zk
+		}
+		if parent := v.zone(signer); parent.status != DNSSECSecure {
+			zk.status, zk.reason = parent.status, parent.reason
+			return zk
+		} else if err := v.verify(ds, parent.keys); err != nil {
+			zk.status, zk.reason = DNSSECBogus, err.Error()
+			return zk
+		}
+		var supported []*dnslib.DS
+		for _, rr := range ds.rrs {
+			if d := rr.(*dnslib.DS); supportedAlgorithm(d.Algorithm) && supportedDigest(d.DigestType) {
+				supported = append(supported, d)
+			}
+		}
+		if len(supported) == 0 {
+			// RFC 4035 section 5.2: a zone signed with unsupported algorithms only is treated as unsigned.
+			zk.status, zk.reason = DNSSECInsecure, fmt.Sprintf("DS records of %s only use unsupported algorithms", zone)
+			return zk
+		}
+		v.verifyKeys(zk, zone, supported, "DS records")
+		return zk
+	}
+
+	// No DS record: the parent zone must prove it does not exist.
+	parentZone := ""
+	for _, set := range rrsets(in.Ns) {
+		if len(set.sigs) > 0 {
+			parentZone = dnslib.CanonicalName(set.sigs[0].SignerName)
+			break
+		}
+		if set.rType == dnslib.TypeSOA {
+			parentZone = set.name
+		}
+	}
+	if parentZone == "" {
+		if parentZone, err = v.zoneOf(parentName(zone)); err != nil {
+			zk.reason = err.Error()
+			return zk
+		}
+	}
+	if parentZone == zone || !dnslib.IsSubDomain(parentZone, zone) {
+		zk.reason = fmt.Sprintf("the DS query for %s was answered by %s, which is not a parent zone", zone, parentZone)
+		return zk
+	}
+	parent := v.zone(parentZone)
+	if parent.status != DNSSECSecure {
+		zk.status, zk.reason = parent.status, parent.reason
+		return zk
+	}
+	optOut, err := v.verifyDenial(in, zone, dnslib.TypeDS, parent.keys)
+	if err != nil {
+		zk.status, zk.reason = DNSSECBogus, fmt.Sprintf("missing DS for %s, without a valid proof of its absence: %s", zone, err)
+		return zk
+	}
+	zk.status, zk.reason = DNSSECInsecure, fmt.Sprintf("no DS record for %s in %s", zone, parentZone)
+	if optOut {
+		zk.reason += ", proven by an opt-out NSEC3 record"
+	}
+	return zk
+}
+
+// verifyKeys validates the DNSKEY records of zone: one of them must match ds and sign the set.
+func (v *validator) verifyKeys(zk *zoneKeys, zone string, ds []*dnslib.DS, source string) {
+	in, err := v.query(zone, dnslib.TypeDNSKEY)
+	if err != nil {
+		zk.status, zk.reason = DNSSECIndeterminate, err.Error()
+		return
+	}
+	set := findRRset(rrsets(in.Answer), zone, dnslib.TypeDNSKEY)
+	if set == nil {
+		zk.status, zk.reason = DNSSECBogus, fmt.Sprintf("no DNSKEY record for %s, which has %s", zone, source)
+		return
+	}
+	var all, keys, matched []*dnslib.DNSKEY
+	for _, rr := range set.rrs {
+		k := rr.(*dnslib.DNSKEY)
+		all = append(all, k)
+		if k.Flags&dnslib.ZONE != 0 && k.Flags&dnslib.REVOKE == 0 {
+			keys = append(keys, k)
+		}
+	}
+	zk.all = all
+	var mismatch string
+	for _, d := range ds {
+		for _, k := range keys {
+			if k.KeyTag() != d.KeyTag {
+				continue
+			}
+			if k.Algorithm != d.Algorithm {
+				mismatch = fmt.Sprintf(
+					"algorithm mismatch: %s %d of %s uses %s, DNSKEY %d uses %s",
+					source, d.KeyTag, zone, dnslib.AlgorithmToString[d.Algorithm], k.KeyTag(), dnslib.AlgorithmToString[k.Algorithm],
+				)
+				continue
+			}
+			if kd := k.ToDS(d.DigestType); kd != nil && strings.EqualFold(kd.Digest, d.Digest) {
+				matched = append(matched, k)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		zk.status = DNSSECBogus
+		if mismatch != "" {
+			zk.reason = mismatch
+			return
+		}
+		tags := make([]string, 0, len(ds))
+		for _, d := range ds {
+			tags = append(tags, fmt.Sprint(d.KeyTag))
+		}
+		zk.reason = fmt.Sprintf("no DNSKEY of %s matches the %s (key tags %s)", zone, source, strings.Join(tags, ", "))
+		return
+	}
+	if err := v.verify(set, matched); err != nil {
+		zk.status, zk.reason = DNSSECBogus, err.Error()
+		return
+	}
+	zk.status, zk.reason, zk.keys = DNSSECSecure, fmt.Sprintf("DNSKEY records of %s match the %s", zone, source), keys
+}
+
+// verifyDenial validates the NSEC or NSEC3 records of a negative response, proving that name does
+// not exist, or has no record of type rType. optOut is true if the proof relies on an opt-out NSEC3 record,
+// which does not prove that an unsigned delegation does not exist.
+func (v *validator) verifyDenial(in *dnslib.Msg, name string, rType uint16, keys []*dnslib.DNSKEY) (bool, error) {
+	name = dnslib.CanonicalName(name)
+	var nsecs []*dnslib.NSEC
+	var nsec3s []*dnslib.NSEC3
+	for _, set := range rrsets(in.Ns) {
+		if set.rType != dnslib.TypeNSEC && set.rType != dnslib.TypeNSEC3 {
+			continue
+		}
+		if err := v.verify(set, keys); err != nil {
+			return false, err
+		}
+		for _, rr := range set.rrs {
+			switch rr := rr.(type) {
+			case *dnslib.NSEC:
+				nsecs = append(nsecs, rr)
+			case *dnslib.NSEC3:
+				nsec3s = append(nsec3s, rr)
+			}
+		}
+	}
+	nxdomain := in.Rcode == dnslib.RcodeNameError
+	switch {
+	case len(nsec3s) > 0:
+		return verifyNSEC3Denial(nsec3s, name, rType, nxdomain)
+	case len(nsecs) > 0:
+		return false, verifyNSECDenial(nsecs, name, rType, nxdomain)
+	default:
+		return false, errors.New("no NSEC or NSEC3 record in the response")
+	}
+}
+
+func hasType(bitmap []uint16, rType uint16) bool {
+	for _, t := range bitmap {
+		if t == rType {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalCompare compares domain names in the canonical order of RFC 4034 section 6.1.
+func canonicalCompare(a, b string) int {
+	la := dnslib.SplitDomainName(dnslib.CanonicalName(a))
+	lb := dnslib.SplitDomainName(dnslib.CanonicalName(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := bytes.Compare([]byte(la[i]), []byte(lb[j])); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// nsecCovers returns true if name is between the owner and the next name of nsec.
+func nsecCovers(nsec *dnslib.NSEC, name string) bool {
+	owner, next := nsec.Hdr.Name, nsec.NextDomain
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(owner, name) < 0 && canonicalCompare(name, next) < 0
+	}
+	// The last NSEC record of the zone, whose next name is the apex.
+	return canonicalCompare(owner, name) < 0 || canonicalCompare(name, next) < 0
+}
+
+func verifyNSECDenial(nsecs []*dnslib.NSEC, name string, rType uint16, nxdomain bool) error {
+	for _, n := range nsecs {
+		if dnslib.CanonicalName(n.Hdr.Name) != name {
+			continue
+		}
+		if nxdomain {
+			return fmt.Errorf("NXDOMAIN response with an NSEC record proving %s exists", name)
+		}
+		if hasType(n.TypeBitMap, rType) || hasType(n.TypeBitMap, dnslib.TypeCNAME) {
+			return fmt.Errorf("NSEC record of %s proves it has %s records", name, dnslib.TypeToString[rType])
+		}
+		return nil
+	}
+	var covering *dnslib.NSEC
+	for _, n := range nsecs {
+		if nsecCovers(n, name) {
+			covering = n
+			break
+		}
+	}
+	if covering == nil {
+		return fmt.Errorf("no NSEC record matches or covers %s", name)
+	}
+	// The closest encloser is the longest ancestor of name that is an ancestor of the owner or next name.
+	ce := name
+	for ce != "." && !dnslib.IsSubDomain(ce, dnslib.CanonicalName(covering.Hdr.Name)) && !dnslib.IsSubDomain(ce, dnslib.CanonicalName(covering.NextDomain)) {
+		ce = parentName(ce)
+	}
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+	for _, n := range nsecs {
+		if nsecCovers(n, wildcard) {
+			return nil
+		}
+		if !nxdomain && dnslib.CanonicalName(n.Hdr.Name) == wildcard && !hasType(n.TypeBitMap, rType) && !hasType(n.TypeBitMap, dnslib.TypeCNAME) {
+			// Wildcard no data, RFC 4035 section 3.1.3.4.
+			return nil
+		}
+	}
+	return fmt.Errorf("no NSEC record proves that the wildcard %s does not exist", wildcard)
+}
+
+// nsec3ClosestEncloser returns the closest encloser of name, the longest ancestor with a matching NSEC3
+// record, and the NSEC3 record covering the next closer name, see RFC 5155 section 8.3.
+func nsec3ClosestEncloser(nsec3s []*dnslib.NSEC3, name string) (string, *dnslib.NSEC3, error) {
+	nextCloser := name
+	for ce := parentName(name); ; ce = parentName(ce) {
+		for _, n := range nsec3s {
+			if !n.Match(ce) {
+				continue
+			}
+			for _, c := range nsec3s {
+				if c.Cover(nextCloser) {
+					return ce, c, nil
+				}
+			}
+			return "", nil, fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+		}
+		if ce == "." {
+			return "", nil, fmt.Errorf("no NSEC3 record matches an ancestor of %s", name)
+		}
+		nextCloser = ce
+	}
+}
+
+func verifyNSEC3Denial(nsec3s []*dnslib.NSEC3, name string, rType uint16, nxdomain bool) (bool, error) {
+	for _, n := range nsec3s {
+		if !n.Match(name) {
+			continue
+		}
+		if nxdomain {
+			return false, fmt.Errorf("NXDOMAIN response with an NSEC3 record proving %s exists", name)
+		}
+		if hasType(n.TypeBitMap, rType) || hasType(n.TypeBitMap, dnslib.TypeCNAME) {
+			return false, fmt.Errorf("NSEC3 record of %s proves it has %s records", name, dnslib.TypeToString[rType])
+		}
+		return false, nil
+	}
+	ce, covering, err := nsec3ClosestEncloser(nsec3s, name)
+	if err != nil {
+		return false, err
+	}
+	if covering.Flags&1 == 1 && (rType == dnslib.TypeDS || nxdomain) {
+		// Opt-out: the next closer name may be an unsigned delegation, RFC 5155 section 6.
+		return true, nil
+	}
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+	for _, n := range nsec3s {
+		if n.Cover(wildcard) {
+			return false, nil
+		}
+		if !nxdomain && n.Match(wildcard) && !hasType(n.TypeBitMap, rType) && !hasType(n.TypeBitMap, dnslib.TypeCNAME) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("no NSEC3 record proves that the wildcard %s does not exist", wildcard)
+}
+
+// verifyWildcard checks that the name of a record set expanded from a wildcard does not exist.
+func (v *validator) verifyWildcard(in *dnslib.Msg, set *rrset, keys []*dnslib.DNSKEY) error {
+	var nsecs []*dnslib.NSEC
+	var nsec3s []*dnslib.NSEC3
+	for _, s := range rrsets(in.Ns) {
+		if s.rType != dnslib.TypeNSEC && s.rType != dnslib.TypeNSEC3 {
+			continue
+		}
+		if err := v.verify(s, keys); err != nil {
+			return err
+		}
+		for _, rr := range s.rrs {
+			switch rr := rr.(type) {
+			case *dnslib.NSEC:
+				nsecs = append(nsecs, rr)
+			case *dnslib.NSEC3:
+				nsec3s = append(nsec3s, rr)
+			}
+		}
+	}
+	for _, n := range nsecs {
+		if nsecCovers(n, set.name) {
+			return nil
+		}
+	}
+	// With NSEC3, the next closer name of the wildcard source must be covered.
+	labels := dnslib.SplitDomainName(set.name)
+	sig := set.sigs[0]
+	if int(sig.Labels) < len(labels) {
+		nextCloser := dnslib.Fqdn(strings.Join(labels[len(labels)-int(sig.Labels)-1:], "."))
+		for _, n := range nsec3s {
+			if n.Cover(nextCloser) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is expanded from a wildcard, without a proof that %s does not exist", set, set.name)
+}
+
+// validate validates the response in to the query of name and rType.
+func (v *validator) validate(in *dnslib.Msg, name string, rType uint16) *DNSSEC {
+	res := &DNSSEC{Status: DNSSECSecure}
+	defer func() {
+		res.Chain = v.chain()
+	}()
+	if in.Rcode != dnslib.RcodeSuccess && in.Rcode != dnslib.RcodeNameError {
+		res.Status, res.Reason = DNSSECIndeterminate, "the server answered "+dnslib.RcodeToString[in.Rcode]
+		return res
+	}
+	var insecure, secure []string
+	// bogus sets the status, unless it is already bogus.
+	bogus := func(status, reason string) {
+		if res.Status != DNSSECBogus {
+			res.Status, res.Reason = status, reason
+		}
+	}
+
+	// Follows the CNAME chain, validating each record set of the answer with the keys of its signer.
+	sets := rrsets(in.Answer)
+	target := dnslib.CanonicalName(name)
+	for i := 0; i < len(sets); i++ {
+		if cname := findRRset(sets, target, dnslib.TypeCNAME); cname != nil && rType != dnslib.TypeCNAME {
+			target = dnslib.CanonicalName(cname.rrs[0].(*dnslib.CNAME).Target)
+		}
+	}
+	for _, set := range sets {
+		if len(set.rrs) == 0 {
+			continue
+		}
+		status, reason := v.validateSet(in, set)
+		switch status {
+		case DNSSECSecure:
+			secure = append(secure, reason)
+		case DNSSECInsecure:
+			insecure = append(insecure, reason)
+		default:
+			bogus(status, reason)
+		}
+	}
+
+	if findRRset(sets, target, rType) == nil {
+		status, reason := v.validateNegative(in, target, rType)
+		switch status {
+		case DNSSECSecure:
+			secure = append(secure, reason)
+		case DNSSECInsecure:
+			insecure = append(insecure, reason)
+		default:
+			bogus(status, reason)
+		}
+	}
+	if res.Status != DNSSECSecure {
+		return res
+	}
+	if len(insecure) > 0 {
+		res.Status, res.Reason = DNSSECInsecure, insecure[0]
+		return res
+	}
+	res.Reason = strings.Join(secure, ", ")
+	return res
+}
+
+// validateSet validates a record set of the answer.
+func (v *validator) validateSet(in *dnslib.Msg, set *rrset) (string, string) {
+	if len(set.sigs) == 0 {
+		zone, err := v.zoneOf(set.name)
+		if err != nil {
+			return DNSSECIndeterminate, err.Error()
+		}
+		zk := v.zone(zone)
+		if zk.status == DNSSECSecure {
+			return DNSSECBogus, fmt.Sprintf("%s is not signed, while %s is signed", set, zone)
+		}
+		return zk.status, zk.reason
+	}
+	signer := dnslib.CanonicalName(set.sigs[0].SignerName)
+	if !dnslib.IsSubDomain(signer, set.name) {
+		return DNSSECBogus, fmt.Sprintf("%s is signed by %s, which is not one of its zones", set, signer)
+	}
+	zk := v.zone(signer)
+	if zk.status != DNSSECSecure {
+		return zk.status, zk.reason
+	}
+	if err := v.verify(set, zk.keys); err != nil {
+		return DNSSECBogus, err.Error()
+	}
+	if int(set.sigs[0].Labels) < len(dnslib.SplitDomainName(set.name)) {
+		if err := v.verifyWildcard(in, set, zk.keys); err != nil {
+			return DNSSECBogus, err.Error()
+		}
+	}
+	return DNSSECSecure, fmt.Sprintf("%s is signed by %s", set, signer)
+}
+
+// validateNegative validates the proof that name does not exist, or has no record of type rType.
+func (v *validator) validateNegative(in *dnslib.Msg, name string, rType uint16) (string, string) {
+	what := fmt.Sprintf("no %s record for %s", dnslib.TypeToString[rType], name)
+	if in.Rcode == dnslib.RcodeNameError {
+		what = name + " does not exist"
+	}
+	signer := ""
+	for _, set := range rrsets(in.Ns) {
+		if len(set.sigs) > 0 {
+			signer = dnslib.CanonicalName(set.sigs[0].SignerName)
+			break
+		}
+	}
+	if signer == "" {
+		zone, err := v.zoneOf(name)
+		if err != nil {
+			return DNSSECIndeterminate, err.Error()
+		}
+		zk := v.zone(zone)
+		if zk.status == DNSSECSecure {
+			return DNSSECBogus, fmt.Sprintf("%s, without a signed proof while %s is signed", what, zone)
+		}
+		return zk.status, zk.reason
+	}
+	zk := v.zone(signer)
+	if zk.status != DNSSECSecure {
+		return zk.status, zk.reason
+	}
+	if soa := findRRset(rrsets(in.Ns), signer, dnslib.TypeSOA); soa != nil {
+		if err := v.verify(soa, zk.keys); err != nil {
+			return DNSSECBogus, err.Error()
+		}
+	}
+	optOut, err := v.verifyDenial(in, name, rType, zk.keys)
+	if err != nil {
+		return DNSSECBogus, fmt.Sprintf("%s, without a valid proof: %s", what, err)
+	}
+	if optOut {
+		return DNSSECInsecure, what + ", proven by an opt-out NSEC3 record"
+	}
+	return DNSSECSecure, what + ", proven by " + signer
+}
+
+// chain returns the validated zones, from the root.
+func (v *validator) chain() []DNSSECZone {
+	zones := make([]DNSSECZone, 0, len(v.zones))
+	for name, zk := range v.zones {
+		z := DNSSECZone{Zone: name, Status: zk.status, Reason: zk.reason}
+		for _, k := range zk.all {
+			role := "ZSK"
+			if k.Flags&dnslib.SEP != 0 {
+				role = "KSK"
+			}
+			z.Keys = append(z.Keys, fmt.Sprintf("%d %s %s", k.KeyTag(), dnslib.AlgorithmToString[k.Algorithm], role))
+		}
+		zones = append(zones, z)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return dnslib.CountLabel(zones[i].Zone) < dnslib.CountLabel(zones[j].Zone) ||
+			(dnslib.CountLabel(zones[i].Zone) == dnslib.CountLabel(zones[j].Zone) && zones[i].Zone < zones[j].Zone)
+	})
+	return zones
+}
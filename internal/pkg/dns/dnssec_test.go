@@ -0,0 +1,199 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// signedZone is a zone with a key signing key and a zone signing key.
+type signedZone struct {
+	name       string
+	ksk, zsk   *dnslib.DNSKEY
+	kskPrivate crypto.Signer
+	zskPrivate crypto.Signer
+}
+
+func newSignedZone(t *testing.T, name string) *signedZone {
+	t.Helper()
+	z := &signedZone{name: name}
+	for _, flags := range []uint16{dnslib.ZONE | dnslib.SEP, dnslib.ZONE} {
+		k := &dnslib.DNSKEY{
+			Hdr:       dnslib.RR_Header{Name: name, Rrtype: dnslib.TypeDNSKEY, Class: dnslib.ClassINET, Ttl: 3600},
+			Flags:     flags,
+			Protocol:  3,
+			Algorithm: dnslib.ECDSAP256SHA256,
+		}
+		priv, err := k.Generate(256)
+		require.NoError(t, err)
+		if flags&dnslib.SEP != 0 {
+			z.ksk, z.kskPrivate = k, priv.(crypto.Signer)
+		} else {
+			z.zsk, z.zskPrivate = k, priv.(crypto.Signer)
+		}
+	}
+	return z
+}
+
+// sign returns rrs with their signature by the zone signing key, or the key signing key for DNSKEY records,
+// valid from inception to expiration.
+func (z *signedZone) sign(t *testing.T, inception, expiration time.Time, rrs ...dnslib.RR) []dnslib.RR {
+	t.Helper()
+	key, priv := z.zsk, z.zskPrivate
+	if rrs[0].Header().Rrtype == dnslib.TypeDNSKEY {
+		key, priv = z.ksk, z.kskPrivate
+	}
+	sig := &dnslib.RRSIG{
+		Hdr:        dnslib.RR_Header{Name: rrs[0].Header().Name, Rrtype: dnslib.TypeRRSIG, Class: dnslib.ClassINET, Ttl: rrs[0].Header().Ttl},
+		Algorithm:  key.Algorithm,
+		KeyTag:     key.KeyTag(),
+		SignerName: z.name,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	require.NoError(t, sig.Sign(priv, rrs))
+	return append(append([]dnslib.RR{}, rrs...), sig)
+}
+
+func mustRR(t *testing.T, s string) dnslib.RR {
+	t.Helper()
+	rr, err := dnslib.NewRR(s)
+	require.NoError(t, err)
+	return rr
+}
+
+// startDNSSECServer starts a server answering like a resolver for a signed hierarchy:
+// the root zone, com., example.com., and unsigned.com. which has no DS record.
+func startDNSSECServer(t *testing.T) (string, *signedZone) {
+	t.Helper()
+	now := time.Now()
+	inception, expiration := now.Add(-time.Hour), now.Add(time.Hour)
+	root, com, example := newSignedZone(t, "."), newSignedZone(t, "com."), newSignedZone(t, "example.com.")
+
+	answers := map[string][]dnslib.RR{}
+	authority := map[string][]dnslib.RR{}
+	rcodes := map[string]int{}
+	for _, z := range []*signedZone{root, com, example} {
+		answers[z.name+" DNSKEY"] = z.sign(t, inception, expiration, z.ksk, z.zsk)
+	}
+	answers["com. DS"] = root.sign(t, inception, expiration, com.ksk.ToDS(dnslib.SHA256))
+	answers["example.com. DS"] = com.sign(t, inception, expiration, example.ksk.ToDS(dnslib.SHA256))
+	answers["www.example.com. A"] = example.sign(t, inception, expiration, mustRR(t, "www.example.com. 300 IN A 192.0.2.1"))
+	answers["old.example.com. A"] = example.sign(t, now.Add(-2*time.Hour), now.Add(-time.Hour), mustRR(t, "old.example.com. 300 IN A 192.0.2.3"))
+	answers["www.unsigned.com. A"] = []dnslib.RR{mustRR(t, "www.unsigned.com. 300 IN A 192.0.2.2")}
+
+	comSOA := com.sign(t, inception, expiration, mustRR(t, "com. 900 IN SOA a.gtld. nstld. 1 1800 900 604800 86400"))
+	exampleSOA := example.sign(t, inception, expiration, mustRR(t, "example.com. 3600 IN SOA ns.example.com. admin.example.com. 1 7200 3600 1209600 3600"))
+	unsignedSOA := []dnslib.RR{mustRR(t, "unsigned.com. 3600 IN SOA ns.unsigned.com. admin.unsigned.com. 1 7200 3600 1209600 3600")}
+	answers["example.com. SOA"] = exampleSOA
+	authority["www.unsigned.com. SOA"] = unsignedSOA
+	authority["unsigned.com. DS"] = append(
+		append([]dnslib.RR{}, comSOA...),
+		com.sign(t, inception, expiration, mustRR(t, "unsigned.com. 86400 IN NSEC zzz.com. NS RRSIG NSEC"))...,
+	)
+	rcodes["nx.example.com. A"] = dnslib.RcodeNameError
+	authority["nx.example.com. A"] = append(
+		append(append([]dnslib.RR{}, exampleSOA...),
+			example.sign(t, inception, expiration, mustRR(t, "example.com. 3600 IN NSEC www.example.com. NS SOA RRSIG NSEC DNSKEY"))...),
+		example.sign(t, inception, expiration, mustRR(t, "www.example.com. 3600 IN NSEC example.com. A RRSIG NSEC"))...,
+	)
+
+	address := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		key := r.Question[0].Name + " " + dnslib.TypeToString[r.Question[0].Qtype]
+		m.Rcode = rcodes[key]
+		m.Answer = answers[key]
+		m.Ns = authority[key]
+		_ = w.WriteMsg(m)
+	})
+	return address, root
+}
+
+func TestClient_Query_dnssec(t *testing.T) {
+	server, root := startDNSSECServer(t)
+	anchor := root.ksk.ToDS(dnslib.SHA256).String()
+
+	tests := []struct {
+		name   string
+		domain string
+		status string
+		reason string
+	}{
+		{"secure", "www.example.com.", DNSSECSecure, "www.example.com. A is signed by example.com."},
+		{"expired", "old.example.com.", DNSSECBogus, "signature of old.example.com. A by key"},
+		{"insecure", "www.unsigned.com.", DNSSECInsecure, "no DS record for unsigned.com. in com."},
+		{"nxdomain", "nx.example.com.", DNSSECSecure, "nx.example.com. does not exist, proven by example.com."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(zap.NewNop(), WithServers(server), WithDNSSEC(anchor), WithTimeouts(time.Second, time.Second))
+			res, err := client.Query(context.Background(), TypeA, tt.domain)
+			require.NoError(t, err)
+			require.NotNil(t, res.Servers[0].Response.DNSSEC)
+			assert.Equal(t, tt.status, res.Servers[0].Response.DNSSEC.Status)
+			assert.Contains(t, res.Servers[0].Response.DNSSEC.Reason, tt.reason)
+		})
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		client := NewClient(zap.NewNop(), WithServers(server), WithDNSSEC(anchor))
+		res, err := client.Query(context.Background(), TypeA, "www.example.com")
+		require.NoError(t, err)
+		chain := res.Servers[0].Response.DNSSEC.Chain
+		require.Len(t, chain, 3)
+		assert.Equal(t, ".", chain[0].Zone)
+		assert.Equal(t, "example.com.", chain[2].Zone)
+		assert.Equal(t, DNSSECSecure, chain[2].Status)
+		assert.Len(t, chain[2].Keys, 2)
+		assert.True(t, res.Servers[0].Response.Flags.CheckingDisabled)
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, res.RenderText(buf))
+		assert.Contains(t, buf.String(), "DNSSEC: secure")
+		assert.Contains(t, buf.String(), "DNSSEC ZONE: com. secure")
+	})
+
+	t.Run("wrong trust anchor", func(t *testing.T) {
+		other := newSignedZone(t, ".")
+		client := NewClient(zap.NewNop(), WithServers(server), WithDNSSEC(other.ksk.String()))
+		res, err := client.Query(context.Background(), TypeA, "www.example.com")
+		require.NoError(t, err)
+		assert.Equal(t, DNSSECBogus, res.Servers[0].Response.DNSSEC.Status)
+		assert.Contains(t, res.Servers[0].Response.DNSSEC.Reason, "no DNSKEY of . matches the trust anchor")
+	})
+}
+
+func TestVerifyNSEC3Denial(t *testing.T) {
+	nsec3 := func(name string, optOut bool, types ...uint16) *dnslib.NSEC3 {
+		hash := dnslib.HashName(name, dnslib.SHA1, 0, "")
+		var flags uint8
+		if optOut {
+			flags = 1
+		}
+		// The next hashed owner name is the successor of the hash, the record covering nothing else.
+		return &dnslib.NSEC3{
+			Hdr:        dnslib.RR_Header{Name: hash + ".example.com.", Rrtype: dnslib.TypeNSEC3, Class: dnslib.ClassINET},
+			Hash:       dnslib.SHA1,
+			Flags:      flags,
+			NextDomain: hash[:len(hash)-1] + string(hash[len(hash)-1]+1),
+			TypeBitMap: types,
+		}
+	}
+	optOut, err := verifyNSEC3Denial([]*dnslib.NSEC3{nsec3("www.example.com.", false, dnslib.TypeA)}, "www.example.com.", dnslib.TypeAAAA, false)
+	require.NoError(t, err)
+	assert.False(t, optOut)
+
+	_, err = verifyNSEC3Denial([]*dnslib.NSEC3{nsec3("www.example.com.", false, dnslib.TypeA)}, "www.example.com.", dnslib.TypeA, false)
+	assert.ErrorContains(t, err, "proves it has A records")
+
+	_, err = verifyNSEC3Denial([]*dnslib.NSEC3{nsec3("example.com.", false, dnslib.TypeSOA)}, "nx.example.com.", dnslib.TypeA, true)
+	assert.ErrorContains(t, err, "no NSEC3 record covers the next closer name nx.example.com.")
+}
@@ -0,0 +1,316 @@
+package dns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode"
+
+	dnslib "github.com/miekg/dns"
+)
+
+const (
+	// defaultUDPSize is the EDNS UDP buffer size of queries with EDNS options, the one recommended by the DNS
+	// flag day 2020.
+	defaultUDPSize = 1232
+	// paddingBlockSize is the block size queries are padded to (RFC 8467).
+	paddingBlockSize = 128
+)
+
+// WithUDPSize sets the EDNS UDP buffer size advertised by queries, adding an OPT record to them.
+func WithUDPSize(size uint16) Option {
+	return func(c *Client) {
+		c.udpSize = size
+	}
+}
+
+// WithClientSubnet sets the EDNS Client Subnet option of queries (RFC 7871), so that servers answer like to
+// a client of subnet, eg: to check the answers of geographic steering. A subnet with a 0 bits prefix asks
+// servers not to use the address of the client.
+func WithClientSubnet(subnet netip.Prefix) Option {
+	return func(c *Client) {
+		c.clientSubnet = subnet.Masked()
+	}
+}
+
+// WithNSID requests the name server identifier of the servers (RFC 5001), eg: to know which instance of
+// an anycast server answered.
+func WithNSID() Option {
+	return func(c *Client) {
+		c.nsid = true
+	}
+}
+
+// WithCookie sends a random client cookie with queries (RFC 7873), servers supporting cookies answering
+// with their server cookie.
+func WithCookie() Option {
+	return func(c *Client) {
+		c.cookie = true
+	}
+}
+
+// WithPadding pads queries to a multiple of 128 bytes (RFC 7830, RFC 8467).
+func WithPadding() Option {
+	return func(c *Client) {
+		c.padding = true
+	}
+}
+
+// WithoutRecursion clears the RD (recursion desired) flag of queries, servers only answering from their
+// cache or their zones.
+func WithoutRecursion() Option {
+	return func(c *Client) {
+		c.noRecursion = true
+	}
+}
+
+// WithCheckingDisabled sets the CD (checking disabled) flag of queries, validating servers returning the
+// records that fail their validation.
+func WithCheckingDisabled() Option {
+	return func(c *Client) {
+		c.checkingDisabled = true
+	}
+}
+
+// WithAuthenticatedData sets the AD (authenticated data) flag of queries, asking validating servers to
+// tell if the records are validated without requesting the DNSSEC records (RFC 6840).
+func WithAuthenticatedData() Option {
+	return func(c *Client) {
+		c.authenticatedData = true
+	}
+}
+
+// WithTCP queries the servers given as addresses or udp:// URLs over TCP.
+func WithTCP() Option {
+	return func(c *Client) {
+		c.tcp = true
+	}
+}
+
+// WithTCPFallback queries again over TCP the UDP servers whose response is truncated.
+func WithTCPFallback() Option {
+	return func(c *Client) {
+		c.tcpFallback = true
+	}
+}
+
+// WithSource sets the source address and port of queries, an invalid address or a 0 port letting the
+// system choose them. It is not supported by DNS over HTTPS and QUIC servers.
+func WithSource(addr netip.Addr, port uint16) Option {
+	return func(c *Client) {
+		c.sourceAddr = addr
+		c.sourcePort = port
+	}
+}
+
+// ParseSource parses a source address and port given as "ip", "ip:port" or ":port", see WithSource.
+func ParseSource(s string) (netip.Addr, uint16, error) {
+	host, p, err := net.SplitHostPort(s)
+	if err != nil {
+		host, p = strings.Trim(s, "[]"), ""
+	}
+	var addr netip.Addr
+	if host != "" {
+		addr, err = netip.ParseAddr(host)
+		if err != nil {
+			return netip.Addr{}, 0, fmt.Errorf("invalid source address %s: %w", s, err)
+		}
+	}
+	var port uint64
+	if p != "" {
+		port, err = strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return netip.Addr{}, 0, fmt.Errorf("invalid source port %s: %w", s, err)
+		}
+	}
+	return addr, uint16(port), nil
+}
+
+// ParseClientSubnet parses a subnet given as a CIDR range or an address, for WithClientSubnet. The
+// prefix of an address is /24 for IPv4 and /56 for IPv6, the lengths recommended by RFC 7871.
+func ParseClientSubnet(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid client subnet %s, expected a CIDR range or an IP address", s)
+	}
+	bits := 24
+	if addr.Is6() {
+		bits = 56
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// hasEDNS returns true if queries have an OPT record.
+func (c *Client) hasEDNS() bool {
+	return c.udpSize > 0 || c.clientSubnet.IsValid() || c.nsid || c.cookie || c.padding || c.dnssec
+}
+
+// newQuery returns the query for name and rType, with the flags and EDNS options of c.
+func (c *Client) newQuery(name string, rType uint16) *dnslib.Msg {
+	m := new(dnslib.Msg)
+	m.SetQuestion(name, rType)
+	if c.dnssec {
+		m = dnssecQuery(name, rType)
+	}
+	m.RecursionDesired = !c.noRecursion
+	m.CheckingDisabled = m.CheckingDisabled || c.checkingDisabled
+	m.AuthenticatedData = c.authenticatedData
+	if !c.hasEDNS() {
+		return m
+	}
+	opt := m.IsEdns0()
+	if opt == nil {
+		m.SetEdns0(defaultUDPSize, false)
+		opt = m.IsEdns0()
+	}
+	if c.udpSize > 0 {
+		opt.SetUDPSize(c.udpSize)
+	}
+	if c.clientSubnet.IsValid() {
+		subnet := &dnslib.EDNS0_SUBNET{
+			Code:          dnslib.EDNS0SUBNET,
+			Family:        1,
+			SourceNetmask: uint8(c.clientSubnet.Bits()),
+			Address:       c.clientSubnet.Addr().AsSlice(),
+		}
+		if c.clientSubnet.Addr().Is6() {
+			subnet.Family = 2
+		}
+		opt.Option = append(opt.Option, subnet)
+	}
+	if c.nsid {
+		opt.Option = append(opt.Option, &dnslib.EDNS0_NSID{Code: dnslib.EDNS0NSID})
+	}
+	if c.cookie {
+		cookie := make([]byte, 8)
+		_, _ = rand.Read(cookie)
+		opt.Option = append(opt.Option, &dnslib.EDNS0_COOKIE{Code: dnslib.EDNS0COOKIE, Cookie: hex.EncodeToString(cookie)})
+	}
+	if c.padding {
+		padding := &dnslib.EDNS0_PADDING{}
+		opt.Option = append(opt.Option, padding)
+		// The length of the message with the option header, its padding being empty.
+		if packed, err := m.Pack(); err == nil {
+			padding.Padding = make([]byte, (paddingBlockSize-len(packed)%paddingBlockSize)%paddingBlockSize)
+		}
+	}
+	return m
+}
+
+// EDNS is the OPT record of a response (RFC 6891).
+type EDNS struct {
+	Version uint8  `json:"version"`
+	UDPSize uint16 `json:"udp_size"`
+	// DO is the DNSSEC OK flag.
+	DO bool `json:"do"`
+	// NSID is the name server identifier, in hexadecimal (RFC 5001).
+	NSID string `json:"nsid,omitempty"`
+	// NSIDText is the name server identifier, if it is printable.
+	NSIDText string `json:"nsid_text,omitempty"`
+	// ClientSubnet is the subnet of the Client Subnet option, eg: "192.0.2.0/24" (RFC 7871).
+	ClientSubnet string `json:"client_subnet,omitempty"`
+	// ClientSubnetScope is the prefix length of the subnet the answer is valid for.
+	ClientSubnetScope *uint8 `json:"client_subnet_scope,omitempty"`
+	// Cookie is the client cookie then the server cookie, in hexadecimal (RFC 7873).
+	Cookie string `json:"cookie,omitempty"`
+	// Padding is the number of padding bytes (RFC 7830).
+	Padding int `json:"padding,omitempty"`
+	// Errors are the extended errors of the response (RFC 8914).
+	Errors []ExtendedError `json:"errors,omitempty"`
+	// Options are the other options, eg: "65001: 0a0b".
+	Options []string `json:"options,omitempty"`
+}
+
+// ExtendedError is an extended DNS error (RFC 8914).
+type ExtendedError struct {
+	Code uint16 `json:"code"`
+	// Name is the name of the code, eg: "Stale Answer".
+	Name string `json:"name"`
+	Text string `json:"text,omitempty"`
+}
+
+// newEDNS returns the OPT record of msg, nil if it has none.
+func newEDNS(msg *dnslib.Msg) *EDNS {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	e := &EDNS{Version: opt.Version(), UDPSize: opt.UDPSize(), DO: opt.Do()}
+	for _, o := range opt.Option {
+		switch v := o.(type) {
+		case *dnslib.EDNS0_NSID:
+			e.NSID = v.Nsid
+			if b, err := hex.DecodeString(v.Nsid); err == nil && printable(string(b)) {
+				e.NSIDText = string(b)
+			}
+		case *dnslib.EDNS0_SUBNET:
+			if addr, ok := netip.AddrFromSlice(v.Address); ok {
+				e.ClientSubnet = netip.PrefixFrom(addr.Unmap(), int(v.SourceNetmask)).Masked().String()
+			}
+			scope := v.SourceScope
+			e.ClientSubnetScope = &scope
+		case *dnslib.EDNS0_COOKIE:
+			e.Cookie = v.Cookie
+		case *dnslib.EDNS0_PADDING:
+			e.Padding = len(v.Padding)
+		case *dnslib.EDNS0_EDE:
+			e.Errors = append(e.Errors, ExtendedError{
+				Code: v.InfoCode,
+				Name: dnslib.ExtendedErrorCodeToString[v.InfoCode],
+				Text: v.ExtraText,
+			})
+		default:
+			e.Options = append(e.Options, fmt.Sprintf("%d: %s", o.Option(), o.String()))
+		}
+	}
+	return e
+}
+
+func printable(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lines returns the options of the OPT record other than the extended errors, one per line.
+func (e *EDNS) lines() []string {
+	var lines []string
+	if e.NSID != "" {
+		if e.NSIDText != "" {
+			lines = append(lines, fmt.Sprintf("NSID: %s (%q)", e.NSID, e.NSIDText))
+		} else {
+			lines = append(lines, "NSID: "+e.NSID)
+		}
+	}
+	if e.ClientSubnet != "" {
+		lines = append(lines, fmt.Sprintf("CLIENT-SUBNET: %s, scope /%d", e.ClientSubnet, *e.ClientSubnetScope))
+	}
+	if e.Cookie != "" {
+		// The client cookie is 8 bytes long, the server cookie follows it.
+		if len(e.Cookie) > 16 {
+			lines = append(lines, fmt.Sprintf("COOKIE: %s (client), %s (server)", e.Cookie[:16], e.Cookie[16:]))
+		} else {
+			lines = append(lines, fmt.Sprintf("COOKIE: %s (client)", e.Cookie))
+		}
+	}
+	if e.Padding > 0 {
+		lines = append(lines, fmt.Sprintf("PADDING: %d bytes", e.Padding))
+	}
+	for _, o := range e.Options {
+		lines = append(lines, "OPTION "+o)
+	}
+	return lines
+}
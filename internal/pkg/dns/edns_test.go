@@ -0,0 +1,175 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"encoding/hex"
+	"net/netip"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// startUDPTCPServer starts a DNS server on the same random local port over UDP and TCP, answering with
+// handler, and returns its address.
+func startUDPTCPServer(t *testing.T, handler dnslib.HandlerFunc) string {
+	t.Helper()
+	address := serveDNS(t, "udp", "127.0.0.1:0", &dnslib.Server{Handler: handler})
+	return serveDNS(t, "tcp", address, &dnslib.Server{Handler: handler})
+}
+
+// ednsEcho answers with the flags and EDNS options of the queries: the client subnet with a /16 scope,
+// the NSID "ns1", the client cookie followed by a server cookie, and an extended error.
+func ednsEcho(queries chan<- *dnslib.Msg) dnslib.HandlerFunc {
+	return func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		queries <- r
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		if opt := r.IsEdns0(); opt != nil {
+			out := &dnslib.OPT{Hdr: dnslib.RR_Header{Name: ".", Rrtype: dnslib.TypeOPT}}
+			out.SetUDPSize(1232)
+			for _, o := range opt.Option {
+				switch v := o.(type) {
+				case *dnslib.EDNS0_SUBNET:
+					v.SourceScope = 16
+					out.Option = append(out.Option, v)
+				case *dnslib.EDNS0_NSID:
+					out.Option = append(out.Option, &dnslib.EDNS0_NSID{Code: dnslib.EDNS0NSID, Nsid: hex.EncodeToString([]byte("ns1"))})
+				case *dnslib.EDNS0_COOKIE:
+					out.Option = append(out.Option, &dnslib.EDNS0_COOKIE{Code: dnslib.EDNS0COOKIE, Cookie: v.Cookie + "0102030405060708"})
+				}
+			}
+			out.Option = append(out.Option, &dnslib.EDNS0_EDE{InfoCode: dnslib.ExtendedErrorCodeStaleAnswer, ExtraText: "stale"})
+			m.Extra = append(m.Extra, out)
+		}
+		_ = w.WriteMsg(m)
+	}
+}
+
+func TestParseClientSubnet(t *testing.T) {
+	p, err := ParseClientSubnet("203.0.113.54")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.0/24", p.String())
+	p, err = ParseClientSubnet("2001:db8:1:2ff:3::1")
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8:1:200::/56", p.String())
+	p, err = ParseClientSubnet("198.51.100.7/20")
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.96.0/20", p.String())
+	_, err = ParseClientSubnet("nope")
+	assert.Error(t, err)
+}
+
+func TestParseSource(t *testing.T) {
+	addr, port, err := ParseSource("192.0.2.1:5300")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), addr)
+	assert.Equal(t, uint16(5300), port)
+	addr, port, err = ParseSource(":5300")
+	require.NoError(t, err)
+	assert.False(t, addr.IsValid())
+	assert.Equal(t, uint16(5300), port)
+	addr, port, err = ParseSource("2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), addr)
+	assert.Zero(t, port)
+	_, _, err = ParseSource("192.0.2.1:99999")
+	assert.Error(t, err)
+}
+
+func TestClient_newQuery(t *testing.T) {
+	m := NewClient(zap.NewNop()).newQuery("example.com.", dnslib.TypeA)
+	assert.True(t, m.RecursionDesired)
+	assert.Nil(t, m.IsEdns0())
+
+	m = NewClient(zap.NewNop(), WithoutRecursion(), WithCheckingDisabled(), WithAuthenticatedData(), WithUDPSize(4096)).
+		newQuery("example.com.", dnslib.TypeA)
+	assert.False(t, m.RecursionDesired)
+	assert.True(t, m.CheckingDisabled)
+	assert.True(t, m.AuthenticatedData)
+	require.NotNil(t, m.IsEdns0())
+	assert.Equal(t, uint16(4096), m.IsEdns0().UDPSize())
+
+	m = NewClient(zap.NewNop(), WithPadding(), WithNSID()).newQuery("example.com.", dnslib.TypeA)
+	assert.Equal(t, uint16(defaultUDPSize), m.IsEdns0().UDPSize())
+	packed, err := m.Pack()
+	require.NoError(t, err)
+	assert.Zero(t, len(packed)%paddingBlockSize)
+}
+
+func TestClient_Query_edns(t *testing.T) {
+	queries := make(chan *dnslib.Msg, 1)
+	address := startServer(t, ednsEcho(queries))
+	client := NewClient(
+		zap.NewNop(),
+		WithServers(address),
+		WithTimeouts(time.Second, time.Second),
+		WithClientSubnet(netip.MustParsePrefix("203.0.113.0/24")),
+		WithNSID(),
+		WithCookie(),
+	)
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+
+	q := <-queries
+	require.NotNil(t, q.IsEdns0())
+	require.Len(t, q.IsEdns0().Option, 3)
+	subnet := q.IsEdns0().Option[0].(*dnslib.EDNS0_SUBNET)
+	assert.Equal(t, uint16(1), subnet.Family)
+	assert.Equal(t, uint8(24), subnet.SourceNetmask)
+	assert.Equal(t, "203.0.113.0", subnet.Address.String())
+	cookie := q.IsEdns0().Option[2].(*dnslib.EDNS0_COOKIE)
+	assert.Len(t, cookie.Cookie, 16)
+
+	e := res.Servers[0].Response.EDNS
+	require.NotNil(t, e)
+	assert.Equal(t, "203.0.113.0/24", e.ClientSubnet)
+	require.NotNil(t, e.ClientSubnetScope)
+	assert.Equal(t, uint8(16), *e.ClientSubnetScope)
+	assert.Equal(t, "6e7331", e.NSID)
+	assert.Equal(t, "ns1", e.NSIDText)
+	assert.Equal(t, cookie.Cookie+"0102030405060708", e.Cookie)
+	assert.Equal(t, []ExtendedError{{Code: 3, Name: "Stale Answer", Text: "stale"}}, e.Errors)
+	assert.Contains(t, e.lines(), "CLIENT-SUBNET: 203.0.113.0/24, scope /16")
+}
+
+func TestClient_Query_tcpFallback(t *testing.T) {
+	protocols := make(chan string, 2)
+	address := startUDPTCPServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		protocols <- w.LocalAddr().Network()
+		if w.LocalAddr().Network() == "udp" {
+			m.Truncated = true
+		} else {
+			rr, _ := dnslib.NewRR(r.Question[0].Name + " 300 IN A 192.0.2.1")
+			m.Answer = append(m.Answer, rr)
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	client := NewClient(zap.NewNop(), WithServers(address), WithTimeouts(time.Second, time.Second))
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	assert.True(t, res.Servers[0].Response.Flags.Truncated)
+	assert.Equal(t, "udp", <-protocols)
+
+	client = NewClient(zap.NewNop(), WithServers(address), WithTimeouts(time.Second, time.Second), WithTCPFallback())
+	res, err = client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "udp", <-protocols)
+	assert.Equal(t, "tcp", <-protocols)
+	r := res.Servers[0].Response
+	assert.True(t, r.TCPFallback)
+	assert.Equal(t, TransportTCP, r.Transport)
+	assert.Len(t, r.Answer, 1)
+
+	client = NewClient(zap.NewNop(), WithServers(address), WithTimeouts(time.Second, time.Second), WithTCP())
+	res, err = client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp", <-protocols)
+	assert.Equal(t, TransportTCP, res.Servers[0].Response.Transport)
+}
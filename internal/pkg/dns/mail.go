@@ -0,0 +1,999 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/jucrouzet/dsak/internal/pkg/httpdsak"
+)
+
+// Severities of mail findings.
+const (
+	// MailSeverityError is a finding that makes mail rejected, spoofable or undeliverable.
+	MailSeverityError = "error"
+	// MailSeverityWarning is a finding that weakens the authentication of mail or its reports.
+	MailSeverityWarning = "warning"
+	// MailSeverityInfo is a finding about an optional mechanism, or a notable setting.
+	MailSeverityInfo = "info"
+)
+
+// Checks of mail findings.
+const (
+	MailCheckMX     = "mx"
+	MailCheckSPF    = "spf"
+	MailCheckDMARC  = "dmarc"
+	MailCheckDKIM   = "dkim"
+	MailCheckMTASTS = "mta-sts"
+	MailCheckTLSRPT = "tls-rpt"
+	MailCheckBIMI   = "bimi"
+)
+
+const (
+	// mtaSTSMaxAge is the maximum max_age of MTA-STS policies, one year (RFC 8461 section 3.2).
+	mtaSTSMaxAge = 31557600
+	// mtaSTSMinAge is the max_age under which MTA-STS policies barely protect from downgrades.
+	mtaSTSMinAge = 86400
+)
+
+// DefaultDKIMSelectors are the DKIM selectors looked up when none is given, the ones of common mail
+// providers and software.
+var DefaultDKIMSelectors = []string{
+	"default", "dkim", "mail", "email", "smtp", "mx", "s1", "s2", "k1", "k2", "k3", "key1", "key2",
+	"selector1", "selector2", "google", "zoho", "mandrill", "everlytickey1", "protonmail", "fm1", "fm2",
+}
+
+var mtaSTSIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9]{1,32}$`)
+
+// MailOptions are the options of a mail check, see Client.CheckMail.
+type MailOptions struct {
+	// Selectors are the DKIM selectors to look up, default is DefaultDKIMSelectors. Keys are required
+	// for the given selectors, they are optional for the default ones.
+	Selectors []string
+	// IP is the address of a sending server to evaluate the SPF policy for, if valid.
+	IP netip.Addr
+}
+
+// Mail is the result of the checks of the email authentication records of a domain.
+type Mail struct {
+	Domain string    `json:"domain"`
+	MX     []*MailMX `json:"mx"`
+	// NullMX is true if the domain has a null MX record, accepting no mail (RFC 7505).
+	NullMX   bool           `json:"null_mx"`
+	SPF      *SPF           `json:"spf,omitempty"`
+	DMARC    *DMARC         `json:"dmarc,omitempty"`
+	DKIM     []*DKIMKey     `json:"dkim"`
+	MTASTS   *MTASTS        `json:"mta_sts,omitempty"`
+	TLSRPT   *TLSRPT        `json:"tls_rpt,omitempty"`
+	BIMI     *BIMI          `json:"bimi,omitempty"`
+	Findings []*MailFinding `json:"findings"`
+}
+
+// MailFinding is an issue found by a mail check.
+type MailFinding struct {
+	// Severity is "error", "warning" or "info".
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+// MailMX is a MX record of a domain, with the addresses of its host.
+type MailMX struct {
+	Preference uint16   `json:"preference"`
+	Host       string   `json:"host"`
+	Addresses  []string `json:"addresses"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// DMARC is the DMARC policy of a domain (RFC 7489).
+type DMARC struct {
+	// Domain is the domain of the record, the organizational domain if the domain has none.
+	Domain string `json:"domain"`
+	Record string `json:"record"`
+	Policy string `json:"policy"`
+	// SubdomainPolicy is the policy of the subdomains, the policy if the record has none.
+	SubdomainPolicy string `json:"subdomain_policy"`
+	// Percent is the percentage of failing mail the policy applies to.
+	Percent int `json:"percent"`
+	// AlignmentDKIM and AlignmentSPF are "r" (relaxed) or "s" (strict).
+	AlignmentDKIM string `json:"adkim"`
+	AlignmentSPF  string `json:"aspf"`
+	// AggregateReports and FailureReports are the URIs reports are sent to.
+	AggregateReports []string `json:"rua"`
+	FailureReports   []string `json:"ruf"`
+}
+
+// DKIMKey is the DKIM public key of a selector (RFC 6376).
+type DKIMKey struct {
+	Selector string `json:"selector"`
+	Name     string `json:"name"`
+	Record   string `json:"record"`
+	// KeyType is "rsa" or "ed25519".
+	KeyType string `json:"key_type"`
+	Bits    int    `json:"bits,omitempty"`
+	// Testing is true if the key is in testing mode (t=y).
+	Testing bool `json:"testing"`
+	// Revoked is true if the key is empty.
+	Revoked bool `json:"revoked"`
+}
+
+// MTASTS is the MTA-STS record and policy of a domain (RFC 8461).
+type MTASTS struct {
+	Record    string        `json:"record"`
+	ID        string        `json:"id"`
+	PolicyURL string        `json:"policy_url"`
+	Policy    *MTASTSPolicy `json:"policy,omitempty"`
+}
+
+// MTASTSPolicy is an MTA-STS policy.
+type MTASTSPolicy struct {
+	Version string `json:"version"`
+	// Mode is "enforce", "testing" or "none".
+	Mode string `json:"mode"`
+	// MX are the patterns of the MX hosts, eg: "*.example.com".
+	MX []string `json:"mx"`
+	// MaxAge is the number of seconds senders cache the policy.
+	MaxAge int `json:"max_age"`
+}
+
+// TLSRPT is the SMTP TLS reporting record of a domain (RFC 8460).
+type TLSRPT struct {
+	Record  string   `json:"record"`
+	Reports []string `json:"rua"`
+}
+
+// BIMI is the BIMI record of a domain, with the logo shown by mailbox providers.
+type BIMI struct {
+	Record string `json:"record"`
+	Logo   string `json:"logo,omitempty"`
+	// Authority is the URL of the mark certificate (VMC) of the logo.
+	Authority string `json:"authority,omitempty"`
+}
+
+func (m *Mail) add(severity, check, format string, a ...any) {
+	m.Findings = append(m.Findings, &MailFinding{Severity: severity, Check: check, Message: fmt.Sprintf(format, a...)})
+}
+
+// count returns the number of findings with severity.
+func (m *Mail) count(severity string) int {
+	n := 0
+	for _, f := range m.Findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Errors returns the number of findings with the error severity.
+func (m *Mail) Errors() int {
+	return m.count(MailSeverityError)
+}
+
+// Warnings returns the number of findings with the warning severity.
+func (m *Mail) Warnings() int {
+	return m.count(MailSeverityWarning)
+}
+
+// CheckMail checks the email authentication records of domain: its MX records and that their hosts
+// resolve, its SPF policy (errors, the limits of 10 DNS lookups and 2 void lookups, all mechanisms),
+// its DMARC policy and reporting URIs, the DKIM keys of selectors, its MTA-STS record and policy, its
+// TLS-RPT and BIMI records.
+func (c *Client) CheckMail(ctx context.Context, domain string, opts MailOptions) *Mail {
+	m := &Mail{Domain: dnslib.Fqdn(strings.ToLower(domain)), MX: []*MailMX{}, DKIM: []*DKIMKey{}, Findings: []*MailFinding{}}
+	c.checkMX(ctx, m)
+	c.checkSPF(ctx, m, opts.IP)
+	c.checkDMARC(ctx, m)
+	c.checkDKIM(ctx, m, opts.Selectors)
+	c.checkMTASTS(ctx, m)
+	c.checkTLSRPT(ctx, m)
+	c.checkBIMI(ctx, m)
+	return m
+}
+
+// mailRecords returns the records of rType of name, following CNAME records, nil if it has none.
+func (c *Client) mailRecords(ctx context.Context, rType Type, name string) ([]dnslib.RR, error) {
+	resp, err := c.lookup(ctx, rType, name)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.msg.Rcode {
+	case dnslib.RcodeSuccess:
+	case dnslib.RcodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s query of %s answered %s", dnslib.TypeToString[uint16(rType)], name, resp.Rcode)
+	}
+	var rrs []dnslib.RR
+	for _, rr := range resp.msg.Answer {
+		if rr.Header().Rrtype == uint16(rType) {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// mailTXT returns the TXT records of name, their strings being joined.
+func (c *Client) mailTXT(ctx context.Context, name string) ([]string, error) {
+	rrs, err := c.mailRecords(ctx, TypeTXT, name)
+	if err != nil {
+		return nil, err
+	}
+	txts := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		txts = append(txts, strings.Join(rr.(*dnslib.TXT).Txt, ""))
+	}
+	return txts, nil
+}
+
+// mailTagRecords returns the TXT records of name starting with version, eg: "v=DMARC1".
+func (c *Client) mailTagRecords(ctx context.Context, name, version string) ([]string, error) {
+	txts, err := c.mailTXT(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var records []string
+	for _, txt := range txts {
+		if tags, _ := parseTagList(txt); len(tags) > 0 && tags[0][0] == "v" && strings.EqualFold(tags[0][1], version) {
+			records = append(records, txt)
+		}
+	}
+	return records, nil
+}
+
+// mailAddresses returns the IPv4 and IPv6 addresses of host, and true if host is a CNAME record.
+func (c *Client) mailAddresses(ctx context.Context, host string) ([]netip.Addr, bool, error) {
+	var addrs []netip.Addr
+	cname := false
+	for _, rType := range []Type{TypeA, TypeAAAA} {
+		resp, err := c.lookup(ctx, rType, host)
+		if err != nil {
+			return addrs, cname, err
+		}
+		if resp.msg.Rcode != dnslib.RcodeSuccess && resp.msg.Rcode != dnslib.RcodeNameError {
+			return addrs, cname, fmt.Errorf("%s query of %s answered %s", dnslib.TypeToString[uint16(rType)], host, resp.Rcode)
+		}
+		for _, rr := range resp.msg.Answer {
+			switch rr := rr.(type) {
+			case *dnslib.A:
+				addr, _ := netip.AddrFromSlice(rr.A.To4())
+				addrs = append(addrs, addr)
+			case *dnslib.AAAA:
+				addr, _ := netip.AddrFromSlice(rr.AAAA)
+				addrs = append(addrs, addr)
+			case *dnslib.CNAME:
+				cname = cname || strings.EqualFold(rr.Hdr.Name, dnslib.Fqdn(host))
+			}
+		}
+	}
+	return addrs, cname, nil
+}
+
+// parseTagList parses a tag list, eg: "v=DMARC1; p=reject", tags names being lower cased.
+func parseTagList(s string) ([][2]string, error) {
+	var tags [][2]string
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return tags, fmt.Errorf("invalid tag %q", part)
+		}
+		tags = append(tags, [2]string{strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)})
+	}
+	return tags, nil
+}
+
+// organizationalDomain returns the registered domain of name, eg: "example.co.uk." for "mail.example.co.uk.".
+func organizationalDomain(name string) string {
+	org, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(strings.ToLower(name), "."))
+	if err != nil {
+		return dnslib.Fqdn(strings.ToLower(name))
+	}
+	return dnslib.Fqdn(org)
+}
+
+func (c *Client) checkMX(ctx context.Context, m *Mail) {
+	rrs, err := c.mailRecords(ctx, TypeMX, m.Domain)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckMX, "MX lookup failed: %s", err)
+		return
+	}
+	if len(rrs) == 0 {
+		m.add(MailSeverityWarning, MailCheckMX, "no MX record, mail is delivered to the addresses of %s (implicit MX)", m.Domain)
+		return
+	}
+	for _, rr := range rrs {
+		mx := rr.(*dnslib.MX)
+		m.MX = append(m.MX, &MailMX{Preference: mx.Preference, Host: mx.Mx, Addresses: []string{}})
+	}
+	sort.Slice(m.MX, func(i, j int) bool {
+		if m.MX[i].Preference != m.MX[j].Preference {
+			return m.MX[i].Preference < m.MX[j].Preference
+		}
+		return m.MX[i].Host < m.MX[j].Host
+	})
+	for _, mx := range m.MX {
+		if mx.Host == "." {
+			m.NullMX = true
+		}
+	}
+	if m.NullMX {
+		if len(m.MX) > 1 {
+			m.add(MailSeverityError, MailCheckMX, "null MX record with other MX records, it must be the only one (RFC 7505)")
+		} else {
+			m.add(MailSeverityInfo, MailCheckMX, "null MX record, %s accepts no mail (RFC 7505)", m.Domain)
+		}
+		return
+	}
+	cnames := make([]bool, len(m.MX))
+	wg := sync.WaitGroup{}
+	for i, mx := range m.MX {
+		if _, err := netip.ParseAddr(strings.TrimSuffix(mx.Host, ".")); err == nil {
+			mx.Error = "the host is an IP address"
+			continue
+		}
+		wg.Add(1)
+		go func(i int, mx *MailMX) {
+			defer wg.Done()
+			addrs, cname, err := c.mailAddresses(ctx, mx.Host)
+			for _, addr := range addrs {
+				mx.Addresses = append(mx.Addresses, addr.String())
+			}
+			cnames[i] = cname
+			if err != nil {
+				mx.Error = err.Error()
+			} else if len(addrs) == 0 {
+				mx.Error = "the host does not resolve"
+			}
+		}(i, mx)
+	}
+	wg.Wait()
+	resolved := 0
+	for i, mx := range m.MX {
+		if len(mx.Addresses) > 0 {
+			resolved++
+		}
+		if mx.Error != "" {
+			m.add(MailSeverityError, MailCheckMX, "MX host %s cannot receive mail: %s", mx.Host, mx.Error)
+		} else if cnames[i] {
+			m.add(MailSeverityWarning, MailCheckMX, "MX host %s is a CNAME record, forbidden by RFC 2181 section 10.3", mx.Host)
+		}
+	}
+	if resolved == 0 {
+		m.add(MailSeverityError, MailCheckMX, "no MX host resolves, %s cannot receive mail", m.Domain)
+	}
+}
+
+func (c *Client) checkDMARC(ctx context.Context, m *Mail) {
+	domain := m.Domain
+	records, err := c.mailTagRecords(ctx, "_dmarc."+domain, "DMARC1")
+	if err != nil {
+		m.add(MailSeverityError, MailCheckDMARC, "DMARC lookup failed: %s", err)
+		return
+	}
+	subdomain := false
+	if org := organizationalDomain(domain); len(records) == 0 && org != domain {
+		// Receivers apply the policy of the organizational domain (RFC 7489 section 6.6.3).
+		if records, err = c.mailTagRecords(ctx, "_dmarc."+org, "DMARC1"); err != nil {
+			m.add(MailSeverityError, MailCheckDMARC, "DMARC lookup failed: %s", err)
+			return
+		}
+		domain, subdomain = org, true
+	}
+	switch {
+	case len(records) == 0:
+		m.add(MailSeverityError, MailCheckDMARC, "no DMARC record at _dmarc.%s, mailbox providers require one to accept bulk mail", m.Domain)
+		return
+	case len(records) > 1:
+		m.add(MailSeverityError, MailCheckDMARC, "%d DMARC records, receivers ignore them all", len(records))
+		return
+	}
+	d := &DMARC{Domain: domain, Record: records[0], Percent: 100, AlignmentDKIM: "r", AlignmentSPF: "r", AggregateReports: []string{}, FailureReports: []string{}}
+	m.DMARC = d
+	tags, err := parseTagList(d.Record)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckDMARC, "invalid DMARC record: %s", err)
+	}
+	for _, tag := range tags[1:] {
+		name, value := tag[0], tag[1]
+		switch name {
+		case "p", "sp":
+			if value = strings.ToLower(value); value != "none" && value != "quarantine" && value != "reject" {
+				m.add(MailSeverityError, MailCheckDMARC, "invalid policy %s=%s, expected none, quarantine or reject", name, value)
+			}
+			if name == "p" {
+				d.Policy = value
+			} else {
+				d.SubdomainPolicy = value
+			}
+		case "pct":
+			pct, err := strconv.Atoi(value)
+			if err != nil || pct < 0 || pct > 100 {
+				m.add(MailSeverityError, MailCheckDMARC, "invalid percentage pct=%s, expected 0 to 100", value)
+				continue
+			}
+			d.Percent = pct
+		case "adkim", "aspf":
+			if value = strings.ToLower(value); value != "r" && value != "s" {
+				m.add(MailSeverityError, MailCheckDMARC, "invalid alignment %s=%s, expected r or s", name, value)
+			}
+			if name == "adkim" {
+				d.AlignmentDKIM = value
+			} else {
+				d.AlignmentSPF = value
+			}
+		case "rua", "ruf":
+			for _, uri := range strings.Split(value, ",") {
+				if uri = strings.TrimSpace(uri); name == "rua" {
+					d.AggregateReports = append(d.AggregateReports, uri)
+				} else {
+					d.FailureReports = append(d.FailureReports, uri)
+				}
+				c.checkDMARCReportURI(ctx, m, d, name, uri)
+			}
+		case "fo", "rf", "ri", "np", "psd", "t":
+		default:
+			m.add(MailSeverityWarning, MailCheckDMARC, "unknown tag %s=%s", name, value)
+		}
+	}
+	if d.Policy == "" {
+		m.add(MailSeverityError, MailCheckDMARC, "no policy (p tag), receivers ignore the record")
+		return
+	}
+	if d.SubdomainPolicy == "" {
+		d.SubdomainPolicy = d.Policy
+	}
+	policy := d.Policy
+	if subdomain {
+		policy = d.SubdomainPolicy
+	}
+	switch {
+	case policy == "none":
+		m.add(MailSeverityWarning, MailCheckDMARC, "policy none only monitors, mail spoofing %s is delivered", m.Domain)
+	case d.Percent < 100:
+		m.add(MailSeverityWarning, MailCheckDMARC, "policy %s only applies to %d%% of the failing mail", policy, d.Percent)
+	}
+	if !subdomain && d.Policy != "none" && d.SubdomainPolicy == "none" {
+		m.add(MailSeverityWarning, MailCheckDMARC, "subdomain policy none, mail spoofing the subdomains of %s is delivered", m.Domain)
+	}
+	if len(d.AggregateReports) == 0 {
+		m.add(MailSeverityWarning, MailCheckDMARC, "no aggregate report URI (rua tag), authentication failures cannot be monitored")
+	}
+}
+
+// checkDMARCReportURI checks a report URI of d, and that a domain receiving reports for another
+// organizational domain authorizes it (RFC 7489 section 7.1).
+func (c *Client) checkDMARCReportURI(ctx context.Context, m *Mail, d *DMARC, tag, uri string) {
+	address, ok := strings.CutPrefix(strings.ToLower(uri), "mailto:")
+	if !ok {
+		if !strings.HasPrefix(strings.ToLower(uri), "https:") {
+			m.add(MailSeverityError, MailCheckDMARC, "invalid report URI %s=%s, expected a mailto: or https: URI", tag, uri)
+		}
+		return
+	}
+	address, _, _ = strings.Cut(address, "!")
+	_, host, ok := strings.Cut(address, "@")
+	if !ok || host == "" {
+		m.add(MailSeverityError, MailCheckDMARC, "invalid report address %s=%s", tag, uri)
+		return
+	}
+	host = dnslib.Fqdn(host)
+	if organizationalDomain(host) == organizationalDomain(d.Domain) {
+		return
+	}
+	name := d.Domain + "_report._dmarc." + host
+	records, err := c.mailTagRecords(ctx, name, "DMARC1")
+	switch {
+	case err != nil:
+		m.add(MailSeverityWarning, MailCheckDMARC, "authorization of the reports to %s cannot be checked: %s", host, err)
+	case len(records) == 0:
+		m.add(
+			MailSeverityWarning, MailCheckDMARC,
+			"reports to %s are not sent, it does not authorize them with a %s TXT record", strings.TrimSuffix(address, "."), name,
+		)
+	}
+}
+
+func (c *Client) checkDKIM(ctx context.Context, m *Mail, selectors []string) {
+	given := len(selectors) > 0
+	if !given {
+		selectors = DefaultDKIMSelectors
+	}
+	keys := make([]*DKIMKey, len(selectors))
+	errs := make([]error, len(selectors))
+	wg := sync.WaitGroup{}
+	for i, selector := range selectors {
+		wg.Add(1)
+		go func(i int, selector string) {
+			defer wg.Done()
+			name := selector + "._domainkey." + m.Domain
+			txts, err := c.mailTXT(ctx, name)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, txt := range txts {
+				if strings.Contains(txt, "p=") {
+					keys[i] = &DKIMKey{Selector: selector, Name: name, Record: txt, KeyType: "rsa"}
+					break
+				}
+			}
+		}(i, selector)
+	}
+	wg.Wait()
+	for i, key := range keys {
+		switch {
+		case errs[i] != nil && given:
+			m.add(MailSeverityError, MailCheckDKIM, "DKIM lookup of selector %s failed: %s", selectors[i], errs[i])
+		case key == nil && given:
+			m.add(MailSeverityError, MailCheckDKIM, "no DKIM key for selector %s at %s._domainkey.%s", selectors[i], selectors[i], m.Domain)
+		case key != nil:
+			m.DKIM = append(m.DKIM, key)
+			checkDKIMKey(m, key)
+		}
+	}
+	if len(m.DKIM) == 0 && !given {
+		m.add(
+			MailSeverityInfo, MailCheckDKIM,
+			"no DKIM key found for the common selectors, give the selectors of the s= tag of the DKIM-Signature header of sent mail",
+		)
+	}
+}
+
+func checkDKIMKey(m *Mail, key *DKIMKey) {
+	tags, err := parseTagList(key.Record)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckDKIM, "invalid DKIM record of selector %s: %s", key.Selector, err)
+		return
+	}
+	var data string
+	for _, tag := range tags {
+		switch tag[0] {
+		case "v":
+			if tag[1] != "DKIM1" {
+				m.add(MailSeverityError, MailCheckDKIM, "invalid version v=%s of selector %s, expected DKIM1", tag[1], key.Selector)
+			}
+		case "k":
+			key.KeyType = strings.ToLower(tag[1])
+		case "t":
+			for _, flag := range strings.Split(tag[1], ":") {
+				key.Testing = key.Testing || strings.TrimSpace(flag) == "y"
+			}
+		case "p":
+			data = strings.Join(strings.Fields(tag[1]), "")
+		}
+	}
+	if data == "" {
+		key.Revoked = true
+		m.add(MailSeverityInfo, MailCheckDKIM, "key of selector %s is revoked", key.Selector)
+		return
+	}
+	der, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckDKIM, "invalid key of selector %s: %s", key.Selector, err)
+		return
+	}
+	switch key.KeyType {
+	case "ed25519":
+		key.Bits = len(der) * 8
+		if len(der) != 32 {
+			m.add(MailSeverityError, MailCheckDKIM, "invalid ed25519 key of selector %s, %d bytes long", key.Selector, len(der))
+		}
+	case "rsa":
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			// Some signers publish PKCS #1 keys, that most verifiers accept.
+			if pub, err = x509.ParsePKCS1PublicKey(der); err != nil {
+				m.add(MailSeverityError, MailCheckDKIM, "invalid RSA key of selector %s: %s", key.Selector, err)
+				return
+			}
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			m.add(MailSeverityError, MailCheckDKIM, "key of selector %s is not an RSA key", key.Selector)
+			return
+		}
+		key.Bits = rsaKey.N.BitLen()
+		switch {
+		case key.Bits < 1024:
+			m.add(MailSeverityError, MailCheckDKIM, "%d bits RSA key of selector %s, receivers reject keys shorter than 1024 bits", key.Bits, key.Selector)
+		case key.Bits < 2048:
+			m.add(MailSeverityWarning, MailCheckDKIM, "%d bits RSA key of selector %s is weak, use a 2048 bits key", key.Bits, key.Selector)
+		}
+	default:
+		m.add(MailSeverityError, MailCheckDKIM, "unknown key type k=%s of selector %s", key.KeyType, key.Selector)
+	}
+	if key.Testing {
+		m.add(MailSeverityWarning, MailCheckDKIM, "selector %s is in testing mode (t=y), receivers may treat its signatures as missing", key.Selector)
+	}
+}
+
+func (c *Client) checkMTASTS(ctx context.Context, m *Mail) {
+	records, err := c.mailTagRecords(ctx, "_mta-sts."+m.Domain, "STSv1")
+	switch {
+	case err != nil:
+		m.add(MailSeverityError, MailCheckMTASTS, "MTA-STS lookup failed: %s", err)
+		return
+	case len(records) == 0:
+		if !m.NullMX {
+			m.add(MailSeverityInfo, MailCheckMTASTS, "no MTA-STS record, sending servers can be downgraded to unencrypted SMTP")
+		}
+		return
+	case len(records) > 1:
+		m.add(MailSeverityError, MailCheckMTASTS, "%d MTA-STS records, senders ignore them all", len(records))
+		return
+	}
+	s := &MTASTS{
+		Record:    records[0],
+		PolicyURL: "https://mta-sts." + strings.TrimSuffix(m.Domain, ".") + "/.well-known/mta-sts.txt",
+	}
+	m.MTASTS = s
+	tags, _ := parseTagList(s.Record)
+	for _, tag := range tags {
+		if tag[0] == "id" {
+			s.ID = tag[1]
+		}
+	}
+	if !mtaSTSIDRegexp.MatchString(s.ID) {
+		m.add(MailSeverityError, MailCheckMTASTS, "invalid policy id %q, expected 1 to 32 letters and digits", s.ID)
+	}
+	res, err := c.fetchMTASTSPolicy(ctx, s.PolicyURL)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckMTASTS, "policy %s cannot be fetched: %s", s.PolicyURL, err)
+		return
+	}
+	body, _ := res.Body.(string)
+	if res.StatusCode != http.StatusOK {
+		m.add(MailSeverityError, MailCheckMTASTS, "policy %s answered %s, senders ignore the record", s.PolicyURL, res.Status)
+		return
+	}
+	if len(res.Headers["Content-Type"]) > 0 {
+		if t, _, _ := mime.ParseMediaType(res.Headers["Content-Type"][0]); t != "text/plain" {
+			m.add(MailSeverityWarning, MailCheckMTASTS, "policy %s is served as %s, expected text/plain", s.PolicyURL, t)
+		}
+	}
+	s.Policy = &MTASTSPolicy{MX: []string{}}
+	for _, line := range strings.Split(body, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "version":
+			s.Policy.Version = value
+		case "mode":
+			s.Policy.Mode = value
+		case "mx":
+			s.Policy.MX = append(s.Policy.MX, value)
+		case "max_age":
+			if s.Policy.MaxAge, err = strconv.Atoi(value); err != nil || s.Policy.MaxAge < 0 || s.Policy.MaxAge > mtaSTSMaxAge {
+				m.add(MailSeverityError, MailCheckMTASTS, "invalid max_age %s, expected 0 to %d seconds", value, mtaSTSMaxAge)
+			}
+		}
+	}
+	checkMTASTSPolicy(m, s.Policy)
+}
+
+func checkMTASTSPolicy(m *Mail, p *MTASTSPolicy) {
+	if p.Version != "STSv1" {
+		m.add(MailSeverityError, MailCheckMTASTS, "invalid policy version %q, expected STSv1", p.Version)
+	}
+	switch p.Mode {
+	case "enforce":
+	case "testing":
+		m.add(MailSeverityInfo, MailCheckMTASTS, "policy in testing mode, senders deliver mail and only report TLS failures")
+	case "none":
+		m.add(MailSeverityWarning, MailCheckMTASTS, "policy mode none, senders do not apply it")
+		return
+	default:
+		m.add(MailSeverityError, MailCheckMTASTS, "invalid policy mode %q, expected enforce, testing or none", p.Mode)
+		return
+	}
+	if p.MaxAge < mtaSTSMinAge {
+		m.add(
+			MailSeverityWarning, MailCheckMTASTS,
+			"max_age of %s barely protects from downgrades, weeks are recommended", time.Duration(p.MaxAge)*time.Second,
+		)
+	}
+	if len(p.MX) == 0 {
+		m.add(MailSeverityError, MailCheckMTASTS, "no mx pattern in the policy, senders cannot deliver mail")
+		return
+	}
+	severity := MailSeverityError
+	if p.Mode == "testing" {
+		severity = MailSeverityWarning
+	}
+	for _, mx := range m.MX {
+		if !mtaSTSMatch(p.MX, mx.Host) {
+			m.add(severity, MailCheckMTASTS, "MX host %s does not match the mx patterns of the policy, senders applying it cannot deliver mail to it", mx.Host)
+		}
+	}
+}
+
+// mtaSTSMatch returns true if host matches a mx pattern of an MTA-STS policy, a "*." prefix matching a
+// single label.
+func mtaSTSMatch(patterns []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSuffix(p, "."))
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if label, rest, found := strings.Cut(host, "."); found && label != "" && rest == suffix {
+				return true
+			}
+		} else if p == host {
+			return true
+		}
+	}
+	return false
+}
+
+// withMailFetch sets the function fetching the MTA-STS policies instead of the HTTP client. It lets
+// tests serve policies without HTTPS servers.
+func withMailFetch(f func(ctx context.Context, url string) (*httpdsak.Response, error)) Option {
+	return func(c *Client) {
+		c.mailFetch = f
+	}
+}
+
+// fetchMTASTSPolicy fetches an MTA-STS policy, redirects not being followed as required by RFC 8461.
+func (c *Client) fetchMTASTSPolicy(ctx context.Context, url string) (*httpdsak.Response, error) {
+	if c.mailFetch != nil {
+		return c.mailFetch(ctx, url)
+	}
+	var res *httpdsak.Response
+	opts := []httpdsak.Option{
+		httpdsak.WithOut(io.Discard),
+		httpdsak.WithLog(io.Discard),
+		httpdsak.WithTimeouts(c.connectTimeout, c.readTimeout),
+		httpdsak.WithResultHandler(func(r *httpdsak.Response) error {
+			res = r
+			return nil
+		}),
+	}
+	if c.insecure {
+		opts = append(opts, httpdsak.WithInsecure())
+	}
+	client, err := httpdsak.NewClient(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Run(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) checkTLSRPT(ctx context.Context, m *Mail) {
+	records, err := c.mailTagRecords(ctx, "_smtp._tls."+m.Domain, "TLSRPTv1")
+	switch {
+	case err != nil:
+		m.add(MailSeverityError, MailCheckTLSRPT, "TLS-RPT lookup failed: %s", err)
+		return
+	case len(records) == 0 && m.MTASTS != nil:
+		m.add(MailSeverityWarning, MailCheckTLSRPT, "no TLS-RPT record, the TLS failures of senders applying the MTA-STS policy are not reported")
+		return
+	case len(records) == 0:
+		if !m.NullMX {
+			m.add(MailSeverityInfo, MailCheckTLSRPT, "no TLS-RPT record, the TLS failures of senders are not reported")
+		}
+		return
+	case len(records) > 1:
+		m.add(MailSeverityError, MailCheckTLSRPT, "%d TLS-RPT records, senders ignore them all", len(records))
+		return
+	}
+	r := &TLSRPT{Record: records[0], Reports: []string{}}
+	m.TLSRPT = r
+	tags, _ := parseTagList(r.Record)
+	for _, tag := range tags {
+		if tag[0] != "rua" {
+			continue
+		}
+		for _, uri := range strings.Split(tag[1], ",") {
+			uri = strings.TrimSpace(uri)
+			r.Reports = append(r.Reports, uri)
+			if l := strings.ToLower(uri); !strings.HasPrefix(l, "mailto:") && !strings.HasPrefix(l, "https:") {
+				m.add(MailSeverityError, MailCheckTLSRPT, "invalid report URI %s, expected a mailto: or https: URI", uri)
+			}
+		}
+	}
+	if len(r.Reports) == 0 {
+		m.add(MailSeverityError, MailCheckTLSRPT, "no report URI (rua tag), senders ignore the record")
+	}
+}
+
+func (c *Client) checkBIMI(ctx context.Context, m *Mail) {
+	records, err := c.mailTagRecords(ctx, "default._bimi."+m.Domain, "BIMI1")
+	switch {
+	case err != nil:
+		m.add(MailSeverityError, MailCheckBIMI, "BIMI lookup failed: %s", err)
+		return
+	case len(records) == 0:
+		if !m.NullMX {
+			m.add(MailSeverityInfo, MailCheckBIMI, "no BIMI record, mailbox providers show no logo")
+		}
+		return
+	case len(records) > 1:
+		m.add(MailSeverityError, MailCheckBIMI, "%d BIMI records, mailbox providers ignore them all", len(records))
+		return
+	}
+	b := &BIMI{Record: records[0]}
+	m.BIMI = b
+	tags, _ := parseTagList(b.Record)
+	for _, tag := range tags {
+		switch tag[0] {
+		case "l":
+			b.Logo = tag[1]
+		case "a":
+			b.Authority = tag[1]
+		}
+	}
+	if b.Logo == "" && b.Authority == "" {
+		m.add(MailSeverityInfo, MailCheckBIMI, "BIMI record declines to show a logo")
+		return
+	}
+	if !strings.HasPrefix(strings.ToLower(b.Logo), "https://") || !strings.HasSuffix(strings.ToLower(b.Logo), ".svg") {
+		m.add(MailSeverityError, MailCheckBIMI, "invalid logo l=%s, expected the https: URL of an SVG file", b.Logo)
+	}
+	if b.Authority == "" {
+		m.add(MailSeverityInfo, MailCheckBIMI, "no mark certificate (a tag), most mailbox providers only show certified logos")
+	}
+	if m.DMARC == nil || m.DMARC.Policy == "none" || m.DMARC.Percent < 100 {
+		m.add(MailSeverityWarning, MailCheckBIMI, "logos are only shown with a DMARC policy quarantine or reject applied to all mail")
+	}
+}
+
+// RenderText writes the records of the domain, then a line per finding and the number of findings.
+func (m *Mail) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	label := color.New(color.Bold)
+	faint := color.New(color.Faint)
+	section := func(name string, lines ...string) {
+		label.Fprintf(buf, "%-8s", name)
+		if len(lines) == 0 {
+			faint.Fprint(buf, " -\n")
+			return
+		}
+		for i, line := range lines {
+			if i > 0 {
+				buf.WriteString(strings.Repeat(" ", 8))
+			}
+			buf.WriteString(" " + line + "\n")
+		}
+	}
+	var lines []string
+	for _, mx := range m.MX {
+		line := fmt.Sprintf("%d %s", mx.Preference, mx.Host)
+		if len(mx.Addresses) > 0 {
+			line += " " + faint.Sprint(strings.Join(mx.Addresses, " "))
+		}
+		if mx.Error != "" {
+			line += " " + color.New(color.FgRed).Sprint(mx.Error)
+		}
+		lines = append(lines, line)
+	}
+	section("MX", lines...)
+	lines = nil
+	if m.SPF != nil {
+		lines = append(lines, m.SPF.Record.Text, faint.Sprintf("%d/%d DNS lookups, include depth %d", m.SPF.Lookups, spfMaxLookups, m.SPF.Depth))
+		if m.SPF.IP != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s %s", m.SPF.IP, mailSPFResultColor(m.SPF.Result).Sprint(m.SPF.Result), faint.Sprint(m.SPF.Match)))
+		}
+	}
+	section("SPF", lines...)
+	lines = nil
+	if m.DMARC != nil {
+		lines = append(lines, m.DMARC.Record)
+		if m.DMARC.Domain != m.Domain {
+			lines = append(lines, faint.Sprintf("of the organizational domain %s", m.DMARC.Domain))
+		}
+	}
+	section("DMARC", lines...)
+	lines = nil
+	for _, key := range m.DKIM {
+		switch {
+		case key.Revoked:
+			lines = append(lines, fmt.Sprintf("%s: revoked", key.Selector))
+		case key.Bits > 0:
+			lines = append(lines, fmt.Sprintf("%s: %s %d bits", key.Selector, key.KeyType, key.Bits))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s", key.Selector, key.KeyType))
+		}
+	}
+	section("DKIM", lines...)
+	lines = nil
+	if m.MTASTS != nil {
+		lines = append(lines, m.MTASTS.Record)
+		if p := m.MTASTS.Policy; p != nil {
+			lines = append(lines, faint.Sprintf(
+				"mode %s, max age %s, mx %s", p.Mode, time.Duration(p.MaxAge)*time.Second, strings.Join(p.MX, " "),
+			))
+		}
+	}
+	section("MTA-STS", lines...)
+	lines = nil
+	if m.TLSRPT != nil {
+		lines = append(lines, m.TLSRPT.Record)
+	}
+	section("TLS-RPT", lines...)
+	lines = nil
+	if m.BIMI != nil {
+		lines = append(lines, m.BIMI.Record)
+	}
+	section("BIMI", lines...)
+	if len(m.Findings) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, f := range m.Findings {
+		mailSeverityColor(f.Severity).Fprintf(buf, "%-7s", f.Severity)
+		faint.Fprintf(buf, " [%s]", f.Check)
+		buf.WriteString(" " + f.Message + "\n")
+	}
+	summary := fmt.Sprintf(
+		"%s: %d errors, %d warnings, %d info", strings.TrimSuffix(m.Domain, "."), m.Errors(), m.Warnings(), m.count(MailSeverityInfo),
+	)
+	if m.Errors()+m.Warnings() == 0 {
+		color.New(color.FgGreen).Fprint(buf, summary)
+	} else {
+		faint.Fprint(buf, summary)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func mailSeverityColor(severity string) *color.Color {
+	switch severity {
+	case MailSeverityError:
+		return color.New(color.FgRed)
+	case MailSeverityWarning:
+		return color.New(color.FgYellow)
+	default:
+		return color.New(color.FgCyan)
+	}
+}
+
+func mailSPFResultColor(result string) *color.Color {
+	switch result {
+	case SPFPass:
+		return color.New(color.FgGreen)
+	case SPFNeutral, SPFSoftFail:
+		return color.New(color.FgYellow)
+	default:
+		return color.New(color.FgRed)
+	}
+}
+
+// Header returns the columns of the mail findings table.
+func (m *Mail) Header() []string {
+	return []string{"domain", "severity", "check", "message"}
+}
+
+// Rows returns the findings, one per row.
+func (m *Mail) Rows() [][]string {
+	rows := make([][]string, 0, len(m.Findings))
+	for _, f := range m.Findings {
+		rows = append(rows, []string{m.Domain, f.Severity, f.Check, f.Message})
+	}
+	return rows
+}
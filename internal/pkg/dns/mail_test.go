@@ -0,0 +1,130 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/httpdsak"
+)
+
+const testMailZone = `$ORIGIN example.test.
+$TTL 300
+@             IN SOA ns1 hostmaster 1 3600 600 86400 60
+@             IN NS  ns1
+ns1           IN A   192.0.2.53
+@             IN MX  10 mx1
+@             IN MX  20 mx2
+@             IN MX  30 mx3
+mx1           IN A   192.0.2.25
+mx2           IN CNAME mx1
+@             IN TXT "v=spf1 mx -all"
+_dmarc        IN TXT "v=DMARC1; p=none; rua=mailto:dmarc@example.test,mailto:dmarc@example.net,mailto:dmarc@example.org"
+s1._domainkey IN TXT "v=DKIM1; k=rsa; t=y; p=%s"
+_mta-sts      IN TXT "v=STSv1; id=20240101"
+_smtp._tls    IN TXT "v=TLSRPTv1; rua=mailto:tls@example.test"
+default._bimi IN TXT "v=BIMI1; l=https://example.test/logo.svg"
+nomail        IN MX  0 .
+`
+
+const testMailReportsZone = `$ORIGIN example.net.
+$TTL 300
+@                              IN SOA ns1 hostmaster 1 3600 600 86400 60
+@                              IN NS  ns1
+ns1                            IN A   198.51.100.53
+example.test._report._dmarc    IN TXT "v=DMARC1"
+`
+
+func TestMTASTSMatch(t *testing.T) {
+	patterns := []string{"mx1.example.com", "*.mail.example.com"}
+	assert.True(t, mtaSTSMatch(patterns, "MX1.example.com."))
+	assert.True(t, mtaSTSMatch(patterns, "a.mail.example.com."))
+	assert.False(t, mtaSTSMatch(patterns, "a.b.mail.example.com."))
+	assert.False(t, mtaSTSMatch(patterns, "mail.example.com."))
+	assert.False(t, mtaSTSMatch(patterns, "mx2.example.com."))
+}
+
+func TestParseTagList(t *testing.T) {
+	tags, err := parseTagList("v=DMARC1; P=reject;; rua = mailto:a@example.com ;")
+	require.NoError(t, err)
+	assert.Equal(t, [][2]string{{"v", "DMARC1"}, {"p", "reject"}, {"rua", "mailto:a@example.com"}}, tags)
+	_, err = parseTagList("v=DMARC1; reject")
+	assert.Error(t, err)
+}
+
+func TestClient_CheckMail(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	s, _ := startStubServer(t, WithZoneFiles(
+		writeZoneFile(t, dir, "example.test.zone", strings.Replace(testMailZone, "%s", base64.StdEncoding.EncodeToString(der), 1)),
+		writeZoneFile(t, dir, "example.net.zone", testMailReportsZone),
+	))
+	var fetched []string
+	fetch := func(_ context.Context, url string) (*httpdsak.Response, error) {
+		fetched = append(fetched, url)
+		return &httpdsak.Response{
+			Status:     "200 OK",
+			StatusCode: 200,
+			Headers:    map[string][]string{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:       "version: STSv1\r\nmode: enforce\r\nmx: mx1.example.test\r\nmx: *.example.org\r\nmax_age: 3600\r\n",
+		}, nil
+	}
+	client := NewClient(zap.NewNop(), WithServers(s.Addr()), withMailFetch(fetch))
+
+	m := client.CheckMail(context.Background(), "Example.test", MailOptions{Selectors: []string{"s1", "s2"}})
+	assert.Equal(t, "example.test.", m.Domain)
+	require.Len(t, m.MX, 3)
+	assert.Equal(t, []string{"192.0.2.25"}, m.MX[0].Addresses)
+	assert.Equal(t, []string{"192.0.2.25"}, m.MX[1].Addresses)
+	assert.Equal(t, "the host does not resolve", m.MX[2].Error)
+	require.NotNil(t, m.SPF)
+	assert.Equal(t, 1, m.SPF.Lookups)
+	require.NotNil(t, m.DMARC)
+	assert.Equal(t, "none", m.DMARC.Policy)
+	assert.Len(t, m.DMARC.AggregateReports, 3)
+	require.Len(t, m.DKIM, 1)
+	assert.Equal(t, 1024, m.DKIM[0].Bits)
+	assert.True(t, m.DKIM[0].Testing)
+	assert.Equal(t, []string{"https://mta-sts.example.test/.well-known/mta-sts.txt"}, fetched)
+	require.NotNil(t, m.MTASTS.Policy)
+	assert.Equal(t, []string{"mx1.example.test", "*.example.org"}, m.MTASTS.Policy.MX)
+	assert.Equal(t, 3600, m.MTASTS.Policy.MaxAge)
+	assert.Equal(t, []string{"mailto:tls@example.test"}, m.TLSRPT.Reports)
+	assert.Equal(t, "https://example.test/logo.svg", m.BIMI.Logo)
+
+	var findings []string
+	for _, f := range m.Findings {
+		findings = append(findings, f.Severity+" "+f.Check+" "+f.Message)
+	}
+	assert.Equal(t, []string{
+		"warning mx MX host mx2.example.test. is a CNAME record, forbidden by RFC 2181 section 10.3",
+		"error mx MX host mx3.example.test. cannot receive mail: the host does not resolve",
+		"warning dmarc authorization of the reports to example.org. cannot be checked: TXT query of example.test._report._dmarc.example.org. answered REFUSED",
+		"warning dmarc policy none only monitors, mail spoofing example.test. is delivered",
+		"warning dkim 1024 bits RSA key of selector s1 is weak, use a 2048 bits key",
+		"warning dkim selector s1 is in testing mode (t=y), receivers may treat its signatures as missing",
+		"error dkim no DKIM key for selector s2 at s2._domainkey.example.test.",
+		"warning mta-sts max_age of 1h0m0s barely protects from downgrades, weeks are recommended",
+		"error mta-sts MX host mx2.example.test. does not match the mx patterns of the policy, senders applying it cannot deliver mail to it",
+		"error mta-sts MX host mx3.example.test. does not match the mx patterns of the policy, senders applying it cannot deliver mail to it",
+		"info bimi no mark certificate (a tag), most mailbox providers only show certified logos",
+		"warning bimi logos are only shown with a DMARC policy quarantine or reject applied to all mail",
+	}, findings)
+	assert.Equal(t, 4, m.Errors())
+	assert.Equal(t, 7, m.Warnings())
+
+	m = client.CheckMail(context.Background(), "nomail.example.test", MailOptions{})
+	assert.True(t, m.NullMX)
+	assert.Equal(t, "null MX record, nomail.example.test. accepts no mail (RFC 7505)", m.Findings[0].Message)
+}
@@ -0,0 +1,398 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// FormatTTL returns a TTL as a duration, eg: "1d2h", "5m" or "30s".
+func FormatTTL(ttl uint32) string {
+	if ttl == 0 {
+		return "0s"
+	}
+	var b strings.Builder
+	for _, u := range []struct {
+		name    string
+		seconds uint32
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}} {
+		if n := ttl / u.seconds; n > 0 {
+			b.WriteString(strconv.FormatUint(uint64(n), 10) + u.name)
+			ttl -= n * u.seconds
+		}
+	}
+	return b.String()
+}
+
+// typeColor returns the color of a record type in the text output, by family: addresses, names,
+// services, texts, zone data and DNSSEC records.
+func typeColor(t uint16) *color.Color {
+	switch t {
+	case dnslib.TypeA, dnslib.TypeAAAA:
+		return color.New(color.FgCyan)
+	case dnslib.TypeCNAME, dnslib.TypeDNAME, dnslib.TypeNS, dnslib.TypePTR:
+		return color.New(color.FgMagenta)
+	case dnslib.TypeMX, dnslib.TypeSRV, dnslib.TypeSVCB, dnslib.TypeHTTPS, dnslib.TypeNAPTR, dnslib.TypeURI:
+		return color.New(color.FgBlue)
+	case dnslib.TypeTXT, dnslib.TypeSPF, dnslib.TypeCAA:
+		return color.New(color.FgYellow)
+	case dnslib.TypeSOA:
+		return color.New(color.FgGreen)
+	case dnslib.TypeDNSKEY, dnslib.TypeDS, dnslib.TypeRRSIG, dnslib.TypeNSEC, dnslib.TypeNSEC3,
+		dnslib.TypeNSEC3PARAM, dnslib.TypeCDS, dnslib.TypeCDNSKEY:
+		return color.New(color.FgRed)
+	default:
+		return color.New(color.Reset)
+	}
+}
+
+// rdata returns the data of rr in the presentation format.
+func rdata(rr dnslib.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// prettyData returns the data of rr in a human readable form, the presentation format for the types
+// without a specific one.
+func prettyData(rr dnslib.RR) string {
+	switch v := rr.(type) {
+	case *dnslib.MX:
+		return fmt.Sprintf("%s (priority %d)", v.Mx, v.Preference)
+	case *dnslib.SRV:
+		return fmt.Sprintf("%s (priority %d, weight %d)", net.JoinHostPort(v.Target, strconv.Itoa(int(v.Port))), v.Priority, v.Weight)
+	case *dnslib.CAA:
+		s := fmt.Sprintf("%s %q", v.Tag, v.Value)
+		if v.Flag&128 != 0 {
+			s += " (critical)"
+		}
+		return s
+	case *dnslib.SVCB:
+		return prettySVCB(v)
+	case *dnslib.HTTPS:
+		return prettySVCB(&v.SVCB)
+	case *dnslib.SOA:
+		return fmt.Sprintf(
+			"%s %s serial %d, refresh %s, retry %s, expire %s, negative TTL %s",
+			v.Ns, v.Mbox, v.Serial, FormatTTL(v.Refresh), FormatTTL(v.Retry), FormatTTL(v.Expire), FormatTTL(v.Minttl),
+		)
+	default:
+		return rdata(rr)
+	}
+}
+
+func prettySVCB(v *dnslib.SVCB) string {
+	if v.Priority == 0 {
+		return "alias of " + v.Target
+	}
+	parts := []string{fmt.Sprintf("priority %d", v.Priority), "target " + v.Target}
+	for _, kv := range v.Value {
+		parts = append(parts, kv.Key().String()+"="+kv.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// recordFields returns the fields of the data of rr, for the types having a structured representation.
+func recordFields(rr dnslib.RR) map[string]any {
+	switch v := rr.(type) {
+	case *dnslib.A:
+		return map[string]any{"address": v.A.String()}
+	case *dnslib.AAAA:
+		return map[string]any{"address": v.AAAA.String()}
+	case *dnslib.CNAME:
+		return map[string]any{"target": v.Target}
+	case *dnslib.DNAME:
+		return map[string]any{"target": v.Target}
+	case *dnslib.NS:
+		return map[string]any{"target": v.Ns}
+	case *dnslib.PTR:
+		return map[string]any{"target": v.Ptr}
+	case *dnslib.MX:
+		return map[string]any{"preference": v.Preference, "exchange": v.Mx}
+	case *dnslib.SRV:
+		return map[string]any{"priority": v.Priority, "weight": v.Weight, "port": v.Port, "target": v.Target}
+	case *dnslib.CAA:
+		return map[string]any{"flag": v.Flag, "tag": v.Tag, "value": v.Value}
+	case *dnslib.SVCB:
+		return svcbFields(v)
+	case *dnslib.HTTPS:
+		return svcbFields(&v.SVCB)
+	case *dnslib.SOA:
+		return map[string]any{
+			"ns":      v.Ns,
+			"mbox":    v.Mbox,
+			"serial":  v.Serial,
+			"refresh": v.Refresh,
+			"retry":   v.Retry,
+			"expire":  v.Expire,
+			"minttl":  v.Minttl,
+		}
+	case *dnslib.TXT:
+		return map[string]any{"strings": v.Txt}
+	case *dnslib.SPF:
+		return map[string]any{"strings": v.Txt}
+	default:
+		return nil
+	}
+}
+
+func svcbFields(v *dnslib.SVCB) map[string]any {
+	params := make(map[string]string, len(v.Value))
+	for _, kv := range v.Value {
+		params[kv.Key().String()] = kv.String()
+	}
+	return map[string]any{"priority": v.Priority, "target": v.Target, "params": params}
+}
+
+// writeRecords writes records as aligned columns: name, TTL, class, type and data.
+func writeRecords(w *bytes.Buffer, indent string, rrs []dnslib.RR) {
+	rows := make([][]string, 0, len(rrs))
+	types := make([]uint16, 0, len(rrs))
+	widths := make([]int, 4)
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Rrtype == dnslib.TypeOPT {
+			continue
+		}
+		row := []string{h.Name, FormatTTL(h.Ttl), dnslib.ClassToString[h.Class], GetTypeName(Type(h.Rrtype)), prettyData(rr)}
+		for i := range widths {
+			widths[i] = max(widths[i], len(row[i]))
+		}
+		rows = append(rows, row)
+		types = append(types, h.Rrtype)
+	}
+	for i, row := range rows {
+		w.WriteString(indent)
+		for j := 0; j < 4; j++ {
+			if j == 3 {
+				typeColor(types[i]).Fprint(w, row[j])
+			} else {
+				w.WriteString(row[j])
+			}
+			w.WriteString(strings.Repeat(" ", widths[j]-len(row[j])+2))
+		}
+		w.WriteString(row[4] + "\n")
+	}
+}
+
+// flagNames returns the names of the header flags set in msg, in the dig order.
+func flagNames(msg *dnslib.Msg) []string {
+	var names []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", msg.Response},
+		{"aa", msg.Authoritative},
+		{"tc", msg.Truncated},
+		{"rd", msg.RecursionDesired},
+		{"ra", msg.RecursionAvailable},
+		{"ad", msg.AuthenticatedData},
+		{"cd", msg.CheckingDisabled},
+	} {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// RenderText writes the response like dig does: the header, the question, answer, authority and
+// additional sections, then the server, the RTT and the TLS details of encrypted connections.
+func (r *Response) RenderText(w io.Writer) error {
+	msg := r.msg
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+
+	faint.Fprintf(buf, ";; opcode: %s, status: ", r.Opcode)
+	rcodeColor(msg.Rcode).Fprint(buf, r.Rcode)
+	faint.Fprintf(buf, ", id: %d", r.ID)
+	buf.WriteString("\n")
+	faint.Fprintf(
+		buf,
+		";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d",
+		strings.Join(flagNames(msg), " "), len(r.Question), len(r.Answer), len(r.Authority), len(r.Additional),
+	)
+	buf.WriteString("\n")
+	if opt := msg.IsEdns0(); opt != nil {
+		flags := ""
+		if opt.Do() {
+			flags = " do"
+		}
+		faint.Fprintf(buf, ";; EDNS: version %d, flags:%s, udp: %d", opt.Version(), flags, opt.UDPSize())
+		buf.WriteString("\n")
+		for _, l := range r.EDNS.lines() {
+			faint.Fprint(buf, ";; "+l)
+			buf.WriteString("\n")
+		}
+		for _, e := range r.EDNS.Errors {
+			color.New(color.FgYellow).Fprintf(buf, ";; EDE: %d (%s)", e.Code, e.Name)
+			if e.Text != "" {
+				color.New(color.FgYellow).Fprintf(buf, ": %s", e.Text)
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	if len(msg.Question) > 0 {
+		buf.WriteString("\n")
+		color.New(color.Bold).Fprint(buf, ";; QUESTION")
+		buf.WriteString("\n")
+		for _, q := range msg.Question {
+			fmt.Fprintf(buf, "%s  %s  ", q.Name, dnslib.ClassToString[q.Qclass])
+			typeColor(q.Qtype).Fprint(buf, GetTypeName(Type(q.Qtype)))
+			buf.WriteString("\n")
+		}
+	}
+	for _, s := range []struct {
+		name string
+		rrs  []dnslib.RR
+	}{{"ANSWER", msg.Answer}, {"AUTHORITY", msg.Ns}, {"ADDITIONAL", msg.Extra}} {
+		if len(newRecords(s.rrs)) == 0 {
+			continue
+		}
+		buf.WriteString("\n")
+		color.New(color.Bold).Fprint(buf, ";; "+s.name)
+		buf.WriteString("\n")
+		writeRecords(buf, "", s.rrs)
+	}
+
+	buf.WriteString("\n")
+	faint.Fprintf(buf, ";; SERVER: %s (%s), RTT: %s", r.Server, r.Transport, time.Duration(r.RTT).Round(time.Microsecond))
+	if r.TCPFallback {
+		faint.Fprint(buf, ", truncated over UDP")
+	}
+	buf.WriteString("\n")
+	if r.TLS != nil {
+		faint.Fprintf(buf, ";; TLS: %s", r.TLS)
+		buf.WriteString("\n")
+		for _, l := range r.TLS.certificateLines() {
+			faint.Fprintf(buf, ";; CERTIFICATE: %s", l)
+			buf.WriteString("\n")
+		}
+	}
+	if r.DNSSEC != nil {
+		r.DNSSEC.writeText(buf, ";; ", true)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// RenderShort writes the data of the answer records, one per line. If servers gave different
+// responses, each line starts with the server address.
+func (r *Result) RenderShort(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	for _, sr := range r.Servers {
+		if sr.Response == nil {
+			continue
+		}
+		for _, rec := range sr.Response.Answer {
+			if !r.Consistent {
+				buf.WriteString(sr.Server + "\t")
+			}
+			buf.WriteString(strings.TrimSpace(rec.Data) + "\n")
+		}
+		if r.Consistent {
+			break
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// TypeInfo describes a record type.
+type TypeInfo struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	URLs        []string `json:"urls"`
+
+	t uint16
+}
+
+// Types returns the description of the record types found in the question and the records of
+// every response, in the order they first appear.
+func (r *Result) Types() []TypeInfo {
+	var infos []TypeInfo
+	seen := make(map[uint16]bool)
+	add := func(t uint16) {
+		if seen[t] || t == dnslib.TypeOPT {
+			return
+		}
+		seen[t] = true
+		infos = append(infos, TypeInfo{
+			Type:        GetTypeName(Type(t)),
+			Description: GetTypeDescription(Type(t)),
+			URLs:        GetTypeURLs(Type(t)),
+			t:           t,
+		})
+	}
+	for _, sr := range r.Servers {
+		if sr.Response == nil {
+			continue
+		}
+		for _, q := range sr.Response.msg.Question {
+			add(q.Qtype)
+		}
+		for _, rrs := range [][]dnslib.RR{sr.Response.msg.Answer, sr.Response.msg.Ns, sr.Response.msg.Extra} {
+			for _, rr := range rrs {
+				add(rr.Header().Rrtype)
+			}
+		}
+	}
+	return infos
+}
+
+// RenderTypes writes the description and the specification URLs of record types.
+func RenderTypes(w io.Writer, infos []TypeInfo) error {
+	buf := &bytes.Buffer{}
+	for _, info := range infos {
+		buf.WriteString(";; ")
+		typeColor(info.t).Fprint(buf, info.Type)
+		fmt.Fprintf(buf, ": %s\n", info.Description)
+		for _, u := range info.URLs {
+			color.New(color.Faint).Fprintf(buf, ";;   %s", u)
+			buf.WriteString("\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func dnssecColor(status string) *color.Color {
+	switch status {
+	case DNSSECSecure:
+		return color.New(color.FgGreen)
+	case DNSSECInsecure:
+		return color.New(color.FgYellow)
+	default:
+		return color.New(color.FgRed)
+	}
+}
+
+// writeText writes the validation status and reason, and the status of each zone of the chain if chain is true.
+func (d *DNSSEC) writeText(buf *bytes.Buffer, prefix string, chain bool) {
+	faint := color.New(color.Faint)
+	faint.Fprint(buf, prefix+"DNSSEC: ")
+	dnssecColor(d.Status).Fprint(buf, d.Status)
+	if d.Reason != "" {
+		faint.Fprint(buf, ", "+d.Reason)
+	}
+	buf.WriteString("\n")
+	if !chain {
+		return
+	}
+	for _, z := range d.Chain {
+		faint.Fprintf(buf, "%sDNSSEC ZONE: %s ", prefix, z.Zone)
+		dnssecColor(z.Status).Fprint(buf, z.Status)
+		faint.Fprintf(buf, ", %s", z.Reason)
+		if len(z.Keys) > 0 {
+			faint.Fprintf(buf, " (keys: %s)", strings.Join(z.Keys, ", "))
+		}
+		buf.WriteString("\n")
+	}
+}
@@ -0,0 +1,92 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResponse(t *testing.T, server string, answers ...string) *Response {
+	t.Helper()
+	m := new(dnslib.Msg)
+	m.SetQuestion("example.com.", dnslib.TypeMX)
+	m.Id = 42
+	m.Response = true
+	m.RecursionAvailable = true
+	for _, a := range answers {
+		rr, err := dnslib.NewRR(a)
+		require.NoError(t, err)
+		m.Answer = append(m.Answer, rr)
+	}
+	return newResponse(server, TransportUDP, 12*time.Millisecond, m)
+}
+
+func TestFormatTTL(t *testing.T) {
+	assert.Equal(t, "0s", FormatTTL(0))
+	assert.Equal(t, "45s", FormatTTL(45))
+	assert.Equal(t, "5m", FormatTTL(300))
+	assert.Equal(t, "1h30m", FormatTTL(5400))
+	assert.Equal(t, "1d2h3m4s", FormatTTL(93784))
+}
+
+func TestPrettyData(t *testing.T) {
+	tests := map[string]string{
+		"example.com. 300 IN MX 10 mail.example.com.":                                     "mail.example.com. (priority 10)",
+		"_sip._tcp.example.com. 300 IN SRV 10 60 5060 sip.example.com.":                   "sip.example.com.:5060 (priority 10, weight 60)",
+		"example.com. 300 IN CAA 128 issue \"letsencrypt.org\"":                           "issue \"letsencrypt.org\" (critical)",
+		"example.com. 300 IN HTTPS 1 . alpn=\"h2,h3\" ipv4hint=\"192.0.2.1\"":             "priority 1, target ., alpn=h2,h3, ipv4hint=192.0.2.1",
+		"example.com. 300 IN HTTPS 0 cdn.example.net.":                                    "alias of cdn.example.net.",
+		"example.com. 300 IN SOA ns.example.com. admin.example.com. 7 7200 900 86400 300": "ns.example.com. admin.example.com. serial 7, refresh 2h, retry 15m, expire 1d, negative TTL 5m",
+		"example.com. 300 IN A 192.0.2.1":                                                 "192.0.2.1",
+	}
+	for in, want := range tests {
+		rr, err := dnslib.NewRR(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, prettyData(rr), in)
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	res := testResponse(t, "192.0.2.53:53", "example.com. 300 IN MX 10 mail.example.com.")
+	assert.Equal(t, map[string]any{"preference": uint16(10), "exchange": "mail.example.com."}, res.Answer[0].Fields)
+}
+
+func TestResponse_RenderText(t *testing.T) {
+	color.NoColor = true
+	res := testResponse(t, "192.0.2.53:53",
+		"example.com. 300 IN MX 10 mail.example.com.",
+		"example.com. 3600 IN MX 20 backup-mail.example.com.",
+	)
+	buf := &bytes.Buffer{}
+	require.NoError(t, res.RenderText(buf))
+	assert.Equal(t, `;; opcode: QUERY, status: NOERROR, id: 42
+;; flags: qr rd ra; QUERY: 1, ANSWER: 2, AUTHORITY: 0, ADDITIONAL: 0
+
+;; QUESTION
+example.com.  IN  MX
+
+;; ANSWER
+example.com.  5m  IN  MX  mail.example.com. (priority 10)
+example.com.  1h  IN  MX  backup-mail.example.com. (priority 20)
+
+;; SERVER: 192.0.2.53:53 (udp), RTT: 12ms
+`, buf.String())
+}
+
+func TestResult_RenderShort(t *testing.T) {
+	a := &ServerResult{Server: "a", Response: testResponse(t, "a", "example.com. 300 IN MX 10 mail.example.com.")}
+	b := &ServerResult{Server: "b", Response: testResponse(t, "b", "example.com. 300 IN MX 10 mail.example.com.")}
+	buf := &bytes.Buffer{}
+	require.NoError(t, newResult([]*ServerResult{a, b}).RenderShort(buf))
+	assert.Equal(t, "10 mail.example.com.\n", buf.String())
+
+	c := &ServerResult{Server: "c", Response: testResponse(t, "c", "example.com. 300 IN MX 20 other.example.com.")}
+	buf.Reset()
+	require.NoError(t, newResult([]*ServerResult{a, c}).RenderShort(buf))
+	assert.Equal(t, "a\t10 mail.example.com.\nc\t20 other.example.com.\n", buf.String())
+}
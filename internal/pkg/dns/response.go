@@ -0,0 +1,155 @@
+package dns
+
+import (
+	"strconv"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+)
+
+// Response is the response of a DNS server to a query.
+type Response struct {
+	Server     string     `json:"server"`
+	Transport  Transport  `json:"transport"`
+	RTT        Duration   `json:"rtt"`
+	ID         uint16     `json:"id"`
+	Opcode     string     `json:"opcode"`
+	Rcode      string     `json:"rcode"`
+	Flags      Flags      `json:"flags"`
+	Question   []Question `json:"question"`
+	Answer     []Record   `json:"answer"`
+	Authority  []Record   `json:"authority"`
+	Additional []Record   `json:"additional"`
+	// EDNS is the OPT record of the response, if any.
+	EDNS *EDNS `json:"edns,omitempty"`
+	// TCPFallback is true if the response was truncated over UDP, the query being sent again over TCP.
+	TCPFallback bool `json:"tcp_fallback,omitempty"`
+	// TLS holds the details of the connection to encrypted servers.
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// DNSSEC is the validation of the response, if requested.
+	DNSSEC *DNSSEC `json:"dnssec,omitempty"`
+
+	msg *dnslib.Msg
+}
+
+// Flags are the header flags of a DNS message.
+type Flags struct {
+	Authoritative      bool `json:"aa"`
+	Truncated          bool `json:"tc"`
+	RecursionDesired   bool `json:"rd"`
+	RecursionAvailable bool `json:"ra"`
+	AuthenticatedData  bool `json:"ad"`
+	CheckingDisabled   bool `json:"cd"`
+}
+
+// Question is a question of a DNS message.
+type Question struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+}
+
+// Record is a resource record of a DNS message.
+type Record struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	TTL   uint32 `json:"ttl"`
+	Data  string `json:"data"`
+	// Fields are the data fields, for the types having a structured representation, eg: MX, SRV, CAA,
+	// SVCB, HTTPS, SOA and TXT.
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// Duration is a time.Duration rendered as a string in JSON, eg: "12.5ms".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(d).String())), nil
+}
+
+func newResponse(server string, transport Transport, rtt time.Duration, msg *dnslib.Msg) *Response {
+	res := &Response{
+		Server:    server,
+		Transport: transport,
+		RTT:       Duration(rtt),
+		ID:        msg.Id,
+		Opcode:    dnslib.OpcodeToString[msg.Opcode],
+		Rcode:     dnslib.RcodeToString[msg.Rcode],
+		Flags: Flags{
+			Authoritative:      msg.Authoritative,
+			Truncated:          msg.Truncated,
+			RecursionDesired:   msg.RecursionDesired,
+			RecursionAvailable: msg.RecursionAvailable,
+			AuthenticatedData:  msg.AuthenticatedData,
+			CheckingDisabled:   msg.CheckingDisabled,
+		},
+		Question:   make([]Question, 0, len(msg.Question)),
+		Answer:     newRecords(msg.Answer),
+		Authority:  newRecords(msg.Ns),
+		Additional: newRecords(msg.Extra),
+		EDNS:       newEDNS(msg),
+		msg:        msg,
+	}
+	for _, q := range msg.Question {
+		res.Question = append(res.Question, Question{
+			Name:  q.Name,
+			Type:  GetTypeName(Type(q.Qtype)),
+			Class: dnslib.ClassToString[q.Qclass],
+		})
+	}
+	return res
+}
+
+func newRecords(rrs []dnslib.RR) []Record {
+	records := make([]Record, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dnslib.TypeOPT {
+			continue
+		}
+		records = append(records, newRecord(rr))
+	}
+	return records
+}
+
+// NewRecord returns rr as a Record.
+func NewRecord(rr dnslib.RR) Record {
+	return newRecord(rr)
+}
+
+func newRecord(rr dnslib.RR) Record {
+	h := rr.Header()
+	return Record{
+		Name:   h.Name,
+		Type:   GetTypeName(Type(h.Rrtype)),
+		Class:  dnslib.ClassToString[h.Class],
+		TTL:    h.Ttl,
+		Data:   rdata(rr),
+		Fields: recordFields(rr),
+	}
+}
+
+// Msg returns the raw DNS message.
+func (r *Response) Msg() *dnslib.Msg {
+	return r.msg
+}
+
+// Header returns the columns of the response records table.
+func (r *Response) Header() []string {
+	return []string{"section", "name", "type", "ttl", "data"}
+}
+
+// Rows returns the response records, one per row.
+func (r *Response) Rows() [][]string {
+	var rows [][]string
+	for _, s := range []struct {
+		name    string
+		records []Record
+	}{{"answer", r.Answer}, {"authority", r.Authority}, {"additional", r.Additional}} {
+		for _, rec := range s.records {
+			rows = append(rows, []string{s.name, rec.Name, rec.Type, strconv.FormatUint(uint64(rec.TTL), 10), rec.Data})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,193 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// Result is the result of a query sent to several servers.
+type Result struct {
+	// Consistent is false if servers gave different responses.
+	Consistent bool            `json:"consistent"`
+	Servers    []*ServerResult `json:"servers"`
+}
+
+// ServerResult is the result of a query sent to a server, either a response or an error.
+type ServerResult struct {
+	Server string `json:"server"`
+	// Differs is true if the response differs from the one given by most servers.
+	Differs  bool      `json:"differs"`
+	Error    string    `json:"error,omitempty"`
+	Response *Response `json:"response,omitempty"`
+
+	err error
+}
+
+func (r *ServerResult) setError(err error) {
+	r.err = err
+	r.Error = err.Error()
+}
+
+// Err returns the error the query to the server failed with, nil if it answered.
+func (r *ServerResult) Err() error {
+	return r.err
+}
+
+// succeeded returns true if the server answered with NOERROR or NXDOMAIN.
+func (r *ServerResult) succeeded() bool {
+	if r.Response == nil {
+		return false
+	}
+	rcode := r.Response.msg.Rcode
+	return rcode == dnslib.RcodeSuccess || rcode == dnslib.RcodeNameError
+}
+
+// signature returns a string identifying the response content: its rcode and its answer records,
+// TTLs and order excluded.
+func (r *ServerResult) signature() string {
+	records := make([]string, 0, len(r.Response.Answer))
+	for _, rec := range r.Response.Answer {
+		records = append(records, strings.ToLower(rec.Name)+" "+rec.Type+" "+rec.Data)
+	}
+	sort.Strings(records)
+	return r.Response.Rcode + "\n" + strings.Join(records, "\n")
+}
+
+func newResult(servers []*ServerResult) *Result {
+	res := &Result{Consistent: true, Servers: servers}
+	counts := make(map[string]int)
+	var majority string
+	for _, sr := range servers {
+		if sr.Response == nil {
+			continue
+		}
+		sig := sr.signature()
+		counts[sig]++
+		if counts[sig] > counts[majority] {
+			majority = sig
+		}
+	}
+	for _, sr := range servers {
+		if sr.Response != nil && sr.signature() != majority {
+			sr.Differs = true
+			res.Consistent = false
+		}
+	}
+	return res
+}
+
+// err returns an error if no server answered.
+func (r *Result) err() error {
+	for _, sr := range r.Servers {
+		if sr.Response != nil {
+			return nil
+		}
+	}
+	if len(r.Servers) == 0 {
+		return fmt.Errorf("no DNS server to query")
+	}
+	return fmt.Errorf("no DNS server answered, %s: %w", r.Servers[0].Server, r.Servers[0].err)
+}
+
+// RenderText writes the response like dig does if there is one server, the rcode, the RTT and the
+// answer of each server otherwise, highlighting the ones that differ.
+func (r *Result) RenderText(w io.Writer) error {
+	if len(r.Servers) == 1 && r.Servers[0].Response != nil {
+		return r.Servers[0].Response.RenderText(w)
+	}
+	buf := &bytes.Buffer{}
+	for _, sr := range r.Servers {
+		color.New(color.Bold).Fprint(buf, sr.Server)
+		if sr.Response == nil {
+			buf.WriteString("  ")
+			color.New(color.FgRed).Fprint(buf, sr.Error)
+			buf.WriteString("\n")
+			continue
+		}
+		buf.WriteString("  ")
+		rcodeColor(sr.Response.msg.Rcode).Fprint(buf, sr.Response.Rcode)
+		color.New(color.Faint).Fprintf(buf, "  %s", time.Duration(sr.Response.RTT).Round(time.Microsecond))
+		if sr.Differs {
+			color.New(color.Bold, color.FgYellow).Fprint(buf, "  differs")
+		}
+		buf.WriteString("\n")
+		if sr.Response.TLS != nil {
+			color.New(color.Faint).Fprintf(buf, "  %s", sr.Response.TLS)
+			buf.WriteString("\n")
+		}
+		if e := sr.Response.EDNS; e != nil {
+			for _, l := range e.lines() {
+				color.New(color.Faint).Fprint(buf, "  "+l)
+				buf.WriteString("\n")
+			}
+			for _, ee := range e.Errors {
+				color.New(color.FgYellow).Fprintf(buf, "  EDE: %d (%s) %s", ee.Code, ee.Name, ee.Text)
+				buf.WriteString("\n")
+			}
+		}
+		if sr.Response.DNSSEC != nil {
+			sr.Response.DNSSEC.writeText(buf, "  ", false)
+		}
+		if len(sr.Response.msg.Answer) == 0 {
+			color.New(color.Faint).Fprint(buf, "  no answer")
+			buf.WriteString("\n")
+		}
+		writeRecords(buf, "  ", sr.Response.msg.Answer)
+	}
+	if !r.Consistent {
+		color.New(color.FgYellow).Fprint(buf, "Servers gave different responses")
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func rcodeColor(rcode int) *color.Color {
+	switch rcode {
+	case dnslib.RcodeSuccess:
+		return color.New(color.FgGreen)
+	case dnslib.RcodeNameError:
+		return color.New(color.FgYellow)
+	default:
+		return color.New(color.FgRed)
+	}
+}
+
+// Header returns the columns of the result records table.
+func (r *Result) Header() []string {
+	return []string{"server", "rcode", "rtt", "differs", "section", "name", "type", "ttl", "data"}
+}
+
+// Rows returns the records of every server response, one per row. A server without records has a
+// row holding its error if any.
+func (r *Result) Rows() [][]string {
+	var rows [][]string
+	for _, sr := range r.Servers {
+		if sr.Response == nil {
+			rows = append(rows, []string{sr.Server, "", "", "", "", "", "", "", sr.Error})
+			continue
+		}
+		prefix := []string{
+			sr.Server,
+			sr.Response.Rcode,
+			time.Duration(sr.Response.RTT).Round(time.Microsecond).String(),
+			strconv.FormatBool(sr.Differs),
+		}
+		records := sr.Response.Rows()
+		if len(records) == 0 {
+			rows = append(rows, append(prefix, "", "", "", "", ""))
+		}
+		for _, rec := range records {
+			rows = append(rows, append(append([]string{}, prefix...), rec...))
+		}
+	}
+	return rows
+}
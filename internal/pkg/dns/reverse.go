@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// MaxReverseAddresses is the maximum number of addresses of a reverse lookup.
+const MaxReverseAddresses = 65536
+
+// Statuses of the addresses of a reverse lookup.
+const (
+	// ReverseConfirmed is an address whose PTR name resolves back to it (FCrDNS).
+	ReverseConfirmed = "confirmed"
+	// ReverseMismatch is an address whose PTR names do not resolve back to it.
+	ReverseMismatch = "mismatch"
+	// ReverseNoPTR is an address without PTR record.
+	ReverseNoPTR = "no-ptr"
+	// ReverseError is an address whose lookups failed.
+	ReverseError = "error"
+)
+
+// Reverse is the result of the reverse lookup of addresses.
+type Reverse struct {
+	Addresses []*ReverseAddress `json:"addresses"`
+}
+
+// ReverseAddress is the reverse lookup of an address, with the forward confirmation of its PTR names.
+type ReverseAddress struct {
+	IP string `json:"ip"`
+	// Name is the in-addr.arpa or ip6.arpa name of the address.
+	Name string   `json:"name"`
+	PTR  []string `json:"ptr"`
+	// Forward are the addresses of the PTR names.
+	Forward []string `json:"forward"`
+	// Confirmed is true if a PTR name resolves back to the address.
+	Confirmed bool   `json:"confirmed"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+
+	err error
+}
+
+func (ra *ReverseAddress) setError(err error) {
+	ra.Status = ReverseError
+	ra.err = err
+	ra.Error = err.Error()
+}
+
+// Err returns the error the lookup failed with, nil if it did not fail.
+func (ra *ReverseAddress) Err() error {
+	return ra.err
+}
+
+// ParseReverseTargets returns the addresses of targets, IP addresses or CIDR ranges, at most MaxReverseAddresses.
+func ParseReverseTargets(targets ...string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	for _, t := range targets {
+		if !strings.Contains(t, "/") {
+			addr, err := netip.ParseAddr(t)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %s: %w", t, err)
+			}
+			addrs = append(addrs, addr.Unmap())
+			continue
+		}
+		prefix, err := netip.ParsePrefix(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %s: %w", t, err)
+		}
+		prefix = prefix.Masked()
+		if bits := prefix.Addr().BitLen() - prefix.Bits(); bits > 16 || len(addrs)+1<<bits > MaxReverseAddresses {
+			return nil, fmt.Errorf("range %s is too large, at most %d addresses can be looked up", t, MaxReverseAddresses)
+		}
+		for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) > MaxReverseAddresses {
+		return nil, fmt.Errorf("too many addresses, at most %d addresses can be looked up", MaxReverseAddresses)
+	}
+	return addrs, nil
+}
+
+// Reverse queries the PTR records of addrs, up to concurrency at the same time, and checks that their
+// names resolve back to the addresses (forward-confirmed reverse DNS). Results are in the order of addrs.
+func (c *Client) Reverse(ctx context.Context, addrs []netip.Addr, concurrency int) *Reverse {
+	res := &Reverse{Addresses: make([]*ReverseAddress, len(addrs))}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, addr := range addrs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, addr netip.Addr) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res.Addresses[i] = c.reverseAddress(ctx, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+	return res
+}
+
+func (c *Client) reverseAddress(ctx context.Context, addr netip.Addr) *ReverseAddress {
+	ra := &ReverseAddress{IP: addr.String(), PTR: []string{}, Forward: []string{}}
+	var err error
+	ra.Name, err = dnslib.ReverseAddr(addr.String())
+	if err != nil {
+		ra.setError(err)
+		return ra
+	}
+	resp, err := c.lookup(ctx, TypePTR, ra.Name)
+	if err != nil {
+		ra.setError(err)
+		return ra
+	}
+	if resp.msg.Rcode != dnslib.RcodeSuccess && resp.msg.Rcode != dnslib.RcodeNameError {
+		ra.setError(fmt.Errorf("PTR query answered %s", resp.Rcode))
+		return ra
+	}
+	for _, rr := range resp.msg.Answer {
+		if ptr, ok := rr.(*dnslib.PTR); ok {
+			ra.PTR = append(ra.PTR, ptr.Ptr)
+		}
+	}
+	if len(ra.PTR) == 0 {
+		ra.Status = ReverseNoPTR
+		return ra
+	}
+	rType := TypeA
+	if addr.Is6() {
+		rType = TypeAAAA
+	}
+	var errs []error
+	for _, name := range ra.PTR {
+		resp, err := c.lookup(ctx, rType, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rr := range resp.msg.Answer {
+			var ip netip.Addr
+			switch rr := rr.(type) {
+			case *dnslib.A:
+				ip, _ = netip.AddrFromSlice(rr.A.To4())
+			case *dnslib.AAAA:
+				ip, _ = netip.AddrFromSlice(rr.AAAA)
+			default:
+				continue
+			}
+			ra.Forward = append(ra.Forward, ip.String())
+			if ip == addr {
+				ra.Confirmed = true
+			}
+		}
+	}
+	switch {
+	case ra.Confirmed:
+		ra.Status = ReverseConfirmed
+	case len(errs) > 0 && len(ra.Forward) == 0:
+		ra.setError(errors.Join(errs...))
+		ra.Error = strings.ReplaceAll(ra.Error, "\n", ", ")
+	default:
+		ra.Status = ReverseMismatch
+	}
+	return ra
+}
+
+// Counts returns the number of addresses by status.
+func (r *Reverse) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, a := range r.Addresses {
+		counts[a.Status]++
+	}
+	return counts
+}
+
+// RenderText writes a line per address, with its PTR names and status, then the number of addresses by status.
+func (r *Reverse) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	ipWidth, ptrWidth := 0, 0
+	for _, a := range r.Addresses {
+		ipWidth, ptrWidth = max(ipWidth, len(a.IP)), max(ptrWidth, len(strings.Join(a.PTR, " ")))
+	}
+	for _, a := range r.Addresses {
+		fmt.Fprintf(buf, "%-*s  %-*s  ", ipWidth, a.IP, ptrWidth, strings.Join(a.PTR, " "))
+		detail := a.Error
+		if a.Status == ReverseMismatch {
+			detail = "does not resolve"
+			if len(a.Forward) > 0 {
+				detail = "resolves to " + strings.Join(a.Forward, " ")
+			}
+		}
+		if detail == "" {
+			reverseStatusColor(a.Status).Fprint(buf, a.Status)
+		} else {
+			reverseStatusColor(a.Status).Fprintf(buf, "%-9s", a.Status)
+			color.New(color.Faint).Fprint(buf, "  "+detail)
+		}
+		buf.WriteString("\n")
+	}
+	counts := r.Counts()
+	var parts []string
+	for _, s := range []string{ReverseConfirmed, ReverseMismatch, ReverseNoPTR, ReverseError} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	color.New(color.Faint).Fprintf(buf, "%d addresses: %s", len(r.Addresses), strings.Join(parts, ", "))
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func reverseStatusColor(status string) *color.Color {
+	switch status {
+	case ReverseConfirmed:
+		return color.New(color.FgGreen)
+	case ReverseMismatch:
+		return color.New(color.FgYellow)
+	case ReverseNoPTR:
+		return color.New(color.Faint)
+	default:
+		return color.New(color.FgRed)
+	}
+}
+
+// Header returns the columns of the reverse lookup table.
+func (r *Reverse) Header() []string {
+	return []string{"ip", "ptr", "confirmed", "status", "forward", "error"}
+}
+
+// Rows returns the addresses, one per row.
+func (r *Reverse) Rows() [][]string {
+	rows := make([][]string, 0, len(r.Addresses))
+	for _, a := range r.Addresses {
+		rows = append(rows, []string{
+			a.IP,
+			strings.Join(a.PTR, " "),
+			strconv.FormatBool(a.Confirmed),
+			a.Status,
+			strings.Join(a.Forward, " "),
+			a.Error,
+		})
+	}
+	return rows
+}
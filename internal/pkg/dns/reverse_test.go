@@ -0,0 +1,76 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+func TestParseReverseTargets(t *testing.T) {
+	addrs, err := ParseReverseTargets("192.0.2.10", "198.51.100.5/30", "2001:db8::1")
+	require.NoError(t, err)
+	require.Len(t, addrs, 6)
+	assert.Equal(t, netip.MustParseAddr("198.51.100.4"), addrs[1])
+	assert.Equal(t, netip.MustParseAddr("198.51.100.7"), addrs[4])
+
+	_, err = ParseReverseTargets("10.0.0.0/8")
+	assert.ErrorContains(t, err, "too large")
+	_, err = ParseReverseTargets("2001:db8::/64")
+	assert.ErrorContains(t, err, "too large")
+	_, err = ParseReverseTargets("not-an-ip")
+	assert.Error(t, err)
+}
+
+func TestClient_Reverse(t *testing.T) {
+	records := map[string]string{
+		"1.2.0.192.in-addr.arpa. PTR": "1.2.0.192.in-addr.arpa. 300 IN PTR host1.example.com.",
+		"2.2.0.192.in-addr.arpa. PTR": "2.2.0.192.in-addr.arpa. 300 IN PTR host2.example.com.",
+		"host1.example.com. A":        "host1.example.com. 300 IN A 192.0.2.1",
+		"host2.example.com. A":        "host2.example.com. 300 IN A 192.0.2.99",
+	}
+	server := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		if s, ok := records[r.Question[0].Name+" "+dnslib.TypeToString[r.Question[0].Qtype]]; ok {
+			rr, _ := dnslib.NewRR(s)
+			m.Answer = append(m.Answer, rr)
+		} else {
+			m.Rcode = dnslib.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	addrs, err := ParseReverseTargets("192.0.2.0/30")
+	require.NoError(t, err)
+	client := NewClient(zap.NewNop(), WithServers(server), WithTimeouts(time.Second, time.Second))
+	res := client.Reverse(context.Background(), addrs, 2)
+	require.Len(t, res.Addresses, 4)
+
+	assert.Equal(t, "0.2.0.192.in-addr.arpa.", res.Addresses[0].Name)
+	assert.Equal(t, ReverseNoPTR, res.Addresses[0].Status)
+	assert.Equal(t, ReverseConfirmed, res.Addresses[1].Status)
+	assert.Equal(t, []string{"host1.example.com."}, res.Addresses[1].PTR)
+	assert.True(t, res.Addresses[1].Confirmed)
+	assert.Equal(t, ReverseMismatch, res.Addresses[2].Status)
+	assert.Equal(t, []string{"192.0.2.99"}, res.Addresses[2].Forward)
+	assert.Equal(t, map[string]int{ReverseConfirmed: 1, ReverseMismatch: 1, ReverseNoPTR: 2}, res.Counts())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, res.RenderText(buf))
+	assert.Contains(t, buf.String(), "resolves to 192.0.2.99")
+	assert.Contains(t, buf.String(), "4 addresses: 1 confirmed, 1 mismatch, 2 no-ptr")
+
+	res = NewClient(zap.NewNop(), WithServers("127.0.0.1:1"), WithTimeouts(time.Second, time.Second)).
+		Reverse(context.Background(), addrs[:1], 1)
+	assert.Equal(t, ReverseError, res.Addresses[0].Status)
+	assert.Equal(t, dsakerr.CategoryNetwork, dsakerr.CategoryOf(res.Addresses[0].Err()))
+}
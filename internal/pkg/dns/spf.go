@@ -0,0 +1,448 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	dnslib "github.com/miekg/dns"
+)
+
+const (
+	// spfMaxLookups is the maximum number of DNS lookups of an SPF evaluation (RFC 7208 section 4.6.4).
+	spfMaxLookups = 10
+	// spfMaxVoidLookups is the maximum number of DNS lookups without answer of an SPF evaluation.
+	spfMaxVoidLookups = 2
+	// spfMaxMX is the maximum number of MX hosts of a mx mechanism.
+	spfMaxMX = 10
+)
+
+// Results of SPF evaluations (RFC 7208 section 2.6).
+const (
+	SPFPass      = "pass"
+	SPFFail      = "fail"
+	SPFSoftFail  = "softfail"
+	SPFNeutral   = "neutral"
+	SPFNone      = "none"
+	SPFPermError = "permerror"
+	SPFTempError = "temperror"
+)
+
+// SPF is the SPF policy of a domain (RFC 7208), with the records of its includes and redirects.
+type SPF struct {
+	Record *SPFRecord `json:"record"`
+	// Lookups is the number of DNS lookups an evaluation can make, receivers failing past 10.
+	Lookups int `json:"lookups"`
+	// VoidLookups is the number of lookups without answer, receivers failing past 2.
+	VoidLookups int `json:"void_lookups"`
+	// Depth is the maximum nesting of includes and redirects.
+	Depth int `json:"depth"`
+	// IP is the address evaluated, if any, Result its result and Match the term that gave it.
+	IP     string `json:"ip,omitempty"`
+	Result string `json:"result,omitempty"`
+	Match  string `json:"match,omitempty"`
+}
+
+// SPFRecord is the SPF record of a domain.
+type SPFRecord struct {
+	Domain string     `json:"domain"`
+	Text   string     `json:"text,omitempty"`
+	Terms  []*SPFTerm `json:"terms"`
+	// Error is the reason the record gives a permerror, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// SPFTerm is a mechanism or a modifier of an SPF record.
+type SPFTerm struct {
+	// Qualifier is "+", "-", "~" or "?", for mechanisms.
+	Qualifier string `json:"qualifier,omitempty"`
+	// Name is the mechanism, eg: "include", or the modifier, eg: "redirect".
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	Modifier bool   `json:"modifier,omitempty"`
+	// Addresses are the addresses the a and mx mechanisms resolved to.
+	Addresses []string `json:"addresses,omitempty"`
+	// Record is the record of the domain of an include mechanism or a redirect modifier.
+	Record *SPFRecord `json:"record,omitempty"`
+
+	// raw is the term as written in the record, target its domain and prefixes the ranges it matches.
+	raw      string
+	target   string
+	prefixes []netip.Prefix
+	// bits4 and bits6 are the prefix lengths of the addresses of the a and mx mechanisms.
+	bits4, bits6 int
+	// macro is true if the domain of the term has macros, that can only be expanded for a message.
+	macro bool
+}
+
+// String returns the term as written in the record.
+func (t *SPFTerm) String() string {
+	return t.raw
+}
+
+// isSPFRecord returns true if txt is an SPF version 1 record.
+func isSPFRecord(txt string) bool {
+	return strings.EqualFold(txt, "v=spf1") || (len(txt) > 7 && strings.EqualFold(txt[:7], "v=spf1 "))
+}
+
+// parseSPFTerm parses a term of an SPF record of domain.
+func parseSPFTerm(s, domain string) (*SPFTerm, error) {
+	if i := strings.IndexAny(s, "=:/"); i > 0 && s[i] == '=' {
+		name := strings.ToLower(s[:i])
+		t := &SPFTerm{Name: name, Value: s[i+1:], Modifier: true, raw: s, target: s[i+1:]}
+		if name == "redirect" && t.Value == "" {
+			return nil, fmt.Errorf("redirect modifier without domain")
+		}
+		t.macro = strings.Contains(t.Value, "%")
+		return t, nil
+	}
+	t := &SPFTerm{Qualifier: "+", raw: s}
+	if strings.ContainsAny(s[:1], "+-~?") {
+		t.Qualifier, s = s[:1], s[1:]
+	}
+	t.Name, t.Value = strings.ToLower(s), ""
+	if i := strings.IndexAny(s, ":/"); i >= 0 {
+		t.Name, t.Value = strings.ToLower(s[:i]), strings.TrimPrefix(s[i:], ":")
+	}
+	spec, cidr := t.Value, ""
+	if i := strings.Index(spec, "/"); i >= 0 && t.Name != "ip4" && t.Name != "ip6" {
+		spec, cidr = spec[:i], spec[i:]
+	}
+	t.target, t.macro = spec, strings.Contains(spec, "%")
+	switch t.Name {
+	case "all", "ptr":
+		if t.Name == "all" && t.Value != "" {
+			return nil, fmt.Errorf("invalid mechanism %s", s)
+		}
+	case "include", "exists":
+		if spec == "" || cidr != "" {
+			return nil, fmt.Errorf("%s mechanism without domain", t.Name)
+		}
+	case "a", "mx":
+		if spec == "" {
+			t.target = domain
+		}
+		var err error
+		if t.bits4, t.bits6, err = parseSPFCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid mechanism %s: %w", s, err)
+		}
+	case "ip4", "ip6":
+		p, err := netip.ParsePrefix(t.Value)
+		if err != nil {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(t.Value); err == nil {
+				p = netip.PrefixFrom(addr, addr.BitLen())
+			}
+		}
+		if err != nil || p.Addr().Is4() != (t.Name == "ip4") {
+			return nil, fmt.Errorf("invalid mechanism %s", s)
+		}
+		t.prefixes = []netip.Prefix{p.Masked()}
+	default:
+		return nil, fmt.Errorf("unknown mechanism %s", s)
+	}
+	return t, nil
+}
+
+// parseSPFCIDR parses the dual CIDR length of a and mx mechanisms, eg: "/24//64".
+func parseSPFCIDR(s string) (int, int, error) {
+	v4, v6 := 32, 128
+	if s == "" {
+		return v4, v6, nil
+	}
+	s4, s6, dual := strings.Cut(strings.TrimPrefix(s, "/"), "//")
+	if strings.HasPrefix(s, "//") {
+		s4, s6, dual = "", strings.TrimPrefix(s, "//"), true
+	}
+	var err error
+	if s4 != "" {
+		if v4, err = strconv.Atoi(s4); err != nil || v4 < 0 || v4 > 32 {
+			return 0, 0, fmt.Errorf("invalid IPv4 prefix length %s", s4)
+		}
+	}
+	if dual {
+		if v6, err = strconv.Atoi(s6); err != nil || v6 < 0 || v6 > 128 {
+			return 0, 0, fmt.Errorf("invalid IPv6 prefix length %s", s6)
+		}
+	}
+	return v4, v6, nil
+}
+
+// spfChecker resolves the SPF records of a domain and its includes.
+type spfChecker struct {
+	c    *Client
+	mail *Mail
+	spf  *SPF
+	// visiting are the domains of the records being resolved, to detect loops.
+	visiting map[string]bool
+}
+
+// checkSPF resolves the SPF policy of the domain and, if ip is valid, evaluates it for ip.
+func (c *Client) checkSPF(ctx context.Context, m *Mail, ip netip.Addr) {
+	s := &spfChecker{c: c, mail: m, spf: &SPF{}, visiting: make(map[string]bool)}
+	txts, err := c.mailTXT(ctx, m.Domain)
+	if err != nil {
+		m.add(MailSeverityError, MailCheckSPF, "SPF lookup failed: %s", err)
+		return
+	}
+	var records []string
+	for _, txt := range txts {
+		if isSPFRecord(txt) {
+			records = append(records, txt)
+		}
+	}
+	switch {
+	case len(records) == 0:
+		m.add(MailSeverityWarning, MailCheckSPF, "no SPF record, receivers cannot check the servers sending mail for %s", m.Domain)
+		return
+	case len(records) > 1:
+		m.add(MailSeverityError, MailCheckSPF, "%d SPF records, receivers fail the SPF check with a permerror", len(records))
+	}
+	m.SPF = s.spf
+	s.spf.Record = s.record(ctx, m.Domain, records[0], 0)
+	if len(records) > 1 {
+		s.spf.Record.Error = "several SPF records"
+	}
+	s.checkRoot()
+	if s.spf.Lookups > spfMaxLookups {
+		m.add(
+			MailSeverityError, MailCheckSPF,
+			"SPF evaluation requires %d DNS lookups, more than the limit of %d, receivers fail it with a permerror", s.spf.Lookups, spfMaxLookups,
+		)
+	} else if s.spf.Lookups > spfMaxLookups-2 {
+		m.add(MailSeverityInfo, MailCheckSPF, "SPF evaluation requires %d of the %d DNS lookups allowed", s.spf.Lookups, spfMaxLookups)
+	}
+	if s.spf.VoidLookups > spfMaxVoidLookups {
+		m.add(
+			MailSeverityError, MailCheckSPF,
+			"%d SPF lookups have no answer, more than the limit of %d, receivers fail the evaluation with a permerror", s.spf.VoidLookups, spfMaxVoidLookups,
+		)
+	}
+	if ip.IsValid() {
+		s.evaluate(ip)
+	}
+}
+
+// resolve returns the SPF record of an include or redirect to domain, nil if it has none.
+func (s *spfChecker) resolve(ctx context.Context, domain string, depth int) *SPFRecord {
+	txts, err := s.c.mailTXT(ctx, domain)
+	if err != nil {
+		s.mail.add(MailSeverityError, MailCheckSPF, "SPF lookup of %s failed: %s", domain, err)
+		return &SPFRecord{Domain: domain, Error: err.Error()}
+	}
+	var records []string
+	for _, txt := range txts {
+		if isSPFRecord(txt) {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		s.spf.VoidLookups++
+		s.mail.add(MailSeverityError, MailCheckSPF, "%s has no SPF record, receivers fail the evaluation with a permerror", domain)
+		return &SPFRecord{Domain: domain, Error: "no SPF record"}
+	case 1:
+		return s.record(ctx, domain, records[0], depth)
+	default:
+		s.mail.add(MailSeverityError, MailCheckSPF, "%s has %d SPF records, receivers fail the evaluation with a permerror", domain, len(records))
+		return &SPFRecord{Domain: domain, Error: "several SPF records"}
+	}
+}
+
+// record parses the SPF record txt of domain, and resolves the domains of its terms.
+func (s *spfChecker) record(ctx context.Context, domain, txt string, depth int) *SPFRecord {
+	s.spf.Depth = max(s.spf.Depth, depth)
+	rec := &SPFRecord{Domain: domain, Text: txt, Terms: []*SPFTerm{}}
+	key := strings.ToLower(dnslib.Fqdn(domain))
+	s.visiting[key] = true
+	defer delete(s.visiting, key)
+	for _, field := range strings.Fields(txt)[1:] {
+		t, err := parseSPFTerm(field, domain)
+		if err != nil {
+			rec.Error = err.Error()
+			s.mail.add(MailSeverityError, MailCheckSPF, "invalid SPF record of %s: %s", domain, err)
+			return rec
+		}
+		rec.Terms = append(rec.Terms, t)
+	}
+	for _, t := range rec.Terms {
+		s.resolveTerm(ctx, rec, t, depth)
+	}
+	return rec
+}
+
+func (s *spfChecker) resolveTerm(ctx context.Context, rec *SPFRecord, t *SPFTerm, depth int) {
+	switch t.Name {
+	case "include", "redirect", "a", "mx", "ptr", "exists":
+		s.spf.Lookups++
+	default:
+		return
+	}
+	if t.Name == "ptr" {
+		s.mail.add(MailSeverityWarning, MailCheckSPF, "%s of %s: the ptr mechanism is slow and unreliable, it is deprecated by RFC 7208", t, rec.Domain)
+		return
+	}
+	if t.macro {
+		// Macros are expanded with the sender and the address of a message.
+		return
+	}
+	switch t.Name {
+	case "include", "redirect":
+		if s.visiting[strings.ToLower(dnslib.Fqdn(t.target))] {
+			rec.Error = "loop through " + t.target
+			s.mail.add(MailSeverityError, MailCheckSPF, "%s of %s loops, receivers fail the evaluation with a permerror", t, rec.Domain)
+			return
+		}
+		if s.spf.Lookups > spfMaxLookups*2 {
+			// The evaluation fails anyway, the records are not resolved further.
+			return
+		}
+		t.Record = s.resolve(ctx, t.target, depth+1)
+	case "a", "mx":
+		hosts := []string{t.target}
+		if t.Name == "mx" {
+			rrs, err := s.c.mailRecords(ctx, TypeMX, t.target)
+			if err != nil {
+				s.mail.add(MailSeverityError, MailCheckSPF, "%s of %s: %s", t, rec.Domain, err)
+				return
+			}
+			hosts = hosts[:0]
+			for _, rr := range rrs {
+				hosts = append(hosts, rr.(*dnslib.MX).Mx)
+			}
+			if len(hosts) > spfMaxMX {
+				s.mail.add(MailSeverityError, MailCheckSPF, "%s of %s has %d MX hosts, more than the limit of %d", t, rec.Domain, len(hosts), spfMaxMX)
+			}
+		}
+		for _, host := range hosts {
+			addrs, _, err := s.c.mailAddresses(ctx, host)
+			if err != nil {
+				s.mail.add(MailSeverityError, MailCheckSPF, "%s of %s: %s", t, rec.Domain, err)
+			}
+			for _, addr := range addrs {
+				t.Addresses = append(t.Addresses, addr.String())
+				bits := t.bits4
+				if addr.Is6() {
+					bits = t.bits6
+				}
+				t.prefixes = append(t.prefixes, netip.PrefixFrom(addr, bits).Masked())
+			}
+		}
+		if len(t.Addresses) == 0 {
+			s.spf.VoidLookups++
+			s.mail.add(MailSeverityWarning, MailCheckSPF, "%s of %s does not resolve to any address", t, rec.Domain)
+		}
+	case "exists":
+		addrs, _, err := s.c.mailAddresses(ctx, t.target)
+		if err == nil && len(addrs) == 0 {
+			s.spf.VoidLookups++
+		}
+		for _, addr := range addrs {
+			t.Addresses = append(t.Addresses, addr.String())
+		}
+	}
+}
+
+// checkRoot checks the all mechanisms of the records, and that the record of the domain ends with one.
+func (s *spfChecker) checkRoot() {
+	var walk func(rec *SPFRecord)
+	walk = func(rec *SPFRecord) {
+		for _, t := range rec.Terms {
+			if t.Name == "all" && t.Qualifier == "+" {
+				s.mail.add(MailSeverityError, MailCheckSPF, "%s of %s authorizes every server to send mail for %s", t, rec.Domain, s.mail.Domain)
+			}
+			if t.Record != nil {
+				walk(t.Record)
+			}
+		}
+	}
+	walk(s.spf.Record)
+	var all, redirect *SPFTerm
+	for _, t := range s.spf.Record.Terms {
+		switch t.Name {
+		case "all":
+			all = t
+		case "redirect":
+			redirect = t
+		}
+	}
+	switch {
+	case all != nil && all.Qualifier == "?":
+		s.mail.add(MailSeverityWarning, MailCheckSPF, "?all gives a neutral result to unauthorized servers, use ~all or -all")
+	case all == nil && redirect == nil && s.spf.Record.Error == "":
+		s.mail.add(MailSeverityWarning, MailCheckSPF, "no all mechanism nor redirect modifier, unauthorized servers get a neutral result, end the record with ~all or -all")
+	}
+}
+
+// evaluate evaluates the SPF policy for ip, with the resolved records.
+func (s *spfChecker) evaluate(ip netip.Addr) {
+	s.spf.IP = ip.String()
+	if s.spf.Lookups > spfMaxLookups || s.spf.VoidLookups > spfMaxVoidLookups {
+		s.spf.Result, s.spf.Match = SPFPermError, "too many DNS lookups"
+	} else {
+		s.spf.Result, s.spf.Match = spfEvaluate(s.spf.Record, ip)
+	}
+	switch s.spf.Result {
+	case SPFPass:
+		s.mail.add(MailSeverityInfo, MailCheckSPF, "%s is authorized to send mail for %s by %s", ip, s.mail.Domain, s.spf.Match)
+	case SPFNeutral:
+		s.mail.add(MailSeverityWarning, MailCheckSPF, "%s gets a neutral SPF result (%s)", ip, s.spf.Match)
+	default:
+		s.mail.add(MailSeverityError, MailCheckSPF, "%s gets a %s SPF result (%s)", ip, s.spf.Result, s.spf.Match)
+	}
+}
+
+// spfEvaluate returns the result of rec for ip, and the term that gave it.
+func spfEvaluate(rec *SPFRecord, ip netip.Addr) (string, string) {
+	if rec.Error != "" {
+		return SPFPermError, rec.Error + " at " + rec.Domain
+	}
+	var redirect *SPFTerm
+	for _, t := range rec.Terms {
+		matched := false
+		switch t.Name {
+		case "all":
+			matched = true
+		case "ip4", "ip6", "a", "mx":
+			for _, p := range t.prefixes {
+				matched = matched || p.Contains(ip)
+			}
+		case "exists":
+			matched = len(t.Addresses) > 0
+		case "include":
+			if t.macro {
+				continue
+			}
+			switch result, match := spfEvaluate(t.Record, ip); result {
+			case SPFPass:
+				return spfQualifierResult(t.Qualifier), fmt.Sprintf("%s, included by %s", match, rec.Domain)
+			case SPFPermError, SPFTempError:
+				return result, match
+			}
+		case "redirect":
+			redirect = t
+		}
+		if matched {
+			return spfQualifierResult(t.Qualifier), fmt.Sprintf("%s of %s", t, rec.Domain)
+		}
+	}
+	if redirect != nil && !redirect.macro {
+		return spfEvaluate(redirect.Record, ip)
+	}
+	return SPFNeutral, "no mechanism matched in " + rec.Domain
+}
+
+func spfQualifierResult(qualifier string) string {
+	switch qualifier {
+	case "-":
+		return SPFFail
+	case "~":
+		return SPFSoftFail
+	case "?":
+		return SPFNeutral
+	default:
+		return SPFPass
+	}
+}
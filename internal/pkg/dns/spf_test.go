@@ -0,0 +1,123 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testSPFZone = `$ORIGIN example.test.
+$TTL 300
+@          IN SOA ns1 hostmaster 1 3600 600 86400 60
+@          IN NS  ns1
+ns1        IN A   192.0.2.53
+mx1        IN A   192.0.2.25
+mx1        IN AAAA 2001:db8::25
+@          IN MX  10 mx1
+@          IN TXT "v=spf1 ip4:192.0.2.0/28 mx/24 include:_spf.example.test ~all"
+_spf       IN TXT "v=spf1 ip6:2001:db8:1::/48 include:_open.example.test -all"
+_open      IN TXT "v=spf1 a:nope.example.test +all"
+loop       IN TXT "v=spf1 include:loop2.example.test -all"
+loop2      IN TXT "v=spf1 redirect=loop.example.test"
+many       IN TXT "v=spf1 a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test a:mx1.example.test -all"
+neutral    IN TXT "v=spf1 ip4:192.0.2.1 ptr"
+invalid    IN TXT "v=spf1 ip4:192.0.2.300 -all"
+`
+
+func TestParseSPFTerm(t *testing.T) {
+	term, err := parseSPFTerm("-mx:example.com/24//64", "example.test.")
+	require.NoError(t, err)
+	assert.Equal(t, "-", term.Qualifier)
+	assert.Equal(t, "mx", term.Name)
+	assert.Equal(t, "example.com", term.target)
+	assert.Equal(t, 24, term.bits4)
+	assert.Equal(t, 64, term.bits6)
+	assert.Equal(t, "-mx:example.com/24//64", term.String())
+
+	term, err = parseSPFTerm("a//96", "example.test.")
+	require.NoError(t, err)
+	assert.Equal(t, "example.test.", term.target)
+	assert.Equal(t, 32, term.bits4)
+	assert.Equal(t, 96, term.bits6)
+	assert.Equal(t, "a//96", term.String())
+
+	term, err = parseSPFTerm("redirect=_spf.example.com", "example.test.")
+	require.NoError(t, err)
+	assert.True(t, term.Modifier)
+	term, err = parseSPFTerm("include:%{i}._spf.example.com", "example.test.")
+	require.NoError(t, err)
+	assert.True(t, term.macro)
+
+	for _, s := range []string{"ip4:2001:db8::1", "ip6:192.0.2.1", "include", "all:x", "a/33", "foo:bar", "redirect="} {
+		_, err := parseSPFTerm(s, "example.test.")
+		assert.Error(t, err, s)
+	}
+}
+
+func TestClient_checkSPF(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := startStubServer(t, WithZoneFiles(writeZoneFile(t, dir, "example.test.zone", testSPFZone)))
+	client := NewClient(zap.NewNop(), WithServers(s.Addr()))
+	check := func(domain string, ip string) *Mail {
+		m := &Mail{Domain: domain}
+		var addr netip.Addr
+		if ip != "" {
+			addr = netip.MustParseAddr(ip)
+		}
+		client.checkSPF(context.Background(), m, addr)
+		return m
+	}
+	messages := func(m *Mail) string {
+		var lines []string
+		for _, f := range m.Findings {
+			lines = append(lines, f.Severity+" "+f.Message)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	m := check("example.test.", "192.0.2.1")
+	require.NotNil(t, m.SPF)
+	assert.Equal(t, 4, m.SPF.Lookups)
+	assert.Equal(t, 1, m.SPF.VoidLookups)
+	assert.Equal(t, 2, m.SPF.Depth)
+	assert.Equal(t, []string{"192.0.2.25", "2001:db8::25"}, m.SPF.Record.Terms[1].Addresses)
+	assert.Equal(t, SPFPass, m.SPF.Result)
+	assert.Equal(t, "ip4:192.0.2.0/28 of example.test.", m.SPF.Match)
+	assert.Contains(t, messages(m), "error +all of _open.example.test authorizes every server")
+	assert.Contains(t, messages(m), "warning a:nope.example.test of _open.example.test does not resolve")
+
+	assert.Equal(t, SPFPass, check("example.test.", "192.0.2.200").SPF.Result)
+	assert.Equal(t, SPFPass, check("example.test.", "2001:db8::25").SPF.Result)
+	assert.Equal(
+		t,
+		"+all of _open.example.test, included by _spf.example.test, included by example.test.",
+		check("example.test.", "203.0.113.1").SPF.Match,
+	)
+
+	m = check("loop.example.test.", "192.0.2.1")
+	assert.Equal(t, SPFPermError, m.SPF.Result)
+	assert.Contains(t, messages(m), "loops")
+
+	m = check("many.example.test.", "192.0.2.25")
+	assert.Equal(t, 11, m.SPF.Lookups)
+	assert.Equal(t, SPFPermError, m.SPF.Result)
+	assert.Contains(t, messages(m), "error SPF evaluation requires 11 DNS lookups")
+
+	m = check("neutral.example.test.", "192.0.2.2")
+	assert.Equal(t, SPFNeutral, m.SPF.Result)
+	assert.Contains(t, messages(m), "ptr mechanism is slow")
+	assert.Contains(t, messages(m), "no all mechanism nor redirect modifier")
+
+	m = check("invalid.example.test.", "")
+	assert.Contains(t, messages(m), "error invalid SPF record of invalid.example.test.: invalid mechanism ip4:192.0.2.300")
+	assert.Empty(t, m.SPF.Result)
+
+	m = check("ns1.example.test.", "")
+	assert.Nil(t, m.SPF)
+	assert.Contains(t, messages(m), "warning no SPF record")
+}
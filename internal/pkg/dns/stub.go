@@ -0,0 +1,482 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxStubCNAMEHops is the maximum number of local CNAME records followed to answer a query.
+	maxStubCNAMEHops = 8
+	// stubForwardTimeout is the timeout of queries forwarded by a stub server.
+	stubForwardTimeout = 5 * time.Second
+)
+
+// Sources of the answers of a stub server.
+const (
+	// StubSourceLocal is a query answered from the zone files.
+	StubSourceLocal = "local"
+	// StubSourceForward is a query answered by a forwarder.
+	StubSourceForward = "forward"
+	// StubSourceRefused is a query refused, as its name is not in the zone files and forwarding is disabled.
+	StubSourceRefused = "refused"
+	// StubSourceError is a query that could not be forwarded.
+	StubSourceError = "error"
+)
+
+// StubServer is a DNS server answering over UDP and TCP from zone files, and optionally forwarding the
+// queries for other names.
+type StubServer struct {
+	logger         *zap.Logger
+	files          []string
+	forwarder      *Client
+	reloadInterval time.Duration
+	onQuery        func(*QueryLog)
+
+	mu       sync.RWMutex
+	data     *stubData
+	modTimes map[string]time.Time
+
+	pc       net.PacketConn
+	listener net.Listener
+}
+
+// StubOption is a function that configures a StubServer.
+type StubOption func(*StubServer)
+
+// WithZoneFiles sets the zone files the server answers from, in the BIND format or in YAML, see LoadZoneFile.
+func WithZoneFiles(files ...string) StubOption {
+	return func(s *StubServer) {
+		s.files = append(s.files, files...)
+	}
+}
+
+// WithForwarder makes the server forward the queries for names that are not in its zone files to the
+// servers of client, in order until one answers. Without forwarder, these queries are refused.
+func WithForwarder(client *Client) StubOption {
+	return func(s *StubServer) {
+		s.forwarder = client
+	}
+}
+
+// WithReloadInterval sets the interval the zone files are checked for changes at, 0 disables reloading.
+func WithReloadInterval(interval time.Duration) StubOption {
+	return func(s *StubServer) {
+		s.reloadInterval = interval
+	}
+}
+
+// WithQueryLog sets a function called after each query is answered.
+func WithQueryLog(fn func(*QueryLog)) StubOption {
+	return func(s *StubServer) {
+		s.onQuery = fn
+	}
+}
+
+// NewStubServer creates a new stub DNS server, Load and Listen must be called before Serve.
+func NewStubServer(logger *zap.Logger, opts ...StubOption) *StubServer {
+	s := &StubServer{
+		logger:   logger,
+		data:     newStubData(),
+		modTimes: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// QueryLog is a query answered by a stub server.
+type QueryLog struct {
+	Time     time.Time `json:"time"`
+	Client   string    `json:"client"`
+	Protocol string    `json:"protocol"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Rcode    string    `json:"rcode"`
+	// Source is where the answer comes from: "local", "forward", "refused" or "error".
+	Source   string   `json:"source"`
+	Answer   []string `json:"answer"`
+	Duration Duration `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RenderText writes the query on a line, with its answer.
+func (q *QueryLog) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	faint.Fprintf(buf, "%s %s/%s ", q.Time.Format("15:04:05.000"), q.Client, q.Protocol)
+	color.New(color.Bold).Fprint(buf, q.Name)
+	buf.WriteString(" ")
+	if t, err := GetType(q.Type); err == nil {
+		typeColor(uint16(t)).Fprint(buf, q.Type)
+	} else {
+		buf.WriteString(q.Type)
+	}
+	buf.WriteString(" ")
+	rcodeColor(dnslib.StringToRcode[q.Rcode]).Fprint(buf, q.Rcode)
+	faint.Fprintf(buf, " %s %s", q.Source, time.Duration(q.Duration).Round(time.Microsecond))
+	if len(q.Answer) > 0 {
+		buf.WriteString("  " + strings.Join(q.Answer, ", "))
+	}
+	if q.Error != "" {
+		color.New(color.FgRed).Fprint(buf, "  "+q.Error)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// stubData are the records of the zone files of a stub server.
+type stubData struct {
+	// records are the records by canonical owner name.
+	records map[string][]dnslib.RR
+	// names are the names that exist: the owners of records, and their ancestors in the zones.
+	names map[string]bool
+	// soa are the SOA records of the zones, by canonical zone name.
+	soa map[string]*dnslib.SOA
+}
+
+func newStubData() *stubData {
+	return &stubData{
+		records: make(map[string][]dnslib.RR),
+		names:   make(map[string]bool),
+		soa:     make(map[string]*dnslib.SOA),
+	}
+}
+
+func (d *stubData) add(rr dnslib.RR) {
+	name := dnslib.CanonicalName(rr.Header().Name)
+	d.records[name] = append(d.records[name], rr)
+	if soa, ok := rr.(*dnslib.SOA); ok {
+		d.soa[name] = soa
+	}
+	d.names[name] = true
+}
+
+// addAncestors adds the ancestors of the owner names inside the zones, the empty non-terminals: they
+// exist and are answered with NODATA instead of NXDOMAIN.
+func (d *stubData) addAncestors() {
+	for name := range d.records {
+		zone := d.zoneOf(name)
+		if zone == "" {
+			continue
+		}
+		for n := name; n != zone && n != "."; n = parentName(n) {
+			d.names[n] = true
+		}
+	}
+}
+
+// zoneOf returns the closest zone with a SOA record holding name, or "" if there is none.
+func (d *stubData) zoneOf(name string) string {
+	for n := name; ; n = parentName(n) {
+		if _, ok := d.soa[n]; ok {
+			return n
+		}
+		if n == "." {
+			return ""
+		}
+	}
+}
+
+// find returns the records of name, synthesized from a wildcard if name does not exist, and whether
+// name exists or matches a wildcard.
+func (d *stubData) find(name string) ([]dnslib.RR, bool) {
+	if d.names[name] {
+		return d.records[name], true
+	}
+	for p := parentName(name); ; p = parentName(p) {
+		if wildcard, ok := d.records["*."+p]; ok {
+			rrs := make([]dnslib.RR, 0, len(wildcard))
+			for _, rr := range wildcard {
+				rr = dnslib.Copy(rr)
+				rr.Header().Name = name
+				rrs = append(rrs, rr)
+			}
+			return rrs, true
+		}
+		// The wildcard must be a child of the closest existing ancestor (RFC 4592 section 3.3.1).
+		if d.names[p] || p == "." {
+			return nil, false
+		}
+	}
+}
+
+// Load reads the zone files, replacing the records the server answers with if they are all valid.
+func (s *StubServer) Load() error {
+	data := newStubData()
+	modTimes := make(map[string]time.Time, len(s.files))
+	for _, file := range s.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("cannot read zone file: %w", err)
+		}
+		modTimes[file] = info.ModTime()
+		zone, err := LoadZoneFile(file)
+		if err != nil {
+			return err
+		}
+		for _, rr := range zone.Records {
+			data.add(rr)
+		}
+	}
+	data.addAncestors()
+	s.mu.Lock()
+	s.data, s.modTimes = data, modTimes
+	s.mu.Unlock()
+	return nil
+}
+
+// changed returns true if a zone file has been modified since it was last checked or loaded.
+func (s *StubServer) changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for _, file := range s.files {
+		var modTime time.Time
+		if info, err := os.Stat(file); err == nil {
+			modTime = info.ModTime()
+		}
+		if !modTime.Equal(s.modTimes[file]) {
+			// Recorded even if loading fails, so that an invalid file is only reported once.
+			s.modTimes[file] = modTime
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Listen opens the UDP and TCP sockets of the server on address, host:port. With port 0, both listen on
+// the same random port.
+func (s *StubServer) Listen(address string) error {
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen on UDP %s: %w", address, err)
+	}
+	listener, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		return fmt.Errorf("cannot listen on TCP %s: %w", address, err)
+	}
+	s.pc, s.listener = pc, listener
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *StubServer) Addr() string {
+	if s.pc == nil {
+		return ""
+	}
+	return s.pc.LocalAddr().String()
+}
+
+// Serve answers queries until ctx is done, reloading the zone files when they change.
+func (s *StubServer) Serve(ctx context.Context) error {
+	if s.pc == nil {
+		return errors.New("the server is not listening")
+	}
+	handler := dnslib.HandlerFunc(func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		s.handle(ctx, w, r)
+	})
+	servers := []*dnslib.Server{
+		{PacketConn: s.pc, Handler: handler},
+		{Listener: s.listener, Handler: handler},
+	}
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *dnslib.Server) {
+			errs <- srv.ActivateAndServe()
+		}(srv)
+	}
+	var tick <-chan time.Time
+	if s.reloadInterval > 0 && len(s.files) > 0 {
+		ticker := time.NewTicker(s.reloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var err error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err = <-errs:
+			err = fmt.Errorf("DNS server stopped: %w", err)
+			break loop
+		case <-tick:
+			if !s.changed() {
+				continue
+			}
+			if lerr := s.Load(); lerr != nil {
+				s.logger.With(zap.Error(lerr)).Warn("Cannot reload zone files, keeping the previous records")
+				continue
+			}
+			s.logger.With(zap.Strings("files", s.files)).Info("Zone files reloaded")
+		}
+	}
+	for _, srv := range servers {
+		_ = srv.Shutdown()
+	}
+	return err
+}
+
+func (s *StubServer) handle(ctx context.Context, w dnslib.ResponseWriter, r *dnslib.Msg) {
+	start := time.Now()
+	q := &QueryLog{Time: start, Protocol: "udp", Answer: []string{}}
+	if addr, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		q.Protocol, q.Client = "tcp", addr.IP.String()
+	} else if addr, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		q.Client = addr.IP.String()
+	}
+	var m *dnslib.Msg
+	if len(r.Question) != 1 || r.Opcode != dnslib.OpcodeQuery {
+		m = new(dnslib.Msg)
+		m.SetRcode(r, dnslib.RcodeNotImplemented)
+		q.Source = StubSourceRefused
+	} else {
+		q.Name, q.Type = r.Question[0].Name, GetTypeName(Type(r.Question[0].Qtype))
+		var err error
+		m, q.Source, err = s.answer(ctx, r)
+		if err != nil {
+			q.Error = err.Error()
+		}
+	}
+	if q.Protocol == "udp" {
+		size := dnslib.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		m.Truncate(size)
+	}
+	if err := w.WriteMsg(m); err != nil {
+		s.logger.With(zap.Error(err)).Debug("Cannot write DNS response")
+	}
+	q.Rcode = dnslib.RcodeToString[m.Rcode]
+	for _, rr := range m.Answer {
+		q.Answer = append(q.Answer, GetTypeName(Type(rr.Header().Rrtype))+" "+strings.TrimSpace(rdata(rr)))
+	}
+	q.Duration = Duration(time.Since(start))
+	if s.onQuery != nil {
+		s.onQuery(q)
+	}
+}
+
+// answer returns the response to r from the zone files, following the CNAME records, or from the
+// forwarder for the names that are not in the zone files, and its source.
+func (s *StubServer) answer(ctx context.Context, r *dnslib.Msg) (*dnslib.Msg, string, error) {
+	s.mu.RLock()
+	data := s.data
+	s.mu.RUnlock()
+	m := new(dnslib.Msg)
+	m.SetReply(r)
+	m.RecursionAvailable = s.forwarder != nil
+	if opt := r.IsEdns0(); opt != nil {
+		m.SetEdns0(max(opt.UDPSize(), dnslib.MinMsgSize), false)
+	}
+	qtype := r.Question[0].Qtype
+	name := dnslib.CanonicalName(r.Question[0].Name)
+	for hops := 0; hops <= maxStubCNAMEHops; hops++ {
+		rrs, found := data.find(name)
+		if !found {
+			zone := data.zoneOf(name)
+			if zone == "" {
+				return s.forwardName(ctx, r, m, name, hops)
+			}
+			m.Authoritative = true
+			m.Rcode = dnslib.RcodeNameError
+			m.Ns = append(m.Ns, data.soa[zone])
+			return m, StubSourceLocal, nil
+		}
+		m.Authoritative = true
+		var cname *dnslib.CNAME
+		matched := false
+		for _, rr := range rrs {
+			switch {
+			case rr.Header().Rrtype == qtype || qtype == dnslib.TypeANY:
+				m.Answer = append(m.Answer, rr)
+				matched = true
+			case rr.Header().Rrtype == dnslib.TypeCNAME:
+				cname = rr.(*dnslib.CNAME)
+			}
+		}
+		if matched {
+			return m, StubSourceLocal, nil
+		}
+		if cname == nil {
+			// NODATA: the name exists without records of the type.
+			if zone := data.zoneOf(name); zone != "" {
+				m.Ns = append(m.Ns, data.soa[zone])
+			}
+			return m, StubSourceLocal, nil
+		}
+		m.Answer = append(m.Answer, cname)
+		name = dnslib.CanonicalName(cname.Target)
+	}
+	m.Rcode = dnslib.RcodeServerFailure
+	return m, StubSourceError, fmt.Errorf("more than %d CNAME records followed", maxStubCNAMEHops)
+}
+
+// forwardName answers the query for name, which is not in the zone files: r itself if name is its
+// question, the target of the local CNAME records of m otherwise.
+func (s *StubServer) forwardName(ctx context.Context, r, m *dnslib.Msg, name string, hops int) (*dnslib.Msg, string, error) {
+	if s.forwarder == nil {
+		if hops > 0 {
+			// The local CNAME records are answered, the client resolves their target.
+			return m, StubSourceLocal, nil
+		}
+		m.Rcode = dnslib.RcodeRefused
+		return m, StubSourceRefused, nil
+	}
+	req := r.Copy()
+	if hops > 0 {
+		req.Question[0].Name = name
+	}
+	in, err := s.forward(ctx, req)
+	if err != nil {
+		m.Rcode = dnslib.RcodeServerFailure
+		return m, StubSourceError, err
+	}
+	if hops == 0 {
+		in.Id = r.Id
+		return in, StubSourceForward, nil
+	}
+	m.Authoritative = false
+	m.Rcode = in.Rcode
+	m.Answer = append(m.Answer, in.Answer...)
+	m.Ns = in.Ns
+	return m, StubSourceForward, nil
+}
+
+// forward sends req to the servers of the forwarder, in order until one answers.
+func (s *StubServer) forward(ctx context.Context, req *dnslib.Msg) (*dnslib.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, stubForwardTimeout)
+	defer cancel()
+	var errs []string
+	for _, address := range s.forwarder.servers {
+		srv, err := parseServer(address)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		in, _, _, err := s.forwarder.exchange(ctx, srv, req.Copy())
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", srv, err))
+			continue
+		}
+		return in, nil
+	}
+	return nil, fmt.Errorf("cannot forward the query: %s", strings.Join(errs, ", "))
+}
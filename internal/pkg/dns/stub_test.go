@@ -0,0 +1,173 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testBINDZone = `$ORIGIN example.test.
+$TTL 300
+@        IN SOA ns1 hostmaster 2024010101 3600 600 86400 60
+@        IN NS  ns1
+ns1      IN A   192.0.2.53
+www      IN A   192.0.2.1
+api      IN CNAME www
+*.apps   IN A   192.0.2.2
+a.b      IN TXT "deep"
+`
+
+const testYAMLZone = `origin: corp.
+ttl: 60
+records:
+  - name: intranet
+    type: A
+    data: [198.51.100.1, 198.51.100.2]
+  - name: proxy.example.org.
+    type: CNAME
+    data: upstream.example.org.
+`
+
+// startStubServer starts a stub server on a random local port and returns it, with the queries it logged.
+func startStubServer(t *testing.T, opts ...StubOption) (*StubServer, func() []*QueryLog) {
+	t.Helper()
+	mu := sync.Mutex{}
+	var logs []*QueryLog
+	opts = append(opts, WithQueryLog(func(q *QueryLog) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, q)
+	}))
+	s := NewStubServer(zap.NewNop(), opts...)
+	require.NoError(t, s.Load())
+	require.NoError(t, s.Listen("127.0.0.1:0"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return s, func() []*QueryLog {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*QueryLog{}, logs...)
+	}
+}
+
+func writeZoneFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func stubQuery(t *testing.T, address string, rType uint16, name string) *dnslib.Msg {
+	t.Helper()
+	m := new(dnslib.Msg)
+	m.SetQuestion(name, rType)
+	in, _, err := (&dnslib.Client{Timeout: time.Second}).Exchange(m, address)
+	require.NoError(t, err)
+	return in
+}
+
+func TestStubServer(t *testing.T) {
+	dir := t.TempDir()
+	upstream := startServer(t, answerA("203.0.113.9", 0))
+	s, logs := startStubServer(t,
+		WithZoneFiles(
+			writeZoneFile(t, dir, "example.test.zone", testBINDZone),
+			writeZoneFile(t, dir, "corp.yaml", testYAMLZone),
+		),
+		WithForwarder(NewClient(zap.NewNop(), WithServers(upstream), WithTimeouts(time.Second, time.Second))),
+	)
+
+	in := stubQuery(t, s.Addr(), dnslib.TypeA, "www.example.test.")
+	assert.True(t, in.Authoritative)
+	require.Len(t, in.Answer, 1)
+	assert.Equal(t, "192.0.2.1", in.Answer[0].(*dnslib.A).A.String())
+
+	// CNAME records are followed.
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "API.example.test.")
+	require.Len(t, in.Answer, 2)
+	assert.Equal(t, dnslib.TypeCNAME, in.Answer[0].Header().Rrtype)
+	assert.Equal(t, "192.0.2.1", in.Answer[1].(*dnslib.A).A.String())
+
+	// Wildcards.
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "web.apps.example.test.")
+	require.Len(t, in.Answer, 1)
+	assert.Equal(t, "web.apps.example.test.", in.Answer[0].Header().Name)
+
+	// Names of a local zone that do not exist, and empty non-terminals.
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "nope.example.test.")
+	assert.Equal(t, dnslib.RcodeNameError, in.Rcode)
+	require.Len(t, in.Ns, 1)
+	assert.Equal(t, dnslib.TypeSOA, in.Ns[0].Header().Rrtype)
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "b.example.test.")
+	assert.Equal(t, dnslib.RcodeSuccess, in.Rcode)
+	assert.Empty(t, in.Answer)
+
+	// Names of the YAML file without SOA override single names, others are forwarded.
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "intranet.corp.")
+	assert.Len(t, in.Answer, 2)
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "other.corp.")
+	assert.False(t, in.Authoritative)
+	require.Len(t, in.Answer, 1)
+	assert.Equal(t, "203.0.113.9", in.Answer[0].(*dnslib.A).A.String())
+
+	// The targets of local CNAME records outside of the zone files are forwarded.
+	in = stubQuery(t, s.Addr(), dnslib.TypeA, "proxy.example.org.")
+	require.Len(t, in.Answer, 2)
+	assert.Equal(t, "upstream.example.org.", in.Answer[1].Header().Name)
+
+	require.Len(t, logs(), 8)
+	q := logs()[7]
+	assert.Equal(t, "proxy.example.org.", q.Name)
+	assert.Equal(t, "A", q.Type)
+	assert.Equal(t, "NOERROR", q.Rcode)
+	assert.Equal(t, StubSourceForward, q.Source)
+	assert.Equal(t, []string{"CNAME upstream.example.org.", "A 203.0.113.9"}, q.Answer)
+	assert.Equal(t, "udp", q.Protocol)
+}
+
+func TestStubServer_refused(t *testing.T) {
+	s, logs := startStubServer(t, WithZoneFiles(writeZoneFile(t, t.TempDir(), "example.test.zone", testBINDZone)))
+
+	in := stubQuery(t, s.Addr(), dnslib.TypeA, "example.org.")
+	assert.Equal(t, dnslib.RcodeRefused, in.Rcode)
+	require.Len(t, logs(), 1)
+	assert.Equal(t, StubSourceRefused, logs()[0].Source)
+}
+
+func TestStubServer_reload(t *testing.T) {
+	path := writeZoneFile(t, t.TempDir(), "example.test.zone", testBINDZone)
+	s, _ := startStubServer(t, WithZoneFiles(path), WithReloadInterval(10*time.Millisecond))
+
+	updated := strings.Replace(testBINDZone, "192.0.2.1", "192.0.2.100", 1)
+	require.NoError(t, os.WriteFile(path, []byte(updated), 0o600))
+	// Make sure the modification time changes on file systems with a coarse resolution.
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Eventually(t, func() bool {
+		in := stubQuery(t, s.Addr(), dnslib.TypeA, "www.example.test.")
+		return len(in.Answer) == 1 && in.Answer[0].(*dnslib.A).A.String() == "192.0.2.100"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// Invalid files are not loaded, the previous records are still answered.
+	require.NoError(t, os.WriteFile(path, []byte("www IN A not-an-ip\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute)))
+	time.Sleep(50 * time.Millisecond)
+	in := stubQuery(t, s.Addr(), dnslib.TypeA, "www.example.test.")
+	require.Len(t, in.Answer, 1)
+	assert.Equal(t, "192.0.2.100", in.Answer[0].(*dnslib.A).A.String())
+}
@@ -0,0 +1,369 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+const (
+	// SystemAlias is the server alias of the name servers of the system resolver.
+	SystemAlias = "system"
+	// DefaultResolvConf is the configuration file of the system resolver.
+	DefaultResolvConf = "/etc/resolv.conf"
+	// DefaultHostsFile is the hosts file of the system resolver.
+	DefaultHostsFile = "/etc/hosts"
+)
+
+// Limits and defaults of the system resolver, the ones of glibc and musl.
+const (
+	systemMaxServers      = 3
+	systemDefaultNDots    = 1
+	systemMaxNDots        = 15
+	systemDefaultTimeout  = 5 * time.Second
+	systemMaxTimeout      = 30 * time.Second
+	systemDefaultAttempts = 2
+	systemMaxAttempts     = 5
+)
+
+// SystemConfig is the configuration of the system resolver, read from resolv.conf.
+type SystemConfig struct {
+	// Servers are the name servers, at most 3 like glibc and musl, 127.0.0.1 if there is none.
+	Servers []string `json:"servers"`
+	// Search are the domains appended to the names having less than NDots dots.
+	Search []string `json:"search"`
+	NDots  int      `json:"ndots"`
+	// Timeout is the timeout of each query.
+	Timeout Duration `json:"timeout"`
+	// Attempts is the number of times each server is queried.
+	Attempts int `json:"attempts"`
+}
+
+// ParseResolvConf returns the system resolver configuration of r, in the resolv.conf format. Unknown
+// keywords and options are ignored, like the system resolver does. hostname is used to set the search
+// domain if r has no search or domain line, like glibc does.
+func ParseResolvConf(r io.Reader, hostname string) (*SystemConfig, error) {
+	sc := &SystemConfig{
+		NDots:    systemDefaultNDots,
+		Timeout:  Duration(systemDefaultTimeout),
+		Attempts: systemDefaultAttempts,
+	}
+	searchSet := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			addr, err := netip.ParseAddr(fields[1])
+			if err != nil || len(sc.Servers) == systemMaxServers {
+				continue
+			}
+			sc.Servers = append(sc.Servers, addr.String())
+		case "domain":
+			// The last search or domain line wins.
+			sc.Search, searchSet = []string{strings.TrimSuffix(fields[1], ".")}, true
+		case "search":
+			sc.Search, searchSet = nil, true
+			for _, d := range fields[1:] {
+				sc.Search = append(sc.Search, strings.TrimSuffix(d, "."))
+			}
+		case "options":
+			for _, opt := range fields[1:] {
+				sc.setOption(opt)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read resolv.conf: %w", err)
+	}
+	if len(sc.Servers) == 0 {
+		sc.Servers = []string{"127.0.0.1"}
+	}
+	if _, domain, ok := strings.Cut(hostname, "."); ok && !searchSet && domain != "" {
+		sc.Search = []string{strings.TrimSuffix(domain, ".")}
+	}
+	if sc.Search == nil {
+		sc.Search = []string{}
+	}
+	return sc, nil
+}
+
+// LoadSystemConfig returns the system resolver configuration of the resolv.conf file at path.
+func LoadSystemConfig(path string) (*SystemConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open resolv.conf: %w", err)
+	}
+	defer f.Close()
+	hostname, _ := os.Hostname()
+	return ParseResolvConf(f, hostname)
+}
+
+// setOption sets the value of a resolv.conf option, eg: "ndots:5", values out of range being capped.
+func (sc *SystemConfig) setOption(opt string) {
+	name, value, _ := strings.Cut(opt, ":")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return
+	}
+	switch name {
+	case "ndots":
+		sc.NDots = min(n, systemMaxNDots)
+	case "timeout":
+		sc.Timeout = Duration(min(time.Duration(max(n, 1))*time.Second, systemMaxTimeout))
+	case "attempts":
+		sc.Attempts = min(max(n, 1), systemMaxAttempts)
+	}
+}
+
+// Candidates returns the names queried for name, in order, like glibc does: name alone if it ends with
+// a dot, name then name with each search domain if it has at least NDots dots, name with each search
+// domain then name otherwise.
+func (sc *SystemConfig) Candidates(name string) []string {
+	return searchCandidates(name, sc.Search, sc.NDots)
+}
+
+func searchCandidates(name string, search []string, ndots int) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	candidates := make([]string, 0, len(search)+1)
+	for _, d := range search {
+		candidates = append(candidates, dnslib.Fqdn(name+"."+d))
+	}
+	if strings.Count(name, ".") >= ndots {
+		return append([]string{dnslib.Fqdn(name)}, candidates...)
+	}
+	return append(candidates, dnslib.Fqdn(name))
+}
+
+// Hosts are the addresses of the names of a hosts file.
+type Hosts struct {
+	// Path is the path of the hosts file.
+	Path  string
+	addrs map[string][]netip.Addr
+}
+
+// ParseHosts returns the addresses of the names of r, in the hosts file format, path being shown as
+// the server answering from it. Invalid lines are ignored, like the system resolver does.
+func ParseHosts(r io.Reader, path string) (*Hosts, error) {
+	h := &Hosts{Path: path, addrs: make(map[string][]netip.Addr)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(dnslib.Fqdn(name))
+			h.addrs[name] = append(h.addrs[name], addr.Unmap())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read hosts file %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// LoadHosts returns the addresses of the names of the hosts file at path.
+func LoadHosts(path string) (*Hosts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open hosts file: %w", err)
+	}
+	defer f.Close()
+	return ParseHosts(f, path)
+}
+
+// Lookup returns the addresses of name of the record type, A or AAAA, nil if it has none.
+func (h *Hosts) Lookup(rType Type, name string) []netip.Addr {
+	var addrs []netip.Addr
+	for _, addr := range h.addrs[strings.ToLower(dnslib.Fqdn(name))] {
+		if (rType == TypeA && addr.Is4()) || (rType == TypeAAAA && addr.Is6()) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// result returns the addresses of name of the record type as the response of a server, nil if it has none.
+func (h *Hosts) result(rType Type, name string) *Result {
+	addrs := h.Lookup(rType, name)
+	if len(addrs) == 0 {
+		return nil
+	}
+	msg := new(dnslib.Msg)
+	msg.SetQuestion(dnslib.Fqdn(name), uint16(rType))
+	// The id and the flags of a query are not relevant to an answer read from a file.
+	msg.Id, msg.RecursionDesired = 0, false
+	msg.Response, msg.Authoritative = true, true
+	for _, addr := range addrs {
+		hdr := dnslib.RR_Header{Name: msg.Question[0].Name, Rrtype: uint16(rType), Class: dnslib.ClassINET}
+		if addr.Is4() {
+			msg.Answer = append(msg.Answer, &dnslib.A{Hdr: hdr, A: addr.AsSlice()})
+		} else {
+			msg.Answer = append(msg.Answer, &dnslib.AAAA{Hdr: hdr, AAAA: addr.AsSlice()})
+		}
+	}
+	return newResult([]*ServerResult{{Server: h.Path, Response: newResponse(h.Path, TransportHosts, 0, msg)}})
+}
+
+// WithSearch makes Client.Resolve and Client.QueryBatch expand names with the search domains, names
+// having less than ndots dots being queried with the search domains first, like the system resolver.
+func WithSearch(search []string, ndots int) Option {
+	return func(c *Client) {
+		c.search, c.ndots = search, ndots
+		if c.search == nil {
+			c.search = []string{}
+		}
+	}
+}
+
+// WithHosts makes Client.Resolve and Client.QueryBatch answer the A and AAAA queries of the names of
+// hosts from it, without querying the servers, like the system resolver.
+func WithHosts(hosts *Hosts) Option {
+	return func(c *Client) {
+		c.hosts = hosts
+	}
+}
+
+// WithAttempts sets the number of times a server is queried if it does not answer, 1 by default.
+func WithAttempts(attempts int) Option {
+	return func(c *Client) {
+		c.attempts = attempts
+	}
+}
+
+// resolves returns true if Client.Resolve differs from Client.Query, a search list or a hosts file
+// being set.
+func (c *Client) resolves() bool {
+	return c.search != nil || c.hosts != nil
+}
+
+// Search is the resolution of a name like the system resolver does.
+type Search struct {
+	Name string `json:"name"`
+	// Hosts is the hosts file the name was found in, if any.
+	Hosts string `json:"hosts,omitempty"`
+	// Candidates are the names queried, in order.
+	Candidates []*SearchCandidate `json:"candidates"`
+	// Matched is the name that has records, empty if none has.
+	Matched string `json:"matched,omitempty"`
+}
+
+// SearchCandidate is a name queried by the resolution of a name.
+type SearchCandidate struct {
+	Name string `json:"name"`
+	// Rcode is the response code given by most servers.
+	Rcode   string `json:"rcode,omitempty"`
+	Answers int    `json:"answers"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Resolve queries name like the system resolver does: the records of the hosts file set by WithHosts
+// are returned if it has the name, else the candidates of the search list set by WithSearch are
+// queried in order until one has records. Like glibc, the next candidate is queried if a response is
+// NXDOMAIN, SERVFAIL or has no record, and the resolution stops at the other errors. The result is the
+// one of the candidate that has records, or of the last one queried.
+func (c *Client) Resolve(ctx context.Context, rType Type, name string) (*Result, *Search, error) {
+	s := &Search{Name: name, Candidates: []*SearchCandidate{}}
+	if c.hosts != nil {
+		if res := c.hosts.result(rType, name); res != nil {
+			s.Hosts, s.Matched = c.hosts.Path, dnslib.Fqdn(name)
+			return res, s, nil
+		}
+	}
+	var (
+		res *Result
+		err error
+	)
+	for _, candidate := range searchCandidates(name, c.search, c.ndots) {
+		sc := &SearchCandidate{Name: candidate}
+		s.Candidates = append(s.Candidates, sc)
+		res, err = c.Query(ctx, rType, candidate)
+		if err != nil {
+			sc.Error = err.Error()
+			return res, s, err
+		}
+		sr := res.majority()
+		sc.Rcode, sc.Answers = sr.Response.Rcode, len(sr.Response.Answer)
+		if sc.Answers > 0 {
+			s.Matched = candidate
+			return res, s, nil
+		}
+		switch sr.Response.msg.Rcode {
+		case dnslib.RcodeSuccess, dnslib.RcodeNameError, dnslib.RcodeServerFailure:
+		default:
+			return res, s, nil
+		}
+	}
+	return res, s, err
+}
+
+// majority returns the first response given by most servers, nil if no server answered.
+func (r *Result) majority() *ServerResult {
+	for _, sr := range r.Servers {
+		if sr.Response != nil && !sr.Differs {
+			return sr
+		}
+	}
+	return nil
+}
+
+// RenderText writes the candidates queried, their response code and whether they have records, then
+// the name that has records.
+func (s *Search) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	if s.Hosts != "" {
+		faint.Fprintf(buf, ";; HOSTS: %s found in %s", s.Name, s.Hosts)
+		buf.WriteString("\n")
+	}
+	for _, sc := range s.Candidates {
+		faint.Fprintf(buf, ";; SEARCH: %s  ", sc.Name)
+		switch {
+		case sc.Error != "":
+			color.New(color.FgRed).Fprint(buf, sc.Error)
+		case sc.Answers > 0:
+			records := "records"
+			if sc.Answers == 1 {
+				records = "record"
+			}
+			color.New(color.FgGreen).Fprintf(buf, "%s, %d %s", sc.Rcode, sc.Answers, records)
+		default:
+			faint.Fprintf(buf, "%s, no record", sc.Rcode)
+		}
+		buf.WriteString("\n")
+	}
+	if s.Matched == "" {
+		color.New(color.FgYellow).Fprintf(buf, ";; %s did not resolve", s.Name)
+	} else {
+		faint.Fprint(buf, ";; MATCHED: ")
+		color.New(color.Bold).Fprint(buf, s.Matched)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
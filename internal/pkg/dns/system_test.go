@@ -0,0 +1,133 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	sc, err := ParseResolvConf(strings.NewReader(`# generated
+domain example.com
+search default.svc.cluster.local svc.cluster.local. cluster.local
+nameserver 10.96.0.10
+nameserver fe80::1%eth0
+nameserver not-an-ip
+nameserver 10.0.0.2
+nameserver 10.0.0.3
+options ndots:5 timeout:60 attempts:3 rotate ; comment
+`), "pod.example.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.96.0.10", "fe80::1%eth0", "10.0.0.2"}, sc.Servers)
+	assert.Equal(t, []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"}, sc.Search)
+	assert.Equal(t, 5, sc.NDots)
+	assert.Equal(t, Duration(30*time.Second), sc.Timeout)
+	assert.Equal(t, 3, sc.Attempts)
+
+	sc, err = ParseResolvConf(strings.NewReader(""), "host.corp.example")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1"}, sc.Servers)
+	assert.Equal(t, []string{"corp.example"}, sc.Search)
+	assert.Equal(t, 1, sc.NDots)
+	assert.Equal(t, Duration(5*time.Second), sc.Timeout)
+	assert.Equal(t, 2, sc.Attempts)
+}
+
+func TestSystemConfig_Candidates(t *testing.T) {
+	sc := &SystemConfig{Search: []string{"ns.svc.cluster.local", "cluster.local"}, NDots: 5}
+	assert.Equal(t, []string{"api.ns.svc.cluster.local.", "api.cluster.local.", "api."}, sc.Candidates("api"))
+	assert.Equal(t, []string{"example.com."}, sc.Candidates("example.com."))
+
+	sc.NDots = 1
+	assert.Equal(t, []string{"example.com.", "example.com.ns.svc.cluster.local.", "example.com.cluster.local."}, sc.Candidates("example.com"))
+}
+
+func TestParseHosts(t *testing.T) {
+	h, err := ParseHosts(strings.NewReader(`127.0.0.1 localhost
+::1 localhost ip6-localhost # loopback
+10.0.0.5 Build.Internal build
+invalid line
+`), "/etc/hosts")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.5")}, h.Lookup(TypeA, "build.internal."))
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("::1")}, h.Lookup(TypeAAAA, "localhost"))
+	assert.Empty(t, h.Lookup(TypeAAAA, "build"))
+	assert.Empty(t, h.Lookup(TypeMX, "localhost"))
+}
+
+func TestClient_Resolve(t *testing.T) {
+	server := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case "api.svc.cluster.local.":
+			if r.Question[0].Qtype != dnslib.TypeA {
+				break
+			}
+			rr, _ := dnslib.NewRR("api.svc.cluster.local. 30 IN A 10.96.0.20")
+			m.Answer = append(m.Answer, rr)
+		case "api.ns.svc.cluster.local.":
+			// No record, the next candidate is queried.
+		case "broken.ns.svc.cluster.local.":
+			m.Rcode = dnslib.RcodeRefused
+		default:
+			m.Rcode = dnslib.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+	hosts, err := ParseHosts(strings.NewReader("10.0.0.5 api\n"), "/etc/hosts")
+	require.NoError(t, err)
+	client := NewClient(
+		zap.NewNop(),
+		WithServers(server),
+		WithTimeouts(time.Second, time.Second),
+		WithSearch([]string{"ns.svc.cluster.local", "svc.cluster.local", "cluster.local"}, 5),
+		WithAttempts(2),
+	)
+
+	res, s, err := client.Resolve(context.Background(), TypeA, "api")
+	require.NoError(t, err)
+	assert.Equal(t, "api.svc.cluster.local.", s.Matched)
+	require.Len(t, s.Candidates, 2)
+	assert.Equal(t, &SearchCandidate{Name: "api.ns.svc.cluster.local.", Rcode: "NOERROR"}, s.Candidates[0])
+	assert.Equal(t, "10.96.0.20", res.Servers[0].Response.Answer[0].Data)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, s.RenderText(buf))
+	assert.Equal(t, `;; SEARCH: api.ns.svc.cluster.local.  NOERROR, no record
+;; SEARCH: api.svc.cluster.local.  NOERROR, 1 record
+;; MATCHED: api.svc.cluster.local.
+`, buf.String())
+
+	_, s, err = client.Resolve(context.Background(), TypeA, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, s.Matched)
+	assert.Len(t, s.Candidates, 4)
+	assert.Equal(t, "missing.", s.Candidates[3].Name)
+
+	_, s, err = client.Resolve(context.Background(), TypeA, "broken")
+	require.NoError(t, err)
+	assert.Len(t, s.Candidates, 1)
+
+	WithHosts(hosts)(client)
+	res, s, err = client.Resolve(context.Background(), TypeA, "api")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/hosts", s.Hosts)
+	assert.Empty(t, s.Candidates)
+	assert.Equal(t, TransportHosts, res.Servers[0].Response.Transport)
+	assert.Equal(t, "10.0.0.5", res.Servers[0].Response.Answer[0].Data)
+
+	batch := client.QueryBatch(context.Background(), []string{"api"}, []Type{TypeA, TypeAAAA}, 2)
+	require.Len(t, batch.Queries, 2)
+	assert.Equal(t, "/etc/hosts", batch.Queries[0].Search.Hosts)
+	assert.Equal(t, "api", batch.Queries[1].Name)
+	assert.Len(t, batch.Queries[1].Search.Candidates, 4)
+}
@@ -0,0 +1,92 @@
+package dns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TLSInfo holds the details of the TLS connection to an encrypted DNS server.
+type TLSInfo struct {
+	Version      string        `json:"version"`
+	CipherSuite  string        `json:"cipher_suite"`
+	ALPN         string        `json:"alpn,omitempty"`
+	ServerName   string        `json:"server_name"`
+	Handshake    Duration      `json:"handshake"`
+	Verified     bool          `json:"verified"`
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Certificate is a certificate presented by a server.
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+}
+
+func newTLSInfo(state tls.ConnectionState, handshake time.Duration) *TLSInfo {
+	info := &TLSInfo{
+		Version:      tlsVersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		ServerName:   state.ServerName,
+		Handshake:    Duration(handshake),
+		Verified:     len(state.VerifiedChains) > 0,
+		Certificates: make([]Certificate, 0, len(state.PeerCertificates)),
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, newCertificate(cert))
+	}
+	return info
+}
+
+func newCertificate(cert *x509.Certificate) Certificate {
+	return Certificate{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		DNSNames:  cert.DNSNames,
+	}
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
+
+// String returns a summary of the connection, eg: "TLS 1.3, TLS_AES_128_GCM_SHA256, ALPN doq, handshake 12ms".
+func (i *TLSInfo) String() string {
+	parts := []string{i.Version, i.CipherSuite}
+	if i.ALPN != "" {
+		parts = append(parts, "ALPN "+i.ALPN)
+	}
+	parts = append(parts, "handshake "+time.Duration(i.Handshake).Round(time.Microsecond).String())
+	if !i.Verified {
+		parts = append(parts, "certificate not verified")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// certificateLines returns a line per certificate: its subject, issuer and expiration.
+func (i *TLSInfo) certificateLines() []string {
+	lines := make([]string, 0, len(i.Certificates))
+	for _, cert := range i.Certificates {
+		lines = append(lines, fmt.Sprintf("%s (issuer %s, expires %s)", cert.Subject, cert.Issuer, cert.NotAfter.Format(time.DateOnly)))
+	}
+	return lines
+}
@@ -0,0 +1,525 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+const (
+	// traceMaxSteps bounds the number of delegations followed by a trace.
+	traceMaxSteps = 16
+	// traceQueryTimeout is the timeout of each trace query if the client has no read timeout, so that
+	// an unreachable server does not use the whole timeout of the trace.
+	traceQueryTimeout = 2 * time.Second
+)
+
+// rootServers are the root hints, as published on https://www.iana.org/domains/root/servers.
+var rootServers = []struct {
+	name    string
+	address string
+}{
+	{"a.root-servers.net.", "198.41.0.4"},
+	{"b.root-servers.net.", "170.247.170.2"},
+	{"c.root-servers.net.", "192.33.4.12"},
+	{"d.root-servers.net.", "199.7.91.13"},
+	{"e.root-servers.net.", "192.203.230.10"},
+	{"f.root-servers.net.", "192.5.5.241"},
+	{"g.root-servers.net.", "192.112.36.4"},
+	{"h.root-servers.net.", "198.97.190.53"},
+	{"i.root-servers.net.", "192.36.148.17"},
+	{"j.root-servers.net.", "192.58.128.30"},
+	{"k.root-servers.net.", "193.0.14.129"},
+	{"l.root-servers.net.", "199.7.83.42"},
+	{"m.root-servers.net.", "202.12.27.33"},
+}
+
+// Statuses of the servers queried by a trace.
+const (
+	TraceStatusAnswer   = "answer"
+	TraceStatusReferral = "referral"
+	TraceStatusLame     = "lame"
+	TraceStatusError    = "error"
+)
+
+// Trace is the result of an iterative resolution, from the root servers to the authoritative servers.
+type Trace struct {
+	Name  string       `json:"name"`
+	Type  string       `json:"type"`
+	Steps []*TraceStep `json:"steps"`
+	// Rcode and Answer are the final authoritative response, if any.
+	Rcode  string   `json:"rcode,omitempty"`
+	Answer []Record `json:"answer"`
+	// AnsweredBy is the server the answer was taken from.
+	AnsweredBy string `json:"answered_by,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	answer []dnslib.RR
+}
+
+// TraceStep is a zone of the delegation chain, with the servers queried for it.
+type TraceStep struct {
+	Zone string `json:"zone"`
+	// NS are the name servers of the zone, as given by the parent zone.
+	NS      []string       `json:"ns"`
+	Servers []*TraceServer `json:"servers"`
+	// ChildNS are the name servers of the zone, as given by the zone servers.
+	ChildNS  []string       `json:"child_ns,omitempty"`
+	Referral *TraceReferral `json:"referral,omitempty"`
+	Issues   []string       `json:"issues,omitempty"`
+}
+
+// TraceServer is a server queried by a trace.
+type TraceServer struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Glue is true if the address was given as glue by the parent zone.
+	Glue   bool     `json:"glue"`
+	Status string   `json:"status"`
+	Rcode  string   `json:"rcode,omitempty"`
+	RTT    Duration `json:"rtt"`
+	// Detail is the referred zone, or why the server is lame or failed.
+	Detail string `json:"detail,omitempty"`
+
+	msg *dnslib.Msg
+}
+
+// TraceReferral is the delegation of a zone to a child zone.
+type TraceReferral struct {
+	Zone string   `json:"zone"`
+	NS   []string `json:"ns"`
+	Glue []Record `json:"glue"`
+
+	glue map[string][]string
+}
+
+// WithRootServers sets the servers a trace starts from, default is the root servers.
+func WithRootServers(servers ...string) Option {
+	return func(c *Client) {
+		c.rootServers = servers
+	}
+}
+
+// withTracePort sets the port of the servers queried by traces, default is 53. It lets tests run
+// the servers of a delegation chain on a random port.
+func withTracePort(port string) Option {
+	return func(c *Client) {
+		c.tracePort = port
+	}
+}
+
+// Trace resolves name iteratively from the root servers, querying every server of each zone of the
+// delegation chain. Name servers without glue are resolved with the servers of the client.
+// The error is returned if the resolution could not reach an authoritative answer, with the trace so far.
+func (c *Client) Trace(ctx context.Context, rType Type, name string) (*Trace, error) {
+	name = dnslib.Fqdn(name)
+	t := &Trace{Name: name, Type: GetTypeName(rType), Answer: []Record{}}
+	step := &TraceStep{Zone: "."}
+	if len(c.rootServers) > 0 {
+		for _, s := range c.rootServers {
+			step.Servers = append(step.Servers, &TraceServer{Name: s, Address: serverAddress(s, c.tracePort)})
+		}
+	} else {
+		for _, s := range rootServers {
+			step.NS = append(step.NS, s.name)
+			step.Servers = append(step.Servers, &TraceServer{Name: s.name, Address: serverAddress(s.address, c.tracePort), Glue: true})
+		}
+	}
+	for i := 0; i < traceMaxSteps; i++ {
+		t.Steps = append(t.Steps, step)
+		c.traceQueryStep(ctx, step, rType, name)
+		if ctx.Err() != nil {
+			return t.fail(ctx.Err())
+		}
+		if answer := step.answer(); answer != nil {
+			t.Rcode = answer.Rcode
+			t.AnsweredBy = answer.Name + " " + answer.Address
+			t.answer = answer.msg.Answer
+			t.Answer = newRecords(answer.msg.Answer)
+			return t, nil
+		}
+		step.Referral = step.referral()
+		if step.Referral == nil {
+			return t.fail(fmt.Errorf("no server of zone %s gave an answer or a referral", step.Zone))
+		}
+		next, err := c.traceNextStep(ctx, step.Referral)
+		if err != nil {
+			return t.fail(err)
+		}
+		step = next
+	}
+	return t.fail(fmt.Errorf("more than %d delegations", traceMaxSteps))
+}
+
+func (t *Trace) fail(err error) (*Trace, error) {
+	t.Error = err.Error()
+	return t, fmt.Errorf("trace failed: %w", err)
+}
+
+// serverAddress returns the address of a server given as an IP address, with port if it has none.
+func serverAddress(s, port string) string {
+	if _, _, err := net.SplitHostPort(s); err == nil {
+		return s
+	}
+	return net.JoinHostPort(strings.Trim(s, "[]"), port)
+}
+
+// traceQueryStep queries every server of step concurrently, then checks the step for issues.
+func (c *Client) traceQueryStep(ctx context.Context, step *TraceStep, rType Type, name string) {
+	wg := sync.WaitGroup{}
+	for _, s := range step.Servers {
+		wg.Add(1)
+		go func(s *TraceServer) {
+			defer wg.Done()
+			c.traceQueryServer(ctx, step.Zone, s, rType, name)
+		}(s)
+	}
+	wg.Wait()
+
+	for _, s := range step.Servers {
+		switch s.Status {
+		case TraceStatusLame:
+			step.Issues = append(step.Issues, fmt.Sprintf("lame delegation: %s (%s) %s", s.Name, s.Address, s.Detail))
+		case TraceStatusError:
+			step.Issues = append(step.Issues, fmt.Sprintf("%s (%s) did not answer: %s", s.Name, s.Address, s.Detail))
+		}
+	}
+	if step.Zone == "." || len(step.NS) == 0 {
+		return
+	}
+	// Compares the NS set given by the parent zone with the one of the zone itself.
+	for _, s := range step.Servers {
+		if s.Status != TraceStatusAnswer && s.Status != TraceStatusReferral {
+			continue
+		}
+		m, _, err := c.traceExchange(ctx, s.Address, Type(dnslib.TypeNS), step.Zone)
+		if err != nil || !m.Authoritative {
+			continue
+		}
+		for _, rr := range m.Answer {
+			if ns, ok := rr.(*dnslib.NS); ok && strings.EqualFold(ns.Hdr.Name, step.Zone) {
+				step.ChildNS = append(step.ChildNS, strings.ToLower(ns.Ns))
+			}
+		}
+		sort.Strings(step.ChildNS)
+		break
+	}
+	if len(step.ChildNS) > 0 {
+		if parentOnly, childOnly := diffSets(step.NS, step.ChildNS); len(parentOnly)+len(childOnly) > 0 {
+			step.Issues = append(step.Issues, fmt.Sprintf(
+				"parent and child NS sets differ, parent only: [%s], child only: [%s]",
+				strings.Join(parentOnly, " "), strings.Join(childOnly, " "),
+			))
+		}
+	}
+}
+
+// diffSets returns the elements only in a and the ones only in b.
+func diffSets(a, b []string) ([]string, []string) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+	}
+	var onlyA, onlyB []string
+	for _, v := range a {
+		if !inB[v] {
+			onlyA = append(onlyA, v)
+		}
+	}
+	for _, v := range b {
+		if !inA[v] {
+			onlyB = append(onlyB, v)
+		}
+	}
+	return onlyA, onlyB
+}
+
+// traceExchange sends a non recursive query to address, over TCP if the UDP response is truncated.
+func (c *Client) traceExchange(ctx context.Context, address string, rType Type, name string) (*dnslib.Msg, time.Duration, error) {
+	timeout := c.readTimeout
+	if timeout == 0 {
+		timeout = traceQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	m := new(dnslib.Msg)
+	m.SetQuestion(name, uint16(rType))
+	m.RecursionDesired = false
+	m.SetEdns0(dnslib.DefaultMsgSize, false)
+	in, rtt, _, err := c.exchange(ctx, &server{transport: TransportUDP, address: address}, m)
+	if err == nil && in.Truncated {
+		in, rtt, _, err = c.exchange(ctx, &server{transport: TransportTCP, address: address}, m)
+	}
+	return in, rtt, err
+}
+
+// traceQueryServer queries s, a server of zone, and sets its status.
+func (c *Client) traceQueryServer(ctx context.Context, zone string, s *TraceServer, rType Type, name string) {
+	m, rtt, err := c.traceExchange(ctx, s.Address, rType, name)
+	if err != nil {
+		s.Status, s.Detail = TraceStatusError, err.Error()
+		return
+	}
+	s.msg, s.RTT, s.Rcode = m, Duration(rtt), dnslib.RcodeToString[m.Rcode]
+	c.logger.With(
+		zap.String("zone", zone),
+		zap.String("server", s.Address),
+		zap.String("rcode", s.Rcode),
+		zap.Duration("rtt", rtt),
+	).Debug("trace query")
+	switch {
+	case m.Rcode != dnslib.RcodeSuccess && m.Rcode != dnslib.RcodeNameError:
+		s.Status, s.Detail = TraceStatusLame, "answered "+s.Rcode
+	case m.Authoritative:
+		s.Status = TraceStatusAnswer
+	default:
+		if child := referredZone(m, zone, name); child != "" {
+			s.Status, s.Detail = TraceStatusReferral, child
+			return
+		}
+		s.Status, s.Detail = TraceStatusLame, "is not authoritative for "+zone
+	}
+}
+
+// referredZone returns the zone m delegates name to, if it is a child of zone.
+func referredZone(m *dnslib.Msg, zone, name string) string {
+	for _, rr := range m.Ns {
+		ns, ok := rr.(*dnslib.NS)
+		if !ok {
+			continue
+		}
+		owner := strings.ToLower(ns.Hdr.Name)
+		if dnslib.IsSubDomain(zone, owner) && dnslib.CountLabel(owner) > dnslib.CountLabel(zone) && dnslib.IsSubDomain(owner, strings.ToLower(name)) {
+			return owner
+		}
+	}
+	return ""
+}
+
+// answer returns the first server of the step that gave an authoritative answer.
+func (s *TraceStep) answer() *TraceServer {
+	for _, srv := range s.Servers {
+		if srv.Status == TraceStatusAnswer {
+			return srv
+		}
+	}
+	return nil
+}
+
+// referral returns the delegation given by most servers of the step, with the A and AAAA glue of every one.
+func (s *TraceStep) referral() *TraceReferral {
+	counts := make(map[string]int)
+	var best string
+	for _, srv := range s.Servers {
+		if srv.Status == TraceStatusReferral {
+			counts[srv.Detail]++
+			if counts[srv.Detail] > counts[best] {
+				best = srv.Detail
+			}
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	ref := &TraceReferral{Zone: best, Glue: []Record{}, glue: make(map[string][]string)}
+	seenNS := make(map[string]bool)
+	seenGlue := make(map[string]bool)
+	for _, srv := range s.Servers {
+		if srv.Status != TraceStatusReferral || srv.Detail != best {
+			continue
+		}
+		for _, rr := range srv.msg.Ns {
+			if ns, ok := rr.(*dnslib.NS); ok && strings.EqualFold(ns.Hdr.Name, best) && !seenNS[strings.ToLower(ns.Ns)] {
+				seenNS[strings.ToLower(ns.Ns)] = true
+				ref.NS = append(ref.NS, strings.ToLower(ns.Ns))
+			}
+		}
+		for _, rr := range srv.msg.Extra {
+			var address string
+			switch a := rr.(type) {
+			case *dnslib.A:
+				address = a.A.String()
+			case *dnslib.AAAA:
+				address = a.AAAA.String()
+			}
+			if address == "" || seenGlue[rr.String()] {
+				continue
+			}
+			owner := strings.ToLower(rr.Header().Name)
+			seenGlue[rr.String()] = true
+			ref.Glue = append(ref.Glue, newRecord(rr))
+			ref.glue[owner] = append(ref.glue[owner], address)
+		}
+	}
+	sort.Strings(ref.NS)
+	return ref
+}
+
+// traceNextStep returns the step of the zone referred to, with its servers: the IPv4 and IPv6 glue addresses
+// of its name servers, or their IPv4 addresses resolved with the servers of the client if they have no glue.
+func (c *Client) traceNextStep(ctx context.Context, ref *TraceReferral) (*TraceStep, error) {
+	step := &TraceStep{Zone: ref.Zone, NS: ref.NS}
+	var errs []error
+	for _, ns := range ref.NS {
+		addresses, glue := ref.glue[ns], true
+		if len(addresses) == 0 {
+			glue = false
+			var err error
+			addresses, err = c.resolveAddresses(ctx, ns)
+			if err != nil {
+				errs = append(errs, err)
+				step.Issues = append(step.Issues, fmt.Sprintf("cannot resolve %s: %s", ns, err))
+				continue
+			}
+		}
+		for _, addr := range addresses {
+			step.Servers = append(step.Servers, &TraceServer{Name: ns, Address: serverAddress(addr, c.tracePort), Glue: glue})
+		}
+	}
+	if len(step.Servers) == 0 {
+		return nil, fmt.Errorf("cannot resolve the name servers of %s: %w", ref.Zone, errors.Join(errs...))
+	}
+	return step, nil
+}
+
+// resolveAddresses returns the IPv4 addresses of name, resolved with the servers of the client.
+func (c *Client) resolveAddresses(ctx context.Context, name string) ([]string, error) {
+	res, err := c.lookup(ctx, TypeA, name)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, rr := range res.msg.Answer {
+		if a, ok := rr.(*dnslib.A); ok {
+			addresses = append(addresses, a.A.String())
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no address found for %s", name)
+	}
+	return addresses, nil
+}
+
+// Issues returns the issues found at every step.
+func (t *Trace) Issues() []string {
+	var issues []string
+	for _, s := range t.Steps {
+		for _, i := range s.Issues {
+			issues = append(issues, s.Zone+": "+i)
+		}
+	}
+	return issues
+}
+
+// RenderText writes each step of the trace: the servers queried with their status and RTT, the
+// referral to the next zone with its glue, and the issues found, then the answer.
+func (t *Trace) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	faint.Fprintf(buf, ";; Tracing %s %s from the root servers", t.Name, t.Type)
+	buf.WriteString("\n")
+	for _, step := range t.Steps {
+		buf.WriteString("\n")
+		color.New(color.Bold).Fprint(buf, step.Zone)
+		if len(step.NS) > 0 && step.Zone != "." {
+			faint.Fprintf(buf, "  NS %s", strings.Join(step.NS, " "))
+		}
+		buf.WriteString("\n")
+		nameWidth, addrWidth := 0, 0
+		for _, s := range step.Servers {
+			nameWidth, addrWidth = max(nameWidth, len(s.Name)), max(addrWidth, len(s.Address))
+		}
+		for _, s := range step.Servers {
+			fmt.Fprintf(buf, "  %-*s  %-*s  ", nameWidth, s.Name, addrWidth, s.Address)
+			glue := "     "
+			if s.Glue {
+				glue = "glue "
+			}
+			faint.Fprint(buf, glue)
+			traceStatusColor(s.Status).Fprintf(buf, " %-8s", s.Status)
+			if s.Status != TraceStatusError {
+				faint.Fprintf(buf, "  %9s", time.Duration(s.RTT).Round(time.Microsecond))
+			}
+			if s.Detail != "" {
+				fmt.Fprintf(buf, "  %s", s.Detail)
+			}
+			buf.WriteString("\n")
+		}
+		if len(step.ChildNS) > 0 {
+			faint.Fprintf(buf, "  child NS %s", strings.Join(step.ChildNS, " "))
+			buf.WriteString("\n")
+		}
+		if step.Referral != nil {
+			fmt.Fprintf(buf, "  referral to %s, %d NS, %d glue records\n", step.Referral.Zone, len(step.Referral.NS), len(step.Referral.Glue))
+		}
+		for _, i := range step.Issues {
+			color.New(color.FgYellow).Fprintf(buf, "  ! %s", i)
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("\n")
+	if t.Error != "" {
+		color.New(color.FgRed).Fprint(buf, ";; "+t.Error)
+		buf.WriteString("\n")
+	} else {
+		faint.Fprintf(buf, ";; %s from %s", t.Rcode, t.AnsweredBy)
+		buf.WriteString("\n")
+		writeRecords(buf, "", t.answer)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func traceStatusColor(status string) *color.Color {
+	switch status {
+	case TraceStatusAnswer:
+		return color.New(color.FgGreen)
+	case TraceStatusReferral:
+		return color.New(color.FgBlue)
+	case TraceStatusLame:
+		return color.New(color.FgYellow)
+	default:
+		return color.New(color.FgRed)
+	}
+}
+
+// Header returns the columns of the trace table.
+func (t *Trace) Header() []string {
+	return []string{"zone", "server", "address", "glue", "status", "rcode", "rtt", "detail"}
+}
+
+// Rows returns the servers queried by the trace, one per row.
+func (t *Trace) Rows() [][]string {
+	var rows [][]string
+	for _, step := range t.Steps {
+		for _, s := range step.Servers {
+			rows = append(rows, []string{
+				step.Zone,
+				s.Name,
+				s.Address,
+				strconv.FormatBool(s.Glue),
+				s.Status,
+				s.Rcode,
+				time.Duration(s.RTT).Round(time.Microsecond).String(),
+				s.Detail,
+			})
+		}
+	}
+	return rows
+}
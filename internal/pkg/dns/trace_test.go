@@ -0,0 +1,168 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// zoneHandler returns a handler answering with the records of rrs matching the question name and type,
+// authoritatively, or referring to the zone of the NS records of ns, with glue.
+func zoneHandler(answers map[string][]string, ns []string, glue []string) dnslib.HandlerFunc {
+	return func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		if rrs, ok := answers[q.Name+" "+dnslib.TypeToString[q.Qtype]]; ok {
+			m.Authoritative = true
+			for _, s := range rrs {
+				rr, _ := dnslib.NewRR(s)
+				m.Answer = append(m.Answer, rr)
+			}
+			_ = w.WriteMsg(m)
+			return
+		}
+		for _, s := range ns {
+			rr, _ := dnslib.NewRR(s)
+			m.Ns = append(m.Ns, rr)
+		}
+		for _, s := range glue {
+			rr, _ := dnslib.NewRR(s)
+			m.Extra = append(m.Extra, rr)
+		}
+		_ = w.WriteMsg(m)
+	}
+}
+
+func TestClient_Trace(t *testing.T) {
+	// The root server listens on a random port, the delegated servers on the same port of other loopback addresses.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	require.NoError(t, pc.Close())
+
+	example := zoneHandler(map[string][]string{
+		"www.example.com. A": {"www.example.com. 300 IN A 192.0.2.1"},
+		"example.com. NS":    {"example.com. 300 IN NS ns1.example.com.", "example.com. 300 IN NS ns3.example.com."},
+	}, nil, nil)
+	for address, handler := range map[string]dnslib.HandlerFunc{
+		"127.0.0.1": zoneHandler(nil,
+			[]string{"com. 172800 IN NS ns1.com.", "com. 172800 IN NS ns2.com."},
+			[]string{"ns1.com. 172800 IN A 127.0.0.2", "ns2.com. 172800 IN A 127.0.0.3"},
+		),
+		"127.0.0.2": zoneHandler(
+			map[string][]string{"com. NS": {"com. 172800 IN NS ns1.com.", "com. 172800 IN NS ns2.com."}},
+			[]string{"example.com. 172800 IN NS ns1.example.com.", "example.com. 172800 IN NS ns2.example.net."},
+			[]string{"ns1.example.com. 172800 IN A 127.0.0.4"},
+		),
+		// ns2.com. refuses queries: a lame delegation.
+		"127.0.0.3": func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+			m := new(dnslib.Msg)
+			m.SetRcode(r, dnslib.RcodeRefused)
+			_ = w.WriteMsg(m)
+		},
+		"127.0.0.4": example,
+		"127.0.0.5": example,
+	} {
+		serveDNS(t, "udp", address+":"+port, &dnslib.Server{Handler: handler})
+	}
+	// ns2.example.net. has no glue, it is resolved with the servers of the client.
+	resolver := startServer(t, answerA("127.0.0.5", 0))
+
+	client := NewClient(
+		zap.NewNop(),
+		WithServers(resolver),
+		WithRootServers("127.0.0.1:"+port),
+		WithTimeouts(time.Second, time.Second),
+		withTracePort(port),
+	)
+	trace, err := client.Trace(context.Background(), TypeA, "www.example.com")
+	require.NoError(t, err)
+
+	require.Len(t, trace.Steps, 3)
+	assert.Equal(t, []string{".", "com.", "example.com."}, []string{trace.Steps[0].Zone, trace.Steps[1].Zone, trace.Steps[2].Zone})
+	assert.Equal(t, TraceStatusReferral, trace.Steps[0].Servers[0].Status)
+	require.NotNil(t, trace.Steps[0].Referral)
+	assert.Equal(t, []string{"ns1.com.", "ns2.com."}, trace.Steps[0].Referral.NS)
+	assert.Len(t, trace.Steps[0].Referral.Glue, 2)
+
+	com := trace.Steps[1]
+	require.Len(t, com.Servers, 2)
+	assert.True(t, com.Servers[0].Glue)
+	assert.Equal(t, TraceStatusReferral, com.Servers[0].Status)
+	assert.Equal(t, TraceStatusLame, com.Servers[1].Status)
+	assert.Equal(t, []string{"ns1.com.", "ns2.com."}, com.ChildNS)
+	require.Len(t, com.Issues, 1)
+	assert.Contains(t, com.Issues[0], "lame delegation: ns2.com.")
+
+	example2 := trace.Steps[2]
+	require.Len(t, example2.Servers, 2)
+	assert.True(t, example2.Servers[0].Glue)
+	assert.False(t, example2.Servers[1].Glue)
+	assert.Equal(t, "127.0.0.5:"+port, example2.Servers[1].Address)
+	assert.Equal(t, TraceStatusAnswer, example2.Servers[0].Status)
+	require.Len(t, example2.Issues, 1)
+	assert.Contains(t, example2.Issues[0], "parent only: [ns2.example.net.], child only: [ns3.example.com.]")
+
+	assert.Equal(t, "NOERROR", trace.Rcode)
+	require.Len(t, trace.Answer, 1)
+	assert.Equal(t, "192.0.2.1", trace.Answer[0].Data)
+	assert.Len(t, trace.Issues(), 2)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, trace.RenderText(buf))
+	assert.Contains(t, buf.String(), "referral to example.com.")
+	assert.Contains(t, buf.String(), "192.0.2.1")
+}
+
+func TestClient_Trace_noReferral(t *testing.T) {
+	root := startServer(t, func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		m := new(dnslib.Msg)
+		m.SetRcode(r, dnslib.RcodeServerFailure)
+		_ = w.WriteMsg(m)
+	})
+	client := NewClient(zap.NewNop(), WithRootServers(root), WithTimeouts(time.Second, time.Second))
+	trace, err := client.Trace(context.Background(), TypeA, "www.example.com")
+	require.Error(t, err)
+	assert.Contains(t, trace.Error, "no server of zone . gave an answer or a referral")
+	assert.Equal(t, TraceStatusLame, trace.Steps[0].Servers[0].Status)
+}
+
+func TestTraceStep_referral_ipv6Glue(t *testing.T) {
+	msg := new(dnslib.Msg)
+	for _, s := range []string{"example.com. 172800 IN NS ns1.example.com.", "example.com. 172800 IN NS ns2.example.com."} {
+		rr, _ := dnslib.NewRR(s)
+		msg.Ns = append(msg.Ns, rr)
+	}
+	for _, s := range []string{
+		"ns1.example.com. 172800 IN A 192.0.2.53",
+		"ns1.example.com. 172800 IN AAAA 2001:db8::53",
+		"ns2.example.com. 172800 IN AAAA 2001:db8::54",
+	} {
+		rr, _ := dnslib.NewRR(s)
+		msg.Extra = append(msg.Extra, rr)
+	}
+	step := &TraceStep{Zone: "com.", Servers: []*TraceServer{{Status: TraceStatusReferral, Detail: "example.com.", msg: msg}}}
+	ref := step.referral()
+	require.NotNil(t, ref)
+	assert.Len(t, ref.Glue, 3)
+
+	// ns2.example.com. only has IPv6 glue, it must not be resolved with the servers of the client.
+	client := NewClient(zap.NewNop(), WithServers("127.0.0.1:1"), WithTimeouts(time.Second, time.Second))
+	next, err := client.traceNextStep(context.Background(), ref)
+	require.NoError(t, err)
+	assert.Empty(t, next.Issues)
+	var addresses []string
+	for _, s := range next.Servers {
+		assert.True(t, s.Glue)
+		addresses = append(addresses, s.Address)
+	}
+	assert.Equal(t, []string{"192.0.2.53:53", "[2001:db8::53]:53", "[2001:db8::54]:53"}, addresses)
+}
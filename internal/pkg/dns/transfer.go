@@ -0,0 +1,423 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // HMAC-SHA1 is still used by TSIG keys of legacy servers.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+const (
+	// tsigFudge is the time difference allowed between the clocks of the client and the server, in seconds.
+	tsigFudge = 300
+	// transferReadTimeout bounds the wait for each message of a transfer when no read timeout is set, the
+	// context still applying.
+	transferReadTimeout = 24 * time.Hour
+)
+
+// TSIGKey is a key authenticating zone transfers (RFC 8945).
+type TSIGKey struct {
+	// Name is the name of the key, as known by the servers.
+	Name string
+	// Algorithm is the HMAC algorithm, eg: hmac-sha256.
+	Algorithm string
+	// Secret is the secret, base64 encoded.
+	Secret string
+}
+
+// ParseTSIGKey returns the key named name from spec, its secret or its algorithm and secret, eg:
+// ["hmac-sha512", "c2VjcmV0"]. The algorithm defaults to hmac-sha256.
+func ParseTSIGKey(name string, spec []string) (*TSIGKey, error) {
+	key := &TSIGKey{Name: dnslib.CanonicalName(name), Algorithm: dnslib.HmacSHA256}
+	switch len(spec) {
+	case 1:
+		key.Secret = spec[0]
+	case 2:
+		key.Algorithm = dnslib.CanonicalName(spec[0])
+		key.Secret = spec[1]
+	default:
+		return nil, fmt.Errorf("invalid TSIG key %s: expected a secret, or an algorithm and a secret", name)
+	}
+	if _, err := tsigHash(key.Algorithm, nil); err != nil {
+		return nil, fmt.Errorf("invalid TSIG key %s: %w", name, err)
+	}
+	if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil {
+		return nil, fmt.Errorf("invalid TSIG key %s: the secret is not base64 encoded: %w", name, err)
+	}
+	return key, nil
+}
+
+// WithTSIG authenticates zone transfers with key.
+func WithTSIG(key *TSIGKey) Option {
+	return func(c *Client) {
+		c.tsig = key
+	}
+}
+
+func tsigHash(algorithm string, secret []byte) (hash.Hash, error) {
+	switch dnslib.CanonicalName(algorithm) {
+	case dnslib.HmacSHA1:
+		return hmac.New(sha1.New, secret), nil
+	case dnslib.HmacSHA224:
+		return hmac.New(sha256.New224, secret), nil
+	case dnslib.HmacSHA256:
+		return hmac.New(sha256.New, secret), nil
+	case dnslib.HmacSHA384:
+		return hmac.New(sha512.New384, secret), nil
+	case dnslib.HmacSHA512:
+		return hmac.New(sha512.New, secret), nil
+	default:
+		return nil, fmt.Errorf(
+			"unsupported TSIG algorithm %s, valid algorithms are hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384 and hmac-sha512",
+			strings.TrimSuffix(algorithm, "."),
+		)
+	}
+}
+
+// tsigProvider signs the transfer requests with a key, and counts the responses it verified: the
+// transfers of miekg/dns accept the messages that are not signed.
+type tsigProvider struct {
+	key      *TSIGKey
+	verified int
+}
+
+// Generate implements dnslib.TsigProvider.
+func (p *tsigProvider) Generate(msg []byte, t *dnslib.TSIG) ([]byte, error) {
+	if dnslib.CanonicalName(t.Hdr.Name) != p.key.Name {
+		return nil, dnslib.ErrSecret
+	}
+	secret, err := base64.StdEncoding.DecodeString(p.key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	h, err := tsigHash(t.Algorithm, secret)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+// Verify implements dnslib.TsigProvider.
+func (p *tsigProvider) Verify(msg []byte, t *dnslib.TSIG) error {
+	expected, err := p.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, mac) {
+		return dnslib.ErrSig
+	}
+	p.verified++
+	return nil
+}
+
+// Transfer is the summary of a zone transfer.
+type Transfer struct {
+	Server string `json:"server"`
+	Zone   string `json:"zone"`
+	// Type is AXFR or IXFR.
+	Type string `json:"type"`
+	// Serial is the serial of the zone on the server.
+	Serial   uint32 `json:"serial"`
+	Records  int    `json:"records"`
+	Messages int    `json:"messages"`
+	// Signed is true if the responses were authenticated by the TSIG key.
+	Signed   bool     `json:"signed"`
+	Duration Duration `json:"duration"`
+}
+
+// AXFR transfers zone from server (RFC 5936), calling fn with each record as it is received, the SOA
+// record being the first and the last one. Transfers from servers given as udp:// or tcp:// are done
+// over TCP, and over TLS from tls:// servers (RFC 9103). fn returning an error stops the transfer.
+func (c *Client) AXFR(ctx context.Context, server, zone string, fn func(dnslib.RR) error) (*Transfer, error) {
+	m := new(dnslib.Msg)
+	m.SetAxfr(dnslib.Fqdn(zone))
+	return c.transfer(ctx, server, m, fn)
+}
+
+// IXFR transfers the changes of zone from server since serial (RFC 1995), see AXFR. The records are
+// the SOA record of the zone, then for each version the old SOA record and the removed records, the new
+// SOA record and the added records, then the SOA record again. A server can also send the whole zone,
+// like AXFR.
+func (c *Client) IXFR(ctx context.Context, server, zone string, serial uint32, fn func(dnslib.RR) error) (*Transfer, error) {
+	zone = dnslib.Fqdn(zone)
+	m := new(dnslib.Msg)
+	m.SetIxfr(zone, serial, ".", ".")
+	return c.transfer(ctx, server, m, fn)
+}
+
+func (c *Client) transfer(ctx context.Context, address string, m *dnslib.Msg, fn func(dnslib.RR) error) (*Transfer, error) {
+	q := m.Question[0]
+	res := &Transfer{Server: address, Zone: q.Name, Type: dnslib.TypeToString[q.Qtype]}
+	s, err := parseServer(address)
+	if err != nil {
+		return nil, dsakerr.Wrap(dsakerr.CategoryUsage, err)
+	}
+	res.Server = s.String()
+	logger := c.logger.With(zap.String("server", res.Server), zap.String("zone", res.Zone), zap.String("type", res.Type))
+
+	var conn net.Conn
+	switch s.transport {
+	case TransportUDP, TransportTCP:
+		cctx, cancel := c.connectContext(ctx)
+		conn, err = c.dialer("tcp").DialContext(cctx, "tcp", s.address)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to DNS server: %w", c.phaseError(ctx, dsakerr.PhaseConnect, err))
+		}
+	case TransportTLS:
+		co, _, err := c.dialTLS(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		conn = co.Conn
+	default:
+		return nil, dsakerr.Errorf(dsakerr.CategoryUsage, "zone transfers are not supported over %s, use udp, tcp or tls", s.transport)
+	}
+	// Closing the connection stops the transfer.
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	t := &dnslib.Transfer{Conn: &dnslib.Conn{Conn: conn}, ReadTimeout: c.readTimeout}
+	if t.ReadTimeout == 0 {
+		t.ReadTimeout = transferReadTimeout
+	}
+	var provider *tsigProvider
+	if c.tsig != nil {
+		provider = &tsigProvider{key: c.tsig}
+		t.TsigProvider = provider
+		m.SetTsig(c.tsig.Name, c.tsig.Algorithm, tsigFudge, time.Now().Unix())
+	}
+	start := time.Now()
+	envelopes, err := t.In(m, s.address)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot send the transfer request: %w", c.phaseError(ctx, dsakerr.PhaseRead, err))
+	}
+	var transferErr error
+	// The envelopes are drained until the channel is closed, after an error too.
+	for env := range envelopes {
+		if transferErr != nil {
+			continue
+		}
+		if env.Error != nil {
+			transferErr = c.transferError(ctx, env.Error)
+			continue
+		}
+		res.Messages++
+		for _, rr := range env.RR {
+			if soa, ok := rr.(*dnslib.SOA); ok && res.Records == 0 {
+				res.Serial = soa.Serial
+			}
+			res.Records++
+			if err := fn(rr); err != nil {
+				transferErr = err
+				conn.Close()
+				break
+			}
+		}
+	}
+	res.Duration = Duration(time.Since(start))
+	if transferErr != nil {
+		logger.With(zap.Error(transferErr)).Debug("Zone transfer failed")
+		return nil, transferErr
+	}
+	if provider != nil {
+		if provider.verified == 0 {
+			return nil, dsakerr.Errorf(dsakerr.CategoryValidation, "the transfer of %s from %s is not signed by the TSIG key", res.Zone, res.Server)
+		}
+		res.Signed = true
+	}
+	logger.With(zap.Int("records", res.Records), zap.Duration("duration", time.Duration(res.Duration))).Debug("Zone transfer succeeded")
+	return res, nil
+}
+
+func (c *Client) transferError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("zone transfer failed: %w", ctx.Err())
+	}
+	switch {
+	case errors.Is(err, dnslib.ErrSig), errors.Is(err, dnslib.ErrSecret), errors.Is(err, dnslib.ErrTime):
+		return dsakerr.Wrap(dsakerr.CategoryValidation, fmt.Errorf("zone transfer failed, invalid TSIG signature: %w", err))
+	case errors.Is(err, dnslib.ErrSoa):
+		return fmt.Errorf("zone transfer failed, the server does not serve the zone or refused the transfer: %w", err)
+	}
+	var dnsErr *dnslib.Error
+	if errors.As(err, &dnsErr) && strings.HasPrefix(dnsErr.Error(), "dns: bad xfr rcode: ") {
+		rcode, _ := strconv.Atoi(strings.TrimPrefix(dnsErr.Error(), "dns: bad xfr rcode: "))
+		return fmt.Errorf("zone transfer failed, the server answered %s", dnslib.RcodeToString[rcode])
+	}
+	return fmt.Errorf("zone transfer failed: %w", c.phaseError(ctx, dsakerr.PhaseRead, err))
+}
+
+// TransferComparison compares the transfers of a zone from several servers, eg: a primary and its
+// secondaries.
+type TransferComparison struct {
+	Zone string `json:"zone"`
+	// InSync is true if the zone was transferred from every server, with the same records.
+	InSync  bool              `json:"in_sync"`
+	Servers []*TransferServer `json:"servers"`
+}
+
+// TransferServer is the transfer of a zone from a server of a TransferComparison.
+type TransferServer struct {
+	Server   string   `json:"server"`
+	Serial   uint32   `json:"serial"`
+	Records  int      `json:"records"`
+	Signed   bool     `json:"signed"`
+	Duration Duration `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	// Differs is true if the records differ from the ones of the first server transferred from.
+	Differs bool `json:"differs"`
+	// Changes are the changes from the records of the first server transferred from.
+	Changes []*ZoneChange `json:"changes"`
+}
+
+// CompareTransfers transfers zone with AXFR from every server concurrently and compares their records to
+// the ones of the first server the transfer succeeded from.
+func (c *Client) CompareTransfers(ctx context.Context, zone string) *TransferComparison {
+	zone = dnslib.Fqdn(zone)
+	res := &TransferComparison{Zone: zone, InSync: true, Servers: make([]*TransferServer, len(c.servers))}
+	zones := make([]*Zone, len(c.servers))
+	wg := sync.WaitGroup{}
+	for i, server := range c.servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			ts := &TransferServer{Server: server, Changes: []*ZoneChange{}}
+			res.Servers[i] = ts
+			z := &Zone{File: server, Origin: dnslib.CanonicalName(zone)}
+			t, err := c.AXFR(ctx, server, zone, func(rr dnslib.RR) error {
+				z.Records = append(z.Records, rr)
+				return nil
+			})
+			if err != nil {
+				ts.Error = err.Error()
+				return
+			}
+			ts.Server, ts.Serial, ts.Records, ts.Signed, ts.Duration = t.Server, t.Serial, t.Records, t.Signed, t.Duration
+			// The SOA record is sent first and last.
+			if len(z.Records) > 1 {
+				z.Records = z.Records[:len(z.Records)-1]
+			}
+			zones[i] = z
+		}(i, server)
+	}
+	wg.Wait()
+	var reference *Zone
+	for i, ts := range res.Servers {
+		if ts.Error != "" {
+			res.InSync = false
+			continue
+		}
+		if reference == nil {
+			reference = zones[i]
+			continue
+		}
+		ts.Changes = DiffZones(reference, zones[i]).Changes
+		ts.Differs = len(ts.Changes) > 0
+		if ts.Differs {
+			res.InSync = false
+		}
+	}
+	return res
+}
+
+// RenderText writes a line per server, with the changes of the servers whose records differ.
+func (r *TransferComparison) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	color.New(color.Bold).Fprintf(buf, "Transfers of %s", r.Zone)
+	buf.WriteString("\n")
+	width := 0
+	for _, ts := range r.Servers {
+		width = max(width, len(ts.Server))
+	}
+	for _, ts := range r.Servers {
+		fmt.Fprintf(buf, "%-*s  ", width, ts.Server)
+		switch {
+		case ts.Error != "":
+			color.New(color.FgRed).Fprint(buf, "error    ")
+			faint.Fprint(buf, "  "+ts.Error)
+		case ts.Differs:
+			color.New(color.FgYellow).Fprint(buf, "differs  ")
+		default:
+			color.New(color.FgGreen).Fprint(buf, "in sync  ")
+		}
+		if ts.Error == "" {
+			fmt.Fprintf(buf, "  serial %d, %d records", ts.Serial, ts.Records)
+			faint.Fprintf(buf, "  %s", time.Duration(ts.Duration).Round(time.Millisecond))
+		}
+		buf.WriteString("\n")
+		for _, c := range ts.Changes {
+			switch c.Change {
+			case ZoneAdded:
+				color.New(color.FgGreen).Fprintf(buf, "  + %s %d IN %s %s", c.Name, c.TTL, c.Type, c.Data)
+			case ZoneRemoved:
+				color.New(color.FgRed).Fprintf(buf, "  - %s %d IN %s %s", c.Name, c.TTL, c.Type, c.Data)
+			default:
+				color.New(color.FgYellow).Fprintf(buf, "  ~ %s %d -> %d IN %s %s", c.Name, c.OldTTL, c.TTL, c.Type, c.Data)
+			}
+			buf.WriteString("\n")
+		}
+	}
+	if r.InSync {
+		color.New(color.FgGreen).Fprintf(buf, "%d servers in sync", len(r.Servers))
+	} else {
+		color.New(color.FgRed).Fprint(buf, "servers not in sync")
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Header returns the columns of the transfer comparison table.
+func (r *TransferComparison) Header() []string {
+	return []string{"server", "serial", "records", "signed", "duration", "differs", "added", "removed", "ttl_changed", "error"}
+}
+
+// Rows returns the transfers, one per row.
+func (r *TransferComparison) Rows() [][]string {
+	rows := make([][]string, 0, len(r.Servers))
+	for _, ts := range r.Servers {
+		counts := (&ZoneDiff{Changes: ts.Changes}).Counts()
+		rows = append(rows, []string{
+			ts.Server,
+			strconv.FormatUint(uint64(ts.Serial), 10),
+			strconv.Itoa(ts.Records),
+			strconv.FormatBool(ts.Signed),
+			time.Duration(ts.Duration).String(),
+			strconv.FormatBool(ts.Differs),
+			strconv.Itoa(counts[ZoneAdded]),
+			strconv.Itoa(counts[ZoneRemoved]),
+			strconv.Itoa(counts[ZoneTTLChanged]),
+			ts.Error,
+		})
+	}
+	return rows
+}
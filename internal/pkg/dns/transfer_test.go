@@ -0,0 +1,144 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testTSIGSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+
+// startTransferServer starts a DNS server on a random local TCP port, transferring zone in messages of
+// 2 records, and returns its address. With secrets, transfers must be signed by one of their keys.
+func startTransferServer(t *testing.T, zone string, secrets map[string]string) string {
+	t.Helper()
+	z, err := ParseZone(strings.NewReader(zone), "test", "")
+	require.NoError(t, err)
+	return serveDNS(t, "tcp", "127.0.0.1:0", &dnslib.Server{
+		TsigSecret: secrets,
+		Handler: dnslib.HandlerFunc(func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+			if secrets != nil && (r.IsTsig() == nil || w.TsigStatus() != nil) {
+				m := new(dnslib.Msg)
+				m.SetRcode(r, dnslib.RcodeNotAuth)
+				_ = w.WriteMsg(m)
+				return
+			}
+			if secrets == nil {
+				// The responses are not signed.
+				r.Extra = nil
+			}
+			rrs := append(append([]dnslib.RR{}, z.Records...), z.Records[0])
+			ch := make(chan *dnslib.Envelope)
+			go func() {
+				for i := 0; i < len(rrs); i += 2 {
+					ch <- &dnslib.Envelope{RR: rrs[i:min(i+2, len(rrs))]}
+				}
+				close(ch)
+			}()
+			_ = new(dnslib.Transfer).Out(w, r, ch)
+		}),
+	})
+}
+
+func TestParseTSIGKey(t *testing.T) {
+	key, err := ParseTSIGKey("Transfer", []string{testTSIGSecret})
+	require.NoError(t, err)
+	assert.Equal(t, &TSIGKey{Name: "transfer.", Algorithm: dnslib.HmacSHA256, Secret: testTSIGSecret}, key)
+	key, err = ParseTSIGKey("transfer.", []string{"HMAC-SHA512", testTSIGSecret})
+	require.NoError(t, err)
+	assert.Equal(t, dnslib.HmacSHA512, key.Algorithm)
+
+	_, err = ParseTSIGKey("transfer", []string{"hmac-md4", testTSIGSecret})
+	assert.ErrorContains(t, err, "unsupported TSIG algorithm hmac-md4")
+	_, err = ParseTSIGKey("transfer", []string{"not base64!"})
+	assert.ErrorContains(t, err, "not base64 encoded")
+	_, err = ParseTSIGKey("transfer", nil)
+	assert.Error(t, err)
+}
+
+func TestClient_AXFR(t *testing.T) {
+	address := startTransferServer(t, testBINDZone, nil)
+	client := NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second))
+	var rrs []dnslib.RR
+	res, err := client.AXFR(context.Background(), "tcp://"+address, "example.test", func(rr dnslib.RR) error {
+		rrs = append(rrs, rr)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://"+address, res.Server)
+	assert.Equal(t, "example.test.", res.Zone)
+	assert.Equal(t, "AXFR", res.Type)
+	assert.Equal(t, uint32(2024010101), res.Serial)
+	assert.Equal(t, 8, res.Records)
+	assert.Equal(t, 4, res.Messages)
+	assert.False(t, res.Signed)
+	require.Len(t, rrs, 8)
+	assert.Equal(t, dnslib.TypeSOA, rrs[0].Header().Rrtype)
+	assert.Equal(t, dnslib.TypeSOA, rrs[7].Header().Rrtype)
+}
+
+func TestClient_AXFR_tsig(t *testing.T) {
+	address := startTransferServer(t, testBINDZone, map[string]string{"transfer.": testTSIGSecret})
+	key, err := ParseTSIGKey("transfer", []string{testTSIGSecret})
+	require.NoError(t, err)
+
+	client := NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second), WithTSIG(key))
+	res, err := client.AXFR(context.Background(), address, "example.test.", func(dnslib.RR) error { return nil })
+	require.NoError(t, err)
+	assert.True(t, res.Signed)
+	assert.Equal(t, 8, res.Records)
+
+	// Without the key, the server refuses the transfer.
+	client = NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second))
+	_, err = client.AXFR(context.Background(), address, "example.test.", func(dnslib.RR) error { return nil })
+	assert.ErrorContains(t, err, "the server answered NOTAUTH")
+
+	// With another secret, the response cannot be verified.
+	other := &TSIGKey{Name: "transfer.", Algorithm: dnslib.HmacSHA256, Secret: "b3RoZXI="}
+	client = NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second), WithTSIG(other))
+	_, err = client.AXFR(context.Background(), address, "example.test.", func(dnslib.RR) error { return nil })
+	assert.Error(t, err)
+
+	// Responses must be signed.
+	unsigned := startTransferServer(t, testBINDZone, nil)
+	client = NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second), WithTSIG(key))
+	_, err = client.AXFR(context.Background(), unsigned, "example.test.", func(dnslib.RR) error { return nil })
+	assert.ErrorContains(t, err, "not signed by the TSIG key")
+}
+
+func TestClient_IXFR(t *testing.T) {
+	address := startTransferServer(t, testBINDZone, nil)
+	client := NewClient(zap.NewNop(), WithTimeouts(time.Second, time.Second))
+	res, err := client.IXFR(context.Background(), address, "example.test", 2023010101, func(dnslib.RR) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, "IXFR", res.Type)
+	assert.Equal(t, uint32(2024010101), res.Serial)
+}
+
+func TestClient_CompareTransfers(t *testing.T) {
+	primary := startTransferServer(t, testBINDZone, nil)
+	inSync := startTransferServer(t, testBINDZone, nil)
+	stale := startTransferServer(t, strings.Replace(testBINDZone, "192.0.2.1", "192.0.2.100", 1), nil)
+
+	client := NewClient(zap.NewNop(), WithServers(primary, inSync, stale), WithTimeouts(time.Second, time.Second))
+	res := client.CompareTransfers(context.Background(), "example.test")
+	assert.False(t, res.InSync)
+	require.Len(t, res.Servers, 3)
+	assert.False(t, res.Servers[1].Differs)
+	assert.Empty(t, res.Servers[1].Changes)
+	assert.True(t, res.Servers[2].Differs)
+	require.Len(t, res.Servers[2].Changes, 2)
+	assert.Equal(t, ZoneRemoved, res.Servers[2].Changes[0].Change)
+	assert.Equal(t, "192.0.2.1", res.Servers[2].Changes[0].Data)
+	assert.Equal(t, ZoneAdded, res.Servers[2].Changes[1].Change)
+	assert.Equal(t, "192.0.2.100", res.Servers[2].Changes[1].Data)
+
+	client = NewClient(zap.NewNop(), WithServers(primary, inSync), WithTimeouts(time.Second, time.Second))
+	assert.True(t, client.CompareTransfers(context.Background(), "example.test").InSync)
+}
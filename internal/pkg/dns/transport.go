@@ -0,0 +1,330 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+// Transport is the protocol used to query a server.
+type Transport string
+
+const (
+	// TransportUDP is plain DNS over UDP, the default.
+	TransportUDP Transport = "udp"
+	// TransportTCP is plain DNS over TCP.
+	TransportTCP Transport = "tcp"
+	// TransportTLS is DNS over TLS (RFC 7858).
+	TransportTLS Transport = "tls"
+	// TransportHTTPS is DNS over HTTPS (RFC 8484).
+	TransportHTTPS Transport = "https"
+	// TransportQUIC is DNS over QUIC (RFC 9250).
+	TransportQUIC Transport = "quic"
+	// TransportHosts are the answers read from a hosts file, see WithHosts.
+	TransportHosts Transport = "hosts"
+)
+
+const dohContentType = "application/dns-message"
+
+// server is a DNS server to query.
+type server struct {
+	transport Transport
+	// address is host:port, or the URL of a DoH server.
+	address string
+	// name is the host name, used to verify the server certificate.
+	name string
+}
+
+// parseServer parses a server given as an address (UDP), or an URL: udp://, tcp://, tls://, https:// or quic://.
+// The port defaults to 53 for UDP and TCP, 853 for TLS and QUIC.
+func parseServer(s string) (*server, error) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		scheme, rest = string(TransportUDP), s
+	}
+	srv := &server{transport: Transport(strings.ToLower(scheme))}
+	port := "53"
+	switch srv.transport {
+	case TransportUDP, TransportTCP:
+	case TransportTLS, TransportQUIC:
+		port = "853"
+	case TransportHTTPS:
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS over HTTPS server %s: %w", s, err)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		srv.address = u.String()
+		srv.name = u.Hostname()
+		return srv, nil
+	default:
+		return nil, fmt.Errorf("unsupported DNS server scheme %s, valid schemes are udp, tcp, tls, https and quic", scheme)
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	if rest == "" {
+		return nil, fmt.Errorf("invalid DNS server %s: missing host", s)
+	}
+	host, p, err := net.SplitHostPort(rest)
+	if err != nil {
+		host, p = strings.Trim(rest, "[]"), port
+	}
+	srv.address = net.JoinHostPort(host, p)
+	srv.name = host
+	return srv, nil
+}
+
+// CheckServer returns an error if s is not a valid server, see WithServers.
+func CheckServer(s string) error {
+	_, err := parseServer(s)
+	return err
+}
+
+// String returns the server as shown in results: its address for UDP, an URL otherwise.
+func (s *server) String() string {
+	if s.transport == TransportUDP || s.transport == TransportHTTPS {
+		return s.address
+	}
+	return string(s.transport) + "://" + s.address
+}
+
+func (c *Client) tlsConfig(s *server, alpn ...string) *tls.Config {
+	return &tls.Config{
+		ServerName:         s.name,
+		InsecureSkipVerify: c.insecure, //nolint:gosec
+		NextProtos:         alpn,
+	}
+}
+
+// connectContext returns ctx bounded by the connect timeout.
+func (c *Client) connectContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.connectTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.connectTimeout)
+}
+
+// dialer returns the dialer of connections over network, "udp" or "tcp", bound to the source address and
+// port of c.
+func (c *Client) dialer(network string) *net.Dialer {
+	d := &net.Dialer{Timeout: c.connectTimeout}
+	if !c.sourceAddr.IsValid() && c.sourcePort == 0 {
+		return d
+	}
+	var ip net.IP
+	if c.sourceAddr.IsValid() {
+		ip = c.sourceAddr.AsSlice()
+	}
+	if network == "udp" {
+		d.LocalAddr = &net.UDPAddr{IP: ip, Port: int(c.sourcePort)}
+	} else {
+		d.LocalAddr = &net.TCPAddr{IP: ip, Port: int(c.sourcePort)}
+	}
+	return d
+}
+
+// exchange sends m to s and returns the response, its round trip time and the TLS details of the connection.
+func (c *Client) exchange(ctx context.Context, s *server, m *dnslib.Msg) (*dnslib.Msg, time.Duration, *TLSInfo, error) {
+	if (s.transport == TransportHTTPS || s.transport == TransportQUIC) && (c.sourceAddr.IsValid() || c.sourcePort != 0) {
+		return nil, 0, nil, dsakerr.Errorf(dsakerr.CategoryUsage, "the source address is not supported over %s", s.transport)
+	}
+	switch s.transport {
+	case TransportHTTPS:
+		return c.exchangeHTTPS(ctx, s, m)
+	case TransportQUIC:
+		return c.exchangeQUIC(ctx, s, m)
+	case TransportUDP, TransportTCP, TransportTLS:
+	}
+	network := string(s.transport)
+	if s.transport == TransportTLS {
+		network = "tcp-tls"
+	}
+	client := &dnslib.Client{
+		Net:         network,
+		Dialer:      c.dialer(string(s.transport)),
+		DialTimeout: c.connectTimeout,
+		ReadTimeout: c.readTimeout,
+	}
+	var co *dnslib.Conn
+	var info *TLSInfo
+	if s.transport == TransportTLS {
+		var err error
+		co, info, err = c.dialTLS(ctx, s)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+	} else {
+		var err error
+		co, err = client.DialContext(ctx, s.address)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("cannot connect to DNS server: %w", c.phaseError(ctx, dsakerr.PhaseConnect, err))
+		}
+	}
+	defer co.Close()
+	in, rtt, err := client.ExchangeWithConnContext(ctx, m, co)
+	if err != nil {
+		return nil, 0, info, fmt.Errorf("DNS query failed: %w", c.phaseError(ctx, dsakerr.PhaseRead, err))
+	}
+	return in, rtt, info, nil
+}
+
+func (c *Client) dialTLS(ctx context.Context, s *server) (*dnslib.Conn, *TLSInfo, error) {
+	cctx, cancel := c.connectContext(ctx)
+	defer cancel()
+	conn, err := c.dialer("tcp").DialContext(cctx, "tcp", s.address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to DNS server: %w", c.phaseError(ctx, dsakerr.PhaseConnect, err))
+	}
+	tlsConn := tls.Client(conn, c.tlsConfig(s))
+	start := time.Now()
+	if err := tlsConn.HandshakeContext(cctx); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("TLS handshake failed: %w", c.phaseError(ctx, dsakerr.PhaseConnect, err))
+	}
+	return &dnslib.Conn{Conn: tlsConn}, newTLSInfo(tlsConn.ConnectionState(), time.Since(start)), nil
+}
+
+func (c *Client) exchangeHTTPS(ctx context.Context, s *server, m *dnslib.Msg) (*dnslib.Msg, time.Duration, *TLSInfo, error) {
+	// The message ID should be 0 with DNS over HTTPS, for caching (RFC 8484 section 4.1).
+	m.Id = 0
+	wire, err := m.Pack()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("cannot pack DNS query: %w", err)
+	}
+	var req *http.Request
+	if c.dohMethod == http.MethodGet {
+		u := s.address + "?dns=" + base64.RawURLEncoding.EncodeToString(wire)
+		if strings.Contains(s.address, "?") {
+			u = s.address + "&dns=" + base64.RawURLEncoding.EncodeToString(wire)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.address, bytes.NewReader(wire))
+		if req != nil {
+			req.Header.Set("Content-Type", dohContentType)
+		}
+	}
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("cannot create DNS over HTTPS request: %w", err)
+	}
+	req.Header.Set("Accept", dohContentType)
+
+	var handshakeStart, sent time.Time
+	var info *TLSInfo
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			handshakeStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				info = newTLSInfo(state, time.Since(handshakeStart))
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			sent = time.Now()
+		},
+	}))
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: c.connectTimeout}).DialContext,
+			ForceAttemptHTTP2:     true,
+			Proxy:                 http.ProxyFromEnvironment,
+			TLSHandshakeTimeout:   c.connectTimeout,
+			ResponseHeaderTimeout: c.readTimeout,
+			TLSClientConfig:       c.tlsConfig(s, "h2", "http/1.1"),
+		},
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		phase := dsakerr.PhaseConnect
+		if !sent.IsZero() {
+			phase = dsakerr.PhaseRead
+		}
+		return nil, 0, info, fmt.Errorf("DNS over HTTPS request failed: %w", c.phaseError(ctx, phase, err))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dnslib.MaxMsgSize))
+	if err != nil {
+		return nil, 0, info, fmt.Errorf("cannot read DNS over HTTPS response: %w", c.phaseError(ctx, dsakerr.PhaseRead, err))
+	}
+	rtt := time.Since(sent)
+	if resp.StatusCode != http.StatusOK {
+		return nil, rtt, info, fmt.Errorf("DNS over HTTPS server answered with status %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, dohContentType) {
+		return nil, rtt, info, fmt.Errorf("DNS over HTTPS server answered with content type %q", ct)
+	}
+	in := new(dnslib.Msg)
+	if err := in.Unpack(body); err != nil {
+		return nil, rtt, info, fmt.Errorf("cannot unpack DNS over HTTPS response: %w", err)
+	}
+	return in, rtt, info, nil
+}
+
+func (c *Client) exchangeQUIC(ctx context.Context, s *server, m *dnslib.Msg) (*dnslib.Msg, time.Duration, *TLSInfo, error) {
+	// The message ID must be 0 with DNS over QUIC (RFC 9250 section 4.2.1).
+	m.Id = 0
+	wire, err := m.Pack()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("cannot pack DNS query: %w", err)
+	}
+	cctx, cancel := c.connectContext(ctx)
+	defer cancel()
+	start := time.Now()
+	conn, err := quic.DialAddr(cctx, s.address, c.tlsConfig(s, "doq"), &quic.Config{HandshakeIdleTimeout: c.connectTimeout})
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("cannot connect to DNS server: %w", c.phaseError(ctx, dsakerr.PhaseConnect, err))
+	}
+	defer conn.CloseWithError(0, "") //nolint:errcheck
+	info := newTLSInfo(conn.ConnectionState().TLS, time.Since(start))
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, 0, info, fmt.Errorf("cannot open DNS over QUIC stream: %w", err)
+	}
+	sent := time.Now()
+	if c.readTimeout > 0 {
+		_ = stream.SetReadDeadline(sent.Add(c.readTimeout))
+	} else if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetReadDeadline(deadline)
+	}
+	buf := make([]byte, 2+len(wire))
+	binary.BigEndian.PutUint16(buf, uint16(len(wire)))
+	copy(buf[2:], wire)
+	if _, err := stream.Write(buf); err != nil {
+		return nil, 0, info, fmt.Errorf("DNS query failed: %w", err)
+	}
+	// Closing the stream only closes its send direction, telling the server the query is complete.
+	_ = stream.Close()
+	body, err := io.ReadAll(io.LimitReader(stream, 2+dnslib.MaxMsgSize))
+	if err != nil {
+		return nil, 0, info, fmt.Errorf("DNS query failed: %w", c.phaseError(ctx, dsakerr.PhaseRead, err))
+	}
+	rtt := time.Since(sent)
+	if len(body) < 2 || int(binary.BigEndian.Uint16(body)) != len(body)-2 {
+		return nil, rtt, info, errors.New("invalid DNS over QUIC response length")
+	}
+	in := new(dnslib.Msg)
+	if err := in.Unpack(body[2:]); err != nil {
+		return nil, rtt, info, fmt.Errorf("cannot unpack DNS over QUIC response: %w", err)
+	}
+	return in, rtt, info, nil
+}
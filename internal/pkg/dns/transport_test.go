@@ -0,0 +1,131 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// reply returns the packed answer of answerA to the packed query wire.
+func reply(t *testing.T, wire []byte, ip string) []byte {
+	t.Helper()
+	q := new(dnslib.Msg)
+	require.NoError(t, q.Unpack(wire))
+	m := new(dnslib.Msg)
+	m.SetReply(q)
+	rr, err := dnslib.NewRR(q.Question[0].Name + " 300 IN A " + ip)
+	require.NoError(t, err)
+	m.Answer = append(m.Answer, rr)
+	b, err := m.Pack()
+	require.NoError(t, err)
+	return b
+}
+
+// startDoH starts a DNS over HTTPS server, returning its URL and its TLS configuration.
+func startDoH(t *testing.T) (string, *tls.Config) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wire []byte
+		var err error
+		if r.Method == http.MethodGet {
+			wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		} else {
+			wire, err = io.ReadAll(r.Body)
+		}
+		if err != nil || r.URL.Path != "/dns-query" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(reply(t, wire, "192.0.2."+map[string]string{http.MethodGet: "1", http.MethodPost: "2"}[r.Method]))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, srv.TLS
+}
+
+func TestClient_Query_https(t *testing.T) {
+	u, _ := startDoH(t)
+	for method, ip := range map[string]string{"GET": "192.0.2.1", "POST": "192.0.2.2"} {
+		t.Run(method, func(t *testing.T) {
+			client := NewClient(zap.NewNop(), WithServers(u), WithInsecure(), WithDoHMethod(method))
+			res, err := client.Query(context.Background(), TypeA, "example.com")
+			require.NoError(t, err)
+			require.NotNil(t, res.Servers[0].Response)
+			assert.Equal(t, u+"/dns-query", res.Servers[0].Server)
+			assert.Equal(t, TransportHTTPS, res.Servers[0].Response.Transport)
+			assert.Contains(t, res.Servers[0].Response.Answer[0].Data, ip)
+			require.NotNil(t, res.Servers[0].Response.TLS)
+			assert.False(t, res.Servers[0].Response.TLS.Verified)
+			assert.NotEmpty(t, res.Servers[0].Response.TLS.Certificates)
+		})
+	}
+}
+
+func TestClient_Query_tls(t *testing.T) {
+	_, tlsConfig := startDoH(t)
+	address := serveDNS(t, "tcp-tls", "127.0.0.1:0", &dnslib.Server{TLSConfig: tlsConfig, Handler: answerA("192.0.2.3", 0)})
+
+	client := NewClient(zap.NewNop(), WithServers("tls://"+address), WithInsecure())
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	require.NotNil(t, res.Servers[0].Response)
+	assert.Equal(t, "tls://"+address, res.Servers[0].Server)
+	assert.Contains(t, res.Servers[0].Response.Answer[0].Data, "192.0.2.3")
+	require.NotNil(t, res.Servers[0].Response.TLS)
+	assert.Equal(t, "TLS 1.3", res.Servers[0].Response.TLS.Version)
+
+	// The server certificate is not trusted.
+	client = NewClient(zap.NewNop(), WithServers("tls://"+address))
+	_, err = client.Query(context.Background(), TypeA, "example.com")
+	require.ErrorContains(t, err, "TLS handshake failed")
+}
+
+func TestClient_Query_quic(t *testing.T) {
+	_, tlsConfig := startDoH(t)
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{"doq"}
+	ln, err := quic.ListenAddr("127.0.0.1:0", tlsConfig, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	go func() {
+		conn, err := ln.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		b, err := io.ReadAll(stream)
+		if err != nil || len(b) < 2 {
+			return
+		}
+		wire := reply(t, b[2:], "192.0.2.4")
+		buf := binary.BigEndian.AppendUint16(nil, uint16(len(wire)))
+		_, _ = stream.Write(append(buf, wire...))
+		_ = stream.Close()
+	}()
+
+	addr := ln.Addr().(*net.UDPAddr)
+	client := NewClient(zap.NewNop(), WithServers("quic://"+addr.String()), WithInsecure())
+	res, err := client.Query(context.Background(), TypeA, "example.com")
+	require.NoError(t, err)
+	require.NotNil(t, res.Servers[0].Response)
+	assert.Contains(t, res.Servers[0].Response.Answer[0].Data, "192.0.2.4")
+	require.NotNil(t, res.Servers[0].Response.TLS)
+	assert.Equal(t, "doq", res.Servers[0].Response.TLS.ALPN)
+}
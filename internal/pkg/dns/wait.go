@@ -0,0 +1,354 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+// minWaitPollTimeout is the minimum timeout of each poll of Wait.
+const minWaitPollTimeout = 2 * time.Second
+
+// WaitOptions are the condition Wait waits for, and how often servers are polled.
+type WaitOptions struct {
+	// Expect is the set of values every server must return, eg: ["203.0.113.7"].
+	Expect []string
+	// Absent makes Wait wait for servers to return no record, instead of Expect.
+	Absent bool
+	// Interval is the minimum interval between two polls.
+	Interval time.Duration
+	// MaxInterval is the maximum interval between two polls, when waiting for cached records to
+	// expire. 0 disables waiting for the TTLs.
+	MaxInterval time.Duration
+}
+
+// WaitStatus is the status of the servers polled by Wait.
+type WaitStatus struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Expect  []string      `json:"expect"`
+	Absent  bool          `json:"expect_absent"`
+	Done    bool          `json:"done"`
+	Polls   int           `json:"polls"`
+	Elapsed Duration      `json:"elapsed"`
+	Servers []*WaitServer `json:"servers"`
+	// NextPoll is the interval before the next poll, if not done.
+	NextPoll Duration `json:"next_poll,omitempty"`
+
+	expect map[string]bool
+}
+
+// WaitServer is the last response of a server polled by Wait.
+type WaitServer struct {
+	Server        string   `json:"server"`
+	Rcode         string   `json:"rcode,omitempty"`
+	Values        []string `json:"values"`
+	TTL           uint32   `json:"ttl"`
+	Authoritative bool     `json:"authoritative"`
+	Matches       bool     `json:"matches"`
+	// MatchedAfter is the time it took the server to match, if it does.
+	MatchedAfter Duration `json:"matched_after,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// normalizeValue returns v with its names lowercased and without their trailing dot, for comparisons.
+func normalizeValue(v string) string {
+	fields := strings.Fields(strings.ToLower(v))
+	for i, f := range fields {
+		fields[i] = strings.TrimSuffix(f, ".")
+	}
+	return strings.Join(fields, " ")
+}
+
+// waitValue returns the value of rr compared to the expected ones: the text of TXT records, the data otherwise.
+func waitValue(rr dnslib.RR) string {
+	if txt, ok := rr.(*dnslib.TXT); ok {
+		return strings.Join(txt.Txt, "")
+	}
+	return strings.TrimSpace(rdata(rr))
+}
+
+// Wait polls the servers until every one returns the expected values or no record, calling onPoll after
+// each poll. Polls are at least opts.Interval apart; if a caching server does not match yet, the next poll
+// waits for its records to expire, up to opts.MaxInterval.
+// The error is returned with the last status if ctx is done before the condition holds.
+func (c *Client) Wait(ctx context.Context, rType Type, name string, opts WaitOptions, onPoll func(*WaitStatus)) (*WaitStatus, error) {
+	name = dnslib.Fqdn(name)
+	status := &WaitStatus{
+		Name:    name,
+		Type:    GetTypeName(rType),
+		Expect:  opts.Expect,
+		Absent:  opts.Absent,
+		Servers: []*WaitServer{},
+		expect:  make(map[string]bool),
+	}
+	if status.Expect == nil {
+		status.Expect = []string{}
+	}
+	for _, v := range opts.Expect {
+		status.expect[normalizeValue(v)] = true
+	}
+	matchedAt := make(map[string]time.Duration)
+	start := time.Now()
+	for {
+		pctx, cancel := context.WithTimeout(ctx, max(opts.Interval, minWaitPollTimeout))
+		res, _ := c.Query(pctx, rType, name)
+		cancel()
+		if ctx.Err() != nil {
+			return status, fmt.Errorf("%s %s did not reach the expected state: %w", name, status.Type, ctx.Err())
+		}
+		status.Polls++
+		status.Elapsed = Duration(time.Since(start))
+		status.update(res, uint16(rType), matchedAt)
+		if status.Done {
+			status.NextPoll = 0
+			onPoll(status)
+			return status, nil
+		}
+		next := status.nextInterval(opts)
+		status.NextPoll = Duration(next)
+		onPoll(status)
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("%s %s did not reach the expected state: %w", name, status.Type, ctx.Err())
+		case <-time.After(next):
+		}
+	}
+}
+
+// update sets the status of the servers from the result of a poll.
+func (s *WaitStatus) update(res *Result, rType uint16, matchedAt map[string]time.Duration) {
+	s.Servers = make([]*WaitServer, 0, len(res.Servers))
+	s.Done = len(res.Servers) > 0
+	for _, sr := range res.Servers {
+		ws := &WaitServer{Server: sr.Server, Values: []string{}}
+		s.Servers = append(s.Servers, ws)
+		if sr.Response == nil {
+			ws.Error = sr.Error
+			s.Done = false
+			delete(matchedAt, sr.Server)
+			continue
+		}
+		msg := sr.Response.msg
+		ws.Rcode, ws.Authoritative = sr.Response.Rcode, msg.Authoritative
+		ttl := uint32(0)
+		for _, rr := range msg.Answer {
+			if rr.Header().Rrtype != rType {
+				continue
+			}
+			ws.Values = append(ws.Values, waitValue(rr))
+			if ttl == 0 || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		if len(ws.Values) == 0 {
+			// The negative caching TTL, RFC 2308 section 5.
+			for _, rr := range msg.Ns {
+				if soa, ok := rr.(*dnslib.SOA); ok {
+					ttl = min(soa.Hdr.Ttl, soa.Minttl)
+				}
+			}
+		}
+		sort.Strings(ws.Values)
+		ws.TTL = ttl
+		ws.Matches = s.matches(msg.Rcode, ws.Values)
+		if !ws.Matches {
+			s.Done = false
+			delete(matchedAt, sr.Server)
+			continue
+		}
+		if _, ok := matchedAt[sr.Server]; !ok {
+			matchedAt[sr.Server] = time.Duration(s.Elapsed)
+		}
+		ws.MatchedAfter = Duration(matchedAt[sr.Server])
+	}
+}
+
+func (s *WaitStatus) matches(rcode int, values []string) bool {
+	if rcode != dnslib.RcodeSuccess && rcode != dnslib.RcodeNameError {
+		return false
+	}
+	if s.Absent {
+		return len(values) == 0
+	}
+	got := make(map[string]bool, len(values))
+	for _, v := range values {
+		got[normalizeValue(v)] = true
+	}
+	if len(got) != len(s.expect) {
+		return false
+	}
+	for v := range s.expect {
+		if !got[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// nextInterval returns the interval before the next poll: until the records cached by the servers that
+// do not match expire, bounded by opts.Interval and opts.MaxInterval.
+// Authoritative servers answer with their current records, their TTLs are ignored.
+func (s *WaitStatus) nextInterval(opts WaitOptions) time.Duration {
+	next := opts.Interval
+	if opts.MaxInterval <= opts.Interval {
+		return next
+	}
+	var expire time.Duration
+	for _, ws := range s.Servers {
+		if ws.Matches || ws.Error != "" || ws.Authoritative || ws.TTL == 0 {
+			continue
+		}
+		if d := time.Duration(ws.TTL) * time.Second; expire == 0 || d < expire {
+			expire = d
+		}
+	}
+	if expire > next {
+		next = min(expire, opts.MaxInterval)
+	}
+	return next
+}
+
+// Matching returns the number of servers matching the condition.
+func (s *WaitStatus) Matching() int {
+	n := 0
+	for _, ws := range s.Servers {
+		if ws.Matches {
+			n++
+		}
+	}
+	return n
+}
+
+// RenderText writes the condition, a line per server with its status and values, then a summary.
+func (s *WaitStatus) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	faint := color.New(color.Faint)
+	expected := "to be absent"
+	if !s.Absent {
+		expected = "to be " + strings.Join(s.Expect, " ")
+	}
+	color.New(color.Bold).Fprintf(buf, "Waiting for %s %s %s", s.Name, s.Type, expected)
+	buf.WriteString("\n")
+	width := 0
+	for _, ws := range s.Servers {
+		width = max(width, len(ws.Server))
+	}
+	for _, ws := range s.Servers {
+		fmt.Fprintf(buf, "%-*s  ", width, ws.Server)
+		switch {
+		case ws.Error != "":
+			color.New(color.FgRed).Fprint(buf, "error    ")
+			faint.Fprint(buf, "  "+ws.Error)
+		case ws.Matches:
+			color.New(color.FgGreen).Fprint(buf, "ok       ")
+			faint.Fprintf(buf, "  %s after %s", waitValues(ws), time.Duration(ws.MatchedAfter).Round(time.Second))
+		default:
+			color.New(color.FgYellow).Fprint(buf, "waiting  ")
+			fmt.Fprintf(buf, "  %s", waitValues(ws))
+			if ws.TTL > 0 {
+				faint.Fprintf(buf, "  TTL %s", FormatTTL(ws.TTL))
+			}
+		}
+		buf.WriteString("\n")
+	}
+	summary := fmt.Sprintf(
+		"%d/%d servers match after %s, poll #%d", s.Matching(), len(s.Servers),
+		time.Duration(s.Elapsed).Round(time.Second), s.Polls,
+	)
+	if !s.Done && s.NextPoll > 0 {
+		summary += ", next poll in " + time.Duration(s.NextPoll).Round(time.Second).String()
+	}
+	if s.Done {
+		color.New(color.FgGreen).Fprint(buf, summary)
+	} else {
+		faint.Fprint(buf, summary)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func waitValues(ws *WaitServer) string {
+	if len(ws.Values) == 0 {
+		return "no record (" + ws.Rcode + ")"
+	}
+	return strings.Join(ws.Values, " ")
+}
+
+// Header returns the columns of the wait status table.
+func (s *WaitStatus) Header() []string {
+	return []string{"server", "matches", "rcode", "values", "ttl", "matched_after", "error"}
+}
+
+// Rows returns the servers, one per row.
+func (s *WaitStatus) Rows() [][]string {
+	rows := make([][]string, 0, len(s.Servers))
+	for _, ws := range s.Servers {
+		after := ""
+		if ws.Matches {
+			after = time.Duration(ws.MatchedAfter).String()
+		}
+		rows = append(rows, []string{
+			ws.Server,
+			strconv.FormatBool(ws.Matches),
+			ws.Rcode,
+			strings.Join(ws.Values, " "),
+			strconv.FormatUint(uint64(ws.TTL), 10),
+			after,
+			ws.Error,
+		})
+	}
+	return rows
+}
+
+// AuthoritativeServers returns the addresses of the name servers of the zone of name, resolved with
+// the servers of the client.
+func (c *Client) AuthoritativeServers(ctx context.Context, name string) ([]string, error) {
+	zone := ""
+	for n := dnslib.Fqdn(name); zone == ""; n = parentName(n) {
+		resp, err := c.lookup(ctx, TypeSOA, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range append(append([]dnslib.RR{}, resp.msg.Answer...), resp.msg.Ns...) {
+			if soa, ok := rr.(*dnslib.SOA); ok && dnslib.IsSubDomain(soa.Hdr.Name, n) {
+				zone = dnslib.CanonicalName(soa.Hdr.Name)
+				break
+			}
+		}
+		if zone == "" && n == "." {
+			return nil, fmt.Errorf("cannot find the zone of %s", name)
+		}
+	}
+	resp, err := c.lookup(ctx, TypeNS, zone)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, rr := range resp.msg.Answer {
+		ns, ok := rr.(*dnslib.NS)
+		if !ok {
+			continue
+		}
+		addresses, err := c.resolveAddresses(ctx, ns.Ns)
+		if err != nil {
+			c.logger.With(zap.String("ns", ns.Ns), zap.Error(err)).Debug("Cannot resolve name server")
+			continue
+		}
+		servers = append(servers, addresses...)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("cannot resolve the name servers of %s", zone)
+	}
+	return servers, nil
+}
@@ -0,0 +1,85 @@
+package dns //nolint:testpackage
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dnslib "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// answerAfter returns a handler answering A queries with before, then with after once it answered n queries.
+func answerAfter(before, after string, n int32) dnslib.HandlerFunc {
+	var count atomic.Int32
+	return func(w dnslib.ResponseWriter, r *dnslib.Msg) {
+		ip := before
+		if count.Add(1) > n {
+			ip = after
+		}
+		answerA(ip, 0)(w, r)
+	}
+}
+
+func TestClient_Wait(t *testing.T) {
+	a := startServer(t, answerA("203.0.113.7", 0))
+	b := startServer(t, answerAfter("192.0.2.1", "203.0.113.7", 2))
+
+	client := NewClient(zap.NewNop(), WithServers(a, b), WithTimeouts(time.Second, time.Second))
+	polls := 0
+	status, err := client.Wait(context.Background(), TypeA, "example.com", WaitOptions{
+		Expect:   []string{"203.0.113.7"},
+		Interval: 10 * time.Millisecond,
+	}, func(s *WaitStatus) {
+		polls++
+		if !s.Done {
+			assert.Equal(t, 1, s.Matching())
+			assert.Equal(t, []string{"192.0.2.1"}, s.Servers[1].Values)
+		}
+	})
+	require.NoError(t, err)
+	assert.True(t, status.Done)
+	assert.Equal(t, 3, status.Polls)
+	assert.Equal(t, 3, polls)
+	assert.True(t, status.Servers[0].Matches)
+	assert.True(t, status.Servers[1].Matches)
+	assert.Greater(t, status.Servers[1].MatchedAfter, status.Servers[0].MatchedAfter)
+}
+
+func TestClient_Wait_timeout(t *testing.T) {
+	a := startServer(t, answerA("192.0.2.1", 0))
+
+	client := NewClient(zap.NewNop(), WithServers(a), WithTimeouts(time.Second, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	status, err := client.Wait(ctx, TypeA, "example.com", WaitOptions{Absent: true, Interval: 10 * time.Millisecond}, func(*WaitStatus) {})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, status.Done)
+	assert.Greater(t, status.Polls, 1)
+}
+
+func TestWaitStatus_nextInterval(t *testing.T) {
+	s := &WaitStatus{Servers: []*WaitServer{
+		{Server: "a", Matches: true, TTL: 10},
+		{Server: "b", TTL: 120},
+		{Server: "c", TTL: 30, Authoritative: true},
+		{Server: "d", TTL: 3600},
+	}}
+	assert.Equal(t, 5*time.Second, s.nextInterval(WaitOptions{Interval: 5 * time.Second}))
+	assert.Equal(t, time.Minute, s.nextInterval(WaitOptions{Interval: 5 * time.Second, MaxInterval: time.Minute}))
+	assert.Equal(t, 2*time.Minute, s.nextInterval(WaitOptions{Interval: 5 * time.Second, MaxInterval: time.Hour}))
+}
+
+func TestWaitStatus_matches(t *testing.T) {
+	s := &WaitStatus{expect: map[string]bool{"10 mail.example.com": true}}
+	assert.True(t, s.matches(dnslib.RcodeSuccess, []string{"10 Mail.Example.com."}))
+	assert.False(t, s.matches(dnslib.RcodeSuccess, []string{"10 mail.example.com.", "20 backup.example.com."}))
+	assert.False(t, s.matches(dnslib.RcodeServerFailure, []string{"10 mail.example.com."}))
+
+	s = &WaitStatus{Absent: true}
+	assert.True(t, s.matches(dnslib.RcodeNameError, []string{}))
+	assert.False(t, s.matches(dnslib.RcodeSuccess, []string{"192.0.2.1"}))
+}
@@ -0,0 +1,245 @@
+package dns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	dnslib "github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultZoneTTL is the TTL of the records of YAML zones without TTL.
+const defaultZoneTTL = 3600
+
+// Zone is the content of a zone file.
+type Zone struct {
+	// File is the name of the zone file, used in messages.
+	File string
+	// Origin is the name of the zone, eg: "example.com.".
+	Origin  string
+	Records []dnslib.RR
+}
+
+// yamlZone is the YAML format of zone files:
+//
+//	origin: example.com.
+//	ttl: 300
+//	records:
+//	  - name: www
+//	    type: A
+//	    data: [192.0.2.1, 192.0.2.2]
+//	  - name: api
+//	    type: CNAME
+//	    ttl: 60
+//	    data: www
+type yamlZone struct {
+	Origin  string `yaml:"origin"`
+	TTL     uint32 `yaml:"ttl"`
+	Records []struct {
+		Name string      `yaml:"name"`
+		Type string      `yaml:"type"`
+		TTL  uint32      `yaml:"ttl"`
+		Data yamlStrings `yaml:"data"`
+	} `yaml:"records"`
+}
+
+// yamlStrings is a list of strings that can also be given as a single string.
+type yamlStrings []string
+
+// UnmarshalYAML decodes a string or a list of strings.
+func (s *yamlStrings) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = []string{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// LoadZoneFile reads a zone file, in the YAML format if its extension is .yaml or .yml, in the BIND
+// format otherwise.
+func LoadZoneFile(path string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open zone file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAMLZone(f, path)
+	default:
+		return ParseZone(f, path, "")
+	}
+}
+
+// ParseZone parses a zone in the BIND format, file being used in errors. Without origin, the origin is
+// the one set by $ORIGIN, or the name of the SOA record.
+func ParseZone(r io.Reader, file, origin string) (*Zone, error) {
+	if origin != "" {
+		origin = dnslib.Fqdn(origin)
+	}
+	zp := dnslib.NewZoneParser(r, origin, file)
+	zone := &Zone{File: file, Origin: origin}
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if soa, isSOA := rr.(*dnslib.SOA); isSOA && zone.Origin == "" {
+			zone.Origin = soa.Hdr.Name
+		}
+		zone.Records = append(zone.Records, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("invalid zone file %s: %w", file, err)
+	}
+	if zone.Origin == "" && len(zone.Records) > 0 {
+		zone.Origin = zone.Records[0].Header().Name
+	}
+	zone.Origin = dnslib.CanonicalName(zone.Origin)
+	return zone, nil
+}
+
+// ParseYAMLZone parses a zone in the YAML format, file being used in errors. Record names are relative
+// to the origin, "@" being the origin, unless they end with a dot.
+func ParseYAMLZone(r io.Reader, file string) (*Zone, error) {
+	var y yamlZone
+	if err := yaml.NewDecoder(r).Decode(&y); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid zone file %s: %w", file, err)
+	}
+	zone := &Zone{File: file, Origin: "."}
+	if y.Origin != "" {
+		zone.Origin = dnslib.CanonicalName(y.Origin)
+	}
+	ttl := y.TTL
+	if ttl == 0 {
+		ttl = defaultZoneTTL
+	}
+	for i, rec := range y.Records {
+		name := zoneName(rec.Name, zone.Origin)
+		rType := strings.ToUpper(rec.Type)
+		if _, ok := dnslib.StringToType[rType]; !ok {
+			return nil, fmt.Errorf("invalid zone file %s: record %d: unknown type %q", file, i+1, rec.Type)
+		}
+		if len(rec.Data) == 0 {
+			return nil, fmt.Errorf("invalid zone file %s: record %d: no data", file, i+1)
+		}
+		recTTL := rec.TTL
+		if recTTL == 0 {
+			recTTL = ttl
+		}
+		for _, data := range rec.Data {
+			// Relative names in the data are relative to the origin, as in BIND zone files.
+			zp := dnslib.NewZoneParser(strings.NewReader(fmt.Sprintf("%s %d IN %s %s", name, recTTL, rType, data)), zone.Origin, file)
+			rr, ok := zp.Next()
+			if !ok {
+				err := zp.Err()
+				if err == nil {
+					err = errors.New("no record")
+				}
+				return nil, fmt.Errorf("invalid zone file %s: record %d: invalid data %q: %w", file, i+1, data, err)
+			}
+			zone.Records = append(zone.Records, rr)
+		}
+	}
+	return zone, nil
+}
+
+// zoneName returns the absolute name of a record name relative to origin.
+func zoneName(name, origin string) string {
+	switch {
+	case name == "" || name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return dnslib.CanonicalName(name)
+	case origin == ".":
+		return dnslib.CanonicalName(name + ".")
+	default:
+		return dnslib.CanonicalName(name + "." + origin)
+	}
+}
+
+// relativeName returns name relative to origin, "@" for the origin, or name if it is outside of origin.
+func relativeName(name, origin string) string {
+	name = dnslib.CanonicalName(name)
+	switch {
+	case name == origin:
+		return "@"
+	case origin != "." && dnslib.IsSubDomain(origin, name):
+		return strings.TrimSuffix(name, "."+origin)
+	default:
+		return name
+	}
+}
+
+// zoneRecordOrder returns the order of rr in a formatted zone: the SOA record, the NS records of the
+// origin, then the other records in the canonical order of their names.
+func zoneRecordOrder(a, b dnslib.RR, origin string) bool {
+	rank := func(rr dnslib.RR) int {
+		switch {
+		case rr.Header().Rrtype == dnslib.TypeSOA:
+			return 0
+		case rr.Header().Rrtype == dnslib.TypeNS && dnslib.CanonicalName(rr.Header().Name) == origin:
+			return 1
+		default:
+			return 2
+		}
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra < rb
+	}
+	if c := canonicalCompare(a.Header().Name, b.Header().Name); c != 0 {
+		return c < 0
+	}
+	if a.Header().Rrtype != b.Header().Rrtype {
+		return a.Header().Rrtype < b.Header().Rrtype
+	}
+	return rdata(a) < rdata(b)
+}
+
+// FormatZone writes zone in the BIND format, normalized: $ORIGIN and $TTL directives, the SOA and NS
+// records of the origin first, then the records sorted by name and type, with names relative to the
+// origin, TTLs only when they differ from the most common one, and aligned columns.
+func FormatZone(w io.Writer, zone *Zone) error {
+	records := append([]dnslib.RR{}, zone.Records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		return zoneRecordOrder(records[i], records[j], zone.Origin)
+	})
+	ttls := make(map[uint32]int)
+	defaultTTL := uint32(0)
+	for _, rr := range records {
+		ttl := rr.Header().Ttl
+		ttls[ttl]++
+		if ttls[ttl] > ttls[defaultTTL] || (ttls[ttl] == ttls[defaultTTL] && ttl < defaultTTL) {
+			defaultTTL = ttl
+		}
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "$ORIGIN %s\n$TTL %d\n", zone.Origin, defaultTTL)
+	tw := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	for _, rr := range records {
+		h := rr.Header()
+		ttl := ""
+		if h.Ttl != defaultTTL {
+			ttl = strconv.FormatUint(uint64(h.Ttl), 10)
+		}
+		data := strings.ReplaceAll(strings.TrimSpace(rdata(rr)), "\t", " ")
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\n",
+			relativeName(h.Name, zone.Origin), ttl, dnslib.ClassToString[h.Class], dnslib.TypeToString[h.Rrtype], data,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,57 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYAMLZone(t *testing.T) {
+	zone, err := ParseYAMLZone(strings.NewReader(testYAMLZone), "corp.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "corp.", zone.Origin)
+	require.Len(t, zone.Records, 3)
+	assert.Equal(t, "intranet.corp.\t60\tIN\tA\t198.51.100.2", zone.Records[1].String())
+	assert.Equal(t, "proxy.example.org.\t60\tIN\tCNAME\tupstream.example.org.", zone.Records[2].String())
+
+	_, err = ParseYAMLZone(strings.NewReader("records: [{name: www, type: BOGUS, data: x}]"), "bad.yaml")
+	assert.ErrorContains(t, err, `record 1: unknown type "BOGUS"`)
+	_, err = ParseYAMLZone(strings.NewReader("records: [{name: www, type: A, data: not-an-ip}]"), "bad.yaml")
+	assert.ErrorContains(t, err, `record 1: invalid data "not-an-ip"`)
+}
+
+func TestFormatZone(t *testing.T) {
+	zone, err := ParseZone(strings.NewReader(`$ORIGIN example.test.
+$TTL 3600
+www      60 IN A   192.0.2.1
+@        IN SOA ns1 hostmaster 1 3600 600 86400 60
+ns1      IN A   192.0.2.53
+@        IN NS  ns1
+mail.example.test. IN A 192.0.2.25
+@        IN MX  10 mail
+`), "example.test.zone", "")
+	require.NoError(t, err)
+	assert.Equal(t, "example.test.", zone.Origin)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, FormatZone(buf, zone))
+	assert.Equal(t, `$ORIGIN example.test.
+$TTL 3600
+@         IN  SOA  ns1.example.test. hostmaster.example.test. 1 3600 600 86400 60
+@         IN  NS   ns1.example.test.
+@         IN  MX   10 mail.example.test.
+mail      IN  A    192.0.2.25
+ns1       IN  A    192.0.2.53
+www   60  IN  A    192.0.2.1
+`, buf.String())
+
+	// Formatting is idempotent.
+	formatted, err := ParseZone(bytes.NewReader(buf.Bytes()), "formatted.zone", "")
+	require.NoError(t, err)
+	again := &bytes.Buffer{}
+	require.NoError(t, FormatZone(again, formatted))
+	assert.Equal(t, buf.String(), again.String())
+}
@@ -0,0 +1,169 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// Changes of the records between two versions of a zone.
+const (
+	// ZoneAdded is a record only in the new version.
+	ZoneAdded = "added"
+	// ZoneRemoved is a record only in the old version.
+	ZoneRemoved = "removed"
+	// ZoneTTLChanged is a record in both versions, with different TTLs.
+	ZoneTTLChanged = "ttl"
+)
+
+// ZoneDiff is the difference between the records of two versions of a zone.
+type ZoneDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []*ZoneChange `json:"changes"`
+}
+
+// ZoneChange is a record added, removed, or whose TTL changed.
+type ZoneChange struct {
+	// Change is "added", "removed" or "ttl".
+	Change string `json:"change"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	TTL    uint32 `json:"ttl"`
+	// OldTTL is the TTL of the record in the old version, if it changed.
+	OldTTL uint32 `json:"old_ttl,omitempty"`
+	Data   string `json:"data"`
+
+	rr dnslib.RR
+}
+
+// zoneRecordKey identifies a record regardless of its TTL and of the case of its name.
+func zoneRecordKey(rr dnslib.RR) string {
+	h := rr.Header()
+	return fmt.Sprintf(
+		"%s %s %s %s",
+		dnslib.CanonicalName(h.Name), dnslib.ClassToString[h.Class], dnslib.TypeToString[h.Rrtype], strings.TrimSpace(rdata(rr)),
+	)
+}
+
+func newZoneChange(change string, rr dnslib.RR) *ZoneChange {
+	h := rr.Header()
+	return &ZoneChange{
+		Change: change,
+		Name:   dnslib.CanonicalName(h.Name),
+		Type:   dnslib.TypeToString[h.Rrtype],
+		TTL:    h.Ttl,
+		Data:   strings.TrimSpace(rdata(rr)),
+		rr:     rr,
+	}
+}
+
+// DiffZones returns the records added to, removed from, and whose TTL changed between from and to, in
+// the order of their names.
+func DiffZones(from, to *Zone) *ZoneDiff {
+	diff := &ZoneDiff{From: from.File, To: to.File, Changes: []*ZoneChange{}}
+	old := make(map[string]dnslib.RR, len(from.Records))
+	for _, rr := range from.Records {
+		old[zoneRecordKey(rr)] = rr
+	}
+	seen := make(map[string]bool, len(to.Records))
+	for _, rr := range to.Records {
+		key := zoneRecordKey(rr)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		prev, ok := old[key]
+		switch {
+		case !ok:
+			diff.Changes = append(diff.Changes, newZoneChange(ZoneAdded, rr))
+		case prev.Header().Ttl != rr.Header().Ttl:
+			c := newZoneChange(ZoneTTLChanged, rr)
+			c.OldTTL = prev.Header().Ttl
+			diff.Changes = append(diff.Changes, c)
+		}
+	}
+	for key, rr := range old {
+		if !seen[key] {
+			diff.Changes = append(diff.Changes, newZoneChange(ZoneRemoved, rr))
+		}
+	}
+	origin := to.Origin
+	// Removed records before the added ones of the same set.
+	rank := map[string]int{ZoneRemoved: 0, ZoneTTLChanged: 1, ZoneAdded: 2}
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if a.Name != b.Name || a.Type != b.Type {
+			return zoneRecordOrder(a.rr, b.rr, origin)
+		}
+		if a.Change != b.Change {
+			return rank[a.Change] < rank[b.Change]
+		}
+		return a.Data < b.Data
+	})
+	return diff
+}
+
+// Counts returns the number of changes by kind.
+func (d *ZoneDiff) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, c := range d.Changes {
+		counts[c.Change]++
+	}
+	return counts
+}
+
+// RenderText writes the changes like a unified diff of records, then their number.
+func (d *ZoneDiff) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	color.New(color.Bold).Fprintf(buf, "--- %s", d.From)
+	buf.WriteString("\n")
+	color.New(color.Bold).Fprintf(buf, "+++ %s", d.To)
+	buf.WriteString("\n")
+	for _, c := range d.Changes {
+		switch c.Change {
+		case ZoneAdded:
+			color.New(color.FgGreen).Fprintf(buf, "+ %s %d IN %s %s", c.Name, c.TTL, c.Type, c.Data)
+		case ZoneRemoved:
+			color.New(color.FgRed).Fprintf(buf, "- %s %d IN %s %s", c.Name, c.TTL, c.Type, c.Data)
+		default:
+			color.New(color.FgYellow).Fprintf(buf, "~ %s %d -> %d IN %s %s", c.Name, c.OldTTL, c.TTL, c.Type, c.Data)
+		}
+		buf.WriteString("\n")
+	}
+	counts := d.Counts()
+	summary := "no difference"
+	if len(d.Changes) > 0 {
+		summary = fmt.Sprintf(
+			"%d added, %d removed, %d TTL changed", counts[ZoneAdded], counts[ZoneRemoved], counts[ZoneTTLChanged],
+		)
+	}
+	color.New(color.Faint).Fprint(buf, summary)
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Header returns the columns of the zone diff table.
+func (d *ZoneDiff) Header() []string {
+	return []string{"change", "name", "type", "ttl", "old_ttl", "data"}
+}
+
+// Rows returns the changes, one per row.
+func (d *ZoneDiff) Rows() [][]string {
+	rows := make([][]string, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		oldTTL := ""
+		if c.Change == ZoneTTLChanged {
+			oldTTL = strconv.FormatUint(uint64(c.OldTTL), 10)
+		}
+		rows = append(rows, []string{c.Change, c.Name, c.Type, strconv.FormatUint(uint64(c.TTL), 10), oldTTL, c.Data})
+	}
+	return rows
+}
@@ -0,0 +1,46 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffZones(t *testing.T) {
+	from, err := ParseZone(strings.NewReader(`$TTL 300
+@    IN SOA ns1 hostmaster 1 3600 600 86400 60
+www  IN A   192.0.2.1
+www  IN A   192.0.2.2
+api  IN A   192.0.2.3
+`), "old.zone", "example.test")
+	require.NoError(t, err)
+	to, err := ParseZone(strings.NewReader(`$TTL 300
+@       IN SOA ns1 hostmaster 2 3600 600 86400 60
+WWW     IN A   192.0.2.2
+www     IN A   192.0.2.4
+api 60  IN A   192.0.2.3
+`), "new.zone", "example.test")
+	require.NoError(t, err)
+
+	diff := DiffZones(from, to)
+	assert.Equal(t, "old.zone", diff.From)
+	assert.Equal(t, "new.zone", diff.To)
+	assert.Equal(t, map[string]int{ZoneAdded: 2, ZoneRemoved: 2, ZoneTTLChanged: 1}, diff.Counts())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, diff.RenderText(buf))
+	assert.Equal(t, `--- old.zone
++++ new.zone
+- example.test. 300 IN SOA ns1.example.test. hostmaster.example.test. 1 3600 600 86400 60
++ example.test. 300 IN SOA ns1.example.test. hostmaster.example.test. 2 3600 600 86400 60
+~ api.example.test. 300 -> 60 IN A 192.0.2.3
+- www.example.test. 300 IN A 192.0.2.1
++ www.example.test. 300 IN A 192.0.2.4
+2 added, 2 removed, 1 TTL changed
+`, buf.String())
+
+	assert.Empty(t, DiffZones(from, from).Changes)
+}
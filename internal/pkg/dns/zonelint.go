@@ -0,0 +1,378 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	dnslib "github.com/miekg/dns"
+)
+
+// Severities of zone lint findings.
+const (
+	// ZoneSeverityError is a finding that breaks resolution or that DNS providers reject.
+	ZoneSeverityError = "error"
+	// ZoneSeverityWarning is a finding that is likely a mistake.
+	ZoneSeverityWarning = "warning"
+)
+
+// Checks of zone lint findings.
+const (
+	ZoneCheckSyntax         = "syntax"
+	ZoneCheckSOA            = "soa"
+	ZoneCheckNS             = "ns"
+	ZoneCheckCNAMEApex      = "cname-apex"
+	ZoneCheckCNAMEOtherData = "cname-other-data"
+	ZoneCheckMissingGlue    = "missing-glue"
+	ZoneCheckDanglingTarget = "dangling-target"
+	ZoneCheckTargetCNAME    = "target-cname"
+	ZoneCheckTTLMismatch    = "ttl-mismatch"
+	ZoneCheckOutOfZone      = "out-of-zone"
+	ZoneCheckSerial         = "serial"
+)
+
+// ZoneLint is the result of the lint of a zone file.
+type ZoneLint struct {
+	File     string         `json:"file"`
+	Origin   string         `json:"origin"`
+	Records  int            `json:"records"`
+	Findings []*ZoneFinding `json:"findings"`
+}
+
+// ZoneFinding is an issue found in a zone file.
+type ZoneFinding struct {
+	// Severity is "error" or "warning".
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Errors returns the number of findings with the error severity.
+func (l *ZoneLint) Errors() int {
+	n := 0
+	for _, f := range l.Findings {
+		if f.Severity == ZoneSeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// Warnings returns the number of findings with the warning severity.
+func (l *ZoneLint) Warnings() int {
+	return len(l.Findings) - l.Errors()
+}
+
+// zoneLinter checks the records of a zone.
+type zoneLinter struct {
+	lint   *ZoneLint
+	zone   *Zone
+	sets   []*rrset
+	byName map[string][]*rrset
+	// delegations are the names below the origin with NS records.
+	delegations map[string]bool
+}
+
+func (l *zoneLinter) add(severity, check, name string, rType uint16, format string, a ...any) {
+	f := &ZoneFinding{Severity: severity, Check: check, Name: name, Message: fmt.Sprintf(format, a...)}
+	if rType != dnslib.TypeNone {
+		f.Type = dnslib.TypeToString[rType]
+	}
+	l.lint.Findings = append(l.lint.Findings, f)
+}
+
+// LintZone parses a zone in the BIND format and checks its records: syntax errors, SOA and NS records
+// of the origin, CNAME records at the origin or with other data, NS records without address records
+// (glue), CNAME, MX, SRV and NS targets inside the zone that do not exist, MX, SRV and NS targets that
+// are CNAME records, records of a set with different TTLs and records outside of the zone.
+// With a previous version of the zone, the SOA serial must have been incremented if the records changed.
+func LintZone(r io.Reader, file, origin string, previous *Zone) *ZoneLint {
+	lint := &ZoneLint{File: file, Findings: []*ZoneFinding{}}
+	zone, err := ParseZone(r, file, origin)
+	if err != nil {
+		lint.Findings = append(lint.Findings, &ZoneFinding{
+			Severity: ZoneSeverityError,
+			Check:    ZoneCheckSyntax,
+			Message:  strings.TrimPrefix(err.Error(), "invalid zone file "+file+": "),
+		})
+		return lint
+	}
+	lint.Origin, lint.Records = zone.Origin, len(zone.Records)
+	l := &zoneLinter{
+		lint:        lint,
+		zone:        zone,
+		sets:        rrsets(zone.Records),
+		byName:      make(map[string][]*rrset),
+		delegations: make(map[string]bool),
+	}
+	for _, set := range l.sets {
+		l.byName[set.name] = append(l.byName[set.name], set)
+		if set.rType == dnslib.TypeNS && set.name != zone.Origin {
+			l.delegations[set.name] = true
+		}
+	}
+	l.checkApex()
+	for _, set := range l.sets {
+		l.checkSet(set)
+	}
+	if previous != nil {
+		l.checkSerial(previous)
+	}
+	sort.SliceStable(lint.Findings, func(i, j int) bool {
+		return canonicalCompare(lint.Findings[i].Name, lint.Findings[j].Name) < 0
+	})
+	return lint
+}
+
+func (l *zoneLinter) find(name string, rType uint16) *rrset {
+	for _, set := range l.byName[name] {
+		if set.rType == rType && len(set.rrs) > 0 {
+			return set
+		}
+	}
+	return nil
+}
+
+// soa returns the SOA record of the origin, if any.
+func (l *zoneLinter) soa() *dnslib.SOA {
+	if set := l.find(l.zone.Origin, dnslib.TypeSOA); set != nil {
+		return set.rrs[0].(*dnslib.SOA)
+	}
+	return nil
+}
+
+func (l *zoneLinter) checkApex() {
+	origin := l.zone.Origin
+	soas := 0
+	for _, set := range l.sets {
+		if set.rType == dnslib.TypeSOA {
+			soas += len(set.rrs)
+			if set.name != origin {
+				l.add(ZoneSeverityError, ZoneCheckSOA, set.name, dnslib.TypeSOA, "SOA record outside of the origin %s", origin)
+			}
+		}
+	}
+	switch {
+	case soas == 0:
+		l.add(ZoneSeverityError, ZoneCheckSOA, origin, dnslib.TypeSOA, "no SOA record")
+	case soas > 1:
+		l.add(ZoneSeverityError, ZoneCheckSOA, origin, dnslib.TypeSOA, "%d SOA records, a zone has one", soas)
+	}
+	if l.find(origin, dnslib.TypeNS) == nil {
+		l.add(ZoneSeverityWarning, ZoneCheckNS, origin, dnslib.TypeNS, "no NS record at the origin")
+	}
+}
+
+// delegated returns true if name is at or below a delegation, its records being in another zone.
+func (l *zoneLinter) delegated(name string) bool {
+	for n := name; n != l.zone.Origin && n != "."; n = parentName(n) {
+		if l.delegations[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// exists returns true if name has records in the zone, or matches a wildcard.
+func (l *zoneLinter) exists(name string) bool {
+	if len(l.byName[name]) > 0 {
+		return true
+	}
+	for p := parentName(name); dnslib.IsSubDomain(l.zone.Origin, p); p = parentName(p) {
+		if len(l.byName["*."+p]) > 0 {
+			return true
+		}
+		if len(l.byName[p]) > 0 || p == "." {
+			return false
+		}
+	}
+	return false
+}
+
+func (l *zoneLinter) checkSet(set *rrset) {
+	origin := l.zone.Origin
+	if len(set.rrs) == 0 {
+		return
+	}
+	if !dnslib.IsSubDomain(origin, set.name) {
+		l.add(ZoneSeverityWarning, ZoneCheckOutOfZone, set.name, set.rType, "record outside of the zone %s, ignored by servers", origin)
+		return
+	}
+	for _, rr := range set.rrs[1:] {
+		if rr.Header().Ttl != set.rrs[0].Header().Ttl {
+			l.add(
+				ZoneSeverityWarning, ZoneCheckTTLMismatch, set.name, set.rType,
+				"records of the set have different TTLs (%d and %d), deprecated by RFC 2181 section 5.2", set.rrs[0].Header().Ttl, rr.Header().Ttl,
+			)
+			break
+		}
+	}
+	switch set.rType {
+	case dnslib.TypeCNAME:
+		l.checkCNAME(set)
+	case dnslib.TypeNS:
+		for _, rr := range set.rrs {
+			l.checkNS(set.name, rr.(*dnslib.NS).Ns)
+		}
+	case dnslib.TypeMX:
+		for _, rr := range set.rrs {
+			l.checkTarget(set, rr.(*dnslib.MX).Mx)
+		}
+	case dnslib.TypeSRV:
+		for _, rr := range set.rrs {
+			l.checkTarget(set, rr.(*dnslib.SRV).Target)
+		}
+	}
+}
+
+func (l *zoneLinter) checkCNAME(set *rrset) {
+	if set.name == l.zone.Origin {
+		l.add(
+			ZoneSeverityError, ZoneCheckCNAMEApex, set.name, dnslib.TypeCNAME,
+			"CNAME record at the origin, it cannot coexist with the SOA and NS records",
+		)
+	}
+	if len(set.rrs) > 1 {
+		l.add(ZoneSeverityError, ZoneCheckCNAMEOtherData, set.name, dnslib.TypeCNAME, "%d CNAME records, a name has at most one", len(set.rrs))
+	}
+	var others []string
+	for _, other := range l.byName[set.name] {
+		switch other.rType {
+		case dnslib.TypeCNAME, dnslib.TypeRRSIG, dnslib.TypeNSEC:
+		default:
+			if len(other.rrs) > 0 {
+				others = append(others, dnslib.TypeToString[other.rType])
+			}
+		}
+	}
+	if len(others) > 0 && set.name != l.zone.Origin {
+		l.add(
+			ZoneSeverityError, ZoneCheckCNAMEOtherData, set.name, dnslib.TypeCNAME,
+			"CNAME record with other data: %s", strings.Join(others, ", "),
+		)
+	}
+	target := dnslib.CanonicalName(set.rrs[0].(*dnslib.CNAME).Target)
+	if dnslib.IsSubDomain(l.zone.Origin, target) && !l.delegated(target) && !l.exists(target) {
+		l.add(ZoneSeverityWarning, ZoneCheckDanglingTarget, set.name, dnslib.TypeCNAME, "target %s does not exist in the zone", target)
+	}
+}
+
+// checkNS checks that the name server target of the NS records of name has addresses in the zone if it
+// is inside the zone: glue records for delegations.
+func (l *zoneLinter) checkNS(name, target string) {
+	target = dnslib.CanonicalName(target)
+	if !dnslib.IsSubDomain(l.zone.Origin, target) {
+		return
+	}
+	if l.find(target, dnslib.TypeA) == nil && l.find(target, dnslib.TypeAAAA) == nil {
+		l.add(
+			ZoneSeverityError, ZoneCheckMissingGlue, name, dnslib.TypeNS,
+			"name server %s is inside the zone and has no A or AAAA record", target,
+		)
+		return
+	}
+	if l.find(target, dnslib.TypeCNAME) != nil {
+		l.add(ZoneSeverityWarning, ZoneCheckTargetCNAME, name, dnslib.TypeNS, "name server %s is a CNAME record", target)
+	}
+}
+
+// checkTarget checks the target of a MX or SRV record: it must exist if inside the zone, and not be a CNAME.
+func (l *zoneLinter) checkTarget(set *rrset, target string) {
+	target = dnslib.CanonicalName(target)
+	// "." is the null MX (RFC 7505) or the SRV record of an unavailable service.
+	if target == "." || !dnslib.IsSubDomain(l.zone.Origin, target) || l.delegated(target) {
+		return
+	}
+	if !l.exists(target) {
+		l.add(ZoneSeverityWarning, ZoneCheckDanglingTarget, set.name, set.rType, "target %s does not exist in the zone", target)
+		return
+	}
+	if l.find(target, dnslib.TypeCNAME) != nil {
+		l.add(
+			ZoneSeverityWarning, ZoneCheckTargetCNAME, set.name, set.rType,
+			"target %s is a CNAME record, it must have address records (RFC 2181 section 10.3)", target,
+		)
+	}
+}
+
+// checkSerial checks that the SOA serial has been incremented, in serial number arithmetic (RFC 1982),
+// if the records changed since the previous version.
+func (l *zoneLinter) checkSerial(previous *Zone) {
+	soa := l.soa()
+	var prev *dnslib.SOA
+	for _, rr := range previous.Records {
+		if s, ok := rr.(*dnslib.SOA); ok {
+			prev = s
+			break
+		}
+	}
+	if soa == nil || prev == nil {
+		return
+	}
+	changed := false
+	for _, c := range DiffZones(previous, l.zone).Changes {
+		if c.Type != "SOA" {
+			changed = true
+			break
+		}
+	}
+	diff := int32(soa.Serial - prev.Serial)
+	switch {
+	case diff < 0:
+		l.add(
+			ZoneSeverityError, ZoneCheckSerial, l.zone.Origin, dnslib.TypeSOA,
+			"serial %d is lower than the previous one %d, secondary servers will not transfer the zone", soa.Serial, prev.Serial,
+		)
+	case diff == 0 && changed:
+		l.add(
+			ZoneSeverityError, ZoneCheckSerial, l.zone.Origin, dnslib.TypeSOA,
+			"serial %d has not been incremented while the records changed", soa.Serial,
+		)
+	}
+}
+
+// RenderText writes a line per finding, then the number of errors and warnings.
+func (l *ZoneLint) RenderText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	for _, f := range l.Findings {
+		fmt.Fprintf(buf, "%s: ", l.File)
+		if f.Severity == ZoneSeverityError {
+			color.New(color.FgRed).Fprintf(buf, "%-7s", f.Severity)
+		} else {
+			color.New(color.FgYellow).Fprintf(buf, "%-7s", f.Severity)
+		}
+		color.New(color.Faint).Fprintf(buf, " [%s]", f.Check)
+		if f.Name != "" {
+			buf.WriteString(" " + strings.TrimSpace(f.Name+" "+f.Type))
+		}
+		buf.WriteString(": " + f.Message + "\n")
+	}
+	summary := fmt.Sprintf("%s: %d records, %d errors, %d warnings", l.File, l.Records, l.Errors(), l.Warnings())
+	if len(l.Findings) == 0 {
+		color.New(color.FgGreen).Fprint(buf, summary)
+	} else {
+		color.New(color.Faint).Fprint(buf, summary)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Header returns the columns of the zone lint table.
+func (l *ZoneLint) Header() []string {
+	return []string{"file", "severity", "check", "name", "type", "message"}
+}
+
+// Rows returns the findings, one per row.
+func (l *ZoneLint) Rows() [][]string {
+	rows := make([][]string, 0, len(l.Findings))
+	for _, f := range l.Findings {
+		rows = append(rows, []string{l.File, f.Severity, f.Check, f.Name, f.Type, f.Message})
+	}
+	return rows
+}
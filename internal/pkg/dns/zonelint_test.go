@@ -0,0 +1,96 @@
+package dns //nolint:testpackage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLintZone = `$ORIGIN example.test.
+$TTL 300
+@          IN SOA   ns1 hostmaster 2024010102 3600 600 86400 60
+@          IN NS    ns1
+@          IN NS    ns2
+ns1        IN A     192.0.2.53
+@          IN MX    10 mail
+@          IN MX    20 alias
+alias      IN CNAME www
+www        IN A     192.0.2.1
+www   600  IN A     192.0.2.2
+web        IN CNAME nowhere
+web        IN TXT   "other data"
+sub        IN NS    ns.sub
+_sip._tcp  IN SRV   0 5 5060 sip.sub
+other.org. IN A     192.0.2.9
+`
+
+func lintChecks(lint *ZoneLint) []string {
+	var checks []string
+	for _, f := range lint.Findings {
+		checks = append(checks, f.Severity+" "+f.Check+" "+f.Name+" "+f.Type)
+	}
+	return checks
+}
+
+func TestLintZone(t *testing.T) {
+	lint := LintZone(strings.NewReader(testLintZone), "example.test.zone", "", nil)
+	assert.Equal(t, "example.test.", lint.Origin)
+	assert.Equal(t, 14, lint.Records)
+	assert.Equal(t, []string{
+		"warning out-of-zone other.org. A",
+		"error missing-glue example.test. NS",
+		"warning dangling-target example.test. MX",
+		"warning target-cname example.test. MX",
+		"error missing-glue sub.example.test. NS",
+		"error cname-other-data web.example.test. CNAME",
+		"warning dangling-target web.example.test. CNAME",
+		"warning ttl-mismatch www.example.test. A",
+	}, lintChecks(lint))
+	assert.Equal(t, 3, lint.Errors())
+	assert.Equal(t, 5, lint.Warnings())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, lint.RenderText(buf))
+	assert.Contains(t, buf.String(), "example.test.zone: error   [missing-glue] example.test. NS: name server ns2.example.test. is inside the zone and has no A or AAAA record\n")
+	assert.Contains(t, buf.String(), "example.test.zone: 14 records, 3 errors, 5 warnings\n")
+}
+
+func TestLintZone_apex(t *testing.T) {
+	lint := LintZone(strings.NewReader("@ 300 IN CNAME example.org.\n"), "apex.zone", "example.test", nil)
+	assert.Equal(t, []string{
+		"error soa example.test. SOA",
+		"warning ns example.test. NS",
+		"error cname-apex example.test. CNAME",
+	}, lintChecks(lint))
+}
+
+func TestLintZone_syntax(t *testing.T) {
+	lint := LintZone(strings.NewReader("$ORIGIN example.test.\nwww 300 IN A not-an-ip\n"), "bad.zone", "", nil)
+	require.Len(t, lint.Findings, 1)
+	assert.Equal(t, ZoneCheckSyntax, lint.Findings[0].Check)
+	assert.Contains(t, lint.Findings[0].Message, "line: 2")
+}
+
+func TestLintZone_serial(t *testing.T) {
+	previous, err := ParseZone(strings.NewReader(testLintZone), "HEAD:example.test.zone", "")
+	require.NoError(t, err)
+
+	// Unchanged.
+	lint := LintZone(strings.NewReader(testLintZone), "example.test.zone", "", previous)
+	assert.NotContains(t, lintChecks(lint), "error serial example.test. SOA")
+
+	changed := strings.Replace(testLintZone, "192.0.2.1", "192.0.2.10", 1)
+	lint = LintZone(strings.NewReader(changed), "example.test.zone", "", previous)
+	assert.Contains(t, lintChecks(lint), "error serial example.test. SOA")
+
+	changed = strings.Replace(changed, "2024010102", "2024010103", 1)
+	lint = LintZone(strings.NewReader(changed), "example.test.zone", "", previous)
+	assert.NotContains(t, lintChecks(lint), "error serial example.test. SOA")
+
+	lowered := strings.Replace(testLintZone, "2024010102", "2024010101", 1)
+	lint = LintZone(strings.NewReader(lowered), "example.test.zone", "", previous)
+	assert.Contains(t, lintChecks(lint), "error serial example.test. SOA")
+}
@@ -0,0 +1,192 @@
+// Package dsakerr handles dsak typed errors, their categories and the matching exit codes.
+package dsakerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os/exec"
+	"time"
+)
+
+// Category is the category of an error.
+type Category string
+
+const (
+	// CategoryGeneric is the category of errors not matching any other category.
+	CategoryGeneric Category = "error"
+	// CategoryUsage is the category of invalid command lines, arguments or configuration values.
+	CategoryUsage Category = "usage"
+	// CategoryNetwork is the category of network failures, eg: unreachable or refusing hosts.
+	CategoryNetwork Category = "network"
+	// CategoryTimeout is the category of commands that did not complete in time.
+	CategoryTimeout Category = "timeout"
+	// CategoryValidation is the category of checks that ran but failed, eg: an expiring certificate.
+	CategoryValidation Category = "validation"
+	// CategoryNotFound is the category of missing things, eg: a file, a domain or a configuration value.
+	CategoryNotFound Category = "notfound"
+)
+
+var exitCodes = map[Category]int{
+	CategoryGeneric:    1,
+	CategoryUsage:      2,
+	CategoryNetwork:    3,
+	CategoryTimeout:    4,
+	CategoryValidation: 5,
+	CategoryNotFound:   6,
+}
+
+// Error is an error with a category.
+type Error struct {
+	Category Category
+	Err      error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given category with the given message.
+func New(category Category, message string) error {
+	return &Error{Category: category, Err: errors.New(message)}
+}
+
+// Errorf returns an error of the given category, formatted like fmt.Errorf.
+func Errorf(category Category, format string, a ...any) error {
+	return &Error{Category: category, Err: fmt.Errorf(format, a...)}
+}
+
+// Wrap sets the category of err, nil if err is nil.
+func Wrap(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Err: err}
+}
+
+// Phase is a phase of a network operation, used to report which timeout expired.
+type Phase string
+
+const (
+	// PhaseConnect is the connection phase: dial and TLS handshake.
+	PhaseConnect Phase = "connect"
+	// PhaseRead is the time waiting for data from the server once connected.
+	PhaseRead Phase = "read"
+	// PhaseTotal is the whole command.
+	PhaseTotal Phase = "total"
+)
+
+// TimeoutError is the error of a phase of a network operation that did not complete in time.
+type TimeoutError struct {
+	Phase   Phase
+	Timeout time.Duration
+	Err     error
+}
+
+// Error implements error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout of %s expired: %s", e.Phase, e.Timeout, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// CategoryOf returns the category of err.
+// The category of the outermost Error of the chain is used, otherwise the category is guessed from
+// well known errors of the chain.
+func CategoryOf(err error) Category {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Category
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return CategoryTimeout
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CategoryTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.Is(err, fs.ErrNotExist) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return CategoryNotFound
+	}
+	var opErr *net.OpError
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &opErr) || errors.As(err, &urlErr) {
+		return CategoryNetwork
+	}
+	return CategoryGeneric
+}
+
+// ExitCode returns the process exit status for err: 0 if err is nil, the exit status of the
+// process if err comes from a failed external command (eg: a plugin), the exit code of its category otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return exitCodes[CategoryOf(err)]
+}
+
+// Report is the structured representation of an error.
+type Report struct {
+	Code     int      `json:"code"`
+	Category Category `json:"category"`
+	Message  string   `json:"message"`
+	// Phase is the phase whose timeout expired, for timeout errors.
+	Phase  Phase    `json:"phase,omitempty"`
+	Causes []string `json:"causes,omitempty"`
+}
+
+// NewReport returns the report of err.
+// Causes lists the messages of the errors wrapped by err, outermost first, omitting the ones
+// having the same message as their parent.
+func NewReport(err error) *Report {
+	r := &Report{
+		Code:     ExitCode(err),
+		Category: CategoryOf(err),
+		Message:  err.Error(),
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		r.Phase = timeoutErr.Phase
+	}
+	r.Causes = causes(err, err.Error())
+	return r
+}
+
+func causes(err error, parentMsg string) []string {
+	var wrapped []error
+	switch e := err.(type) { //nolint:errorlint
+	case interface{ Unwrap() error }:
+		if u := e.Unwrap(); u != nil {
+			wrapped = []error{u}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+	var list []string
+	for _, w := range wrapped {
+		msg := w.Error()
+		if msg != parentMsg {
+			list = append(list, msg)
+		}
+		list = append(list, causes(w, msg)...)
+	}
+	return list
+}
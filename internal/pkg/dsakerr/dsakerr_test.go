@@ -0,0 +1,63 @@
+package dsakerr //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Category
+	}{
+		{name: "plain error", err: errors.New("boom"), want: CategoryGeneric},
+		{name: "typed error", err: New(CategoryValidation, "expired"), want: CategoryValidation},
+		{
+			name: "outermost typed error wins",
+			err:  Wrap(CategoryUsage, fmt.Errorf("wrapped: %w", New(CategoryNetwork, "unreachable"))),
+			want: CategoryUsage,
+		},
+		{name: "wrapped typed error", err: fmt.Errorf("wrapped: %w", New(CategoryNotFound, "nope")), want: CategoryNotFound},
+		{name: "deadline exceeded", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: CategoryTimeout},
+		{name: "missing file", err: fmt.Errorf("open: %w", fs.ErrNotExist), want: CategoryNotFound},
+		{name: "unknown domain", err: &net.DNSError{Err: "no such host", IsNotFound: true}, want: CategoryNotFound},
+		{name: "refused connection", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: CategoryNetwork},
+		{name: "network timeout", err: &net.DNSError{Err: "timeout", IsTimeout: true}, want: CategoryTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CategoryOf(tt.err))
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, 1, ExitCode(errors.New("boom")))
+	assert.Equal(t, 2, ExitCode(New(CategoryUsage, "bad")))
+	assert.Equal(t, 3, ExitCode(New(CategoryNetwork, "bad")))
+	assert.Equal(t, 4, ExitCode(New(CategoryTimeout, "bad")))
+	assert.Equal(t, 5, ExitCode(New(CategoryValidation, "bad")))
+	assert.Equal(t, 6, ExitCode(New(CategoryNotFound, "bad")))
+	assert.Nil(t, Wrap(CategoryUsage, nil))
+}
+
+func TestNewReport(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("request failed: %w", Errorf(CategoryNetwork, "dial: %w", cause))
+	r := NewReport(err)
+	assert.Equal(t, 3, r.Code)
+	assert.Equal(t, CategoryNetwork, r.Category)
+	assert.Equal(t, "request failed: dial: connection refused", r.Message)
+	assert.Equal(t, []string{"dial: connection refused", "connection refused"}, r.Causes)
+
+	joined := NewReport(errors.Join(errors.New("a"), errors.New("b")))
+	assert.Equal(t, []string{"a", "b"}, joined.Causes)
+}
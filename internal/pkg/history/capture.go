@@ -0,0 +1,68 @@
+package history
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Capture keeps a copy of the output of an invocation, up to a maximum size.
+type Capture struct {
+	buf       bytes.Buffer
+	max       int
+	mu        sync.Mutex
+	truncated bool
+}
+
+// NewCapture returns a capture keeping at most max bytes.
+func NewCapture(max int) *Capture {
+	return &Capture{max: max}
+}
+
+// Write implements io.Writer, it never fails and drops what exceeds the maximum size.
+func (c *Capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room := c.max - c.buf.Len(); len(p) > room {
+		c.buf.Write(p[:room])
+		c.truncated = true
+		return len(p), nil
+	}
+	c.buf.Write(p)
+	return len(p), nil
+}
+
+// String returns the captured output.
+func (c *Capture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// Truncated returns true if some output has been dropped.
+func (c *Capture) Truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncated
+}
+
+// Tee returns a writer writing to w and to the capture.
+// The returned writer is an io.Closer closing w if w is one.
+func (c *Capture) Tee(w io.Writer) io.WriteCloser {
+	return &tee{
+		Writer: io.MultiWriter(w, c),
+		w:      w,
+	}
+}
+
+type tee struct {
+	io.Writer
+	w io.Writer
+}
+
+func (t *tee) Close() error {
+	if closer, ok := t.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
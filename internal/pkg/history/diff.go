@@ -0,0 +1,120 @@
+package history
+
+import (
+	"strings"
+)
+
+// DiffOp is the operation of a diff line.
+type DiffOp string
+
+const (
+	// DiffEqual is a line present in both texts.
+	DiffEqual DiffOp = " "
+	// DiffRemoved is a line only present in the first text.
+	DiffRemoved DiffOp = "-"
+	// DiffAdded is a line only present in the second text.
+	DiffAdded DiffOp = "+"
+)
+
+// DiffLine is a line of a diff.
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the memory used to compute a diff, bigger texts are diffed as a whole replacement.
+const maxDiffCells = 16 << 20
+
+// Diff returns the line diff turning a into b, based on their longest common subsequence of lines.
+func Diff(a, b string) []DiffLine {
+	al := splitLines(a)
+	bl := splitLines(b)
+	// Common prefix and suffix are kept out of the subsequence computation.
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix && al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+	diff := make([]DiffLine, 0, len(al)+len(bl))
+	for _, l := range al[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: l})
+	}
+	diff = append(diff, diffLines(al[prefix:len(al)-suffix], bl[prefix:len(bl)-suffix])...)
+	for _, l := range al[len(al)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: l})
+	}
+	return diff
+}
+
+// Changed returns true if diff has added or removed lines.
+func Changed(diff []DiffLine) bool {
+	for _, l := range diff {
+		if l.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func diffLines(a, b []string) []DiffLine {
+	var diff []DiffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: l})
+		}
+		for _, l := range b {
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: l})
+		}
+		return diff
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: DiffRemoved, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: DiffAdded, Text: b[j]})
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,206 @@
+// Package history stores dsak invocations so that they can be listed, shown and run again.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a recorded invocation.
+type Entry struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	// Args is the command line, without the executable name.
+	Args    []string `json:"args"`
+	Command string   `json:"command"`
+	// Dir is the working directory of the invocation.
+	Dir string `json:"dir"`
+	// ConfigFile is the configuration file used by the invocation.
+	ConfigFile string `json:"config_file"`
+	// Config holds the effective configuration values, by name.
+	Config     map[string]any `json:"config"`
+	Duration   Duration       `json:"duration"`
+	ExitStatus int            `json:"exit_status"`
+	Error      string         `json:"error,omitempty"`
+	// Output is the captured output, nil if it was not recorded.
+	Output          *string `json:"output,omitempty"`
+	OutputTruncated bool    `json:"output_truncated,omitempty"`
+}
+
+// CommandLine returns the command line of the entry, quoted like a POSIX shell would need it.
+func (e *Entry) CommandLine() string {
+	return "dsak " + QuoteArgs(e.Args)
+}
+
+// Duration is a time.Duration stored as a string, eg: "1.2s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(d).String())), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: %w", b, err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Store is a history store, keeping each entry as a JSON file named after its ID in a directory.
+type Store struct {
+	dir  string
+	size int
+}
+
+// NewStore returns a store in the given directory, keeping at most size entries, 0 for no limit.
+func NewStore(dir string, size int) *Store {
+	return &Store{
+		dir:  dir,
+		size: size,
+	}
+}
+
+// Add records e, setting its ID, and removes the entries exceeding the size of the store.
+func (s *Store) Add(e *Entry) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	e.ID = 1
+	if len(ids) > 0 {
+		e.ID = ids[len(ids)-1] + 1
+	}
+	// Entries are created exclusively, another dsak process may have taken the ID in the meantime.
+	for {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		f, err := os.OpenFile(s.path(e.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			e.ID++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create history entry: %w", err)
+		}
+		_, err = f.Write(b)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write history entry: %w", err)
+		}
+		break
+	}
+	ids = append(ids, e.ID)
+	if s.size > 0 && len(ids) > s.size {
+		for _, id := range ids[:len(ids)-s.size] {
+			if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove history entry %d: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id int) (*Entry, error) {
+	b, err := os.ReadFile(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("history entry %d not found: %w", id, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history entry %d: %w", id, err)
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("failed to decode history entry %d: %w", id, err)
+	}
+	return e, nil
+}
+
+// List returns all the entries, oldest first.
+func (s *Store) List() ([]*Entry, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		e, err := s.Get(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed by another dsak process.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *Store) path(id int) string {
+	return filepath.Join(s.dir, strconv.Itoa(id)+".json")
+}
+
+func (s *Store) ids() ([]int, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+	var ids []int
+	for _, f := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil || f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// QuoteArgs joins args, quoting the ones a POSIX shell would split or expand.
+func QuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,@%+", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
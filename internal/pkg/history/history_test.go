@@ -0,0 +1,88 @@
+package history //nolint:testpackage
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	s := NewStore(dir, 2)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	output := "93.184.215.14\n"
+	for _, args := range [][]string{{"a"}, {"b"}, {"c"}} {
+		require.NoError(t, s.Add(&Entry{
+			Time:     time.Now(),
+			Args:     args,
+			Duration: Duration(1500 * time.Millisecond),
+			Config:   map[string]any{"global.format": "text"},
+			Output:   &output,
+		}))
+	}
+
+	list, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, 2, list[0].ID)
+	assert.Equal(t, []string{"b"}, list[0].Args)
+	assert.Equal(t, 3, list[1].ID)
+
+	e, err := s.Get(3)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(1500*time.Millisecond), e.Duration)
+	assert.Equal(t, "text", e.Config["global.format"])
+	require.NotNil(t, e.Output)
+	assert.Equal(t, output, *e.Output)
+
+	_, err = s.Get(1)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// An ID taken by another process is skipped.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "4.json"), []byte("{}"), 0o600))
+	e = &Entry{Args: []string{"d"}}
+	require.NoError(t, s.Add(e))
+	assert.Equal(t, 5, e.ID)
+}
+
+func TestCapture(t *testing.T) {
+	c := NewCapture(5)
+	n, err := c.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = c.Write([]byte("defg"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "abcde", c.String())
+	assert.True(t, c.Truncated())
+}
+
+func TestDiff(t *testing.T) {
+	diff := Diff("a\nb\nc\nd\n", "a\nc\nd\ne\n")
+	assert.Equal(t, []DiffLine{
+		{Op: DiffEqual, Text: "a"},
+		{Op: DiffRemoved, Text: "b"},
+		{Op: DiffEqual, Text: "c"},
+		{Op: DiffEqual, Text: "d"},
+		{Op: DiffAdded, Text: "e"},
+	}, diff)
+	assert.True(t, Changed(diff))
+	assert.False(t, Changed(Diff("a\nb", "a\nb\n")))
+	assert.Empty(t, Diff("", ""))
+}
+
+func TestQuoteArgs(t *testing.T) {
+	assert.Equal(t,
+		`dns query example.com --server=1.1.1.1:53 '' 'a b' '$x' 'it'\''s'`,
+		QuoteArgs([]string{"dns", "query", "example.com", "--server=1.1.1.1:53", "", "a b", "$x", "it's"}),
+	)
+}
@@ -7,29 +7,37 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/itchyny/gojq"
 )
 
 type Client struct {
-	acccept     string
-	body        io.ReadCloser
-	client      *http.Client
-	contentType string
-	forceHTTP1  bool
-	forceHTTP2  bool
-	forceType   string
-	headers     http.Header
-	insecure    bool
-	jq          *gojq.Query
-	log         io.Writer
-	method      string
-	out         io.Writer
-	raw         bool
-	req         *http.Request
-	style       string
-	trace       bool
-	url         *url.URL
+	acccept        string
+	body           io.ReadCloser
+	client         *http.Client
+	connected      atomic.Bool
+	connectTimeout time.Duration
+	contentType    string
+	forceHTTP1     bool
+	forceHTTP2     bool
+	forceType      string
+	handshaking    atomic.Bool
+	headers        http.Header
+	insecure       bool
+	jq             *gojq.Query
+	log            io.Writer
+	method         string
+	out            io.Writer
+	raw            bool
+	readTimeout    time.Duration
+	req            *http.Request
+	// resultHandler, when set, receives the response instead of it being written to out.
+	resultHandler func(*Response) error
+	style         string
+	trace         bool
+	url           *url.URL
 }
 
 func NewClient(uri string, opts ...Option) (*Client, error) {
@@ -69,7 +77,7 @@ func (c *Client) Run(ctx context.Context) error {
 	}
 	res, err := c.client.Do(c.req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("request failed: %w", c.phaseError(ctx, err))
 	}
 	defer res.Body.Close()
 	c.traceInfo("Response status code is ")
@@ -79,5 +87,8 @@ func (c *Client) Run(ctx context.Context) error {
 		c.traceValuef("%s \n", res.Status)
 	}
 	c.showResponseHeaders(res.Header)
-	return c.output(ctx, res)
+	if c.resultHandler != nil {
+		return c.phaseError(ctx, c.outputResult(ctx, res))
+	}
+	return c.phaseError(ctx, c.output(ctx, res))
 }
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/chroma/styles"
 	"github.com/itchyny/gojq"
@@ -15,12 +16,6 @@ import (
 // Option is a function that configures a Client.
 type Option func(*Client) error
 
-/*
-	commander.WithConfig(configKeyHTTPDebugResponseStyle),
-	commander.WithConfig(configKeyHTTPDebugTrace),
-
-*/
-
 // WithOut configures the client output writer.
 func WithOut(writer io.Writer) Option {
 	return func(c *Client) error {
@@ -158,3 +153,21 @@ func WithForceType(t string) Option {
 		return nil
 	}
 }
+
+// WithResultHandler tells client to give the response as a Response to f instead of writing it to the output.
+func WithResultHandler(f func(*Response) error) Option {
+	return func(c *Client) error {
+		c.resultHandler = f
+		return nil
+	}
+}
+
+// WithTimeouts sets the connect timeout (dial and TLS handshake) and the read timeout (wait for data
+// once connected) of the request, 0 meaning they are only bounded by the context.
+func WithTimeouts(connect, read time.Duration) Option {
+	return func(c *Client) error {
+		c.connectTimeout = connect
+		c.readTimeout = read
+		return nil
+	}
+}
@@ -8,7 +8,7 @@ import (
 
 func (c *Client) buildRequest(ctx context.Context) error {
 	req, err := http.NewRequestWithContext(
-		c.getTracerContext(ctx),
+		c.withConnectedTrace(c.getTracerContext(ctx)),
 		c.method,
 		c.url.String(),
 		wrapSizedBody(c.body),
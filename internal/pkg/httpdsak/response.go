@@ -0,0 +1,111 @@
+package httpdsak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Response is the structured result of a request, given to the handler set by WithResultHandler.
+type Response struct {
+	URL        string              `json:"url"`
+	Method     string              `json:"method"`
+	Protocol   string              `json:"protocol"`
+	Status     string              `json:"status"`
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers"`
+	// Body is the parsed body for JSON responses, the body as a string otherwise.
+	Body any `json:"body"`
+	// JQ is the list of results of the jq filter, if any.
+	JQ []any `json:"jq,omitempty"`
+}
+
+// Header returns the columns of the response table.
+func (r *Response) Header() []string {
+	return []string{"field", "value"}
+}
+
+// Rows returns the response status, headers and body as rows.
+func (r *Response) Rows() [][]string {
+	rows := [][]string{
+		{"url", r.URL},
+		{"method", r.Method},
+		{"protocol", r.Protocol},
+		{"status_code", strconv.Itoa(r.StatusCode)},
+	}
+	names := make([]string, 0, len(r.Headers))
+	for n := range r.Headers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		for _, v := range r.Headers[n] {
+			rows = append(rows, []string{"header " + n, v})
+		}
+	}
+	if r.JQ != nil {
+		for _, v := range r.JQ {
+			b, _ := json.Marshal(v)
+			rows = append(rows, []string{"jq", string(b)})
+		}
+	} else if s, ok := r.Body.(string); ok {
+		rows = append(rows, []string{"body", s})
+	} else {
+		b, _ := json.Marshal(r.Body)
+		rows = append(rows, []string{"body", string(b)})
+	}
+	return rows
+}
+
+func (c *Client) outputResult(ctx context.Context, res *http.Response) error {
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body : %w", err)
+	}
+	result := &Response{
+		URL:        c.url.String(),
+		Method:     c.method,
+		Protocol:   res.Proto,
+		Status:     res.Status,
+		StatusCode: res.StatusCode,
+		Headers:    res.Header,
+		Body:       string(b),
+	}
+	mimeType := c.forceType
+	if mimeType == "" {
+		mimeType, _, _ = mime.ParseMediaType(res.Header.Get("content-type"))
+	}
+	var decoded any
+	isJSON := json.Unmarshal(b, &decoded) == nil
+	if isJSON && (mimeType == "application/json" || strings.HasSuffix(mimeType, "+json")) {
+		result.Body = decoded
+	} else if !utf8.Valid(b) {
+		result.Body = fmt.Sprintf("<%d bytes of binary data>", len(b))
+	}
+	if c.jq != nil {
+		if !isJSON {
+			return errors.New("response is not valid json")
+		}
+		result.JQ = []any{}
+		iter := c.jq.RunWithContext(ctx, decoded)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				return err
+			}
+			result.JQ = append(result.JQ, v)
+		}
+	}
+	return c.resultHandler(result)
+}
@@ -0,0 +1,68 @@
+package httpdsak
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+// readTimeoutConn is a connection whose reads fail if no data is received for timeout.
+// Reads of the TLS handshake are not bounded by timeout but by the handshake timeout of the
+// transport.
+type readTimeoutConn struct {
+	net.Conn
+	handshaking *atomic.Bool
+	timeout     time.Duration
+}
+
+func (c *readTimeoutConn) Read(b []byte) (int, error) {
+	var deadline time.Time
+	if !c.handshaking.Load() {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err := c.Conn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// withConnectedTrace tracks the TLS handshakes, during which reads have no timeout, and when the
+// connection is established, to tell connect and read timeouts apart.
+func (c *Client) withConnectedTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			c.handshaking.Store(true)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			c.handshaking.Store(false)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			c.connected.Store(true)
+		},
+	})
+}
+
+// phaseError returns err as a dsakerr.TimeoutError if it is a connect or read timeout.
+// Expiration of the total timeout, the context one, is left as is.
+func (c *Client) phaseError(ctx context.Context, err error) error {
+	var netErr net.Error
+	if err == nil || ctx.Err() != nil || !errors.As(err, &netErr) || !netErr.Timeout() {
+		return err
+	}
+	if !c.connected.Load() {
+		if c.connectTimeout == 0 {
+			return err
+		}
+		return &dsakerr.TimeoutError{Phase: dsakerr.PhaseConnect, Timeout: c.connectTimeout, Err: err}
+	}
+	if c.readTimeout == 0 {
+		return err
+	}
+	return &dsakerr.TimeoutError{Phase: dsakerr.PhaseRead, Timeout: c.readTimeout, Err: err}
+}
@@ -0,0 +1,66 @@
+package httpdsak //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+func TestReadTimeout(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	for name, srv := range map[string]*httptest.Server{
+		"http":  httptest.NewServer(handler),
+		"https": httptest.NewTLSServer(handler),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer srv.Close()
+
+			c, err := NewClient(
+				srv.URL,
+				WithOut(io.Discard),
+				WithLog(io.Discard),
+				WithRaw(),
+				WithInsecure(),
+				WithTimeouts(time.Second, 100*time.Millisecond),
+			)
+			require.NoError(t, err)
+			// The total timeout only bounds the test if reads have no deadline.
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err = c.Run(ctx)
+			require.Error(t, err)
+			var timeoutErr *dsakerr.TimeoutError
+			require.True(t, errors.As(err, &timeoutErr), "error is %v", err)
+			assert.Equal(t, dsakerr.PhaseRead, timeoutErr.Phase)
+			assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+		})
+	}
+}
+
+func TestTotalTimeoutIsNotAPhase(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithOut(io.Discard), WithLog(io.Discard), WithRaw(), WithTimeouts(time.Second, time.Second))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = c.Run(ctx)
+	require.Error(t, err)
+	var timeoutErr *dsakerr.TimeoutError
+	assert.False(t, errors.As(err, &timeoutErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
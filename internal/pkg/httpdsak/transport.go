@@ -12,9 +12,12 @@ import (
 func (c *Client) buildTransport(ctx context.Context) (*http.Transport, error) {
 	v, ok := ctx.Deadline()
 	var tlsHandshakeTimeout time.Duration
-	if ok {
+	switch {
+	case c.connectTimeout > 0:
+		tlsHandshakeTimeout = c.connectTimeout
+	case ok:
 		tlsHandshakeTimeout = time.Until(v)
-	} else {
+	default:
 		tlsHandshakeTimeout = 10 * time.Second
 	}
 	var rtNextProto map[string]func(string, *tls.Conn) http.RoundTripper
@@ -32,7 +35,7 @@ func (c *Client) buildTransport(ctx context.Context) (*http.Transport, error) {
 	}
 
 	return &http.Transport{
-		DialContext:           getDialer,
+		DialContext:           c.dial,
 		ExpectContinueTimeout: 1,
 		ForceAttemptHTTP2:     true,
 		IdleConnTimeout:       time.Second,
@@ -54,17 +57,24 @@ func (c *Client) buildTransport(ctx context.Context) (*http.Transport, error) {
 	}, nil
 }
 
-func getDialer(ctx context.Context, network string, addr string) (net.Conn, error) {
+func (c *Client) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
 	v, ok := ctx.Deadline()
 	var timeout time.Duration
-	if ok {
+	switch {
+	case c.connectTimeout > 0:
+		timeout = c.connectTimeout
+	case ok:
 		timeout = time.Until(v)
-	} else {
+	default:
 		timeout = 10 * time.Second
 	}
 	dialer := &net.Dialer{
 		Timeout:   timeout,
 		KeepAlive: 0,
 	}
-	return dialer.DialContext(ctx, network, addr)
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil || c.readTimeout == 0 {
+		return conn, err
+	}
+	return &readTimeoutConn{Conn: conn, handshaking: &c.handshaking, timeout: c.readTimeout}, nil
 }
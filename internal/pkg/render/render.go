@@ -0,0 +1,191 @@
+// Package render renders command results in the output formats supported by dsak.
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	// FormatText is the human readable format of each command.
+	FormatText Format = "text"
+	// FormatJSON renders results as indented JSON.
+	FormatJSON Format = "json"
+	// FormatYAML renders results as YAML, with the same schema as JSON.
+	FormatYAML Format = "yaml"
+	// FormatTable renders results as an aligned table.
+	FormatTable Format = "table"
+	// FormatCSV renders results as CSV, with a header line.
+	FormatCSV Format = "csv"
+	// FormatJSONLines renders results as compact JSON, one line per element of a list result.
+	FormatJSONLines Format = "jsonl"
+)
+
+var formats = []Format{FormatText, FormatJSON, FormatYAML, FormatTable, FormatCSV, FormatJSONLines}
+
+// Formats returns the list of the supported formats.
+func Formats() []string {
+	list := make([]string, len(formats))
+	for i, f := range formats {
+		list[i] = string(f)
+	}
+	return list
+}
+
+// ParseFormat returns the format matching s.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range formats {
+		if strings.EqualFold(string(f), s) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %s, valid formats are %s", s, strings.Join(Formats(), ", "))
+}
+
+// Texter is implemented by results having a human readable representation.
+type Texter interface {
+	RenderText(w io.Writer) error
+}
+
+// Tabler is implemented by results that can be represented as a table.
+type Tabler interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Render writes v to w in the given format.
+// JSON, JSON lines and YAML use the json tags of v.
+// Text uses Texter if implemented by v, then Tabler, then YAML.
+// Table and CSV need v to implement Tabler.
+func Render(w io.Writer, format Format, v any) error {
+	switch format {
+	case FormatJSON:
+		return renderJSON(w, v)
+	case FormatYAML:
+		return renderYAML(w, v)
+	case FormatJSONLines:
+		return renderJSONLines(w, v)
+	case FormatTable, FormatCSV:
+		t, ok := v.(Tabler)
+		if !ok {
+			return fmt.Errorf("format %s is not supported by this command", format)
+		}
+		if format == FormatCSV {
+			return renderCSV(w, t)
+		}
+		return renderTable(w, t)
+	case FormatText:
+	}
+	if t, ok := v.(Texter); ok {
+		return t.RenderText(w)
+	}
+	if t, ok := v.(Tabler); ok {
+		return renderTable(w, t)
+	}
+	return renderYAML(w, v)
+}
+
+func renderJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// renderJSONLines renders each element of v on its own line if v is a list, v on one line otherwise.
+func renderJSONLines(w io.Writer, v any) error {
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(b.Bytes(), &items); err != nil {
+		items = []json.RawMessage{b.Bytes()}
+	}
+	buf := &bytes.Buffer{}
+	for _, item := range items {
+		if err := json.Compact(buf, item); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// renderYAML renders v as YAML using its JSON representation, so that both formats share
+// the same schema and field order.
+func renderYAML(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && strings.Contains(node.Value, "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+	for _, n := range node.Content {
+		resetStyle(n)
+	}
+}
+
+func renderTable(w io.Writer, t Tabler) error {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	header := t.Header()
+	upper := make([]string, len(header))
+	for i, h := range header {
+		upper[i] = strings.ToUpper(h)
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	for _, row := range t.Rows() {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = strings.ReplaceAll(c, "\n", " ")
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func renderCSV(w io.Writer, t Tabler) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header()); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows()); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,118 @@
+package render //nolint:testpackage
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testResult struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+func (r *testResult) Header() []string {
+	return []string{"name", "count"}
+}
+
+func (r *testResult) Rows() [][]string {
+	return [][]string{{r.Name, "2"}, {"other, name", "10"}}
+}
+
+type textResult struct {
+	testResult
+}
+
+func (r *textResult) RenderText(w io.Writer) error {
+	_, err := io.WriteString(w, "text "+r.Name+"\n")
+	return err
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("JSON")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+	_, err = ParseFormat("xml")
+	require.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	res := &testResult{Name: "a", Count: 2, Tags: []string{"x", "y"}}
+	tests := []struct {
+		name   string
+		format Format
+		value  any
+		want   string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			value:  res,
+			want:   "{\n  \"name\": \"a\",\n  \"count\": 2,\n  \"tags\": [\n    \"x\",\n    \"y\"\n  ]\n}\n",
+		},
+		{
+			name:   "yaml keeps json field order",
+			format: FormatYAML,
+			value:  res,
+			want:   "name: a\ncount: 2\ntags:\n  - x\n  - y\n",
+		},
+		{
+			name:   "json lines of an object",
+			format: FormatJSONLines,
+			value:  res,
+			want:   "{\"name\":\"a\",\"count\":2,\"tags\":[\"x\",\"y\"]}\n",
+		},
+		{
+			name:   "json lines of a list",
+			format: FormatJSONLines,
+			value:  []*testResult{res, {Name: "b"}},
+			want:   "{\"name\":\"a\",\"count\":2,\"tags\":[\"x\",\"y\"]}\n{\"name\":\"b\",\"count\":0,\"tags\":null}\n",
+		},
+		{
+			name:   "table",
+			format: FormatTable,
+			value:  res,
+			want:   "NAME         COUNT\na            2\nother, name  10\n",
+		},
+		{
+			name:   "csv",
+			format: FormatCSV,
+			value:  res,
+			want:   "name,count\na,2\n\"other, name\",10\n",
+		},
+		{
+			name:   "text uses texter",
+			format: FormatText,
+			value:  &textResult{*res},
+			want:   "text a\n",
+		},
+		{
+			name:   "text falls back to table",
+			format: FormatText,
+			value:  res,
+			want:   "NAME         COUNT\na            2\nother, name  10\n",
+		},
+		{
+			name:   "text falls back to yaml",
+			format: FormatText,
+			value:  map[string]int{"a": 1},
+			want:   "a: 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, Render(buf, tt.format, tt.value))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestRenderUnsupportedTable(t *testing.T) {
+	err := Render(io.Discard, FormatCSV, map[string]int{"a": 1})
+	require.Error(t, err)
+}
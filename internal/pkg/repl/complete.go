@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var builtins = []string{"exit", "quit", "set", "unset", "vars"}
+
+func (s *Shell) autoComplete(ctx context.Context, t *term.Terminal) func(string, int, rune) (string, int, bool) {
+	return func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' || s.complete == nil {
+			return "", 0, false
+		}
+		prefix := line[:pos]
+		args, err := Parse(prefix, s.lookup)
+		if err != nil {
+			return "", 0, false
+		}
+		rawToComplete := ""
+		if prefix != "" && !strings.ContainsAny(prefix[len(prefix)-1:], " \t") {
+			rawToComplete = prefix[strings.LastIndexAny(prefix, " \t")+1:]
+			args = args[:len(args)-1]
+		}
+		toComplete := ""
+		if rawToComplete != "" {
+			parsed, err := Parse(rawToComplete, s.lookup)
+			if err == nil && len(parsed) > 0 {
+				toComplete = parsed[0]
+			}
+		}
+		if len(args) > 0 && args[0] == "dsak" {
+			args = args[1:]
+		}
+		candidates := s.candidates(ctx, args, toComplete)
+		switch len(candidates) {
+		case 0:
+			return "", 0, false
+		case 1:
+			newPrefix := prefix[:len(prefix)-len(rawToComplete)] + candidates[0] + " "
+			return newPrefix + line[pos:], len(newPrefix), true
+		}
+		common := commonPrefix(candidates)
+		if len(common) > len(toComplete) {
+			newPrefix := prefix[:len(prefix)-len(rawToComplete)] + common
+			return newPrefix + line[pos:], len(newPrefix), true
+		}
+		fmt.Fprintf(t, "%s\n", strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+}
+
+func (s *Shell) candidates(ctx context.Context, args []string, toComplete string) []string {
+	var list []string
+	if len(args) == 0 {
+		for _, b := range builtins {
+			if strings.HasPrefix(b, toComplete) {
+				list = append(list, b)
+			}
+		}
+	}
+	completions, err := s.complete(ctx, args, toComplete)
+	if err != nil {
+		return list
+	}
+	for _, c := range completions {
+		c, _, _ = strings.Cut(c, "\t")
+		if c != "" && strings.HasPrefix(c, toComplete) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func commonPrefix(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	prefix := list[0]
+	for _, s := range list[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
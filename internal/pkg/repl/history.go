@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+func (s *Shell) loadHistory() []string {
+	if s.historyFile == "" {
+		return nil
+	}
+	f, err := os.Open(s.historyFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.IndexFunc(line, func(r rune) bool { return r < ' ' }) >= 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > s.historySize {
+		lines = lines[len(lines)-s.historySize:]
+		_ = os.WriteFile(s.historyFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	return lines
+}
+
+func (s *Shell) appendHistory(line string) {
+	if s.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(s.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(line + "\n")
+}
+
+// replayReadWriter first reads the given history lines, discarding anything written, then
+// reads and writes to the real terminal.
+type replayReadWriter struct {
+	in     io.Reader
+	mtx    sync.Mutex
+	muted  bool
+	out    io.Writer
+	replay *bytes.Buffer
+}
+
+func newReplayReadWriter(history []string, in io.Reader, out io.Writer) *replayReadWriter {
+	replay := &bytes.Buffer{}
+	for _, line := range history {
+		replay.WriteString(line)
+		replay.WriteByte('\r')
+	}
+	return &replayReadWriter{
+		in:     in,
+		muted:  true,
+		out:    out,
+		replay: replay,
+	}
+}
+
+func (r *replayReadWriter) unmute() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.muted = false
+}
+
+// Read implements io.Reader.
+func (r *replayReadWriter) Read(p []byte) (int, error) {
+	if r.replay.Len() > 0 {
+		return r.replay.Read(p)
+	}
+	return r.in.Read(p)
+}
+
+// Write implements io.Writer.
+func (r *replayReadWriter) Write(p []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.muted {
+		return len(p), nil
+	}
+	return r.out.Write(p)
+}
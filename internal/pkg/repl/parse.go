@@ -0,0 +1,158 @@
+package repl
+
+import (
+	"errors"
+	"strings"
+)
+
+// LookupFunc returns the value of a variable and if it is set.
+type LookupFunc func(name string) (string, bool)
+
+// ErrUnterminatedQuote is returned when a line contains a quote that is not closed.
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// Parse splits a command line into arguments like a POSIX shell would do.
+// Arguments are separated by whitespaces, single quotes preserve their content,
+// double quotes preserve their content except for variables and backslash escapes.
+// Variables are written $name or ${name}, $_ and $? being special variables.
+// Unknown variables expand to an empty string.
+func Parse(line string, lookup LookupFunc) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+	)
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case r == '\'':
+			inArg = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, ErrUnterminatedQuote
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inArg = true
+			end, err := parseDoubleQuoted(runes, i+1, &current, lookup)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case r == '\\':
+			inArg = true
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			}
+		case r == '$':
+			inArg = true
+			i = expandVariable(runes, i, &current, lookup)
+		default:
+			inArg = true
+			current.WriteRune(r)
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseDoubleQuoted writes the content of a double quoted string starting at from and returns the
+// position of the closing quote.
+func parseDoubleQuoted(runes []rune, from int, current *strings.Builder, lookup LookupFunc) (int, error) {
+	for i := from; i < len(runes); i++ {
+		switch runes[i] {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(runes) && strings.ContainsRune(`"\$`, runes[i+1]) {
+				i++
+			}
+			current.WriteRune(runes[i])
+		case '$':
+			i = expandVariable(runes, i, current, lookup)
+		default:
+			current.WriteRune(runes[i])
+		}
+	}
+	return 0, ErrUnterminatedQuote
+}
+
+// expandVariable writes the value of the variable starting at position i (the $ sign) and returns
+// the position of the last character of the variable reference.
+func expandVariable(runes []rune, i int, current *strings.Builder, lookup LookupFunc) int {
+	if i+1 >= len(runes) {
+		current.WriteRune('$')
+		return i
+	}
+	var name string
+	end := i + 1
+	switch {
+	case runes[end] == '?':
+		name = "?"
+	case runes[end] == '{':
+		closing := indexRune(runes, end+1, '}')
+		if closing < 0 {
+			current.WriteRune('$')
+			return i
+		}
+		name = string(runes[end+1 : closing])
+		end = closing
+	case isVariableRune(runes[end], true):
+		for end+1 < len(runes) && isVariableRune(runes[end+1], false) {
+			end++
+		}
+		name = string(runes[i+1 : end+1])
+	default:
+		current.WriteRune('$')
+		return i
+	}
+	if lookup != nil {
+		if v, ok := lookup(name); ok {
+			current.WriteString(v)
+		}
+	}
+	return end
+}
+
+func isVariableRune(r rune, first bool) bool {
+	switch {
+	case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return true
+	case r >= '0' && r <= '9':
+		return !first
+	}
+	return false
+}
+
+// IsVariableName returns if name is a valid variable name.
+func IsVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !isVariableRune(r, i == 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package repl //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	vars := map[string]string{
+		"_":    "1.2.3.4",
+		"?":    "0",
+		"host": "example.com",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{name: "empty line", line: "  ", want: nil},
+		{name: "simple words", line: "dns  query\texample.com", want: []string{"dns", "query", "example.com"}},
+		{name: "single quotes", line: `echo 'a "b" $host'`, want: []string{"echo", `a "b" $host`}},
+		{name: "double quotes", line: `echo "a \"b\" $host"`, want: []string{"echo", `a "b" example.com`}},
+		{name: "empty quotes", line: `echo ""`, want: []string{"echo", ""}},
+		{name: "backslash escape", line: `echo a\ b \$host`, want: []string{"echo", "a b", "$host"}},
+		{name: "special variables", line: "echo $_ $?", want: []string{"echo", "1.2.3.4", "0"}},
+		{name: "braced variable", line: "echo ${host}:443", want: []string{"echo", "example.com:443"}},
+		{name: "unknown variable", line: "echo x$nope", want: []string{"echo", "x"}},
+		{name: "lonely dollar", line: "echo $ a$", want: []string{"echo", "$", "a$"}},
+		{name: "concatenation", line: `echo 'a'"b"c`, want: []string{"echo", "abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line, lookup)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("unterminated quotes", func(t *testing.T) {
+		_, err := Parse(`echo "a`, lookup)
+		assert.ErrorIs(t, err, ErrUnterminatedQuote)
+		_, err = Parse(`echo 'a`, lookup)
+		assert.ErrorIs(t, err, ErrUnterminatedQuote)
+	})
+}
+
+func TestIsVariableName(t *testing.T) {
+	assert.True(t, IsVariableName("a"))
+	assert.True(t, IsVariableName("_a1"))
+	assert.False(t, IsVariableName(""))
+	assert.False(t, IsVariableName("1a"))
+	assert.False(t, IsVariableName("a-b"))
+}
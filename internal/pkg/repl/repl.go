@@ -0,0 +1,273 @@
+// Package repl implements dsak's interactive shell.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Executor runs a command line, writing its output to out.
+type Executor func(ctx context.Context, args []string, out io.Writer) error
+
+// Completer returns the completions for a command line, toComplete being the word being completed.
+type Completer func(ctx context.Context, args []string, toComplete string) ([]string, error)
+
+// StatusFunc returns the exit status matching a command error.
+type StatusFunc func(err error) int
+
+// Shell is an interactive shell running dsak commands.
+type Shell struct {
+	complete    Completer
+	errOut      io.Writer
+	exec        Executor
+	historyFile string
+	historySize int
+	in          *os.File
+	out         io.Writer
+	prompt      string
+	status      StatusFunc
+	vars        map[string]string
+}
+
+// Option is a function that configures a Shell.
+type Option func(*Shell) error
+
+// New creates a new shell running commands with exec.
+func New(exec Executor, opts ...Option) (*Shell, error) {
+	s := &Shell{
+		errOut:      os.Stderr,
+		exec:        exec,
+		historySize: 1000,
+		in:          os.Stdin,
+		out:         os.Stdout,
+		prompt:      "dsak> ",
+		status: func(err error) int {
+			if err != nil {
+				return 1
+			}
+			return 0
+		},
+		vars: map[string]string{"?": "0"},
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WithCompleter configures the function used for tab completion.
+func WithCompleter(f Completer) Option {
+	return func(s *Shell) error {
+		s.complete = f
+		return nil
+	}
+}
+
+// WithHistoryFile configures the file used to persist the history, no history is persisted if empty.
+func WithHistoryFile(file string) Option {
+	return func(s *Shell) error {
+		s.historyFile = file
+		return nil
+	}
+}
+
+// WithHistorySize configures the number of lines kept in the history file.
+func WithHistorySize(size int) Option {
+	return func(s *Shell) error {
+		if size <= 0 {
+			return errors.New("history size must be positive")
+		}
+		s.historySize = size
+		return nil
+	}
+}
+
+// WithPrompt configures the shell prompt.
+func WithPrompt(prompt string) Option {
+	return func(s *Shell) error {
+		s.prompt = prompt
+		return nil
+	}
+}
+
+// WithStatus configures the function used to compute the $? variable.
+func WithStatus(f StatusFunc) Option {
+	return func(s *Shell) error {
+		s.status = f
+		return nil
+	}
+}
+
+// WithIO configures the shell input, output and error output.
+func WithIO(in *os.File, out, errOut io.Writer) Option {
+	return func(s *Shell) error {
+		s.in = in
+		s.out = out
+		s.errOut = errOut
+		return nil
+	}
+}
+
+// Run runs the shell until the input is closed or the exit command is entered.
+func (s *Shell) Run(ctx context.Context) error {
+	if !term.IsTerminal(int(s.in.Fd())) {
+		return s.runNonInteractive(ctx)
+	}
+	return s.runInteractive(ctx)
+}
+
+func (s *Shell) runNonInteractive(ctx context.Context) error {
+	scanner := bufio.NewScanner(s.in)
+	for scanner.Scan() {
+		if s.execLine(ctx, scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Shell) runInteractive(ctx context.Context) error {
+	fd := int(s.in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal in raw mode: %w", err)
+	}
+	defer term.Restore(fd, state) //nolint:errcheck
+
+	history := s.loadHistory()
+	rw := newReplayReadWriter(history, s.in, s.out)
+	t := term.NewTerminal(rw, "")
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		_ = t.SetSize(width, height)
+	}
+	// Feeding the terminal with the history lines is the only way to fill its history.
+	for range history {
+		if _, err := t.ReadLine(); err != nil {
+			break
+		}
+	}
+	rw.unmute()
+	t.SetPrompt(s.prompt)
+	t.AutoCompleteCallback = s.autoComplete(ctx, t)
+
+	for {
+		line, err := t.ReadLine()
+		if errors.Is(err, io.EOF) {
+			fmt.Fprint(t, "\n")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		s.appendHistory(line)
+		if err := term.Restore(fd, state); err != nil {
+			return err
+		}
+		quit := s.execLine(ctx, line)
+		if _, err := term.MakeRaw(fd); err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// execLine runs a line and returns true if the shell should exit.
+func (s *Shell) execLine(ctx context.Context, line string) bool {
+	args, err := Parse(line, s.lookup)
+	if err != nil {
+		fmt.Fprintf(s.errOut, "Error: %s\n", err)
+		s.vars["?"] = strconv.Itoa(s.status(err))
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "dsak" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "set":
+		s.builtinSet(args[1:])
+		return false
+	case "unset":
+		for _, name := range args[1:] {
+			delete(s.vars, name)
+		}
+		return false
+	case "vars":
+		s.builtinVars()
+		return false
+	case "shell":
+		fmt.Fprintln(s.errOut, "Error: already in a dsak shell")
+		return false
+	}
+	capture := &strings.Builder{}
+	err = s.exec(ctx, args, io.MultiWriter(s.out, capture))
+	s.vars["_"] = strings.TrimRight(StripANSI(capture.String()), "\n")
+	s.vars["?"] = strconv.Itoa(s.status(err))
+	return false
+}
+
+func (s *Shell) builtinSet(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(s.errOut, "Usage: set name [value...]")
+		return
+	}
+	if !IsVariableName(args[0]) || args[0] == "_" {
+		fmt.Fprintf(s.errOut, "Error: invalid variable name: %s\n", args[0])
+		return
+	}
+	if len(args) == 1 {
+		s.vars[args[0]] = s.vars["_"]
+		return
+	}
+	s.vars[args[0]] = strings.Join(args[1:], " ")
+}
+
+func (s *Shell) builtinVars() {
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%s=%q\n", name, s.vars[name])
+	}
+}
+
+func (s *Shell) lookup(name string) (string, bool) {
+	v, ok := s.vars[name]
+	if ok {
+		return v, true
+	}
+	return os.LookupEnv(name)
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// StripANSI removes ANSI escape sequences (colors, cursor moves) from s.
+func StripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
@@ -0,0 +1,62 @@
+package repl //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShell_Run(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join([]string{
+		"dsak echo \x1b[31mhello\x1b[0m",
+		"set greeting",
+		"echo $greeting world",
+		"fail",
+		"echo status $?",
+		"exit",
+		"echo never",
+	}, "\n")), 0o600))
+	in, err := os.Open(input)
+	require.NoError(t, err)
+	defer in.Close()
+
+	var runs [][]string
+	out := &strings.Builder{}
+	sh, err := New(
+		func(_ context.Context, args []string, w io.Writer) error {
+			runs = append(runs, args)
+			if args[0] == "fail" {
+				return errors.New("failed")
+			}
+			_, err := fmt.Fprintln(w, strings.Join(args[1:], " "))
+			return err
+		},
+		WithIO(in, out, io.Discard),
+	)
+	require.NoError(t, err)
+	require.NoError(t, sh.Run(context.Background()))
+
+	assert.Equal(t, [][]string{
+		{"echo", "\x1b[31mhello\x1b[0m"},
+		{"echo", "hello", "world"},
+		{"fail"},
+		{"echo", "status", "1"},
+	}, runs)
+	assert.Equal(t, "status 1", sh.vars["_"])
+	assert.Equal(t, "hello", sh.vars["greeting"])
+}
+
+func Test_commonPrefix(t *testing.T) {
+	assert.Equal(t, "", commonPrefix(nil))
+	assert.Equal(t, "que", commonPrefix([]string{"query", "queue"}))
+	assert.Equal(t, "", commonPrefix([]string{"a", "b"}))
+}
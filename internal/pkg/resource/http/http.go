@@ -1,19 +1,19 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
 	"sync"
 	"sync/atomic"
 
-	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 // R represents an HTTP resource as an io.ReadWriteCloser.
 type R struct {
-	cmd    *cobra.Command
+	ctx    func() context.Context
 	logger *zap.Logger
 	url    *url.URL
 	reader io.ReadCloser
@@ -22,7 +22,8 @@ type R struct {
 	mtx    sync.Mutex
 }
 
-func New(cmd *cobra.Command, uri string, logger *zap.Logger) (*R, error) {
+// New returns the HTTP resource at uri, ctx returning the context of its requests.
+func New(ctx func() context.Context, uri string, logger *zap.Logger) (*R, error) {
 	parsedURI, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
@@ -31,7 +32,7 @@ func New(cmd *cobra.Command, uri string, logger *zap.Logger) (*R, error) {
 		return nil, fmt.Errorf("not a valid HTTP URL: %s", uri)
 	}
 	return &R{
-		cmd:    cmd,
+		ctx:    ctx,
 		logger: logger.With(zap.String("resource_type", "http")),
 		url:    parsedURI,
 		mtx:    sync.Mutex{},
@@ -9,6 +9,8 @@ import (
 	"sync/atomic"
 
 	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 // Read implements io.Reader.
@@ -25,6 +27,7 @@ func (r *R) getReader() (io.ReadCloser, func(), error) {
 	r.mtx.Lock()
 	if r.reader == nil { //nolint:nestif
 		if r.writer != nil {
+			r.mtx.Unlock()
 			return nil, func() {}, errors.New("resource is already in use as a writer, cannot read")
 		}
 		req, err := http.NewRequest(http.MethodGet, r.url.String(), http.NoBody)
@@ -32,14 +35,20 @@ func (r *R) getReader() (io.ReadCloser, func(), error) {
 			r.mtx.Unlock()
 			return nil, func() {}, err
 		}
-		req = req.WithContext(r.cmd.Context())
+		req = req.WithContext(r.ctx())
 		client := &http.Client{}
 		res, err := client.Do(req)
 		if err != nil {
 			r.mtx.Unlock()
 			return nil, func() {}, err
 		}
+		if res.StatusCode == http.StatusNotFound {
+			_ = res.Body.Close()
+			r.mtx.Unlock()
+			return nil, func() {}, dsakerr.Errorf(dsakerr.CategoryNotFound, "resource returned status %d", res.StatusCode)
+		}
 		if res.StatusCode >= http.StatusBadRequest {
+			_ = res.Body.Close()
 			r.mtx.Unlock()
 			return nil, func() {}, fmt.Errorf("resource returned status %d", res.StatusCode)
 		}
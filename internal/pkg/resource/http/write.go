@@ -19,9 +19,10 @@ func (r *R) getWriter() (io.WriteCloser, func(), error) {
 	r.mtx.Lock()
 	if r.writer == nil {
 		if r.reader != nil {
+			r.mtx.Unlock()
 			return nil, func() {}, errors.New("resource is already in use as a reader, cannot write")
 		}
-		r.writer = newMemoryWriter(r.cmd.Context(), r.url.String())
+		r.writer = newMemoryWriter(r.ctx(), r.url.String())
 	}
 	return r.writer, r.mtx.Unlock, nil
 }
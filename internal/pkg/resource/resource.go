@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"io"
 
 	"github.com/spf13/cobra"
@@ -14,21 +15,41 @@ type Type uint
 
 // R represents a resource in dsak.
 type R struct {
-	cmd      *cobra.Command
+	ctx      resourcetype.ContextFunc
 	logger   *zap.Logger
 	resource resourcetype.Handler
 	url      string
 }
 
-// New returns a new resource.
+// WithDir returns a copy of ctx in which relative file paths are relative to dir instead of the
+// working directory.
+func WithDir(ctx context.Context, dir string) context.Context {
+	return resourcetype.WithDir(ctx, dir)
+}
+
+// Dir returns the directory relative file paths are relative to in ctx, "" for the working directory.
+func Dir(ctx context.Context) string {
+	return resourcetype.Dir(ctx)
+}
+
+// New returns a new resource, bound to the context and the input of cmd.
 func New(cmd *cobra.Command, url string, logger *zap.Logger) (*R, error) {
+	return open(cmd.Context, url, logger, cmd.InOrStdin())
+}
+
+// Open returns a new resource, ctx returning the context of its operations.
+func Open(ctx resourcetype.ContextFunc, url string, logger *zap.Logger) (*R, error) {
+	return open(ctx, url, logger, nil)
+}
+
+func open(ctx resourcetype.ContextFunc, url string, logger *zap.Logger, in io.Reader) (*R, error) {
 	logger = logger.With(zap.String("resource", url))
 	r := &R{
-		cmd:    cmd,
+		ctx:    ctx,
 		logger: logger,
 		url:    url,
 	}
-	res, err := resourcetype.Parse(cmd, url, logger)
+	res, err := resourcetype.Parse(ctx, url, logger, in)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +64,7 @@ type ioResult struct {
 
 // Read implements io.Reader.
 func (r *R) Read(p []byte) (int, error) {
-	ctx := r.cmd.Context()
+	ctx := r.ctx()
 	if ctx.Err() != nil {
 		return 0, ctx.Err()
 	}
@@ -62,7 +83,7 @@ func (r *R) Read(p []byte) (int, error) {
 
 // Write implements io.Writer.
 func (r *R) Write(p []byte) (int, error) {
-	ctx := r.cmd.Context()
+	ctx := r.ctx()
 	if ctx.Err() != nil {
 		return 0, ctx.Err()
 	}
@@ -81,7 +102,7 @@ func (r *R) Write(p []byte) (int, error) {
 
 // Close implements io.Closer.
 func (r *R) Close() error {
-	ctx := r.cmd.Context()
+	ctx := r.ctx()
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
@@ -1,12 +1,16 @@
 package resourcetype
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	"github.com/jucrouzet/dsak/internal/pkg/resource/http"
@@ -26,10 +30,66 @@ const (
 	rTypeHTTP    = "http"
 )
 
-func Parse(cmd *cobra.Command, s string, logger *zap.Logger) (Handler, error) {
+// dirKey is the context key of the directory file paths are relative to.
+type dirKey struct{}
+
+// WithDir returns a copy of ctx in which relative file paths are relative to dir instead of the
+// working directory.
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+// Dir returns the directory relative file paths are relative to in ctx, "" for the working directory.
+func Dir(ctx context.Context) string {
+	dir, _ := ctx.Value(dirKey{}).(string)
+	return dir
+}
+
+// ContextFunc returns the context of the operations on a resource.
+// It is called on each operation, so that a resource can be opened before its context is final.
+type ContextFunc func() context.Context
+
+// Opener opens the resource at an URL whose scheme is the one the opener is registered for.
+type Opener func(ctx ContextFunc, url string, logger *zap.Logger) (Handler, error)
+
+var (
+	openers = map[string]Opener{
+		rTypeHTTP: openHTTP,
+		"https":   openHTTP,
+	}
+	openersMtx sync.RWMutex
+)
+
+// Register registers the opener of the resources with the given URL scheme, replacing the current one if any.
+func Register(scheme string, opener Opener) {
+	openersMtx.Lock()
+	defer openersMtx.Unlock()
+	openers[strings.ToLower(scheme)] = opener
+}
+
+// Schemes returns the URL schemes having a registered opener.
+func Schemes() []string {
+	openersMtx.RLock()
+	defer openersMtx.RUnlock()
+	list := make([]string, 0, len(openers))
+	for scheme := range openers {
+		list = append(list, scheme)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func openHTTP(ctx ContextFunc, url string, logger *zap.Logger) (Handler, error) {
+	logger.Debug("resource is http(s)")
+	return http.New(ctx, url, logger)
+}
+
+// Parse returns the handler of the resource s: stdin (or -), stdout, stderr, a file path or an URL
+// whose scheme has a registered opener. The stdin resource reads from in, os.Stdin if nil.
+func Parse(ctx ContextFunc, s string, logger *zap.Logger, in io.Reader) (Handler, error) {
 	if strings.EqualFold(s, rTypeStdIn) || strings.EqualFold(s, "-") {
 		logger.Debug("resource is stdin")
-		return standard.NewStdIn()
+		return standard.NewStdIn(in)
 	}
 	if strings.EqualFold(s, rTypeStdOut) {
 		logger.Debug("resource is stdout")
@@ -41,6 +101,9 @@ func Parse(cmd *cobra.Command, s string, logger *zap.Logger) (Handler, error) {
 	}
 	if !strings.Contains(s, "://") {
 		logger.Debug("resource is a file")
+		if dir := Dir(ctx()); dir != "" && !filepath.IsAbs(s) {
+			s = filepath.Join(dir, s)
+		}
 		return standard.NewFile(s, logger)
 	}
 	uri, err := url.Parse(s)
@@ -48,11 +111,11 @@ func Parse(cmd *cobra.Command, s string, logger *zap.Logger) (Handler, error) {
 		logger.With(zap.Error(err)).Warn("failed to parse resource url")
 		return nil, ErrUnknownType
 	}
-	switch uri.Scheme {
-	case rTypeHTTP, "https":
-		logger.Debug("resource is http(s)")
-		return http.New(cmd, s, logger)
-	default:
+	openersMtx.RLock()
+	opener, ok := openers[strings.ToLower(uri.Scheme)]
+	openersMtx.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("%w: unsupported scheme: %s", ErrUnknownType, uri.Scheme)
 	}
+	return opener(ctx, s, logger)
 }
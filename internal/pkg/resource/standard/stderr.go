@@ -18,8 +18,9 @@ func (r *StdErr) Read(_ []byte) (int, error) {
 }
 
 // Close implements io.Closer.
+// The process stderr is left open as commands can be run in-process.
 func (r *StdErr) Close() error {
-	return os.Stderr.Close()
+	return nil
 }
 
 // Write implements io.Writer.
@@ -2,24 +2,32 @@ package standard
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
 // StdIn represents stdin as an io.ReadWriteCloser.
-type StdIn int
+type StdIn struct {
+	in io.Reader
+}
 
-func NewStdIn() (*StdIn, error) {
-	return new(StdIn), nil
+// NewStdIn returns the stdin resource reading from in, os.Stdin if nil.
+func NewStdIn(in io.Reader) (*StdIn, error) {
+	if in == nil {
+		in = os.Stdin
+	}
+	return &StdIn{in: in}, nil
 }
 
 // Read implements io.Reader.
 func (r *StdIn) Read(p []byte) (int, error) {
-	return os.Stdin.Read(p)
+	return r.in.Read(p)
 }
 
 // Close implements io.Closer.
+// The process stdin is left open as commands can be run in-process.
 func (r *StdIn) Close() error {
-	return os.Stdin.Close()
+	return nil
 }
 
 // Write implements io.Writer.
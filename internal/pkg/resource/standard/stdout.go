@@ -18,8 +18,9 @@ func (r *StdOut) Read(_ []byte) (int, error) {
 }
 
 // Close implements io.Closer.
+// The process stdout is left open as commands can be run in-process.
 func (r *StdOut) Close() error {
-	return os.Stdout.Close()
+	return nil
 }
 
 // Write implements io.Writer.
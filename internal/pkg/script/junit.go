@@ -0,0 +1,99 @@
+package script
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the script result as a JUnit XML report.
+func WriteJUnit(w io.Writer, res *Result) error {
+	name := res.Name
+	if name == "" {
+		name = "dsak"
+	}
+	suite := junitTestSuite{
+		Name: name,
+		Time: fmt.Sprintf("%.3f", res.Duration.Seconds()),
+	}
+	for _, sr := range res.Steps {
+		tc := junitTestCase{
+			Name:      sr.Name,
+			ClassName: fmt.Sprintf("%s.%s", name, sr.ID),
+			Time:      fmt.Sprintf("%.3f", sr.Duration.Seconds()),
+			SystemOut: sr.Output,
+		}
+		msg := &junitMessage{
+			Message: strings.Join(sr.Failures, "; "),
+			Content: strings.Join(sr.Failures, "\n"),
+		}
+		switch sr.Status {
+		case StepFailed:
+			tc.Failure = msg
+			suite.Failures++
+		case StepError:
+			tc.Error = msg
+			suite.Errors++
+		case StepSkipped:
+			tc.Skipped = &junitMessage{Message: "skipped"}
+			suite.Skipped++
+		case StepPassed:
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	report := junitTestSuites{
+		Suites:   []junitTestSuite{suite},
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,359 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+)
+
+// Executor runs a command line, writing its output to out.
+type Executor func(ctx context.Context, args []string, out io.Writer) error
+
+// StatusFunc returns the exit status matching a command error.
+type StatusFunc func(err error) int
+
+// StepStatus is the result status of a step.
+type StepStatus string
+
+const (
+	// StepPassed means the step ran and passed all its assertions.
+	StepPassed StepStatus = "passed"
+	// StepFailed means the step ran and failed an assertion.
+	StepFailed StepStatus = "failed"
+	// StepSkipped means the step was not run.
+	StepSkipped StepStatus = "skipped"
+	// StepError means the step could not be run or its output could not be processed.
+	StepError StepStatus = "error"
+)
+
+// StepResult is the result of a step.
+type StepResult struct {
+	Args []string `json:"args,omitempty"`
+	// ContinueOnError is true if the step is allowed to fail.
+	ContinueOnError bool          `json:"continue_on_error,omitempty"`
+	Duration        time.Duration `json:"duration"`
+	ExitStatus      int           `json:"exit_status"`
+	Failures        []string      `json:"failures,omitempty"`
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	Output          string        `json:"output"`
+	Status          StepStatus    `json:"status"`
+}
+
+// Result is the result of a script.
+type Result struct {
+	Duration time.Duration `json:"duration"`
+	Name     string        `json:"name"`
+	Steps    []*StepResult `json:"steps"`
+}
+
+// Failed returns if a step failed or errored, ignoring steps allowed to fail.
+func (r *Result) Failed() bool {
+	for _, s := range r.Steps {
+		if !s.ContinueOnError && (s.Status == StepFailed || s.Status == StepError) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs scripts.
+type Runner struct {
+	exec     Executor
+	reporter func(*StepResult)
+	status   StatusFunc
+	vars     map[string]string
+}
+
+// Option is a function that configures a Runner.
+type Option func(*Runner)
+
+// NewRunner creates a script runner running commands with exec.
+func NewRunner(exec Executor, opts ...Option) *Runner {
+	r := &Runner{
+		exec:     exec,
+		reporter: func(*StepResult) {},
+		status: func(err error) int {
+			if err != nil {
+				return 1
+			}
+			return 0
+		},
+		vars: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithStatus configures the function used to compute the exit status of a step.
+func WithStatus(f StatusFunc) Option {
+	return func(r *Runner) {
+		r.status = f
+	}
+}
+
+// WithVars sets variables, overriding the script ones.
+func WithVars(vars map[string]string) Option {
+	return func(r *Runner) {
+		for k, v := range vars {
+			r.vars[k] = v
+		}
+	}
+}
+
+// WithReporter sets a function called after each step.
+func WithReporter(f func(*StepResult)) Option {
+	return func(r *Runner) {
+		r.reporter = f
+	}
+}
+
+type state struct {
+	failed  bool
+	results []*StepResult
+	vars    map[string]string
+}
+
+// Run runs the script.
+func (r *Runner) Run(ctx context.Context, s *Script) *Result {
+	start := time.Now()
+	st := &state{vars: make(map[string]string)}
+	for k, v := range s.Vars {
+		st.vars[k] = v
+	}
+	for k, v := range r.vars {
+		st.vars[k] = v
+	}
+	res := &Result{Name: s.Name}
+	for i := range s.Steps {
+		sr := r.runStep(ctx, &s.Steps[i], st)
+		st.results = append(st.results, sr)
+		res.Steps = append(res.Steps, sr)
+		r.reporter(sr)
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+func (r *Runner) runStep(ctx context.Context, step *Step, st *state) *StepResult {
+	sr := &StepResult{ID: step.ID, Name: step.Name, Status: StepSkipped, ContinueOnError: step.ContinueOnError}
+	run, err := r.shouldRun(ctx, step, st)
+	if err != nil {
+		return st.fail(step, sr, StepError, err.Error())
+	}
+	if !run {
+		return sr
+	}
+	lookup := func(name string) (string, bool) {
+		if v, ok := st.vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	sr.Args, err = repl.Parse(step.Run, lookup)
+	if err != nil {
+		return st.fail(step, sr, StepError, fmt.Sprintf("invalid command line: %s", err))
+	}
+	if len(sr.Args) > 0 && sr.Args[0] == "dsak" {
+		sr.Args = sr.Args[1:]
+	}
+	out := &strings.Builder{}
+	start := time.Now()
+	err = r.exec(ctx, sr.Args, out)
+	sr.Duration = time.Since(start)
+	sr.Output = repl.StripANSI(out.String())
+	sr.ExitStatus = r.status(err)
+
+	if failures := checkAssertions(ctx, step, sr, err); len(failures) > 0 {
+		return st.fail(step, sr, StepFailed, failures...)
+	}
+	for i := range step.Capture {
+		v, err := step.Capture[i].apply(ctx, sr.Output)
+		if err != nil {
+			return st.fail(step, sr, StepError, fmt.Sprintf("capture of %s failed: %s", step.Capture[i].Var, err))
+		}
+		st.vars[step.Capture[i].Var] = v
+	}
+	sr.Status = StepPassed
+	return sr
+}
+
+func (st *state) fail(step *Step, sr *StepResult, status StepStatus, failures ...string) *StepResult {
+	sr.Status = status
+	sr.Failures = append(sr.Failures, failures...)
+	if !step.ContinueOnError {
+		st.failed = true
+	}
+	return sr
+}
+
+func (r *Runner) shouldRun(ctx context.Context, step *Step, st *state) (bool, error) {
+	switch step.When {
+	case WhenSuccess:
+		if st.failed {
+			return false, nil
+		}
+	case WhenFailure:
+		if !st.failed {
+			return false, nil
+		}
+	case WhenAlways:
+	}
+	if step.ifQuery == nil {
+		return true, nil
+	}
+	v, err := runJQ(ctx, step.ifQuery, st.jqInput())
+	if err != nil {
+		return false, fmt.Errorf("if expression failed: %w", err)
+	}
+	return isTruthy(v), nil
+}
+
+// jqInput returns the state as seen by if expressions.
+func (st *state) jqInput() map[string]any {
+	vars := make(map[string]any, len(st.vars))
+	for k, v := range st.vars {
+		vars[k] = v
+	}
+	steps := make(map[string]any, len(st.results))
+	for _, sr := range st.results {
+		steps[sr.ID] = map[string]any{
+			"exit_status": sr.ExitStatus,
+			"output":      sr.Output,
+			"status":      string(sr.Status),
+		}
+	}
+	return map[string]any{
+		"failed": st.failed,
+		"steps":  steps,
+		"vars":   vars,
+	}
+}
+
+func checkAssertions(ctx context.Context, step *Step, sr *StepResult, runErr error) []string {
+	var failures []string
+	hasStatus := false
+	for i := range step.Assert {
+		a := &step.Assert[i]
+		if a.Status != nil {
+			hasStatus = true
+		}
+		if msg := a.Check(ctx, sr.Output, sr.ExitStatus); msg != "" {
+			failures = append(failures, msg)
+		}
+	}
+	if !hasStatus && runErr != nil {
+		failures = append([]string{fmt.Sprintf("command failed: %s", runErr)}, failures...)
+	}
+	return failures
+}
+
+// Check returns why the assertion does not hold for the output and the exit status of a command,
+// an empty string if it holds.
+func (a *Assertion) Check(ctx context.Context, output string, exitStatus int) string {
+	switch {
+	case a.Status != nil:
+		if exitStatus != *a.Status {
+			return fmt.Sprintf("expected exit status %d, got %d", *a.Status, exitStatus)
+		}
+	case a.Contains != nil:
+		if !strings.Contains(output, *a.Contains) {
+			return fmt.Sprintf("expected output to contain %q", *a.Contains)
+		}
+	case a.NotContains != nil:
+		if strings.Contains(output, *a.NotContains) {
+			return fmt.Sprintf("expected output not to contain %q", *a.NotContains)
+		}
+	case a.Matches != nil:
+		if !a.matches.MatchString(output) {
+			return fmt.Sprintf("expected output to match %q", *a.Matches)
+		}
+	case a.JQ != nil:
+		input, err := parseJSON(output)
+		if err != nil {
+			return err.Error()
+		}
+		v, err := runJQ(ctx, a.jq, input)
+		if err != nil {
+			return fmt.Sprintf("jq assertion %q failed: %s", *a.JQ, err)
+		}
+		if !isTruthy(v) {
+			return fmt.Sprintf("expected jq assertion %q to be true", *a.JQ)
+		}
+	}
+	return ""
+}
+
+func (c *Capture) apply(ctx context.Context, output string) (string, error) {
+	switch {
+	case c.jq != nil:
+		input, err := parseJSON(output)
+		if err != nil {
+			return "", err
+		}
+		v, err := runJQ(ctx, c.jq, input)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case c.regex != nil:
+		m := c.regex.FindStringSubmatch(output)
+		if m == nil {
+			return "", errors.New("regex does not match output")
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	}
+	return strings.TrimRight(output, "\n"), nil
+}
+
+func parseJSON(output string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(output), &v); err != nil {
+		return nil, fmt.Errorf("output is not valid json: %w", err)
+	}
+	return v, nil
+}
+
+// runJQ runs a jq query and returns its first result.
+func runJQ(ctx context.Context, q *gojq.Query, input any) (any, error) {
+	iter := q.RunWithContext(ctx, input)
+	v, ok := iter.Next()
+	if !ok {
+		return nil, nil
+	}
+	if err, ok := v.(error); ok {
+		return nil, err
+	}
+	return v, nil
+}
+
+func isTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
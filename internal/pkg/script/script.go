@@ -0,0 +1,265 @@
+// Package script handles dsak scripts: lists of dsak commands with variables, captures and assertions.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jucrouzet/dsak/internal/pkg/repl"
+)
+
+// Script is a list of dsak command steps.
+type Script struct {
+	Name  string            `yaml:"name"`
+	Vars  map[string]string `yaml:"vars"`
+	Steps []Step            `yaml:"steps"`
+}
+
+// When tells when a step is run, depending on the result of previous steps.
+type When string
+
+const (
+	// WhenSuccess runs the step only if every previous step succeeded, this is the default.
+	WhenSuccess When = "success"
+	// WhenFailure runs the step only if a previous step failed.
+	WhenFailure When = "failure"
+	// WhenAlways always runs the step.
+	WhenAlways When = "always"
+)
+
+// Step is a dsak command to run.
+type Step struct {
+	// ID identifies the step in conditions, defaults to step<index> (starting at 1).
+	ID string `yaml:"id"`
+	// Name is a human readable name for the step, defaults to the command line.
+	Name string `yaml:"name"`
+	// Run is the command line to run, without the dsak prefix. Variables are expanded.
+	Run string `yaml:"run"`
+	// When tells when the step is run depending on previous steps results.
+	When When `yaml:"when"`
+	// If is a jq expression evaluated on the script state, the step is skipped if it is false or null.
+	If string `yaml:"if"`
+	// ContinueOnError makes the script continue as if the step succeeded when it fails.
+	ContinueOnError bool `yaml:"continue_on_error"`
+	// Capture is the list of variables to set from the step output.
+	Capture []Capture `yaml:"capture"`
+	// Assert is the list of assertions the step must pass, default is to expect a 0 exit status.
+	Assert []Assertion `yaml:"assert"`
+
+	ifQuery *gojq.Query
+}
+
+// Capture sets a variable from the step output.
+// Without JQ nor Regex, the whole output is captured.
+type Capture struct {
+	// Var is the name of the variable to set.
+	Var string `yaml:"var"`
+	// JQ is a jq expression applied to the output parsed as JSON.
+	JQ string `yaml:"jq"`
+	// Regex is a regular expression matched against the output, first group (or whole match) is captured.
+	Regex string `yaml:"regex"`
+
+	jq    *gojq.Query
+	regex *regexp.Regexp
+}
+
+// Assertion is a check on the result of a step. Only one of its fields should be set.
+type Assertion struct {
+	// Status is the expected exit status.
+	Status *int `yaml:"status"`
+	// Contains is a string the output must contain.
+	Contains *string `yaml:"contains"`
+	// NotContains is a string the output must not contain.
+	NotContains *string `yaml:"not_contains"`
+	// Matches is a regular expression the output must match.
+	Matches *string `yaml:"matches"`
+	// JQ is a jq expression applied to the output parsed as JSON that must be true.
+	JQ *string `yaml:"jq"`
+
+	jq      *gojq.Query
+	matches *regexp.Regexp
+}
+
+// Load reads and validates a script.
+func Load(r io.Reader) (*Script, error) {
+	s := &Script{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Script) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("script has no steps")
+	}
+	for name := range s.Vars {
+		if !repl.IsVariableName(name) {
+			return fmt.Errorf("invalid variable name: %s", name)
+		}
+	}
+	ids := make(map[string]struct{})
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if _, ok := ids[step.ID]; ok {
+			return fmt.Errorf("duplicate step id: %s", step.ID)
+		}
+		ids[step.ID] = struct{}{}
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("invalid step %s: %w", step.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Step) validate() error {
+	if !repl.IsVariableName(s.ID) {
+		return fmt.Errorf("invalid id: %s", s.ID)
+	}
+	if s.Run == "" {
+		return errors.New("run is empty")
+	}
+	if s.Name == "" {
+		s.Name = s.Run
+	}
+	switch s.When {
+	case "":
+		s.When = WhenSuccess
+	case WhenSuccess, WhenFailure, WhenAlways:
+	default:
+		return fmt.Errorf("invalid when value: %s", s.When)
+	}
+	if s.If != "" {
+		q, err := gojq.Parse(s.If)
+		if err != nil {
+			return fmt.Errorf("invalid if expression: %w", err)
+		}
+		s.ifQuery = q
+	}
+	for i := range s.Capture {
+		if err := s.Capture[i].validate(); err != nil {
+			return fmt.Errorf("invalid capture: %w", err)
+		}
+	}
+	for i := range s.Assert {
+		if err := s.Assert[i].validate(); err != nil {
+			return fmt.Errorf("invalid assertion: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Capture) validate() error {
+	if !repl.IsVariableName(c.Var) {
+		return fmt.Errorf("invalid variable name: %q", c.Var)
+	}
+	if c.JQ != "" && c.Regex != "" {
+		return errors.New("jq and regex cannot be used together")
+	}
+	if c.JQ != "" {
+		q, err := gojq.Parse(c.JQ)
+		if err != nil {
+			return fmt.Errorf("invalid jq expression: %w", err)
+		}
+		c.jq = q
+	}
+	if c.Regex != "" {
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		c.regex = re
+	}
+	return nil
+}
+
+// ParseAssertion parses an assertion written as kind:value, kind being one of the assertion fields,
+// eg: status:0, contains:NOERROR, not_contains:SERVFAIL, matches:^1\.2\., jq:.ok == true.
+func ParseAssertion(s string) (*Assertion, error) {
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid assertion %q, expected kind:value", s)
+	}
+	a := &Assertion{}
+	switch strings.TrimSpace(kind) {
+	case "status":
+		status, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status assertion %q: %w", s, err)
+		}
+		a.Status = &status
+	case "contains":
+		a.Contains = &value
+	case "not_contains":
+		a.NotContains = &value
+	case "matches":
+		a.Matches = &value
+	case "jq":
+		a.JQ = &value
+	default:
+		return nil, fmt.Errorf("invalid assertion kind %q, expected status, contains, not_contains, matches or jq", kind)
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// String returns the assertion written as kind:value.
+func (a *Assertion) String() string {
+	switch {
+	case a.Status != nil:
+		return fmt.Sprintf("status:%d", *a.Status)
+	case a.Contains != nil:
+		return "contains:" + *a.Contains
+	case a.NotContains != nil:
+		return "not_contains:" + *a.NotContains
+	case a.Matches != nil:
+		return "matches:" + *a.Matches
+	case a.JQ != nil:
+		return "jq:" + *a.JQ
+	}
+	return ""
+}
+
+func (a *Assertion) validate() error {
+	count := 0
+	for _, set := range []bool{a.Status != nil, a.Contains != nil, a.NotContains != nil, a.Matches != nil, a.JQ != nil} {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return errors.New("an assertion must have exactly one of status, contains, not_contains, matches or jq")
+	}
+	if a.Matches != nil {
+		re, err := regexp.Compile(*a.Matches)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		a.matches = re
+	}
+	if a.JQ != nil {
+		q, err := gojq.Parse(*a.JQ)
+		if err != nil {
+			return fmt.Errorf("invalid jq expression: %w", err)
+		}
+		a.jq = q
+	}
+	return nil
+}
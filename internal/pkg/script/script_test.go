@@ -0,0 +1,208 @@
+package script //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("valid script", func(t *testing.T) {
+		s, err := Load(strings.NewReader(`
+name: test
+vars:
+  host: example.com
+steps:
+  - run: dns query ${host}
+  - id: second
+    name: second step
+    run: http cert ${host}
+    when: always
+    capture:
+      - var: ip
+        regex: "([0-9.]+)"
+    assert:
+      - status: 0
+      - jq: .ok
+`))
+		require.NoError(t, err)
+		assert.Equal(t, "step1", s.Steps[0].ID)
+		assert.Equal(t, "dns query ${host}", s.Steps[0].Name)
+		assert.Equal(t, WhenSuccess, s.Steps[0].When)
+		assert.Equal(t, "second step", s.Steps[1].Name)
+		assert.Equal(t, WhenAlways, s.Steps[1].When)
+	})
+
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{name: "no steps", script: "name: x", err: "script has no steps"},
+		{name: "unknown field", script: "steps:\n  - run: a\n    foo: bar", err: "field foo not found"},
+		{name: "empty run", script: "steps:\n  - name: a", err: "run is empty"},
+		{name: "duplicate id", script: "steps:\n  - {id: a, run: a}\n  - {id: a, run: b}", err: "duplicate step id: a"},
+		{name: "invalid when", script: "steps:\n  - {run: a, when: never}", err: "invalid when value: never"},
+		{name: "invalid if", script: "steps:\n  - {run: a, if: '.['}", err: "invalid if expression"},
+		{name: "invalid capture", script: "steps:\n  - {run: a, capture: [{var: 'a b'}]}", err: "invalid variable name"},
+		{name: "empty assertion", script: "steps:\n  - {run: a, assert: [{}]}", err: "exactly one of"},
+		{name: "double assertion", script: "steps:\n  - {run: a, assert: [{status: 0, contains: a}]}", err: "exactly one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tt.script))
+			require.Error(t, err)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func fakeExecutor(ctx context.Context, args []string, out io.Writer) error {
+	switch args[0] {
+	case "echo":
+		_, err := fmt.Fprintln(out, strings.Join(args[1:], " "))
+		return err
+	case "fail":
+		return errors.New("failure")
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+func TestRunner_Run(t *testing.T) {
+	s, err := Load(strings.NewReader(`
+name: test
+vars:
+  name: world
+steps:
+  - id: json
+    run: >-
+      echo '{"ok": true, "ip": "203.0.113.7"}'
+    capture:
+      - var: ip
+        jq: .ip
+    assert:
+      - jq: .ok == true
+      - contains: "203.0.113"
+  - id: hello
+    run: echo hello $name from $ip
+    capture:
+      - var: who
+        regex: "hello (\\w+)"
+      - var: all
+    assert:
+      - matches: "^hello"
+      - not_contains: nope
+  - id: skipped
+    run: echo nope
+    if: .vars.who == "world"
+  - id: failing
+    run: fail
+  - id: afterfailure
+    run: echo never
+  - id: onfailure
+    when: failure
+    run: echo $all
+  - id: expectedfailure
+    when: always
+    run: fail
+    assert:
+      - status: 1
+`))
+	require.NoError(t, err)
+	var reported []string
+	r := NewRunner(
+		fakeExecutor,
+		WithVars(map[string]string{"name": "override"}),
+		WithReporter(func(sr *StepResult) {
+			reported = append(reported, sr.ID)
+		}),
+	)
+	res := r.Run(context.Background(), s)
+	require.Len(t, res.Steps, 7)
+	assert.Equal(t, []string{"json", "hello", "skipped", "failing", "afterfailure", "onfailure", "expectedfailure"}, reported)
+
+	statuses := make(map[string]StepStatus)
+	for _, sr := range res.Steps {
+		statuses[sr.ID] = sr.Status
+	}
+	assert.Equal(t, map[string]StepStatus{
+		"json":            StepPassed,
+		"hello":           StepPassed,
+		"skipped":         StepSkipped,
+		"failing":         StepFailed,
+		"afterfailure":    StepSkipped,
+		"onfailure":       StepPassed,
+		"expectedfailure": StepPassed,
+	}, statuses)
+	assert.Equal(t, []string{"echo", "hello", "override", "from", "203.0.113.7"}, res.Steps[1].Args)
+	assert.Equal(t, "hello override from 203.0.113.7\n", res.Steps[5].Output)
+	assert.Equal(t, []string{"command failed: failure"}, res.Steps[3].Failures)
+	assert.True(t, res.Failed())
+
+	out := &bytes.Buffer{}
+	require.NoError(t, WriteJUnit(out, res))
+	assert.Contains(t, out.String(), `<testsuites tests="7" failures="1" errors="0" skipped="2"`)
+	assert.Contains(t, out.String(), `<failure message="command failed: failure">`)
+}
+
+func TestRunner_RunAssertionFailures(t *testing.T) {
+	s, err := Load(strings.NewReader(`
+steps:
+  - run: echo not json
+    continue_on_error: true
+    assert:
+      - jq: .ok
+      - contains: missing
+      - status: 2
+  - run: echo next
+`))
+	require.NoError(t, err)
+	res := NewRunner(fakeExecutor).Run(context.Background(), s)
+	assert.Equal(t, StepFailed, res.Steps[0].Status)
+	assert.Len(t, res.Steps[0].Failures, 3)
+	assert.Equal(t, StepPassed, res.Steps[1].Status)
+	assert.False(t, res.Failed())
+}
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		in     string
+		output string
+		status int
+		fails  bool
+	}{
+		{in: "status:0", status: 0},
+		{in: "status: 2", status: 1, fails: true},
+		{in: "contains:NOERROR", output: "status: NOERROR"},
+		{in: "not_contains:SERVFAIL", output: "status: SERVFAIL", fails: true},
+		{in: `matches:^1\.2\.`, output: "1.2.3.4"},
+		{in: "jq:.answer | length > 0", output: `{"answer": [1]}`},
+		{in: "jq:.ok", output: `{"ok": false}`, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			a, err := ParseAssertion(tt.in)
+			require.NoError(t, err)
+			msg := a.Check(context.Background(), tt.output, tt.status)
+			if tt.fails {
+				assert.NotEmpty(t, msg)
+			} else {
+				assert.Empty(t, msg)
+			}
+		})
+	}
+	for _, in := range []string{"nope", "size:3", "status:x", "matches:(", "jq:...."} {
+		_, err := ParseAssertion(in)
+		assert.Error(t, err, in)
+	}
+	a, err := ParseAssertion("jq:.ok == true")
+	require.NoError(t, err)
+	assert.Equal(t, "jq:.ok == true", a.String())
+}
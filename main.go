@@ -5,10 +5,11 @@ import (
 
 	_ "github.com/jucrouzet/dsak/cmd"
 	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
 )
 
 func main() {
 	if err := commander.Run(os.Args[1:]); err != nil {
-		os.Exit(1)
+		os.Exit(dsakerr.ExitCode(err))
 	}
 }
@@ -0,0 +1,477 @@
+// Package dns is the DNS client of dsak.
+package dns
+
+import (
+	"io"
+	"net/netip"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/dns"
+)
+
+// Client is a DNS client.
+type Client = dns.Client
+
+// Option is a function that configures a Client.
+type Option = dns.Option
+
+// Type is a DNS record type.
+type Type = dns.Type
+
+// Result is the result of a query sent to several servers.
+type Result = dns.Result
+
+// ServerResult is the result of a query sent to a server, either a response or an error.
+type ServerResult = dns.ServerResult
+
+// Transport is the protocol used to query a server.
+type Transport = dns.Transport
+
+// TLSInfo holds the details of the TLS connection to an encrypted DNS server.
+type TLSInfo = dns.TLSInfo
+
+// Response is the response of a DNS server to a query.
+type Response = dns.Response
+
+// Flags are the header flags of a DNS message.
+type Flags = dns.Flags
+
+// Question is a question of a DNS message.
+type Question = dns.Question
+
+// Record is a resource record of a DNS message.
+type Record = dns.Record
+
+// TypeInfo describes a record type.
+type TypeInfo = dns.TypeInfo
+
+// Trace is the result of an iterative resolution, from the root servers to the authoritative servers.
+type Trace = dns.Trace
+
+// TraceStep is a zone of the delegation chain of a trace, with the servers queried for it.
+type TraceStep = dns.TraceStep
+
+// TraceServer is a server queried by a trace.
+type TraceServer = dns.TraceServer
+
+// TraceReferral is the delegation of a zone to a child zone.
+type TraceReferral = dns.TraceReferral
+
+// DNSSEC is the result of the DNSSEC validation of a response.
+type DNSSEC = dns.DNSSEC
+
+// DNSSECZone is the validation of the keys of a zone.
+type DNSSECZone = dns.DNSSECZone
+
+// DNSSEC validation statuses.
+const (
+	DNSSECSecure        = dns.DNSSECSecure
+	DNSSECInsecure      = dns.DNSSECInsecure
+	DNSSECBogus         = dns.DNSSECBogus
+	DNSSECIndeterminate = dns.DNSSECIndeterminate
+)
+
+// Reverse is the result of the reverse lookup of addresses.
+type Reverse = dns.Reverse
+
+// ReverseAddress is the reverse lookup of an address, with the forward confirmation of its PTR names.
+type ReverseAddress = dns.ReverseAddress
+
+// Batch is the result of Client.QueryBatch, the queries of several names and record types.
+type Batch = dns.Batch
+
+// BatchQuery is the result of the query of a name and a record type of a Batch.
+type BatchQuery = dns.BatchQuery
+
+// SystemConfig is the configuration of the system resolver, read from resolv.conf.
+type SystemConfig = dns.SystemConfig
+
+// Hosts are the addresses of the names of a hosts file.
+type Hosts = dns.Hosts
+
+// Search is the resolution of a name by Client.Resolve, like the system resolver does.
+type Search = dns.Search
+
+// SearchCandidate is a name queried by Client.Resolve.
+type SearchCandidate = dns.SearchCandidate
+
+// System resolver alias and files.
+const (
+	SystemAlias       = dns.SystemAlias
+	DefaultResolvConf = dns.DefaultResolvConf
+	DefaultHostsFile  = dns.DefaultHostsFile
+)
+
+// AllCommonTypes is the name expanding to CommonTypes in the type lists of ParseTypes.
+const AllCommonTypes = dns.AllCommonTypes
+
+// CommonTypes are the record types queried for AllCommonTypes.
+var CommonTypes = dns.CommonTypes
+
+// WaitOptions are the condition Client.Wait waits for, and how often servers are polled.
+type WaitOptions = dns.WaitOptions
+
+// WaitStatus is the status of the servers polled by Client.Wait.
+type WaitStatus = dns.WaitStatus
+
+// WaitServer is the last response of a server polled by Client.Wait.
+type WaitServer = dns.WaitServer
+
+// StubServer is a DNS server answering from zone files, see NewStubServer.
+type StubServer = dns.StubServer
+
+// StubOption is a function that configures a StubServer.
+type StubOption = dns.StubOption
+
+// QueryLog is a query answered by a StubServer.
+type QueryLog = dns.QueryLog
+
+// Zone is the content of a zone file.
+type Zone = dns.Zone
+
+// ZoneLint is the result of LintZone.
+type ZoneLint = dns.ZoneLint
+
+// ZoneFinding is an issue found by LintZone.
+type ZoneFinding = dns.ZoneFinding
+
+// ZoneDiff is the difference between the records of two versions of a zone, see DiffZones.
+type ZoneDiff = dns.ZoneDiff
+
+// ZoneChange is a record added, removed, or whose TTL changed.
+type ZoneChange = dns.ZoneChange
+
+// EDNS is the OPT record of a response, with its options.
+type EDNS = dns.EDNS
+
+// ExtendedError is an extended DNS error (RFC 8914) of a response.
+type ExtendedError = dns.ExtendedError
+
+// TSIGKey is a key authenticating zone transfers, see ParseTSIGKey.
+type TSIGKey = dns.TSIGKey
+
+// Transfer is the summary of a zone transfer, see Client.AXFR and Client.IXFR.
+type Transfer = dns.Transfer
+
+// TransferComparison compares the transfers of a zone from several servers, see Client.CompareTransfers.
+type TransferComparison = dns.TransferComparison
+
+// TransferServer is the transfer of a zone from a server of a TransferComparison.
+type TransferServer = dns.TransferServer
+
+// BenchOptions are the options of a benchmark, see Client.Bench.
+type BenchOptions = dns.BenchOptions
+
+// Bench is the result of a benchmark of servers, ranked from the best.
+type Bench = dns.Bench
+
+// BenchServer is the result of the benchmark of a server.
+type BenchServer = dns.BenchServer
+
+// BenchLatency are the latency percentiles of a benchmark.
+type BenchLatency = dns.BenchLatency
+
+// DefaultBenchNames are the names queried by benchmarks when none is given.
+var DefaultBenchNames = dns.DefaultBenchNames
+
+// MailOptions are the options of a mail check, see Client.CheckMail.
+type MailOptions = dns.MailOptions
+
+// Mail is the result of the checks of the email authentication records of a domain.
+type Mail = dns.Mail
+
+// MailFinding is an issue found by a mail check.
+type MailFinding = dns.MailFinding
+
+// MailMX is a MX record of a domain, with the addresses of its host.
+type MailMX = dns.MailMX
+
+// Severities of mail findings.
+const (
+	MailSeverityError   = dns.MailSeverityError
+	MailSeverityWarning = dns.MailSeverityWarning
+	MailSeverityInfo    = dns.MailSeverityInfo
+)
+
+// SPF is the SPF policy of a domain, with its evaluation for an address if requested.
+type SPF = dns.SPF
+
+// SPFRecord is the SPF record of a domain.
+type SPFRecord = dns.SPFRecord
+
+// SPFTerm is a mechanism or a modifier of an SPF record.
+type SPFTerm = dns.SPFTerm
+
+// DMARC is the DMARC policy of a domain.
+type DMARC = dns.DMARC
+
+// DKIMKey is the DKIM public key of a selector.
+type DKIMKey = dns.DKIMKey
+
+// MTASTS is the MTA-STS record and policy of a domain.
+type MTASTS = dns.MTASTS
+
+// MTASTSPolicy is an MTA-STS policy.
+type MTASTSPolicy = dns.MTASTSPolicy
+
+// TLSRPT is the SMTP TLS reporting record of a domain.
+type TLSRPT = dns.TLSRPT
+
+// BIMI is the BIMI record of a domain.
+type BIMI = dns.BIMI
+
+// DefaultDKIMSelectors are the DKIM selectors looked up by mail checks when none is given.
+var DefaultDKIMSelectors = dns.DefaultDKIMSelectors
+
+// Duration is a time.Duration rendered as a string in JSON, eg: "12.5ms".
+type Duration = dns.Duration
+
+// NewClient creates a new DNS client, logging to logger if not nil.
+func NewClient(logger *zap.Logger, opts ...Option) *Client {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return dns.NewClient(logger, opts...)
+}
+
+// WithServers sets the servers to query, default is 1.1.1.1 and 8.8.8.8.
+// Servers are addresses queried over UDP, or URLs: udp://, tcp://, tls:// (DNS over TLS),
+// https:// (DNS over HTTPS) or quic:// (DNS over QUIC).
+func WithServers(servers ...string) Option {
+	return dns.WithServers(servers...)
+}
+
+// WithFirstAnswer makes queries stop at the first successful response, NOERROR or NXDOMAIN, instead of
+// waiting for every server.
+func WithFirstAnswer() Option {
+	return dns.WithFirstAnswer()
+}
+
+// WithInsecure disables the verification of the certificates of encrypted DNS servers.
+func WithInsecure() Option {
+	return dns.WithInsecure()
+}
+
+// WithDNSSEC sets the DO and CD bits of queries and validates the responses, against the root trust
+// anchor or the given ones. Anchors are DS or DNSKEY records in the presentation format.
+func WithDNSSEC(anchors ...string) Option {
+	return dns.WithDNSSEC(anchors...)
+}
+
+// WithRootServers sets the servers a trace starts from, default is the root servers.
+func WithRootServers(servers ...string) Option {
+	return dns.WithRootServers(servers...)
+}
+
+// WithDoHMethod sets the HTTP method of DNS over HTTPS queries, GET or POST (the default).
+func WithDoHMethod(method string) Option {
+	return dns.WithDoHMethod(method)
+}
+
+// WithTimeouts sets the connect and read timeouts of queries, 0 meaning they are only bounded by the context.
+func WithTimeouts(connect, read time.Duration) Option {
+	return dns.WithTimeouts(connect, read)
+}
+
+// WithUDPSize sets the EDNS UDP buffer size advertised by queries, adding an OPT record to them.
+func WithUDPSize(size uint16) Option {
+	return dns.WithUDPSize(size)
+}
+
+// WithClientSubnet sets the EDNS Client Subnet option of queries, so that servers answer like to a client
+// of subnet, see ParseClientSubnet.
+func WithClientSubnet(subnet netip.Prefix) Option {
+	return dns.WithClientSubnet(subnet)
+}
+
+// WithNSID requests the name server identifier of the servers.
+func WithNSID() Option {
+	return dns.WithNSID()
+}
+
+// WithCookie sends a random client cookie with queries.
+func WithCookie() Option {
+	return dns.WithCookie()
+}
+
+// WithPadding pads queries to a multiple of 128 bytes.
+func WithPadding() Option {
+	return dns.WithPadding()
+}
+
+// WithoutRecursion clears the RD (recursion desired) flag of queries.
+func WithoutRecursion() Option {
+	return dns.WithoutRecursion()
+}
+
+// WithCheckingDisabled sets the CD (checking disabled) flag of queries.
+func WithCheckingDisabled() Option {
+	return dns.WithCheckingDisabled()
+}
+
+// WithAuthenticatedData sets the AD (authenticated data) flag of queries.
+func WithAuthenticatedData() Option {
+	return dns.WithAuthenticatedData()
+}
+
+// WithTCP queries the servers given as addresses or udp:// URLs over TCP.
+func WithTCP() Option {
+	return dns.WithTCP()
+}
+
+// WithTCPFallback queries again over TCP the UDP servers whose response is truncated.
+func WithTCPFallback() Option {
+	return dns.WithTCPFallback()
+}
+
+// WithSearch makes Client.Resolve and Client.QueryBatch expand names with the search domains, names
+// having less than ndots dots being queried with the search domains first, like the system resolver.
+func WithSearch(search []string, ndots int) Option {
+	return dns.WithSearch(search, ndots)
+}
+
+// WithHosts makes Client.Resolve and Client.QueryBatch answer the A and AAAA queries of the names of
+// hosts from it, without querying the servers.
+func WithHosts(hosts *Hosts) Option {
+	return dns.WithHosts(hosts)
+}
+
+// WithAttempts sets the number of times a server is queried if it does not answer, 1 by default.
+func WithAttempts(attempts int) Option {
+	return dns.WithAttempts(attempts)
+}
+
+// ParseResolvConf returns the system resolver configuration of r, in the resolv.conf format, hostname
+// giving the search domain if r has no search or domain line.
+func ParseResolvConf(r io.Reader, hostname string) (*SystemConfig, error) {
+	return dns.ParseResolvConf(r, hostname)
+}
+
+// LoadSystemConfig returns the system resolver configuration of the resolv.conf file at path, eg:
+// DefaultResolvConf.
+func LoadSystemConfig(path string) (*SystemConfig, error) {
+	return dns.LoadSystemConfig(path)
+}
+
+// ParseHosts returns the addresses of the names of r, in the hosts file format, path being shown as the
+// server answering from it.
+func ParseHosts(r io.Reader, path string) (*Hosts, error) {
+	return dns.ParseHosts(r, path)
+}
+
+// LoadHosts returns the addresses of the names of the hosts file at path, eg: DefaultHostsFile.
+func LoadHosts(path string) (*Hosts, error) {
+	return dns.LoadHosts(path)
+}
+
+// WithSource sets the source address and port of queries, an invalid address or a 0 port letting the
+// system choose them, see ParseSource.
+func WithSource(addr netip.Addr, port uint16) Option {
+	return dns.WithSource(addr, port)
+}
+
+// ParseClientSubnet parses a subnet given as a CIDR range or an address, whose prefix is then /24 for
+// IPv4 and /56 for IPv6.
+func ParseClientSubnet(s string) (netip.Prefix, error) {
+	return dns.ParseClientSubnet(s)
+}
+
+// ParseSource parses a source address and port given as "ip", "ip:port" or ":port".
+func ParseSource(s string) (netip.Addr, uint16, error) {
+	return dns.ParseSource(s)
+}
+
+// WithTSIG authenticates zone transfers with key.
+func WithTSIG(key *TSIGKey) Option {
+	return dns.WithTSIG(key)
+}
+
+// ParseTSIGKey returns the key named name from spec, its secret or its algorithm and secret, eg:
+// ["hmac-sha512", "c2VjcmV0"]. The algorithm defaults to hmac-sha256.
+func ParseTSIGKey(name string, spec []string) (*TSIGKey, error) {
+	return dns.ParseTSIGKey(name, spec)
+}
+
+// ParseType returns the record type named v, eg: "AAAA".
+func ParseType(v string) (Type, error) {
+	return dns.GetType(v)
+}
+
+// ParseTypes returns the record types of values, type names, comma-separated lists of type names or
+// AllCommonTypes, without duplicates.
+func ParseTypes(values ...string) ([]Type, error) {
+	return dns.ParseTypes(values...)
+}
+
+// TypeNames returns the names of the known record types.
+func TypeNames() []string {
+	return dns.GetTypeNames()
+}
+
+// TypeName returns the name of the record type t.
+func TypeName(t Type) string {
+	return dns.GetTypeName(t)
+}
+
+// ParseReverseTargets returns the addresses of targets, IP addresses or CIDR ranges, to give to Client.Reverse.
+func ParseReverseTargets(targets ...string) ([]netip.Addr, error) {
+	return dns.ParseReverseTargets(targets...)
+}
+
+// NewStubServer creates a DNS server answering over UDP and TCP from zone files, to serve deterministic
+// records to tests. Load and Listen must be called before Serve.
+func NewStubServer(logger *zap.Logger, opts ...StubOption) *StubServer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return dns.NewStubServer(logger, opts...)
+}
+
+// WithZoneFiles sets the zone files a StubServer answers from, in the BIND format or in YAML.
+func WithZoneFiles(files ...string) StubOption {
+	return dns.WithZoneFiles(files...)
+}
+
+// WithForwarder makes a StubServer forward the queries for other names to the servers of client.
+func WithForwarder(client *Client) StubOption {
+	return dns.WithForwarder(client)
+}
+
+// WithReloadInterval sets the interval a StubServer checks its zone files for changes at, 0 disables reloading.
+func WithReloadInterval(interval time.Duration) StubOption {
+	return dns.WithReloadInterval(interval)
+}
+
+// WithQueryLog sets a function called after a StubServer answered a query.
+func WithQueryLog(fn func(*QueryLog)) StubOption {
+	return dns.WithQueryLog(fn)
+}
+
+// LoadZoneFile reads a zone file, in the YAML format if its extension is .yaml or .yml, in the BIND format otherwise.
+func LoadZoneFile(path string) (*Zone, error) {
+	return dns.LoadZoneFile(path)
+}
+
+// ParseZone parses a zone in the BIND format, file being used in errors. Without origin, the origin is
+// the one set by $ORIGIN, or the name of the SOA record.
+func ParseZone(r io.Reader, file, origin string) (*Zone, error) {
+	return dns.ParseZone(r, file, origin)
+}
+
+// FormatZone writes zone in the normalized BIND format of dsak dns zone fmt.
+func FormatZone(w io.Writer, zone *Zone) error {
+	return dns.FormatZone(w, zone)
+}
+
+// LintZone parses a zone in the BIND format and checks its records like dsak dns zone lint. With a
+// previous version of the zone, the SOA serial must have been incremented if the records changed.
+func LintZone(r io.Reader, file, origin string, previous *Zone) *ZoneLint {
+	return dns.LintZone(r, file, origin, previous)
+}
+
+// DiffZones returns the records added to, removed from, and whose TTL changed between from and to.
+func DiffZones(from, to *Zone) *ZoneDiff {
+	return dns.DiffZones(from, to)
+}
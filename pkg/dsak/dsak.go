@@ -0,0 +1,106 @@
+// Package dsak runs dsak commands in-process, capturing their output and errors, for Go programs
+// embedding dsak instead of running its binary.
+//
+// The packages under pkg/dsak expose the building blocks of the commands: resource opens and
+// registers resources, dns is the DNS client and httpdebug the HTTP debugging client.
+package dsak
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	// Registers the dsak commands.
+	_ "github.com/jucrouzet/dsak/cmd"
+	"github.com/jucrouzet/dsak/internal/pkg/commander"
+	"github.com/jucrouzet/dsak/internal/pkg/config"
+	"github.com/jucrouzet/dsak/internal/pkg/dsakerr"
+)
+
+// Result is the result of a command line.
+type Result struct {
+	// Stdout is the output of the command, unless it was written to another resource with --output.
+	Stdout string
+	// Stderr holds the error message and the usage of the command if it failed.
+	Stderr string
+	// ExitCode is the exit status the dsak binary would have exited with, see the README for their meaning.
+	ExitCode int
+}
+
+// Runner runs dsak command lines in-process.
+type Runner struct {
+	configFile string
+	in         io.Reader
+	settings   map[string]any
+}
+
+// Option is a function that configures a Runner.
+type Option func(*Runner)
+
+// WithConfigFile sets the configuration file, default is the one of the dsak binary:
+// $DSAK_CONFIGFILE or ~/.dsak.yaml.
+func WithConfigFile(path string) Option {
+	return func(r *Runner) {
+		r.configFile = path
+	}
+}
+
+// WithSetting sets a configuration value, eg: WithSetting("global.format", "json").
+// It takes precedence over the configuration file, the environment and the command line flags.
+func WithSetting(name string, value any) Option {
+	return func(r *Runner) {
+		r.settings[name] = value
+	}
+}
+
+// WithStdin sets the standard input of the commands, default is an empty input.
+func WithStdin(in io.Reader) Option {
+	return func(r *Runner) {
+		r.in = in
+	}
+}
+
+// NewRunner creates a new runner.
+// Command lines it runs are not recorded in the dsak history and their output is not colored.
+func NewRunner(opts ...Option) *Runner {
+	r := &Runner{
+		in:       &bytes.Buffer{},
+		settings: map[string]any{"global.nocolor": true},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run runs the command line given by args, eg: Run(ctx, "dns", "query", "example.com").
+// The result is returned even if the command failed, the error being the one it failed with.
+func (r *Runner) Run(ctx context.Context, args ...string) (*Result, error) {
+	var cfgArgs []string
+	if r.configFile != "" {
+		cfgArgs = []string{"--configfile", r.configFile}
+	}
+	cfg, err := config.New(cfgArgs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	for name, value := range r.settings {
+		cfg.Set(name, value)
+	}
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	runner := commander.NewRunner(
+		commander.WithRunnerConfig(cfg),
+		commander.WithRunnerIn(r.in),
+		commander.WithRunnerOut(out),
+		commander.WithRunnerErr(errOut),
+		commander.WithoutFinishHook(),
+	)
+	err = runner.Run(ctx, args)
+	return &Result{
+		Stdout:   out.String(),
+		Stderr:   errOut.String(),
+		ExitCode: dsakerr.ExitCode(err),
+	}, err
+}
@@ -0,0 +1,106 @@
+package dsak //nolint:testpackage
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_Run(t *testing.T) {
+	r := NewRunner(
+		WithConfigFile(filepath.Join(t.TempDir(), "dsak.yaml")),
+		WithStdin(strings.NewReader("hello")),
+	)
+
+	res, err := r.Run(context.Background(), "base", "hex", "255")
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Stdout: "ff\n"}, res)
+
+	res, err = r.Run(context.Background(), "base64", "encode", "-")
+	require.NoError(t, err)
+	assert.Equal(t, "aGVsbG8=", strings.TrimSpace(res.Stdout))
+
+	res, err = r.Run(context.Background(), "base", "hex")
+	require.Error(t, err)
+	assert.Equal(t, 2, res.ExitCode)
+	assert.Empty(t, res.Stdout)
+	assert.Contains(t, res.Stderr, "Error: accepts 2 arg(s), received 1")
+}
+
+func TestRunner_Run_setting(t *testing.T) {
+	r := NewRunner(
+		WithConfigFile(filepath.Join(t.TempDir(), "dsak.yaml")),
+		WithSetting("global.format", "json"),
+	)
+	res, err := r.Run(context.Background(), "base", "hex", "255")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"input": "255", "decimal": 255, "base": 16, "result": "ff"}`, res.Stdout)
+}
+
+func TestRunner_Run_each(t *testing.T) {
+	r := NewRunner(
+		WithConfigFile(filepath.Join(t.TempDir(), "dsak.yaml")),
+		WithSetting("global.format", "jsonl"),
+		WithStdin(strings.NewReader("255\n# comment\n\nzz\n16\n")),
+	)
+	res, err := r.Run(context.Background(), "each", "-j", "2", "-", "--", "base", "hex", "{}")
+	require.Error(t, err)
+	assert.Equal(t, 5, res.ExitCode)
+	lines := strings.Split(strings.TrimSpace(res.Stdout), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"input":"255","status":"ok"`)
+	assert.Contains(t, lines[0], `"output":{"input":"255","decimal":255,"base":16,"result":"ff"}`)
+	assert.Contains(t, lines[1], `"input":"zz","status":"failed","exit_status":2`)
+	assert.Contains(t, lines[2], `"result":"10"`)
+	assert.Contains(t, res.Stderr, "1 of 3 inputs failed")
+}
+
+func TestRunner_Run_concurrent(t *testing.T) {
+	inputs := make([]string, 0, 32)
+	for i := 0; i < 32; i++ {
+		inputs = append(inputs, strconv.Itoa(i))
+	}
+	r := NewRunner(
+		WithConfigFile(filepath.Join(t.TempDir(), "dsak.yaml")),
+		WithSetting("global.format", "jsonl"),
+		WithStdin(strings.NewReader(strings.Join(inputs, "\n"))),
+	)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := r.Run(context.Background(), "base", "hex", "255")
+			assert.NoError(t, err)
+			assert.Contains(t, res.Stdout, `"result":"ff"`)
+		}()
+	}
+	res, err := r.Run(context.Background(), "each", "-j", "8", "-", "--", "base", "hex", "{}")
+	wg.Wait()
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(res.Stdout), "\n"), len(inputs))
+	assert.NotContains(t, res.Stdout, "\x1b[")
+}
+
+func TestRunner_Run_totalTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	r := NewRunner(WithConfigFile(filepath.Join(t.TempDir(), "dsak.yaml")))
+	res, err := r.Run(
+		context.Background(),
+		"dns", "query", "--servers", pc.LocalAddr().String(), "--timeout", "100ms", "--jsonlogs", "example.com",
+	)
+	require.Error(t, err)
+	assert.Equal(t, 4, res.ExitCode)
+	assert.Contains(t, res.Stderr, `"phase":"total"`)
+}
@@ -0,0 +1,112 @@
+// Package httpdebug is the HTTP debugging client of dsak, the one of dsak http debug.
+//
+// By default the response is written to os.Stdout and the request log to os.Stderr, use WithOut and
+// WithLog to change them, or WithResultHandler to get the response as a Response.
+package httpdebug
+
+import (
+	"io"
+	"time"
+
+	"github.com/jucrouzet/dsak/internal/pkg/httpdsak"
+)
+
+// Client is an HTTP debugging client, running one request.
+type Client = httpdsak.Client
+
+// Option is a function that configures a Client.
+type Option = httpdsak.Option
+
+// Response is the response to a request, as given to the result handler.
+type Response = httpdsak.Response
+
+// NewClient creates a client for a request to uri.
+func NewClient(uri string, opts ...Option) (*Client, error) {
+	return httpdsak.NewClient(uri, opts...)
+}
+
+// WithOut sets the writer the response is written to.
+func WithOut(w io.Writer) Option {
+	return httpdsak.WithOut(w)
+}
+
+// WithLog sets the writer the request log is written to.
+func WithLog(w io.Writer) Option {
+	return httpdsak.WithLog(w)
+}
+
+// WithResultHandler gives the response to f instead of writing it to the output.
+func WithResultHandler(f func(*Response) error) Option {
+	return httpdsak.WithResultHandler(f)
+}
+
+// WithInsecure disables the verification of the server certificate.
+func WithInsecure() Option {
+	return httpdsak.WithInsecure()
+}
+
+// WithMethod sets the request method, default is GET.
+func WithMethod(method string) Option {
+	return httpdsak.WithMethod(method)
+}
+
+// WithAccept sets the request accept header value.
+func WithAccept(v string) Option {
+	return httpdsak.WithAccept(v)
+}
+
+// WithBody sets the request body.
+func WithBody(b io.ReadCloser) Option {
+	return httpdsak.WithBody(b)
+}
+
+// WithContentType sets the request content-type header value, default is application/octet-stream.
+func WithContentType(v string) Option {
+	return httpdsak.WithContentType(v)
+}
+
+// WithHeader adds a header to the request.
+func WithHeader(name, value string) Option {
+	return httpdsak.WithHeader(name, value)
+}
+
+// WithForceHTTP1 forces the use of HTTP/1.1.
+func WithForceHTTP1() Option {
+	return httpdsak.WithForceHTTP1()
+}
+
+// WithForceHTTP2 forces the use of HTTP/2.
+func WithForceHTTP2() Option {
+	return httpdsak.WithForceHTTP2()
+}
+
+// WithJQ sets a jq filter applied to a JSON response.
+func WithJQ(filter string) Option {
+	return httpdsak.WithJQ(filter)
+}
+
+// WithRaw writes the raw response body.
+func WithRaw() Option {
+	return httpdsak.WithRaw()
+}
+
+// WithStyle sets the style used to highlight the response body.
+func WithStyle(style string) Option {
+	return httpdsak.WithStyle(style)
+}
+
+// WithTrace logs a trace of the request execution: DNS, connection, TLS and timings.
+func WithTrace() Option {
+	return httpdsak.WithTrace()
+}
+
+// WithForceType handles the response as if it had the given content-type.
+func WithForceType(t string) Option {
+	return httpdsak.WithForceType(t)
+}
+
+// WithTimeouts sets the connect timeout (dial and TLS handshake) and the read timeout (wait for data
+// once connected) of the request, 0 meaning they are only bounded by the context.
+func WithTimeouts(connect, read time.Duration) Option {
+	return httpdsak.WithTimeouts(connect, read)
+}
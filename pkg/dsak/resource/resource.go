@@ -0,0 +1,67 @@
+// Package resource opens dsak resources: stdin (or -), stdout, stderr, files and URLs.
+//
+// URLs are opened by the opener registered for their scheme, http and https being built-in.
+// Openers registered with Register are also used by the dsak commands run in-process.
+package resource
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/dsak/internal/pkg/resource"
+	"github.com/jucrouzet/dsak/internal/pkg/resource/resourcetype"
+)
+
+// ErrUnknownType is returned when opening an URL whose scheme has no registered opener.
+var ErrUnknownType = resourcetype.ErrUnknownType
+
+// Resource is an opened resource, it reads, writes or closes within the context it was opened with.
+type Resource = resource.R
+
+// Handler is the implementation of a resource type.
+type Handler interface {
+	io.ReadWriteCloser
+	// Size returns the size of the resource, 0 if unknown.
+	Size() int64
+}
+
+// Opener opens the resource at url. The context is the one of the resource operations.
+type Opener func(ctx context.Context, url string, logger *zap.Logger) (Handler, error)
+
+type options struct {
+	logger *zap.Logger
+}
+
+// Option is a function that configures how a resource is opened.
+type Option func(*options)
+
+// WithLogger sets the logger of the resource, default is no logging.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Open opens the resource designated by url, within ctx.
+// The resource is opened lazily: files and URLs are only accessed on the first read or write.
+func Open(ctx context.Context, url string, opts ...Option) (*Resource, error) {
+	o := &options{logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return resource.Open(func() context.Context { return ctx }, url, o.logger)
+}
+
+// Register registers the opener of the resources with the given URL scheme, replacing the current one if any.
+func Register(scheme string, opener Opener) {
+	resourcetype.Register(scheme, func(ctx resourcetype.ContextFunc, url string, logger *zap.Logger) (resourcetype.Handler, error) {
+		return opener(ctx(), url, logger)
+	})
+}
+
+// Schemes returns the URL schemes having a registered opener.
+func Schemes() []string {
+	return resourcetype.Schemes()
+}
@@ -0,0 +1,41 @@
+package resource //nolint:testpackage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stringHandler struct {
+	*strings.Reader
+}
+
+func (h *stringHandler) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func (h *stringHandler) Close() error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	_, err := Open(context.Background(), "test://hello")
+	require.ErrorIs(t, err, ErrUnknownType)
+
+	Register("test", func(_ context.Context, url string, _ *zap.Logger) (Handler, error) {
+		return &stringHandler{strings.NewReader(strings.TrimPrefix(url, "test://"))}, nil
+	})
+	assert.Contains(t, Schemes(), "test")
+	r, err := Open(context.Background(), "test://hello")
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	assert.Equal(t, int64(5), r.Size())
+	require.NoError(t, r.Close())
+}